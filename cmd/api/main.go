@@ -14,12 +14,8 @@ import (
 	_ "github.com/Dubjay18/ecom-api/docs" // This is for swagger
 	"github.com/Dubjay18/ecom-api/internal/config"
 	"github.com/Dubjay18/ecom-api/internal/container"
-	"github.com/Dubjay18/ecom-api/internal/handler"
-	"github.com/Dubjay18/ecom-api/internal/middleware"
-	"github.com/gin-contrib/cors"
+	"github.com/Dubjay18/ecom-api/internal/worker"
 	"github.com/gin-gonic/gin"
-	swaggerFiles "github.com/swaggo/files"
-	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
 // @title           E-commerce API
@@ -56,44 +52,19 @@ func main() {
 	}
 	defer c.Close()
 
-	// Initialize Gin router
-	router := gin.New()
-
-	// Add middleware
-	router.Use(gin.Recovery())
-	router.Use(gin.Logger())
-
-	// CORS configuration
-	router.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}))
-
-	// API routes group
-	api := router.Group("/api/v1")
-
 	loggerInit := config.InitLog()
-	api.Use(middleware.LoggerMiddleware(loggerInit))
-
-	// Initialize handlers
-	handler.NewUserHandler(api, c.UserService, loggerInit, cfg.JWT.SecretKey)
-	handler.NewProductHandler(api, c.ProductService, loggerInit, cfg.JWT.SecretKey, cfg.APIKeys)
-	handler.NewOrderHandler(api, c.OrderService, cfg.JWT.SecretKey)
-
-	// Swagger documentation route
-	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
-
-	// Health check endpoint
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"status": "ok",
-			"time":   time.Now().Format(time.RFC3339),
-		})
-	})
+	router := newRouter(cfg, c, loggerInit)
+
+	// Background jobs
+	scheduler := worker.NewScheduler(loggerInit)
+	scheduler.Add(worker.PurgeArchivedProductsJob(c.ProductService, cfg.Jobs.ProductPurgeInterval, cfg.Jobs.ArchivedProductRetention, loggerInit))
+	scheduler.Add(worker.PublishScheduledProductsJob(c.ProductService, cfg.Jobs.ProductPublishInterval, loggerInit))
+	scheduler.Add(worker.ExpireReservationsJob(c.OrderService, cfg.Jobs.ReservationSweepInterval, loggerInit))
+	scheduler.Add(worker.EvaluateStockAlertsJob(c.StockAlertService, cfg.Jobs.StockAlertInterval, loggerInit))
+	scheduler.Add(worker.AllocateBackordersJob(c.OrderService, cfg.Jobs.BackorderInterval, loggerInit))
+	scheduler.Add(worker.ApplyScheduledPricesJob(c.PriceScheduleService, cfg.Jobs.PriceScheduleInterval, loggerInit))
+	scheduler.Add(worker.PurgeAbandonedCartsJob(c.CartService, cfg.Jobs.CartPurgeInterval, cfg.Jobs.AnonymousCartRetention, loggerInit))
+	scheduler.Add(worker.PurgeIdempotencyKeysJob(c.IdempotencyService, cfg.Jobs.IdempotencyKeyPurgeInterval, loggerInit))
 
 	// Create HTTP server
 	srv := &http.Server{
@@ -113,7 +84,8 @@ func main() {
 		<-sig
 
 		// Shutdown signal with grace period of 30 seconds
-		shutdownCtx, _ := context.WithTimeout(serverCtx, 30*time.Second)
+		shutdownCtx, cancel := context.WithTimeout(serverCtx, 30*time.Second)
+		defer cancel()
 
 		go func() {
 			<-shutdownCtx.Done()
@@ -127,13 +99,25 @@ func main() {
 		if err := srv.Shutdown(shutdownCtx); err != nil {
 			log.Fatal(err)
 		}
+		// Background work still running is stopped a little before the
+		// deadline, so imports have time to record where they stopped. Imports
+		// go first, the stock they change still alerts and allocates
+		// backorders.
+		backgroundCtx, cancelBackground := context.WithTimeout(shutdownCtx, 25*time.Second)
+		defer cancelBackground()
+		c.ProductCSVService.Shutdown(backgroundCtx)
+		c.StockAlertService.Shutdown(backgroundCtx)
+		c.OrderService.Shutdown(backgroundCtx)
 		serverStopCtx()
 	}()
 
+	// Jobs stop with the server context
+	scheduler.Start(serverCtx)
+
 	// Start server
 
 	log.Printf("server is starting on %s\n", cfg.Server.GetServerAddress())
-	fmt.Println(`
+	fmt.Print(`
  /$$  / $$            $$                       
 | $$  | $$          | $$                       
 | $$  | $$  /$$$$$$ | $$$$$$$   /$$$$$$        
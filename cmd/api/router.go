@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Dubjay18/ecom-api/internal/config"
+	"github.com/Dubjay18/ecom-api/internal/container"
+	"github.com/Dubjay18/ecom-api/internal/handler"
+	"github.com/Dubjay18/ecom-api/internal/middleware"
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+)
+
+// newRouter returns the router serving the API of the container's services
+func newRouter(cfg *config.Config, c *container.Container, loggerInit *logrus.Logger) *gin.Engine {
+	// Initialize Gin router
+	router := gin.New()
+
+	// Add middleware
+	router.Use(gin.Recovery())
+	router.Use(gin.Logger())
+
+	// CORS configuration
+	router.Use(cors.New(cors.Config{
+		AllowOrigins:     []string{"*"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Currency", "X-Cart-Token", "Idempotency-Key"},
+		ExposeHeaders:    []string{"Content-Length", "X-Cart-Token", "Idempotent-Replayed"},
+		AllowCredentials: true,
+		MaxAge:           12 * time.Hour,
+	}))
+
+	// API routes group, handlers add authentication to groups of their own
+	// so it is never added to the shared group
+	api := router.Group("/api/v1")
+	api.Use(middleware.LoggerMiddleware(loggerInit))
+
+	// Initialize handlers
+	handler.NewUserHandler(api, c.UserService, c.CartService, loggerInit, cfg.JWT.SecretKey)
+	// Carts are open to anonymous shoppers
+	handler.NewCartHandler(api, c.CartService, c.IdempotencyService, cfg.JWT.SecretKey)
+	handler.NewProductHandler(api, c.ProductService, c.ProductCSVService, c.PricingService, loggerInit, cfg.JWT.SecretKey, cfg.APIKeys, cfg.Imports)
+	handler.NewOrderHandler(api, c.OrderService, c.PaymentService, c.IdempotencyService, cfg.JWT.SecretKey)
+	handler.NewPaymentHandler(api, c.PaymentService, c.IdempotencyService, cfg.JWT.SecretKey)
+	handler.NewReturnHandler(api, c.ReturnService, c.IdempotencyService, cfg.JWT.SecretKey)
+	handler.NewShipmentHandler(api, c.ShipmentService, cfg.JWT.SecretKey)
+	handler.NewPromotionHandler(api, c.PromotionService, cfg.JWT.SecretKey)
+	// Webhooks are signed by the payment provider instead of authenticated
+	handler.NewPaymentWebhookHandler(api, c.PaymentService)
+	handler.NewReviewHandler(api, c.ReviewService, cfg.JWT.SecretKey)
+	handler.NewInventoryHandler(api, c.InventoryService, c.StockAlertService, cfg.JWT.SecretKey)
+	handler.NewWarehouseHandler(api, c.WarehouseService, cfg.JWT.SecretKey)
+	handler.NewPricingHandler(api, c.PricingService, cfg.JWT.SecretKey)
+	handler.NewPriceScheduleHandler(api, c.PriceScheduleService, cfg.JWT.SecretKey)
+
+	// Swagger documentation route
+	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+
+	// Health check endpoint
+	router.GET("/health", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"status": "ok",
+			"time":   time.Now().Format(time.RFC3339),
+		})
+	})
+
+	return router
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Dubjay18/ecom-api/internal/config"
+	"github.com/Dubjay18/ecom-api/internal/container"
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/internal/repository"
+	"github.com/Dubjay18/ecom-api/internal/service"
+	"github.com/Dubjay18/ecom-api/pkg/jwt"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const testSecret = "test-secret"
+
+// orderRepo serves orders from memory, the methods the tests do not reach
+// are left to the embedded nil interface
+type orderRepo struct {
+	repository.OrderRepository
+	orders map[uint]*domain.Order
+}
+
+func (r *orderRepo) GetByID(_ context.Context, id uint) (*domain.Order, error) {
+	order, ok := r.orders[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return order, nil
+}
+
+func (r *orderRepo) GetDetail(ctx context.Context, id uint) (*domain.Order, error) {
+	return r.GetByID(ctx, id)
+}
+
+func (r *orderRepo) List(_ context.Context, userID uint) ([]domain.Order, error) {
+	var orders []domain.Order
+	for _, order := range r.orders {
+		if order.UserID == userID {
+			orders = append(orders, *order)
+		}
+	}
+	return orders, nil
+}
+
+func newTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	orders := &orderRepo{orders: map[uint]*domain.Order{
+		1: {Base: domain.Base{ID: 1}, UserID: 10, Status: domain.StatusPending},
+		2: {Base: domain.Base{ID: 2}, UserID: 20, Status: domain.StatusPending},
+	}}
+	cfg := &config.Config{JWT: config.JWTConfig{SecretKey: testSecret}}
+	c := &container.Container{
+		Config:       cfg,
+		OrderService: service.NewOrderService(nil, orders, nil, nil, nil, nil, nil),
+	}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return newRouter(cfg, c, logger)
+}
+
+func token(t *testing.T, userID uint, role domain.UserRole) string {
+	t.Helper()
+	user := &domain.User{Email: "user@example.com", Role: role}
+	user.ID = userID
+	signed, err := jwt.NewJWTService(testSecret, time.Hour).GenerateToken(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func serve(router *gin.Engine, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCustomerReachesOwnOrders(t *testing.T) {
+	router := newTestRouter(t)
+	customer := token(t, 10, domain.RoleUser)
+
+	rec := serve(router, http.MethodGet, "/api/v1/orders", customer)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /orders = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		Data []domain.Order `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Data) != 1 || body.Data[0].ID != 1 {
+		t.Fatalf("GET /orders returned %+v, want only order 1", body.Data)
+	}
+
+	if rec := serve(router, http.MethodGet, "/api/v1/orders/1", customer); rec.Code != http.StatusOK {
+		t.Fatalf("GET /orders/1 = %d, want 200: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestCustomerCannotReadOtherUsersOrder(t *testing.T) {
+	router := newTestRouter(t)
+
+	// Other users' orders look the same as missing ones
+	rec := serve(router, http.MethodGet, "/api/v1/orders/2", token(t, 10, domain.RoleUser))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("GET /orders/2 = %d, want 404: %s", rec.Code, rec.Body)
+	}
+
+	rec = serve(router, http.MethodGet, "/api/v1/orders/2", token(t, 1, domain.RoleAdmin))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("admin GET /orders/2 = %d, want 200: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestAdminRoutesRefuseCustomers(t *testing.T) {
+	router := newTestRouter(t)
+	customer := token(t, 10, domain.RoleUser)
+
+	routes := []struct{ method, path string }{
+		{http.MethodPost, "/api/v1/products"},
+		{http.MethodDelete, "/api/v1/products/1"},
+		{http.MethodGet, "/api/v1/products/archived"},
+		{http.MethodPut, "/api/v1/orders/1/status"},
+		{http.MethodPost, "/api/v1/orders/1/notes"},
+		{http.MethodPost, "/api/v1/payments/1/refunds"},
+		{http.MethodGet, "/api/v1/payments/webhook-events"},
+		{http.MethodGet, "/api/v1/returns"},
+		{http.MethodPost, "/api/v1/returns/1/approve"},
+		{http.MethodPost, "/api/v1/orders/1/shipments"},
+		{http.MethodPost, "/api/v1/shipments/1/deliver"},
+		{http.MethodGet, "/api/v1/promotions"},
+		{http.MethodGet, "/api/v1/warehouses"},
+		{http.MethodPut, "/api/v1/exchange-rates/EUR"},
+	}
+	for _, route := range routes {
+		if rec := serve(router, route.method, route.path, customer); rec.Code != http.StatusForbidden {
+			t.Errorf("%s %s = %d, want 403", route.method, route.path, rec.Code)
+		}
+		if rec := serve(router, route.method, route.path, ""); rec.Code != http.StatusUnauthorized {
+			t.Errorf("anonymous %s %s = %d, want 401", route.method, route.path, rec.Code)
+		}
+	}
+}
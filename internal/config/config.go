@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"log"
+	"strings"
 
 	"time"
 
@@ -17,7 +18,13 @@ type Config struct {
 	DB     DBConfig
 	JWT    JWTConfig
 	// Redis   RedisConfig // For rate limiting and caching if needed
-	APIKeys APIKeysConfig
+	APIKeys     APIKeysConfig
+	Jobs        JobsConfig
+	Mail        MailConfig
+	StockAlerts StockAlertsConfig
+	Pricing     PricingConfig
+	Payments    PaymentsConfig
+	Imports     ImportsConfig
 }
 
 type ServerConfig struct {
@@ -64,6 +71,22 @@ type BaseConfig struct {
 	MAIL_PASSWORD string `mapstructure:"MAIL_PASSWORD"`
 	MAIL_USERNAME string `mapstructure:"MAIL_USERNAME"`
 	MAIL_PORT     string `mapstructure:"MAIL_PORT"`
+	MAIL_FROM     string `mapstructure:"MAIL_FROM"`
+
+	STOCK_ALERT_EMAILS         string `mapstructure:"STOCK_ALERT_EMAILS"`
+	STOCK_ALERT_WEBHOOK_URL    string `mapstructure:"STOCK_ALERT_WEBHOOK_URL"`
+	STOCK_ALERT_WEBHOOK_SECRET string `mapstructure:"STOCK_ALERT_WEBHOOK_SECRET"`
+
+	BASE_CURRENCY string `mapstructure:"BASE_CURRENCY"`
+	SHIPPING_RATE string `mapstructure:"SHIPPING_RATE"`
+
+	STRIPE_KEY             string `mapstructure:"STRIPE_KEY"`
+	PAYMENT_PROVIDER       string `mapstructure:"PAYMENT_PROVIDER"`
+	PAYMENT_WEBHOOK_SECRET string `mapstructure:"PAYMENT_WEBHOOK_SECRET"`
+	PAYMENT_ALLOW_FAKE     bool   `mapstructure:"PAYMENT_ALLOW_FAKE"`
+
+	IMPORT_MAX_FILE_SIZE int64 `mapstructure:"IMPORT_MAX_FILE_SIZE"`
+	IMPORT_MAX_ROWS      int   `mapstructure:"IMPORT_MAX_ROWS"`
 
 	REDIS_PORT string `mapstructure:"REDIS_PORT"`
 	REDIS_HOST string `mapstructure:"REDIS_HOST"`
@@ -101,6 +124,74 @@ type APIKeysConfig struct {
 	CloudinaryCloudName string `mapstructure:"CLOUDINARY_CLOUD_NAME"`
 }
 
+// JobsConfig controls the background jobs run by the worker scheduler
+type JobsConfig struct {
+	ProductPurgeInterval     time.Duration
+	ArchivedProductRetention time.Duration
+	ProductPublishInterval   time.Duration
+	// ReservationTTL is how long unpaid orders hold their stock
+	ReservationTTL           time.Duration
+	ReservationSweepInterval time.Duration
+	StockAlertInterval       time.Duration
+	// BackorderInterval is how often waiting backorders are re-checked
+	// against available stock
+	BackorderInterval time.Duration
+	// PriceScheduleInterval is how often scheduled prices are started and
+	// ended
+	PriceScheduleInterval time.Duration
+	// AnonymousCartRetention is how long an unused anonymous cart is kept
+	AnonymousCartRetention time.Duration
+	CartPurgeInterval      time.Duration
+	// IdempotencyKeyTTL is how long the response to a request made with an
+	// Idempotency-Key is replayed to retries
+	IdempotencyKeyTTL           time.Duration
+	IdempotencyKeyPurgeInterval time.Duration
+}
+
+type MailConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// StockAlertsConfig says who is told when products run low on stock
+type StockAlertsConfig struct {
+	Recipients    []string
+	WebhookURL    string
+	WebhookSecret string
+}
+
+// PricingConfig sets the currency product prices are stored in, other
+// currencies are priced from it through exchange rates
+type PricingConfig struct {
+	BaseCurrency string
+	// ShippingRate is the flat shipping charge of an order in the base
+	// currency, such as "4.99"
+	ShippingRate string
+}
+
+// PaymentsConfig selects the payment provider, "stripe" or "fake". There is
+// no default, the server refuses to start without one.
+type PaymentsConfig struct {
+	Provider string
+	// WebhookSecret verifies webhooks from the provider, such as Stripe's
+	// endpoint signing secret. Webhooks are refused while it is unset.
+	WebhookSecret string
+	// AllowFake lets the fake provider, which takes no real money, be used
+	// in development and tests. It is never allowed in production.
+	AllowFake bool
+}
+
+// ImportsConfig limits the product CSV files that can be imported
+type ImportsConfig struct {
+	// MaxFileSize is the largest file accepted, in bytes
+	MaxFileSize int64
+	// MaxRows is the most rows a file may have, not counting the header
+	MaxRows int
+}
+
 // LoadConfig reads configuration from environment variables or config file
 func LoadConfig(path string) (*Config, error) {
 	v := viper.New()
@@ -151,15 +242,78 @@ func LoadConfig(path string) (*Config, error) {
 			RefreshTokenExpiry: 7 * 24 * time.Hour,
 		},
 		APIKeys: APIKeysConfig{
+			StripeKey:           baseConfig.STRIPE_KEY,
 			CloudinaryKey:       baseConfig.CLOUDINARY_KEY,
 			CloudinarySecret:    baseConfig.CLOUDINARY_SECRET,
 			CloudinaryCloudName: baseConfig.CLOUDINARY_CLOUD_NAME,
 		},
+		Jobs: JobsConfig{
+			ProductPurgeInterval:        24 * time.Hour,
+			ArchivedProductRetention:    90 * 24 * time.Hour,
+			ProductPublishInterval:      time.Minute,
+			ReservationTTL:              30 * time.Minute,
+			ReservationSweepInterval:    time.Minute,
+			StockAlertInterval:          15 * time.Minute,
+			BackorderInterval:           5 * time.Minute,
+			PriceScheduleInterval:       time.Minute,
+			AnonymousCartRetention:      30 * 24 * time.Hour,
+			CartPurgeInterval:           24 * time.Hour,
+			IdempotencyKeyTTL:           24 * time.Hour,
+			IdempotencyKeyPurgeInterval: time.Hour,
+		},
+		Mail: MailConfig{
+			Host:     baseConfig.MAIL_SERVER,
+			Port:     baseConfig.MAIL_PORT,
+			Username: baseConfig.MAIL_USERNAME,
+			Password: baseConfig.MAIL_PASSWORD,
+			From:     baseConfig.MAIL_FROM,
+		},
+		StockAlerts: StockAlertsConfig{
+			Recipients:    splitList(baseConfig.STOCK_ALERT_EMAILS),
+			WebhookURL:    baseConfig.STOCK_ALERT_WEBHOOK_URL,
+			WebhookSecret: baseConfig.STOCK_ALERT_WEBHOOK_SECRET,
+		},
+		Pricing: PricingConfig{
+			BaseCurrency: strings.ToUpper(baseConfig.BASE_CURRENCY),
+			ShippingRate: baseConfig.SHIPPING_RATE,
+		},
+		Payments: PaymentsConfig{
+			Provider:      strings.ToLower(baseConfig.PAYMENT_PROVIDER),
+			WebhookSecret: baseConfig.PAYMENT_WEBHOOK_SECRET,
+			AllowFake:     baseConfig.PAYMENT_ALLOW_FAKE,
+		},
+		Imports: ImportsConfig{
+			MaxFileSize: baseConfig.IMPORT_MAX_FILE_SIZE,
+			MaxRows:     baseConfig.IMPORT_MAX_ROWS,
+		},
+	}
+	if config.Pricing.BaseCurrency == "" {
+		config.Pricing.BaseCurrency = "USD"
+	}
+	if config.Pricing.ShippingRate == "" {
+		config.Pricing.ShippingRate = "0"
+	}
+	if config.Imports.MaxFileSize <= 0 {
+		config.Imports.MaxFileSize = 10 << 20
+	}
+	if config.Imports.MaxRows <= 0 {
+		config.Imports.MaxRows = 10000
 	}
 
 	return config, nil
 }
 
+// splitList splits a comma separated value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // GetDSN returns database connection string
 func (c *DBConfig) GetDSN() string {
 	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
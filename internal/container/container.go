@@ -1,11 +1,17 @@
 package container
 
 import (
+	"fmt"
+
 	"github.com/Dubjay18/ecom-api/internal/config"
 	"github.com/Dubjay18/ecom-api/internal/infrastructure/database"
 	"github.com/Dubjay18/ecom-api/internal/repository"
 	"github.com/Dubjay18/ecom-api/internal/service"
 	"github.com/Dubjay18/ecom-api/pkg/jwt"
+	"github.com/Dubjay18/ecom-api/pkg/mailer"
+	"github.com/Dubjay18/ecom-api/pkg/money"
+	"github.com/Dubjay18/ecom-api/pkg/payment"
+	"github.com/Dubjay18/ecom-api/pkg/webhook"
 )
 
 type Container struct {
@@ -13,17 +19,60 @@ type Container struct {
 	DB     *database.Database
 
 	// Repositories
-	UserRepository    repository.UserRepository
-	ProductRepository repository.ProductRepository
-	OrderRepository   repository.OrderRepository
+	UserRepository           repository.UserRepository
+	ProductRepository        repository.ProductRepository
+	OrderRepository          repository.OrderRepository
+	ImportJobRepository      repository.ImportJobRepository
+	ReviewRepository         repository.ReviewRepository
+	ReservationRepository    repository.ReservationRepository
+	StockMovementRepository  repository.StockMovementRepository
+	WarehouseRepository      repository.WarehouseRepository
+	PriceRepository          repository.PriceRepository
+	PriceHistoryRepository   repository.PriceHistoryRepository
+	CartRepository           repository.CartRepository
+	OrderEventRepository     repository.OrderEventRepository
+	IdempotencyRepository    repository.IdempotencyRepository
+	PaymentRepository        repository.PaymentRepository
+	PaymentWebhookRepository repository.PaymentWebhookRepository
+	ReturnRepository         repository.ReturnRepository
+	ShipmentRepository       repository.ShipmentRepository
+	PromotionRepository      repository.PromotionRepository
 
 	// Services
-	UserService    service.UserService
-	ProductService *service.ProductService
-	OrderService   *service.OrderService
+	UserService          service.UserService
+	ProductService       *service.ProductService
+	OrderService         *service.OrderService
+	ProductCSVService    *service.ProductCSVService
+	ReviewService        *service.ReviewService
+	InventoryService     *service.InventoryService
+	WarehouseService     *service.WarehouseService
+	StockAlertService    *service.StockAlertService
+	PricingService       *service.PricingService
+	PriceScheduleService *service.PriceScheduleService
+	CartService          *service.CartService
+	IdempotencyService   *service.IdempotencyService
+	PaymentService       *service.PaymentService
+	ReturnService        *service.ReturnService
+	ShipmentService      *service.ShipmentService
+	PromotionService     *service.PromotionService
 }
 
 func NewContainer(cfg *config.Config) (*Container, error) {
+	// Amounts read without a currency are in the base currency
+	if !money.IsSupported(cfg.Pricing.BaseCurrency) {
+		return nil, fmt.Errorf("unsupported base currency %q", cfg.Pricing.BaseCurrency)
+	}
+	money.DefaultCurrency = cfg.Pricing.BaseCurrency
+	shippingRate, err := money.Parse(cfg.Pricing.ShippingRate, cfg.Pricing.BaseCurrency)
+	if err != nil || shippingRate.IsNegative() {
+		return nil, fmt.Errorf("invalid shipping rate %q", cfg.Pricing.ShippingRate)
+	}
+
+	provider, err := newPaymentProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	// Initialize database
 	db, err := database.NewPostgresDB(&cfg.DB)
 	if err != nil {
@@ -33,31 +82,123 @@ func NewContainer(cfg *config.Config) (*Container, error) {
 	// jwt service
 	jwtService := jwt.NewJWTService(cfg.JWT.SecretKey, cfg.JWT.AccessTokenExpiry)
 	// Initialize repositories
+	txManager := repository.NewTxManager(db.DB)
 	userRepo := repository.NewUserRepository(db.DB)
 	productRepo := repository.NewProductRepository(db.DB)
 	orderRepo := repository.NewOrderRepository(db.DB)
+	importJobRepo := repository.NewImportJobRepository(db.DB)
+	reviewRepo := repository.NewReviewRepository(db.DB)
+	reservationRepo := repository.NewReservationRepository(db.DB)
+	stockMovementRepo := repository.NewStockMovementRepository(db.DB)
+	warehouseRepo := repository.NewWarehouseRepository(db.DB)
+	priceRepo := repository.NewPriceRepository(db.DB)
+	priceHistoryRepo := repository.NewPriceHistoryRepository(db.DB)
+	cartRepo := repository.NewCartRepository(db.DB)
+	orderEventRepo := repository.NewOrderEventRepository(db.DB)
+	idempotencyRepo := repository.NewIdempotencyRepository(db.DB)
+	paymentRepo := repository.NewPaymentRepository(db.DB)
+	paymentWebhookRepo := repository.NewPaymentWebhookRepository(db.DB)
+	returnRepo := repository.NewReturnRepository(db.DB)
+	shipmentRepo := repository.NewShipmentRepository(db.DB)
+	promotionRepo := repository.NewPromotionRepository(db.DB)
 
 	// Initialize services
 	userService := service.NewUserService(userRepo, jwtService)
-	productService := service.NewProductService(productRepo)
-	orderService := service.NewOrderService(orderRepo, productRepo)
+	inventoryService := service.NewInventoryService(txManager, productRepo, reservationRepo, stockMovementRepo, warehouseRepo, cfg.Jobs.ReservationTTL)
+	productService := service.NewProductService(txManager, productRepo, inventoryService, priceHistoryRepo)
+	pricingService := service.NewPricingService(priceRepo, productRepo, userRepo, cfg.Pricing.BaseCurrency, shippingRate)
+	promotionService := service.NewPromotionService(promotionRepo)
+	orderService := service.NewOrderService(txManager, orderRepo, productRepo, inventoryService, pricingService, orderEventRepo, promotionService)
+	productCSVService := service.NewProductCSVService(txManager, productService, productRepo, importJobRepo, cfg.Imports.MaxRows)
+	reviewService := service.NewReviewService(txManager, reviewRepo, orderRepo, productRepo)
+	warehouseService := service.NewWarehouseService(txManager, warehouseRepo)
+	priceScheduleService := service.NewPriceScheduleService(txManager, productRepo, priceHistoryRepo)
+	cartService := service.NewCartService(txManager, cartRepo, productRepo, pricingService, orderService)
+	idempotencyService := service.NewIdempotencyService(idempotencyRepo, cfg.Jobs.IdempotencyKeyTTL)
+	paymentService := service.NewPaymentService(txManager, paymentRepo, orderRepo, orderService, provider, paymentWebhookRepo)
+	returnService := service.NewReturnService(txManager, returnRepo, orderRepo, orderService, paymentService, inventoryService)
+	shipmentService := service.NewShipmentService(txManager, shipmentRepo, orderRepo, orderService)
+	stockAlertService := service.NewStockAlertService(
+		productRepo,
+		mailer.New(mailer.SMTPConfig{
+			Host:     cfg.Mail.Host,
+			Port:     cfg.Mail.Port,
+			Username: cfg.Mail.Username,
+			Password: cfg.Mail.Password,
+			From:     cfg.Mail.From,
+		}),
+		webhook.New(cfg.StockAlerts.WebhookURL, cfg.StockAlerts.WebhookSecret),
+		cfg.StockAlerts.Recipients,
+	)
+	inventoryService.OnStockChange(stockAlertService.StockChanged)
+	inventoryService.OnStockChange(orderService.StockChanged)
 
 	return &Container{
 		Config: cfg,
 		DB:     db,
 
 		// Repositories
-		UserRepository:    userRepo,
-		ProductRepository: productRepo,
-		OrderRepository:   orderRepo,
+		UserRepository:           userRepo,
+		ProductRepository:        productRepo,
+		OrderRepository:          orderRepo,
+		ImportJobRepository:      importJobRepo,
+		ReviewRepository:         reviewRepo,
+		ReservationRepository:    reservationRepo,
+		StockMovementRepository:  stockMovementRepo,
+		WarehouseRepository:      warehouseRepo,
+		PriceRepository:          priceRepo,
+		PriceHistoryRepository:   priceHistoryRepo,
+		CartRepository:           cartRepo,
+		OrderEventRepository:     orderEventRepo,
+		IdempotencyRepository:    idempotencyRepo,
+		PaymentRepository:        paymentRepo,
+		PaymentWebhookRepository: paymentWebhookRepo,
+		ReturnRepository:         returnRepo,
+		ShipmentRepository:       shipmentRepo,
+		PromotionRepository:      promotionRepo,
 
 		// Services
-		UserService:    userService,
-		ProductService: productService,
-		OrderService:   orderService,
+		UserService:          userService,
+		ProductService:       productService,
+		OrderService:         orderService,
+		ProductCSVService:    productCSVService,
+		ReviewService:        reviewService,
+		InventoryService:     inventoryService,
+		WarehouseService:     warehouseService,
+		StockAlertService:    stockAlertService,
+		PricingService:       pricingService,
+		PriceScheduleService: priceScheduleService,
+		CartService:          cartService,
+		IdempotencyService:   idempotencyService,
+		PaymentService:       paymentService,
+		ReturnService:        returnService,
+		ShipmentService:      shipmentService,
+		PromotionService:     promotionService,
 	}, nil
 }
 
+// newPaymentProvider returns the configured payment provider
+func newPaymentProvider(cfg *config.Config) (payment.Provider, error) {
+	switch cfg.Payments.Provider {
+	case "stripe":
+		if cfg.APIKeys.StripeKey == "" {
+			return nil, fmt.Errorf("the stripe payment provider requires STRIPE_KEY")
+		}
+		return payment.NewStripe(cfg.APIKeys.StripeKey, cfg.Payments.WebhookSecret), nil
+	case "fake":
+		if cfg.Server.Mode == "production" {
+			return nil, fmt.Errorf("the fake payment provider cannot be used in production")
+		}
+		if !cfg.Payments.AllowFake {
+			return nil, fmt.Errorf("the fake payment provider takes no real money, set PAYMENT_ALLOW_FAKE=true to use it")
+		}
+		return payment.NewFake(cfg.Payments.WebhookSecret), nil
+	case "":
+		return nil, fmt.Errorf("PAYMENT_PROVIDER must be set to stripe or fake")
+	}
+	return nil, fmt.Errorf("unknown payment provider %q", cfg.Payments.Provider)
+}
+
 func (c *Container) Close() error {
 	sqlDB, err := c.DB.DB.DB()
 	if err != nil {
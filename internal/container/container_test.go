@@ -0,0 +1,46 @@
+package container
+
+import (
+	"testing"
+
+	"github.com/Dubjay18/ecom-api/internal/config"
+)
+
+func TestNewPaymentProvider(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      string
+		provider  string
+		stripeKey string
+		allowFake bool
+		want      string
+	}{
+		{name: "unset", stripeKey: "sk_test"},
+		{name: "unknown", provider: "paypal"},
+		{name: "stripe", provider: "stripe", stripeKey: "sk_test", want: "stripe"},
+		{name: "stripe without key", provider: "stripe"},
+		{name: "fake not allowed", provider: "fake"},
+		{name: "fake allowed", provider: "fake", allowFake: true, want: "fake"},
+		{name: "fake in production", mode: "production", provider: "fake", allowFake: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Server.Mode = tt.mode
+			cfg.Payments.Provider = tt.provider
+			cfg.Payments.AllowFake = tt.allowFake
+			cfg.APIKeys.StripeKey = tt.stripeKey
+
+			provider, err := newPaymentProvider(cfg)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("newPaymentProvider = %s, want an error", provider.Name())
+				}
+				return
+			}
+			if err != nil || provider.Name() != tt.want {
+				t.Fatalf("newPaymentProvider = %v, %v, want %s", provider, err, tt.want)
+			}
+		})
+	}
+}
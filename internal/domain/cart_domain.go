@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"github.com/Dubjay18/ecom-api/pkg/money"
+)
+
+// Cart is a server-side shopping cart. A user has at most one, anonymous
+// shoppers are given a cart identified by Token until they log in.
+type Cart struct {
+	Base
+	UserID *uint      `json:"user_id,omitempty" gorm:"uniqueIndex"`
+	Token  *string    `json:"token,omitempty" gorm:"uniqueIndex;size:64"`
+	Items  []CartItem `json:"items" gorm:"foreignKey:CartID"`
+	// Currency, Subtotal, ItemCount and CheckoutReady are worked out from
+	// live prices and stock each time the cart is read
+	Currency      string      `json:"currency" gorm:"-"`
+	Subtotal      money.Money `json:"subtotal" gorm:"-"`
+	ItemCount     int         `json:"item_count" gorm:"-"`
+	CheckoutReady bool        `json:"checkout_ready" gorm:"-"`
+}
+
+type CartItem struct {
+	Base
+	CartID    uint     `json:"-" gorm:"uniqueIndex:idx_cart_items_cart_product;not null"`
+	ProductID uint     `json:"product_id" gorm:"uniqueIndex:idx_cart_items_cart_product;not null"`
+	Quantity  int      `json:"quantity" gorm:"not null"`
+	Product   *Product `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+	// UnitPrice and LineTotal are in the cart's currency
+	UnitPrice money.Money `json:"unit_price" gorm:"-"`
+	LineTotal money.Money `json:"line_total" gorm:"-"`
+	// Issues says why the item cannot be checked out as it is
+	Issues []string `json:"issues,omitempty" gorm:"-"`
+}
+
+// CartOwner identifies a cart by its user, or by its token for anonymous
+// shoppers
+type CartOwner struct {
+	UserID uint
+	Token  string
+}
+
+type AddCartItemRequest struct {
+	ProductID uint `json:"product_id" binding:"required"`
+	Quantity  int  `json:"quantity" binding:"required,gt=0"`
+}
+
+type UpdateCartItemRequest struct {
+	Quantity int `json:"quantity" binding:"required,gt=0"`
+}
+
+type CheckoutRequest struct {
+	ShippingAddr  CreatAddressRequest `json:"shipping_address" binding:"required"`
+	PaymentMethod string              `json:"payment_method" binding:"required"`
+	// Currency overrides the X-Currency header and the user's preference
+	Currency string `json:"currency" binding:"omitempty,len=3"`
+	// CouponCode applies a coupon on top of the automatic promotions
+	CouponCode string `json:"coupon_code"`
+}
@@ -0,0 +1,23 @@
+package domain
+
+import "time"
+
+// IdempotencyKey records a request made with an Idempotency-Key header so
+// retries of it get the first response instead of being run again
+type IdempotencyKey struct {
+	Base
+	UserID uint   `gorm:"uniqueIndex:idx_idempotency_keys_user_key;not null"`
+	Key    string `gorm:"uniqueIndex:idx_idempotency_keys_user_key;size:255;not null"`
+	// Fingerprint is a hash of the method, path and body of the request
+	Fingerprint string `gorm:"type:char(64);not null"`
+	// StatusCode is zero while the first request is still in flight
+	StatusCode   int
+	ContentType  string `gorm:"size:100"`
+	ResponseBody []byte
+	ExpiresAt    time.Time `gorm:"index;not null"`
+}
+
+// IsComplete reports whether the response of the request has been stored
+func (k *IdempotencyKey) IsComplete() bool {
+	return k.StatusCode != 0
+}
@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/Dubjay18/ecom-api/pkg/money"
+)
+
+type ImportJob struct {
+	Base
+	UserID    uint             `json:"user_id" gorm:"index;not null"`
+	Status    ImportStatus     `json:"status" gorm:"type:varchar(20);default:'pending'"`
+	DryRun    bool             `json:"dry_run" gorm:"default:false"`
+	TotalRows int              `json:"total_rows"`
+	Created   int              `json:"created"`
+	Updated   int              `json:"updated"`
+	Failed    int              `json:"failed"`
+	Errors    []ImportRowError `json:"errors" gorm:"type:jsonb;serializer:json"`
+}
+
+type ImportStatus string
+
+const (
+	ImportPending   ImportStatus = "pending"
+	ImportRunning   ImportStatus = "running"
+	ImportCompleted ImportStatus = "completed"
+	ImportFailed    ImportStatus = "failed"
+)
+
+type ImportRowError struct {
+	Row   int    `json:"row"`
+	SKU   string `json:"sku,omitempty"`
+	Error string `json:"error"`
+}
+
+// ProductImportRow is a single parsed line of a product CSV import
+type ProductImportRow struct {
+	SKU         string        `validate:"required,max=50"`
+	Name        string        `validate:"required,max=255"`
+	Description string        `validate:"-"`
+	Price       money.Money   `validate:"-"`
+	Stock       int           `validate:"gte=0"`
+	Category    string        `validate:"max=100"`
+	ImageURL    string        `validate:"omitempty,url,max=255"`
+	Status      ProductStatus `validate:"omitempty,oneof=draft scheduled published discontinued"`
+	PublishAt   *time.Time
+}
+
+// ProductCSVHeader lists the columns used for product CSV import and export
+var ProductCSVHeader = []string{"sku", "name", "description", "price", "currency", "stock", "category", "image_url", "status", "publish_at"}
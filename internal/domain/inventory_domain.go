@@ -0,0 +1,80 @@
+package domain
+
+import "time"
+
+// StockReservation holds stock for an order until it is paid for, released
+// or expires
+type StockReservation struct {
+	Base
+	OrderID     uint              `json:"order_id" gorm:"index;not null"`
+	ProductID   uint              `json:"product_id" gorm:"index;not null"`
+	WarehouseID uint              `json:"warehouse_id" gorm:"index;not null"`
+	Quantity    int               `json:"quantity" gorm:"not null"`
+	Status      ReservationStatus `json:"status" gorm:"type:varchar(20);default:'active';index"`
+	ExpiresAt   time.Time         `json:"expires_at" gorm:"index;not null"`
+}
+
+type ReservationStatus string
+
+const (
+	ReservationActive    ReservationStatus = "active"
+	ReservationCommitted ReservationStatus = "committed"
+	ReservationReleased  ReservationStatus = "released"
+)
+
+// StockMovement is an append-only ledger entry recording why a product's
+// on-hand stock changed. Summing a product's movements gives its stock.
+type StockMovement struct {
+	Base
+	ProductID   uint         `json:"product_id" gorm:"index;not null"`
+	WarehouseID uint         `json:"warehouse_id" gorm:"index;not null"`
+	Quantity    int          `json:"quantity" gorm:"not null"`
+	Type        MovementType `json:"type" gorm:"type:varchar(30);not null;index"`
+	UserID      *uint        `json:"user_id,omitempty" gorm:"index"`
+	Reason      string       `json:"reason" gorm:"size:255"`
+	Reference   string       `json:"reference" gorm:"size:100;index"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+type MovementType string
+
+const (
+	MovementSale         MovementType = "sale"
+	MovementCancellation MovementType = "cancellation_restock"
+	MovementReturn       MovementType = "return"
+	MovementAdjustment   MovementType = "adjustment"
+	MovementImport       MovementType = "import"
+	// MovementTransfer moves stock between warehouses, each transfer writes
+	// one entry for each side so the product total is unchanged
+	MovementTransfer MovementType = "transfer"
+)
+
+// StockChange describes who changed stock and why, it is copied onto the
+// ledger entries written for the change
+type StockChange struct {
+	// WarehouseID is where the stock changed, zero means the default warehouse
+	WarehouseID uint
+	Type        MovementType
+	UserID      *uint
+	Reason      string
+	Reference   string
+}
+
+type StockAdjustmentRequest struct {
+	ProductID uint `json:"product_id" binding:"required"`
+	// WarehouseID defaults to the default warehouse when omitted
+	WarehouseID uint   `json:"warehouse_id"`
+	Quantity    int    `json:"quantity" binding:"required"`
+	Reason      string `json:"reason" binding:"required,max=255"`
+	Reference   string `json:"reference" binding:"max=100"`
+}
+
+// StockReconciliation compares a product's stock with its ledger
+type StockReconciliation struct {
+	ProductID   uint   `json:"product_id"`
+	SKU         string `json:"sku"`
+	Stock       int    `json:"stock"`
+	LedgerStock int    `json:"ledger_stock"`
+	Drift       int    `json:"drift"`
+	HasDrift    bool   `json:"has_drift"`
+}
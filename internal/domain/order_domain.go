@@ -1,24 +1,129 @@
 package domain
 
+import (
+	"time"
+
+	"github.com/Dubjay18/ecom-api/pkg/money"
+	"gorm.io/gorm"
+)
+
 type Order struct {
 	Base
-	UserID            uint        `json:"user_id" gorm:"index;not null"`
-	User              User        `json:"-" gorm:"foreignKey:UserID"`
-	Status            OrderStatus `json:"status" gorm:"type:varchar(20);default:'pending'"`
-	TotalAmount       float64     `json:"total_amount" gorm:"type:decimal(10,2);not null"`
-	Items             []OrderItem `json:"items" gorm:"foreignKey:OrderID"`
-	ShippingAddressID uint        `json:"shipping_address_id" gorm:"not null"`
-	// ShippingAddr   Address       `json:"address,omitempty" gorm:"foreignKey:ShippingAddrID"`
-	PaymentStatus PaymentStatus `json:"payment_status" gorm:"type:varchar(20);default:'pending'"`
+	UserID uint        `json:"user_id" gorm:"index;not null"`
+	User   User        `json:"-" gorm:"foreignKey:UserID"`
+	Status OrderStatus `json:"status" gorm:"type:varchar(20);default:'pending'"`
+	// Subtotal is the sum of the line prices, TotalAmount what is charged
+	// for them and ShippingAmount once DiscountTotal is taken off.
+	// ShippingDiscount is the part of DiscountTotal taken off shipping.
+	Subtotal          money.Money   `json:"subtotal" gorm:"type:decimal(15,3);not null"`
+	ShippingAmount    money.Money   `json:"shipping_amount" gorm:"type:decimal(15,3);not null;default:0"`
+	ShippingDiscount  money.Money   `json:"shipping_discount" gorm:"type:decimal(15,3);not null;default:0"`
+	DiscountTotal     money.Money   `json:"discount_total" gorm:"type:decimal(15,3);not null;default:0"`
+	TotalAmount       money.Money   `json:"total_amount" gorm:"type:decimal(15,3);not null"`
+	Items             []OrderItem   `json:"items" gorm:"foreignKey:OrderID"`
+	ShippingAddressID uint          `json:"shipping_address_id" gorm:"not null"`
+	ShippingAddress   *Address      `json:"shipping_address,omitempty" gorm:"foreignKey:ShippingAddressID"`
+	PaymentStatus     PaymentStatus `json:"payment_status" gorm:"type:varchar(20);default:'pending'"`
+	// PaymentMethod is the payment provider's method the order is paid with
+	PaymentMethod string `json:"payment_method" gorm:"size:100"`
+	// Currency and ExchangeRate snapshot the currency the order was placed
+	// in and the rate from the base currency at the time
+	Currency     string `json:"currency" gorm:"type:char(3);not null"`
+	ExchangeRate string `json:"exchange_rate" gorm:"type:decimal(18,8);not null;default:1"`
+	// Discounts are the promotions applied to the order
+	Discounts []OrderDiscount `json:"discounts,omitempty" gorm:"foreignKey:OrderID"`
+	// FreeShipping is set by a free shipping promotion, which takes all of
+	// ShippingAmount off
+	FreeShipping bool `json:"free_shipping" gorm:"not null;default:false"`
+	// Shipments carry the tracking details of the shipped items
+	Shipments []Shipment `json:"shipments,omitempty" gorm:"foreignKey:OrderID"`
+}
+
+// AfterFind reads the amounts in the order's own currency
+func (o *Order) AfterFind(tx *gorm.DB) (err error) {
+	if o.Currency == "" {
+		return nil
+	}
+	if o.Subtotal, err = o.Subtotal.AsCurrency(o.Currency); err != nil {
+		return err
+	}
+	if o.ShippingAmount, err = o.ShippingAmount.AsCurrency(o.Currency); err != nil {
+		return err
+	}
+	if o.ShippingDiscount, err = o.ShippingDiscount.AsCurrency(o.Currency); err != nil {
+		return err
+	}
+	if o.DiscountTotal, err = o.DiscountTotal.AsCurrency(o.Currency); err != nil {
+		return err
+	}
+	o.TotalAmount, err = o.TotalAmount.AsCurrency(o.Currency)
+	return err
+}
+
+// NetShipping returns the shipping charge less its discount, what was paid
+// for shipping
+func (o *Order) NetShipping() money.Money {
+	return money.New(o.ShippingAmount.Amount-o.ShippingDiscount.Amount, o.Currency)
 }
 
 type OrderItem struct {
 	Base
-	OrderID   uint    `json:"-" gorm:"index;not null"`
-	ProductID uint    `json:"-" gorm:"index;not null"`
-	Product   Product `json:"product,omitempty" gorm:"foreignKey:ProductID"`
-	Quantity  int     `json:"quantity" gorm:"not null"`
-	Price     float64 `json:"price" gorm:"type:decimal(10,2);not null"`
+	OrderID   uint        `json:"-" gorm:"index;not null"`
+	ProductID uint        `json:"-" gorm:"index;not null"`
+	Product   Product     `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+	Quantity  int         `json:"quantity" gorm:"not null"`
+	Price     money.Money `json:"price" gorm:"type:decimal(15,3);not null"`
+	// Discount is the part of Price taken off by promotions
+	Discount money.Money `json:"discount" gorm:"type:decimal(15,3);not null;default:0"`
+	// WarehouseID is the warehouse the item was allocated to
+	WarehouseID uint `json:"warehouse_id" gorm:"index"`
+	// BackorderedQuantity is the part of Quantity still waiting for stock,
+	// it is allocated first come first served as stock is replenished
+	BackorderedQuantity int `json:"backordered_quantity" gorm:"not null;default:0"`
+	// ExpectedAt is when a backordered pre-order item is expected to ship
+	ExpectedAt *time.Time `json:"expected_at,omitempty"`
+	// ShippedQuantity is the part of Quantity handed to a carrier
+	ShippedQuantity int `json:"shipped_quantity" gorm:"not null;default:0"`
+	// Currency is the currency of Price, the same as the order's
+	Currency string `json:"-" gorm:"type:char(3);not null"`
+}
+
+// AfterFind reads the amounts in the item's own currency
+func (i *OrderItem) AfterFind(tx *gorm.DB) (err error) {
+	if i.Currency == "" {
+		return nil
+	}
+	if i.Price, err = i.Price.AsCurrency(i.Currency); err != nil {
+		return err
+	}
+	i.Discount, err = i.Discount.AsCurrency(i.Currency)
+	return err
+}
+
+// NetPrice returns the line price less its discount, what was paid for it
+func (i *OrderItem) NetPrice() money.Money {
+	return money.New(i.Price.Amount-i.Discount.Amount, i.Price.Currency)
+}
+
+// UnshippedQuantity returns the part of the item not shipped yet
+func (i *OrderItem) UnshippedQuantity() int {
+	return i.Quantity - i.ShippedQuantity
+}
+
+// ShippableQuantity returns the part of the item that is in stock and not
+// shipped yet
+func (i *OrderItem) ShippableQuantity() int {
+	return i.InStockQuantity() - i.ShippedQuantity
+}
+
+// IsBackordered reports whether some of the item is waiting for stock
+func (i *OrderItem) IsBackordered() bool {
+	return i.BackorderedQuantity > 0
+}
+
+// InStockQuantity returns the part of the item filled from stock on hand
+func (i *OrderItem) InStockQuantity() int {
+	return i.Quantity - i.BackorderedQuantity
 }
 
 type OrderStatus string
@@ -26,9 +131,11 @@ type OrderStatus string
 const (
 	StatusPending   OrderStatus = "pending"
 	StatusConfirmed OrderStatus = "confirmed"
-	StatusShipped   OrderStatus = "shipped"
-	StatusDelivered OrderStatus = "delivered"
-	StatusCancelled OrderStatus = "cancelled"
+	// StatusPartiallyShipped orders have some of their items shipped
+	StatusPartiallyShipped OrderStatus = "partially_shipped"
+	StatusShipped          OrderStatus = "shipped"
+	StatusDelivered        OrderStatus = "delivered"
+	StatusCancelled        OrderStatus = "cancelled"
 )
 
 type PaymentStatus string
@@ -55,15 +162,19 @@ type CreateOrderRequest struct {
 	Items         []CreateOrderItem   `json:"items" binding:"required"`
 	ShippingAddr  CreatAddressRequest `json:"shipping_address" binding:"required"`
 	PaymentMethod string              `json:"payment_method" binding:"required"`
+	// Currency overrides the X-Currency header and the user's preference
+	Currency string `json:"currency" binding:"omitempty,len=3"`
+	// CouponCode applies a coupon on top of the automatic promotions
+	CouponCode string `json:"coupon_code"`
 }
 
 type CreateOrderItem struct {
 	ProductID uint `json:"product_id" binding:"required"`
-	Quantity  int  `json:"quantity" binding:"required"`
+	Quantity  int  `json:"quantity" binding:"required,min=1"`
 }
 
 type UpdateOrderStatusRequest struct {
-	Status OrderStatus `json:"status"`
+	Status OrderStatus `json:"status" binding:"required"`
 }
 
 type CreatAddressRequest struct {
@@ -0,0 +1,45 @@
+package domain
+
+import "time"
+
+// OrderEvent is one entry in the history of an order
+type OrderEvent struct {
+	Base
+	OrderID    uint           `json:"order_id" gorm:"index;not null"`
+	Type       OrderEventType `json:"type" gorm:"type:varchar(30);not null"`
+	FromStatus string         `json:"from_status,omitempty" gorm:"type:varchar(20)"`
+	ToStatus   string         `json:"to_status,omitempty" gorm:"type:varchar(20)"`
+	Message    string         `json:"message" gorm:"type:text"`
+	// Internal events are only shown to admins
+	Internal  bool      `json:"internal" gorm:"default:false"`
+	ActorType ActorType `json:"actor_type" gorm:"type:varchar(20);not null"`
+	// ActorID is the user who caused the event, it is only shown to admins
+	ActorID    *uint     `json:"actor_id,omitempty"`
+	OccurredAt time.Time `json:"occurred_at" gorm:"not null"`
+}
+
+type OrderEventType string
+
+const (
+	EventOrderPlaced   OrderEventType = "placed"
+	EventStatusChanged OrderEventType = "status_changed"
+	EventPayment       OrderEventType = "payment"
+	EventNote          OrderEventType = "note"
+	EventFulfillment   OrderEventType = "fulfillment"
+	EventReturn        OrderEventType = "return"
+)
+
+// ActorType says who caused an order event
+type ActorType string
+
+const (
+	ActorCustomer ActorType = "customer"
+	ActorAdmin    ActorType = "admin"
+	ActorSystem   ActorType = "system"
+)
+
+type CreateOrderNoteRequest struct {
+	Message string `json:"message" binding:"required"`
+	// Internal notes are hidden from the customer
+	Internal bool `json:"internal"`
+}
@@ -0,0 +1,62 @@
+package domain
+
+// OrderTransitions lists the statuses an order may move to from each
+// status. Delivered and cancelled orders are final. The shipping statuses
+// follow the order's shipments.
+var OrderTransitions = map[OrderStatus][]OrderStatus{
+	StatusPending:          {StatusConfirmed, StatusCancelled},
+	StatusConfirmed:        {StatusPartiallyShipped, StatusShipped, StatusCancelled},
+	StatusPartiallyShipped: {StatusShipped},
+	StatusShipped:          {StatusDelivered},
+	StatusDelivered:        {},
+	StatusCancelled:        {},
+}
+
+// PaymentTransitions lists the payment statuses an order may move to from
+// each payment status. A failed payment can be retried.
+var PaymentTransitions = map[PaymentStatus][]PaymentStatus{
+	PaymentPending:   {PaymentCompleted, PaymentFailed},
+	PaymentFailed:    {PaymentPending, PaymentCompleted},
+	PaymentCompleted: {PaymentRefunded},
+	PaymentRefunded:  {},
+}
+
+// IsPaid reports whether the order's payment has been taken
+func (o *Order) IsPaid() bool {
+	return o.PaymentStatus == PaymentCompleted
+}
+
+// ShippedItems reports whether some and whether all of the order's items
+// have been shipped
+func (o *Order) ShippedItems() (some, all bool) {
+	all = true
+	for i := range o.Items {
+		if o.Items[i].ShippedQuantity > 0 {
+			some = true
+		}
+		if o.Items[i].UnshippedQuantity() > 0 {
+			all = false
+		}
+	}
+	return some, all
+}
+
+// HasBackorders reports whether some of the order is waiting for stock
+func (o *Order) HasBackorders() bool {
+	for i := range o.Items {
+		if o.Items[i].IsBackordered() {
+			return true
+		}
+	}
+	return false
+}
+
+// Item returns the item of the order with ID id, or nil if it has none
+func (o *Order) Item(id uint) *OrderItem {
+	for i := range o.Items {
+		if o.Items[i].ID == id {
+			return &o.Items[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/Dubjay18/ecom-api/pkg/money"
+	"gorm.io/gorm"
+)
+
+// Payment is an attempt to pay for an order through a payment provider. An
+// order can have several, such as a declined card followed by another one,
+// but only one in progress at a time.
+type Payment struct {
+	Base
+	OrderID  uint   `json:"order_id" gorm:"index;not null"`
+	Provider string `json:"provider" gorm:"size:20;not null"`
+	// ProviderRef is the ID of the payment at the provider
+	ProviderRef    string              `json:"provider_ref" gorm:"size:255;index"`
+	Method         string              `json:"method" gorm:"size:100"`
+	Status         PaymentRecordStatus `json:"status" gorm:"type:varchar(20);not null"`
+	Amount         money.Money         `json:"amount" gorm:"type:decimal(15,3);not null"`
+	RefundedAmount money.Money         `json:"refunded_amount" gorm:"type:decimal(15,3);not null;default:0"`
+	Currency       string              `json:"-" gorm:"type:char(3);not null"`
+	FailureReason  string              `json:"failure_reason,omitempty" gorm:"type:text"`
+	// ClientSecret lets the customer's browser complete a payment that
+	// requires action, it is returned when the payment starts but not stored
+	ClientSecret string `json:"client_secret,omitempty" gorm:"-"`
+}
+
+// AfterFind reads the amounts in the payment's own currency
+func (p *Payment) AfterFind(tx *gorm.DB) (err error) {
+	if p.Currency == "" {
+		return nil
+	}
+	if p.Amount, err = p.Amount.AsCurrency(p.Currency); err != nil {
+		return err
+	}
+	p.RefundedAmount, err = p.RefundedAmount.AsCurrency(p.Currency)
+	return err
+}
+
+// IsActive reports whether the payment may still take the customer's money
+func (p *Payment) IsActive() bool {
+	return p.Status == PaymentRecordPending || p.Status == PaymentRecordAuthorized
+}
+
+type PaymentRecordStatus string
+
+const (
+	// PaymentRecordPending waits for the customer or the provider
+	PaymentRecordPending    PaymentRecordStatus = "pending"
+	PaymentRecordAuthorized PaymentRecordStatus = "authorized"
+	PaymentRecordCaptured   PaymentRecordStatus = "captured"
+	PaymentRecordFailed     PaymentRecordStatus = "failed"
+	PaymentRecordVoided     PaymentRecordStatus = "voided"
+	// PaymentRecordRefunded has had all of its captured amount refunded,
+	// partly refunded payments stay captured
+	PaymentRecordRefunded PaymentRecordStatus = "refunded"
+)
+
+// PaymentRefund is money returned on a captured payment. It is recorded
+// pending before the provider is asked for it, so a refund interrupted
+// half way can be resumed instead of being made twice.
+type PaymentRefund struct {
+	Base
+	PaymentID uint `json:"payment_id" gorm:"index;not null"`
+	// Reference identifies what the refund is for, such as a return. A
+	// refund asked for again with the same reference resumes this one.
+	Reference string       `json:"reference,omitempty" gorm:"size:100"`
+	Amount    money.Money  `json:"amount" gorm:"type:decimal(15,3);not null"`
+	Currency  string       `json:"-" gorm:"type:char(3);not null"`
+	Status    RefundStatus `json:"status" gorm:"type:varchar(20);not null"`
+	// ProviderRef is the ID of the refund at the provider
+	ProviderRef   string `json:"provider_ref,omitempty" gorm:"size:255"`
+	FailureReason string `json:"failure_reason,omitempty" gorm:"type:text"`
+}
+
+// AfterFind reads the amount in the refund's own currency
+func (r *PaymentRefund) AfterFind(tx *gorm.DB) (err error) {
+	if r.Currency == "" {
+		return nil
+	}
+	r.Amount, err = r.Amount.AsCurrency(r.Currency)
+	return err
+}
+
+type RefundStatus string
+
+const (
+	// RefundPending has been recorded but not confirmed by the provider
+	RefundPending   RefundStatus = "pending"
+	RefundSucceeded RefundStatus = "succeeded"
+	RefundFailed    RefundStatus = "failed"
+)
+
+type StartPaymentRequest struct {
+	// PaymentMethod overrides the payment method the order was placed with
+	PaymentMethod string `json:"payment_method"`
+}
+
+type RefundPaymentRequest struct {
+	// Amount defaults to the whole amount not refunded yet
+	Amount *money.Money `json:"amount"`
+}
+
+// PaymentWebhookEvent is a webhook event received from a payment provider,
+// stored as received so it can be replayed
+type PaymentWebhookEvent struct {
+	Base
+	Provider string `json:"provider" gorm:"size:20;uniqueIndex:idx_payment_webhook_events_provider_event;not null"`
+	// EventID is the provider's ID of the event, redeliveries share it
+	EventID     string             `json:"event_id" gorm:"size:255;uniqueIndex:idx_payment_webhook_events_provider_event;not null"`
+	Type        string             `json:"type" gorm:"size:100"`
+	Payload     json.RawMessage    `json:"payload" gorm:"not null"`
+	Status      WebhookEventStatus `json:"status" gorm:"type:varchar(20);index;not null"`
+	Error       string             `json:"error,omitempty" gorm:"type:text"`
+	ReceivedAt  time.Time          `json:"received_at" gorm:"not null"`
+	ProcessedAt *time.Time         `json:"processed_at,omitempty"`
+}
+
+type WebhookEventStatus string
+
+const (
+	WebhookReceived  WebhookEventStatus = "received"
+	WebhookProcessed WebhookEventStatus = "processed"
+	// WebhookIgnored events were stored but need no action
+	WebhookIgnored WebhookEventStatus = "ignored"
+	WebhookFailed  WebhookEventStatus = "failed"
+)
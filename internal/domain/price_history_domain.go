@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/Dubjay18/ecom-api/pkg/money"
+)
+
+// PriceChange records one change of a product's base price
+type PriceChange struct {
+	Base
+	ProductID uint `json:"product_id" gorm:"index:idx_price_changes_product_changed_at;not null"`
+	// OldPrice is empty for the price a product was created with
+	OldPrice       *money.Money      `json:"old_price,omitempty" gorm:"type:decimal(15,3)"`
+	NewPrice       money.Money       `json:"new_price" gorm:"type:decimal(15,3);not null"`
+	CompareAtPrice *money.Money      `json:"compare_at_price,omitempty" gorm:"type:decimal(15,3)"`
+	Source         PriceChangeSource `json:"source" gorm:"type:varchar(20);not null"`
+	// ScheduleID is the scheduled price that made the change, if any
+	ScheduleID *uint     `json:"schedule_id,omitempty"`
+	ChangedBy  *uint     `json:"changed_by,omitempty"`
+	ChangedAt  time.Time `json:"changed_at" gorm:"index:idx_price_changes_product_changed_at;not null"`
+}
+
+type PriceChangeSource string
+
+const (
+	PriceSourceManual        PriceChangeSource = "manual"
+	PriceSourceImport        PriceChangeSource = "import"
+	PriceSourceScheduleStart PriceChangeSource = "schedule_start"
+	PriceSourceScheduleEnd   PriceChangeSource = "schedule_end"
+)
+
+// ScheduledPrice is a future price change. With EndsAt it is a sale, the
+// price it replaced is shown as the compare-at price and restored when the
+// sale ends. Without EndsAt the new price is permanent.
+type ScheduledPrice struct {
+	Base
+	ProductID uint           `json:"product_id" gorm:"index;not null"`
+	Price     money.Money    `json:"price" gorm:"type:decimal(15,3);not null"`
+	StartsAt  time.Time      `json:"starts_at" gorm:"not null"`
+	EndsAt    *time.Time     `json:"ends_at,omitempty"`
+	Status    ScheduleStatus `json:"status" gorm:"type:varchar(20);not null;default:'pending';index"`
+	// OriginalPrice is the price when the sale started, restored at its end
+	OriginalPrice *money.Money `json:"original_price,omitempty" gorm:"type:decimal(15,3)"`
+	CreatedBy     *uint        `json:"created_by,omitempty"`
+}
+
+// IsSale reports whether the schedule reverts once it ends
+func (s *ScheduledPrice) IsSale() bool {
+	return s.EndsAt != nil
+}
+
+type ScheduleStatus string
+
+const (
+	SchedulePending   ScheduleStatus = "pending"
+	ScheduleActive    ScheduleStatus = "active"
+	ScheduleCompleted ScheduleStatus = "completed"
+	ScheduleCancelled ScheduleStatus = "cancelled"
+)
+
+type CreateScheduledPriceRequest struct {
+	Price    money.Money `json:"price" binding:"required"`
+	StartsAt time.Time   `json:"starts_at" binding:"required"`
+	EndsAt   *time.Time  `json:"ends_at"`
+}
@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"github.com/Dubjay18/ecom-api/pkg/money"
+	"gorm.io/gorm"
+)
+
+// ProductPrice is an explicit price of a product in a currency other than
+// the base one, it takes precedence over converting the base price
+type ProductPrice struct {
+	Base
+	ProductID uint        `json:"product_id" gorm:"uniqueIndex:idx_product_prices_product_currency;not null"`
+	Currency  string      `json:"currency" gorm:"uniqueIndex:idx_product_prices_product_currency;type:char(3);not null"`
+	Amount    money.Money `json:"amount" gorm:"type:decimal(15,3);not null"`
+}
+
+// AfterFind reads the amount in the price's own currency
+func (p *ProductPrice) AfterFind(tx *gorm.DB) (err error) {
+	p.Amount, err = p.Amount.AsCurrency(p.Currency)
+	return err
+}
+
+// ExchangeRate converts base currency amounts into Currency, Rate is the
+// units of Currency one unit of the base currency buys
+type ExchangeRate struct {
+	Base
+	Currency  string `json:"currency" gorm:"uniqueIndex;type:char(3);not null"`
+	Rate      string `json:"rate" gorm:"type:decimal(18,8);not null"`
+	UpdatedBy *uint  `json:"updated_by,omitempty"`
+}
+
+type SetProductPriceRequest struct {
+	// Amount is a decimal amount in major units, such as "12500.00"
+	Amount string `json:"amount" binding:"required"`
+}
+
+type SetExchangeRateRequest struct {
+	// Rate is the units of the currency one unit of the base currency buys
+	Rate string `json:"rate" binding:"required"`
+}
+
+type UpdateCurrencyRequest struct {
+	// Currency is an ISO 4217 code, empty resets to the base currency
+	Currency string `json:"currency" binding:"omitempty,len=3"`
+}
@@ -1,39 +1,174 @@
 package domain
 
+import (
+	"time"
+
+	"github.com/Dubjay18/ecom-api/pkg/money"
+	"gorm.io/gorm"
+)
+
 type Product struct {
 	Base
 	Name        string      `json:"name" gorm:"size:255;not null"`
 	Description string      `json:"description" gorm:"type:text"`
-	Price       float64     `json:"price" gorm:"type:decimal(10,2);not null"`
-	SKU         string      `json:"sku" gorm:"uniqueIndex;size:50;not null"`
-	Stock       int         `json:"stock" gorm:"not null"`
-	Category    string      `json:"category" gorm:"type:varchar(100);"`
-	ImageURL    string      `json:"image_url" gorm:"size:255"`
-	OrderItems  []OrderItem `json:"-" gorm:"foreignKey:ProductID"`
+	Price       money.Money `json:"price" gorm:"type:decimal(15,3);not null"`
+	// CompareAtPrice is the regular price while a sale price is active
+	CompareAtPrice *money.Money `json:"compare_at_price,omitempty" gorm:"type:decimal(15,3)"`
+	SKU            string       `json:"sku" gorm:"uniqueIndex;size:50;not null"`
+	Stock          int          `json:"stock" gorm:"not null"`
+	// Reserved is the stock held by active reservations of unpaid orders
+	Reserved     int           `json:"reserved" gorm:"not null;default:0"`
+	Category     string        `json:"category" gorm:"type:varchar(100);"`
+	ImageURL     string        `json:"image_url" gorm:"size:255"`
+	ThumbnailURL string        `json:"thumbnail_url" gorm:"size:255"`
+	MediumURL    string        `json:"medium_url" gorm:"size:255"`
+	LargeURL     string        `json:"large_url" gorm:"size:255"`
+	Status       ProductStatus `json:"status" gorm:"type:varchar(20);default:'draft';index"`
+	PublishAt    *time.Time    `json:"publish_at,omitempty"`
+	// RatingAverage and RatingCount aggregate approved reviews
+	RatingAverage float64 `json:"rating_average" gorm:"type:decimal(3,2);default:0"`
+	RatingCount   int     `json:"rating_count" gorm:"default:0"`
+	// ReorderThreshold triggers a low-stock alert once available stock
+	// falls to it, zero disables alerts for the product
+	ReorderThreshold int `json:"reorder_threshold" gorm:"not null;default:0"`
+	// LowStockAlertedAt is set while the product is below its threshold and
+	// managers have been told, it is cleared once stock recovers
+	LowStockAlertedAt *time.Time `json:"low_stock_alerted_at,omitempty"`
+	// LowStockWebhookAt is the same for the stock alert webhook, which is
+	// delivered apart from the email
+	LowStockWebhookAt *time.Time `json:"low_stock_webhook_at,omitempty"`
+	// BackorderPolicy says whether orders may exceed available stock,
+	// BackorderLimit caps the outstanding backordered units when limited
+	BackorderPolicy BackorderPolicy `json:"backorder_policy" gorm:"type:varchar(20);not null;default:'deny'"`
+	BackorderLimit  int             `json:"backorder_limit" gorm:"not null;default:0"`
+	// Backordered is the units ordered but waiting for stock
+	Backordered int `json:"backordered" gorm:"not null;default:0"`
+	// PreOrder products take orders ahead of their release, every unit is
+	// backordered until stock arrives around AvailableAt
+	PreOrder    bool       `json:"pre_order" gorm:"default:false"`
+	AvailableAt *time.Time `json:"available_at,omitempty"`
+	// DisplayPrice is Price in the currency of the request, when it differs
+	DisplayPrice *money.Money `json:"display_price,omitempty" gorm:"-"`
+	OrderItems   []OrderItem  `json:"-" gorm:"foreignKey:ProductID"`
+	// DeletedAt marks a product as archived, it is hidden from the catalog
+	// but still resolvable from past orders
+	DeletedAt gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index" swaggertype:"string"`
+}
+
+type ProductStatus string
+
+const (
+	ProductDraft        ProductStatus = "draft"
+	ProductScheduled    ProductStatus = "scheduled"
+	ProductPublished    ProductStatus = "published"
+	ProductDiscontinued ProductStatus = "discontinued"
+)
+
+type BackorderPolicy string
+
+const (
+	BackorderDeny      BackorderPolicy = "deny"
+	BackorderLimited   BackorderPolicy = "limited"
+	BackorderUnlimited BackorderPolicy = "unlimited"
+)
+
+// CanBackorder reports whether quantity more units may be backordered
+func (p *Product) CanBackorder(quantity int) bool {
+	switch p.BackorderPolicy {
+	case BackorderUnlimited:
+		return true
+	case BackorderLimited:
+		return p.Backordered+quantity <= p.BackorderLimit
+	default:
+		return false
+	}
+}
+
+// Backorders returns how many of quantity units would be backordered,
+// reporting false if the product cannot take an order that size. Pre-orders
+// are backordered in full until stock arrives.
+func (p *Product) Backorders(quantity int) (int, bool) {
+	available := p.Available()
+	if p.PreOrder || available < 0 {
+		available = 0
+	}
+	if quantity <= available {
+		return 0, true
+	}
+	backordered := quantity - available
+	return backordered, p.CanBackorder(backordered)
+}
+
+// Available returns the on-hand stock that is not held by a reservation
+func (p *Product) Available() int {
+	return p.Stock - p.Reserved
+}
+
+// IsLowStock reports whether available stock is at or below the reorder
+// threshold
+func (p *Product) IsLowStock() bool {
+	return p.ReorderThreshold > 0 && p.Available() <= p.ReorderThreshold
+}
+
+// IsVisible reports whether the product can be listed and ordered by customers
+func (p *Product) IsVisible() bool {
+	return p.Status == ProductPublished
 }
 
 type ProductFilter struct {
 	Name string
 
-	MinPrice float64
+	MinPrice money.Money
+
+	MaxPrice money.Money
+
+	// Statuses limits results to the given lifecycle states, empty means all
+	Statuses []ProductStatus
 
-	MaxPrice float64
+	// Sort is one of the ProductSort values, empty keeps insertion order
+	Sort ProductSort
 }
 
+type ProductSort string
+
+const (
+	SortNewest    ProductSort = "newest"
+	SortPriceAsc  ProductSort = "price_asc"
+	SortPriceDesc ProductSort = "price_desc"
+	SortRating    ProductSort = "rating"
+)
+
 type CreateProductRequest struct {
-	Name        string  `form:"name" binding:"required"`
-	Price       float64 `form:"price" binding:"required,gt=0"`
-	Description string  `json:"description"`
-	Stock       int     `form:"stock" binding:"required,gt=0"`
-	SKU         string  `form:"sku" binding:"required"`
-	Category    string  `form:"category"`
+	Name        string        `form:"name" binding:"required"`
+	Price       money.Money   `form:"price" binding:"required"`
+	Description string        `json:"description"`
+	Stock       int           `form:"stock" binding:"gte=0"`
+	SKU         string        `form:"sku" binding:"required"`
+	Category    string        `form:"category"`
+	Status      ProductStatus `form:"status" binding:"omitempty,oneof=draft scheduled published discontinued"`
+	PublishAt   *time.Time    `form:"publish_at" time_format:"2006-01-02T15:04:05Z07:00"`
+	// ReorderThreshold is the available stock level that triggers a low-stock alert
+	ReorderThreshold int             `form:"reorder_threshold" binding:"gte=0"`
+	BackorderPolicy  BackorderPolicy `form:"backorder_policy" binding:"omitempty,oneof=deny limited unlimited"`
+	BackorderLimit   int             `form:"backorder_limit" binding:"gte=0"`
+	PreOrder         bool            `form:"pre_order"`
+	AvailableAt      *time.Time      `form:"available_at" time_format:"2006-01-02T15:04:05Z07:00"`
 }
 
 type UpdateProductRequest struct {
-	Name        string  `form:"name"`
-	Price       float64 `form:"price" binding:"gt=0"`
-	Description string  `json:"description"`
-	Stock       int     `form:"stock" binding:"gt=0"`
-	SKU         string  `form:"sku"`
-	Category    string  `form:"category"`
+	Name        string      `form:"name"`
+	Price       money.Money `form:"price"`
+	Description string      `json:"description"`
+	// Stock is the new stock level, nil leaves it as it is
+	Stock     *int          `form:"stock" binding:"omitempty,gte=0"`
+	SKU       string        `form:"sku"`
+	Category  string        `form:"category"`
+	Status    ProductStatus `form:"status" binding:"omitempty,oneof=draft scheduled published discontinued"`
+	PublishAt *time.Time    `form:"publish_at" time_format:"2006-01-02T15:04:05Z07:00"`
+	// ReorderThreshold is the available stock level that triggers a low-stock alert
+	ReorderThreshold int             `form:"reorder_threshold" binding:"gte=0"`
+	BackorderPolicy  BackorderPolicy `form:"backorder_policy" binding:"omitempty,oneof=deny limited unlimited"`
+	BackorderLimit   int             `form:"backorder_limit" binding:"gte=0"`
+	PreOrder         bool            `form:"pre_order"`
+	AvailableAt      *time.Time      `form:"available_at" time_format:"2006-01-02T15:04:05Z07:00"`
 }
@@ -0,0 +1,136 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/Dubjay18/ecom-api/pkg/money"
+	"gorm.io/gorm"
+)
+
+// Promotion is a discount on orders. Coupons have a Code customers enter
+// at checkout, promotions without one apply automatically to every order
+// that qualifies. Amounts are in the base currency, orders in another
+// currency convert them at the order's exchange rate.
+type Promotion struct {
+	Base
+	Name string `json:"name" gorm:"size:255;not null"`
+	// Code is stored upper case, coupon codes are not case sensitive
+	Code *string       `json:"code,omitempty" gorm:"uniqueIndex;size:50"`
+	Type PromotionType `json:"type" gorm:"type:varchar(20);not null"`
+	// PercentOff is taken off the eligible lines of percent_off promotions
+	PercentOff int `json:"percent_off,omitempty" gorm:"not null;default:0"`
+	// AmountOff is taken off the eligible lines of fixed_amount promotions,
+	// shared between them in proportion to their prices
+	AmountOff *money.Money `json:"amount_off,omitempty" gorm:"type:decimal(15,3)"`
+	// Buying BuyQuantity units of an eligible product gets GetQuantity more
+	// of it free with buy_x_get_y promotions
+	BuyQuantity int `json:"buy_quantity,omitempty" gorm:"not null;default:0"`
+	GetQuantity int `json:"get_quantity,omitempty" gorm:"not null;default:0"`
+	// MinSubtotal is the order subtotal needed for the promotion to apply
+	MinSubtotal *money.Money `json:"min_subtotal,omitempty" gorm:"type:decimal(15,3)"`
+	// ProductIDs and Categories restrict the discount to lines of these
+	// products or categories, all lines are eligible when both are empty
+	ProductIDs []uint   `json:"product_ids,omitempty" gorm:"type:jsonb;serializer:json"`
+	Categories []string `json:"categories,omitempty" gorm:"type:jsonb;serializer:json"`
+	// UsageLimit caps the orders the promotion applies to overall and
+	// PerUserLimit the orders of each user, zero means unlimited.
+	// UsageCount counts the orders it applies to that were not cancelled.
+	UsageLimit   int `json:"usage_limit" gorm:"not null;default:0"`
+	PerUserLimit int `json:"per_user_limit" gorm:"not null;default:0"`
+	UsageCount   int `json:"usage_count" gorm:"not null;default:0"`
+	// StartsAt and EndsAt bound when the promotion applies, either may be
+	// unset
+	StartsAt *time.Time `json:"starts_at,omitempty"`
+	EndsAt   *time.Time `json:"ends_at,omitempty"`
+	Active   bool       `json:"active" gorm:"not null;default:true"`
+}
+
+type PromotionType string
+
+const (
+	PromotionPercentOff   PromotionType = "percent_off"
+	PromotionFixedAmount  PromotionType = "fixed_amount"
+	PromotionFreeShipping PromotionType = "free_shipping"
+	PromotionBuyXGetY     PromotionType = "buy_x_get_y"
+)
+
+// IsCoupon reports whether the promotion needs its code to apply
+func (p *Promotion) IsCoupon() bool {
+	return p.Code != nil
+}
+
+// IsRunning reports whether the promotion is active and within its validity
+// window at t
+func (p *Promotion) IsRunning(t time.Time) bool {
+	if !p.Active {
+		return false
+	}
+	if p.StartsAt != nil && t.Before(*p.StartsAt) {
+		return false
+	}
+	return p.EndsAt == nil || t.Before(*p.EndsAt)
+}
+
+// IsEligible reports whether a product's lines are discounted by the
+// promotion
+func (p *Promotion) IsEligible(product *Product) bool {
+	if len(p.ProductIDs) == 0 && len(p.Categories) == 0 {
+		return true
+	}
+	for _, id := range p.ProductIDs {
+		if id == product.ID {
+			return true
+		}
+	}
+	for _, category := range p.Categories {
+		if category == product.Category {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderDiscount is a promotion applied to an order. It counts towards the
+// promotion's usage limits until the order is cancelled.
+type OrderDiscount struct {
+	Base
+	OrderID     uint          `json:"-" gorm:"index;not null"`
+	PromotionID uint          `json:"promotion_id" gorm:"index;not null"`
+	Code        string        `json:"code,omitempty" gorm:"size:50"`
+	Name        string        `json:"name" gorm:"size:255;not null"`
+	Type        PromotionType `json:"type" gorm:"type:varchar(20);not null"`
+	// Amount is the total taken off the order's lines
+	Amount   money.Money `json:"amount" gorm:"type:decimal(15,3);not null"`
+	Currency string      `json:"-" gorm:"type:char(3);not null"`
+	// ReleasedAt is set when the order is cancelled and the use of the
+	// promotion given back
+	ReleasedAt *time.Time `json:"released_at,omitempty"`
+}
+
+// AfterFind reads the amount in the discount's own currency
+func (d *OrderDiscount) AfterFind(tx *gorm.DB) (err error) {
+	if d.Currency == "" {
+		return nil
+	}
+	d.Amount, err = d.Amount.AsCurrency(d.Currency)
+	return err
+}
+
+type PromotionRequest struct {
+	Name         string        `json:"name" binding:"required"`
+	Code         string        `json:"code" binding:"omitempty,max=50"`
+	Type         PromotionType `json:"type" binding:"required,oneof=percent_off fixed_amount free_shipping buy_x_get_y"`
+	PercentOff   int           `json:"percent_off" binding:"min=0,max=100"`
+	AmountOff    *money.Money  `json:"amount_off"`
+	BuyQuantity  int           `json:"buy_quantity" binding:"min=0"`
+	GetQuantity  int           `json:"get_quantity" binding:"min=0"`
+	MinSubtotal  *money.Money  `json:"min_subtotal"`
+	ProductIDs   []uint        `json:"product_ids"`
+	Categories   []string      `json:"categories"`
+	UsageLimit   int           `json:"usage_limit" binding:"min=0"`
+	PerUserLimit int           `json:"per_user_limit" binding:"min=0"`
+	StartsAt     *time.Time    `json:"starts_at"`
+	EndsAt       *time.Time    `json:"ends_at"`
+	// Active defaults to true
+	Active *bool `json:"active"`
+}
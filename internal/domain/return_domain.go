@@ -0,0 +1,96 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/Dubjay18/ecom-api/pkg/money"
+	"gorm.io/gorm"
+)
+
+// Return is a customer's request to send back items of a delivered order
+// for a refund, also known as an RMA
+type Return struct {
+	Base
+	OrderID uint         `json:"order_id" gorm:"index;not null"`
+	UserID  uint         `json:"user_id" gorm:"index;not null"`
+	Status  ReturnStatus `json:"status" gorm:"type:varchar(20);index;not null"`
+	Reason  string       `json:"reason" gorm:"type:text;not null"`
+	// RejectionReason says why an admin rejected the return
+	RejectionReason string       `json:"rejection_reason,omitempty" gorm:"type:text"`
+	Items           []ReturnItem `json:"items" gorm:"foreignKey:ReturnID"`
+	// RefundAmount is what was refunded, set once the return is refunded
+	RefundAmount *money.Money `json:"refund_amount,omitempty" gorm:"type:decimal(15,3)"`
+	// RefundsShipping is set on the return that refunds the last units of
+	// its order, its refund includes what was paid for shipping
+	RefundsShipping bool       `json:"refunds_shipping" gorm:"not null;default:false"`
+	Currency        string     `json:"-" gorm:"type:char(3);not null"`
+	PaymentID       *uint      `json:"payment_id,omitempty"`
+	RefundedAt      *time.Time `json:"refunded_at,omitempty"`
+}
+
+// AfterFind reads the refund in the return's own currency
+func (r *Return) AfterFind(tx *gorm.DB) (err error) {
+	if r.Currency == "" || r.RefundAmount == nil {
+		return nil
+	}
+	amount, err := r.RefundAmount.AsCurrency(r.Currency)
+	r.RefundAmount = &amount
+	return err
+}
+
+type ReturnStatus string
+
+const (
+	ReturnRequested ReturnStatus = "requested"
+	ReturnApproved  ReturnStatus = "approved"
+	ReturnRejected  ReturnStatus = "rejected"
+	// ReturnReceived returns have had their items checked in
+	ReturnReceived ReturnStatus = "received"
+	ReturnRefunded ReturnStatus = "refunded"
+)
+
+// ReturnItem is a quantity of one order item sent back
+type ReturnItem struct {
+	Base
+	ReturnID    uint       `json:"-" gorm:"index;not null"`
+	OrderItemID uint       `json:"order_item_id" gorm:"index;not null"`
+	OrderItem   *OrderItem `json:"order_item,omitempty" gorm:"foreignKey:OrderItemID"`
+	Quantity    int        `json:"quantity" gorm:"not null"`
+	Reason      string     `json:"reason,omitempty" gorm:"type:text"`
+	// ReceivedQuantity is how many units arrived back, set when the
+	// return is received
+	ReceivedQuantity int `json:"received_quantity" gorm:"not null;default:0"`
+	// Restocked units went back into inventory
+	Restocked int `json:"restocked" gorm:"not null;default:0"`
+	// RefundedQuantity is how many units are refunded, set when the return
+	// starts being refunded
+	RefundedQuantity int `json:"refunded_quantity" gorm:"not null;default:0"`
+}
+
+type CreateReturnRequest struct {
+	Reason string                    `json:"reason" binding:"required"`
+	Items  []CreateReturnItemRequest `json:"items" binding:"required,min=1,dive"`
+}
+
+type CreateReturnItemRequest struct {
+	OrderItemID uint   `json:"order_item_id" binding:"required"`
+	Quantity    int    `json:"quantity" binding:"required,min=1"`
+	Reason      string `json:"reason"`
+}
+
+type RejectReturnRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+type ReceiveReturnRequest struct {
+	Items []ReceiveReturnItemRequest `json:"items" binding:"required,min=1,dive"`
+}
+
+type ReceiveReturnItemRequest struct {
+	ReturnItemID uint `json:"return_item_id" binding:"required"`
+	// Quantity is how many units arrived, it may be zero
+	Quantity int `json:"quantity" binding:"min=0"`
+	// Restock puts the received units back into inventory, leave it unset
+	// for damaged goods
+	Restock bool `json:"restock"`
+}
@@ -0,0 +1,38 @@
+package domain
+
+type Review struct {
+	Base
+	UserID           uint         `json:"user_id" gorm:"uniqueIndex:idx_reviews_user_product;not null"`
+	User             User         `json:"-" gorm:"foreignKey:UserID"`
+	ProductID        uint         `json:"product_id" gorm:"uniqueIndex:idx_reviews_user_product;index;not null"`
+	Product          Product      `json:"-" gorm:"foreignKey:ProductID"`
+	Rating           int          `json:"rating" gorm:"not null"`
+	Title            string       `json:"title" gorm:"size:255;not null"`
+	Body             string       `json:"body" gorm:"type:text"`
+	Status           ReviewStatus `json:"status" gorm:"type:varchar(20);default:'pending';index"`
+	VerifiedPurchase bool         `json:"verified_purchase" gorm:"default:false"`
+}
+
+type ReviewStatus string
+
+const (
+	ReviewPending  ReviewStatus = "pending"
+	ReviewApproved ReviewStatus = "approved"
+	ReviewRejected ReviewStatus = "rejected"
+)
+
+type CreateReviewRequest struct {
+	Rating int    `json:"rating" binding:"required,min=1,max=5"`
+	Title  string `json:"title" binding:"required,max=255"`
+	Body   string `json:"body"`
+}
+
+type ModerateReviewRequest struct {
+	Status ReviewStatus `json:"status" binding:"required,oneof=pending approved rejected"`
+}
+
+// RatingSummary is the aggregate of approved reviews for a product
+type RatingSummary struct {
+	Average float64 `json:"average"`
+	Count   int     `json:"count"`
+}
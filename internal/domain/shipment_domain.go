@@ -0,0 +1,58 @@
+package domain
+
+import "time"
+
+// Shipment is a box of order items handed to a carrier. An order can ship
+// in several shipments, its status follows them.
+type Shipment struct {
+	Base
+	OrderID        uint           `json:"order_id" gorm:"index;not null"`
+	Status         ShipmentStatus `json:"status" gorm:"type:varchar(20);not null"`
+	Carrier        string         `json:"carrier" gorm:"size:100;not null"`
+	TrackingNumber string         `json:"tracking_number" gorm:"size:100;not null"`
+	// TrackingURL links to the carrier's tracking page
+	TrackingURL string         `json:"tracking_url,omitempty" gorm:"size:500"`
+	Items       []ShipmentItem `json:"items" gorm:"foreignKey:ShipmentID"`
+	ShippedAt   time.Time      `json:"shipped_at" gorm:"not null"`
+	DeliveredAt *time.Time     `json:"delivered_at,omitempty"`
+}
+
+type ShipmentStatus string
+
+const (
+	ShipmentShipped   ShipmentStatus = "shipped"
+	ShipmentDelivered ShipmentStatus = "delivered"
+)
+
+// IsDelivered reports whether the carrier has delivered the shipment
+func (s *Shipment) IsDelivered() bool {
+	return s.Status == ShipmentDelivered
+}
+
+// ShipmentItem is a quantity of one order item in a shipment
+type ShipmentItem struct {
+	Base
+	ShipmentID  uint `json:"-" gorm:"index;not null"`
+	OrderItemID uint `json:"order_item_id" gorm:"index;not null"`
+	Quantity    int  `json:"quantity" gorm:"not null"`
+}
+
+type CreateShipmentRequest struct {
+	Carrier        string                      `json:"carrier" binding:"required"`
+	TrackingNumber string                      `json:"tracking_number" binding:"required"`
+	TrackingURL    string                      `json:"tracking_url" binding:"omitempty,url"`
+	Items          []CreateShipmentItemRequest `json:"items" binding:"required,min=1,dive"`
+}
+
+type CreateShipmentItemRequest struct {
+	OrderItemID uint `json:"order_item_id" binding:"required"`
+	Quantity    int  `json:"quantity" binding:"required,min=1"`
+}
+
+// UpdateShipmentRequest corrects the tracking details of a shipment, unset
+// fields are left alone
+type UpdateShipmentRequest struct {
+	Carrier        string `json:"carrier"`
+	TrackingNumber string `json:"tracking_number"`
+	TrackingURL    string `json:"tracking_url" binding:"omitempty,url"`
+}
@@ -2,13 +2,16 @@ package domain
 
 type User struct {
 	Base
-	Email     string    `json:"email" gorm:"uniqueIndex;not null"`
-	Password  string    `json:"-" gorm:"not null"`
-	FirstName string    `json:"first_name" gorm:"size:100"`
-	LastName  string    `json:"last_name" gorm:"size:100"`
-	Role      UserRole  `json:"role" gorm:"type:varchar(20);default:'user'"`
-	Orders    []Order   `json:"orders,omitempty" gorm:"foreignKey:UserID"`
-	Addresses []Address `json:"addresses,omitempty" gorm:"foreignKey:UserID"`
+	Email     string   `json:"email" gorm:"uniqueIndex;not null"`
+	Password  string   `json:"-" gorm:"not null"`
+	FirstName string   `json:"first_name" gorm:"size:100"`
+	LastName  string   `json:"last_name" gorm:"size:100"`
+	Role      UserRole `json:"role" gorm:"type:varchar(20);default:'user'"`
+	// PreferredCurrency prices the catalog for the user, empty means the
+	// base currency
+	PreferredCurrency string    `json:"preferred_currency" gorm:"type:char(3)"`
+	Orders            []Order   `json:"orders,omitempty" gorm:"foreignKey:UserID"`
+	Addresses         []Address `json:"addresses,omitempty" gorm:"foreignKey:UserID"`
 }
 
 type UserRole string
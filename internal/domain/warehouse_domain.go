@@ -0,0 +1,83 @@
+package domain
+
+import "strings"
+
+type Warehouse struct {
+	Base
+	Code    string `json:"code" gorm:"uniqueIndex;size:50;not null"`
+	Name    string `json:"name" gorm:"size:255;not null"`
+	Street  string `json:"street" gorm:"size:255"`
+	City    string `json:"city" gorm:"size:100"`
+	State   string `json:"state" gorm:"size:100"`
+	Country string `json:"country" gorm:"size:100;not null"`
+	// IsDefault marks the warehouse that receives stock changes made without
+	// an explicit warehouse, such as product updates and imports
+	IsDefault bool `json:"is_default" gorm:"default:false"`
+	IsActive  bool `json:"is_active" gorm:"default:true"`
+}
+
+// Distance ranks how close the warehouse is to an address, lower is nearer
+func (w *Warehouse) Distance(addr *Address) int {
+	if !strings.EqualFold(w.Country, addr.Country) {
+		return 2
+	}
+	if !strings.EqualFold(w.State, addr.State) {
+		return 1
+	}
+	return 0
+}
+
+// WarehouseStock is the stock of one product held in one warehouse. The
+// product's Stock and Reserved are the totals across its warehouses.
+type WarehouseStock struct {
+	Base
+	WarehouseID uint      `json:"warehouse_id" gorm:"uniqueIndex:idx_warehouse_stocks_warehouse_product;not null"`
+	Warehouse   Warehouse `json:"warehouse,omitempty" gorm:"foreignKey:WarehouseID"`
+	ProductID   uint      `json:"product_id" gorm:"uniqueIndex:idx_warehouse_stocks_warehouse_product;index;not null"`
+	Stock       int       `json:"stock" gorm:"not null;default:0"`
+	Reserved    int       `json:"reserved" gorm:"not null;default:0"`
+}
+
+// Available returns the stock in the warehouse that is not reserved
+func (s *WarehouseStock) Available() int {
+	return s.Stock - s.Reserved
+}
+
+// StockTransfer moves stock of a product between two warehouses
+type StockTransfer struct {
+	Base
+	ProductID       uint   `json:"product_id" gorm:"index;not null"`
+	FromWarehouseID uint   `json:"from_warehouse_id" gorm:"index;not null"`
+	ToWarehouseID   uint   `json:"to_warehouse_id" gorm:"index;not null"`
+	Quantity        int    `json:"quantity" gorm:"not null"`
+	UserID          uint   `json:"user_id" gorm:"index"`
+	Reason          string `json:"reason" gorm:"size:255"`
+}
+
+type CreateWarehouseRequest struct {
+	Code      string `json:"code" binding:"required,max=50"`
+	Name      string `json:"name" binding:"required,max=255"`
+	Street    string `json:"street"`
+	City      string `json:"city"`
+	State     string `json:"state"`
+	Country   string `json:"country" binding:"required"`
+	IsDefault bool   `json:"is_default"`
+}
+
+type UpdateWarehouseRequest struct {
+	Name      string `json:"name" binding:"max=255"`
+	Street    string `json:"street"`
+	City      string `json:"city"`
+	State     string `json:"state"`
+	Country   string `json:"country"`
+	IsDefault *bool  `json:"is_default"`
+	IsActive  *bool  `json:"is_active"`
+}
+
+type StockTransferRequest struct {
+	ProductID       uint   `json:"product_id" binding:"required"`
+	FromWarehouseID uint   `json:"from_warehouse_id" binding:"required"`
+	ToWarehouseID   uint   `json:"to_warehouse_id" binding:"required,nefield=FromWarehouseID"`
+	Quantity        int    `json:"quantity" binding:"required,min=1"`
+	Reason          string `json:"reason" binding:"max=255"`
+}
@@ -0,0 +1,286 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/internal/middleware"
+	"github.com/Dubjay18/ecom-api/internal/service"
+	"github.com/Dubjay18/ecom-api/pkg/common"
+	"github.com/Dubjay18/ecom-api/pkg/common/response"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// cartTokenHeader identifies the cart of an anonymous shopper
+const cartTokenHeader = "X-Cart-Token"
+
+type CartHandler struct {
+	r           *gin.RouterGroup
+	s           *service.CartService
+	idempotency *service.IdempotencyService
+}
+
+// NewCartHandler registers the cart routes. They are open to anonymous
+// shoppers and authenticate users only when a token is sent.
+func NewCartHandler(r *gin.RouterGroup, s *service.CartService, idempotency *service.IdempotencyService, secretKey string) *CartHandler {
+	handler := &CartHandler{
+		r:           r.Group("", middleware.OptionalAuthMiddleware(secretKey)),
+		s:           s,
+		idempotency: idempotency,
+	}
+	handler.RegisterRoutes()
+	return handler
+}
+
+// GetCart godoc
+// @Summary Get the cart
+// @Description Get the cart of the authenticated user, or the anonymous cart named by X-Cart-Token, priced with live prices and stock
+// @Tags cart
+// @Produce json
+// @Security JWT
+// @Param X-Cart-Token header string false "Anonymous cart token"
+// @Param X-Currency header string false "ISO 4217 currency to price the cart in"
+// @Success 200 {object} domain.Cart
+// @Router /api/v1/cart [get]
+func (h *CartHandler) GetCart(c *gin.Context) {
+	owner, cerr := h.owner(c)
+	if cerr != nil {
+		response.Error(c, cerr.Code, "Failed to get cart", cerr.Message)
+		return
+	}
+
+	cart, cerr := h.s.Get(c.Request.Context(), owner, requestCurrency(c))
+	if cerr != nil {
+		response.Error(c, cerr.Code, "Failed to get cart", cerr.Message)
+		return
+	}
+
+	h.render(c, http.StatusOK, "Cart retrieved successfully", cart)
+}
+
+// AddCartItem godoc
+// @Summary Add an item to the cart
+// @Description Add units of a product to the cart, an anonymous shopper without a cart is given one and its token is returned in X-Cart-Token
+// @Tags cart
+// @Accept json
+// @Produce json
+// @Security JWT
+// @Param X-Cart-Token header string false "Anonymous cart token"
+// @Param X-Currency header string false "ISO 4217 currency to price the cart in"
+// @Param item body domain.AddCartItemRequest true "Cart item"
+// @Success 200 {object} domain.Cart
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /api/v1/cart/items [post]
+func (h *CartHandler) AddCartItem(c *gin.Context) {
+	var req domain.AddCartItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if verrs, ok := err.(validator.ValidationErrors); ok {
+			response.RenderBindingErrors(c, verrs)
+			return
+		}
+		response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+		return
+	}
+
+	owner, cerr := h.owner(c)
+	if cerr != nil {
+		response.Error(c, cerr.Code, "Failed to add cart item", cerr.Message)
+		return
+	}
+
+	cart, cerr := h.s.AddItem(c.Request.Context(), owner, &req, requestCurrency(c))
+	if cerr != nil {
+		response.Error(c, cerr.Code, "Failed to add cart item", cerr.Message)
+		return
+	}
+
+	h.render(c, http.StatusOK, "Item added to cart", cart)
+}
+
+// UpdateCartItem godoc
+// @Summary Update a cart item
+// @Description Set the quantity of a product in the cart
+// @Tags cart
+// @Accept json
+// @Produce json
+// @Security JWT
+// @Param X-Cart-Token header string false "Anonymous cart token"
+// @Param X-Currency header string false "ISO 4217 currency to price the cart in"
+// @Param product_id path int true "Product ID"
+// @Param item body domain.UpdateCartItemRequest true "Quantity"
+// @Success 200 {object} domain.Cart
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /api/v1/cart/items/{product_id} [put]
+func (h *CartHandler) UpdateCartItem(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("product_id"))
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid product ID", err.Error())
+		return
+	}
+
+	var req domain.UpdateCartItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if verrs, ok := err.(validator.ValidationErrors); ok {
+			response.RenderBindingErrors(c, verrs)
+			return
+		}
+		response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+		return
+	}
+
+	owner, cerr := h.owner(c)
+	if cerr != nil {
+		response.Error(c, cerr.Code, "Failed to update cart item", cerr.Message)
+		return
+	}
+
+	cart, cerr := h.s.UpdateItem(c.Request.Context(), owner, uint(productID), req.Quantity, requestCurrency(c))
+	if cerr != nil {
+		response.Error(c, cerr.Code, "Failed to update cart item", cerr.Message)
+		return
+	}
+
+	h.render(c, http.StatusOK, "Cart item updated", cart)
+}
+
+// RemoveCartItem godoc
+// @Summary Remove a cart item
+// @Description Take a product out of the cart
+// @Tags cart
+// @Produce json
+// @Security JWT
+// @Param X-Cart-Token header string false "Anonymous cart token"
+// @Param X-Currency header string false "ISO 4217 currency to price the cart in"
+// @Param product_id path int true "Product ID"
+// @Success 200 {object} domain.Cart
+// @Failure 404 {object} response.ErrorResponse
+// @Router /api/v1/cart/items/{product_id} [delete]
+func (h *CartHandler) RemoveCartItem(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("product_id"))
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid product ID", err.Error())
+		return
+	}
+
+	owner, cerr := h.owner(c)
+	if cerr != nil {
+		response.Error(c, cerr.Code, "Failed to remove cart item", cerr.Message)
+		return
+	}
+
+	cart, cerr := h.s.RemoveItem(c.Request.Context(), owner, uint(productID), requestCurrency(c))
+	if cerr != nil {
+		response.Error(c, cerr.Code, "Failed to remove cart item", cerr.Message)
+		return
+	}
+
+	h.render(c, http.StatusOK, "Cart item removed", cart)
+}
+
+// ClearCart godoc
+// @Summary Clear the cart
+// @Description Remove every item from the cart
+// @Tags cart
+// @Produce json
+// @Security JWT
+// @Param X-Cart-Token header string false "Anonymous cart token"
+// @Success 200 {object} response.Response
+// @Router /api/v1/cart [delete]
+func (h *CartHandler) ClearCart(c *gin.Context) {
+	owner, cerr := h.owner(c)
+	if cerr != nil {
+		response.Error(c, cerr.Code, "Failed to clear cart", cerr.Message)
+		return
+	}
+
+	if cerr := h.s.Clear(c.Request.Context(), owner); cerr != nil {
+		response.Error(c, cerr.Code, "Failed to clear cart", cerr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Cart cleared", nil)
+}
+
+// Checkout godoc
+// @Summary Check out the cart
+// @Description Place an order for everything in the authenticated user's cart and empty it. Anonymous carts are merged into the user's cart when X-Cart-Token is sent.
+// @Tags cart
+// @Accept json
+// @Produce json
+// @Security JWT
+// @Param X-Cart-Token header string false "Anonymous cart token"
+// @Param X-Currency header string false "ISO 4217 currency to price the order in"
+// @Param checkout body domain.CheckoutRequest true "Checkout details"
+// @Param Idempotency-Key header string false "Unique key that makes retries of the checkout return the first response"
+// @Success 201 {object} domain.Order
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Router /api/v1/cart/checkout [post]
+func (h *CartHandler) Checkout(c *gin.Context) {
+	var req domain.CheckoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if verrs, ok := err.(validator.ValidationErrors); ok {
+			response.RenderBindingErrors(c, verrs)
+			return
+		}
+		response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+		return
+	}
+
+	owner, cerr := h.owner(c)
+	if cerr != nil {
+		response.Error(c, cerr.Code, "Failed to check out", cerr.Message)
+		return
+	}
+	if owner.UserID == 0 {
+		response.Error(c, http.StatusUnauthorized, "Log in to check out", nil)
+		return
+	}
+	if req.Currency == "" {
+		req.Currency = requestCurrency(c)
+	}
+
+	order, cerr := h.s.Checkout(actorContext(c), owner.UserID, &req)
+	if cerr != nil {
+		response.Error(c, cerr.Code, "Failed to check out", cerr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusCreated, "Order created successfully", order)
+}
+
+// owner identifies the cart of the request. A logged in user who still
+// sends an anonymous cart token has that cart merged into theirs.
+func (h *CartHandler) owner(c *gin.Context) (domain.CartOwner, *common.AppError) {
+	owner := domain.CartOwner{UserID: c.GetUint("userID"), Token: c.GetHeader(cartTokenHeader)}
+	if owner.UserID != 0 && owner.Token != "" {
+		if cerr := h.s.Merge(c.Request.Context(), owner.Token, owner.UserID); cerr != nil {
+			return owner, cerr
+		}
+		owner.Token = ""
+	}
+	return owner, nil
+}
+
+// render responds with a cart, handing anonymous shoppers their cart token
+func (h *CartHandler) render(c *gin.Context, status int, message string, cart *domain.Cart) {
+	if cart.Token != nil {
+		c.Header(cartTokenHeader, *cart.Token)
+	}
+	response.Success(c, status, message, cart)
+}
+
+// RegisterRoutes registers cart-related routes
+func (h *CartHandler) RegisterRoutes() {
+	cart := h.r.Group("/cart")
+	cart.GET("", h.GetCart)
+	cart.DELETE("", h.ClearCart)
+	cart.POST("/items", h.AddCartItem)
+	cart.PUT("/items/:product_id", h.UpdateCartItem)
+	cart.DELETE("/items/:product_id", h.RemoveCartItem)
+	cart.POST("/checkout", middleware.Idempotency(h.idempotency), h.Checkout)
+}
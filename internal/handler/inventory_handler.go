@@ -0,0 +1,235 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/internal/middleware"
+	"github.com/Dubjay18/ecom-api/internal/service"
+	"github.com/Dubjay18/ecom-api/pkg/common/response"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type InventoryHandler struct {
+	r      *gin.RouterGroup
+	s      *service.InventoryService
+	alerts *service.StockAlertService
+}
+
+func NewInventoryHandler(r *gin.RouterGroup, s *service.InventoryService, alerts *service.StockAlertService, secretKey string) *InventoryHandler {
+	handler := &InventoryHandler{
+		r:      r.Group("", middleware.AuthMiddleware(secretKey)),
+		s:      s,
+		alerts: alerts,
+	}
+	handler.RegisterRoutes()
+	return handler
+}
+
+// AdjustStock godoc
+// @Summary Adjust product stock
+// @Description Change a product's on-hand stock in a warehouse by a signed quantity and record it in the inventory ledger (admin only)
+// @Tags inventory
+// @Accept json
+// @Produce json
+// @Security JWT
+// @Param adjustment body domain.StockAdjustmentRequest true "Stock adjustment"
+// @Success 201 {object} domain.StockMovement
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /api/v1/inventory/adjustments [post]
+func (h *InventoryHandler) AdjustStock(c *gin.Context) {
+	var req domain.StockAdjustmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if verrs, ok := err.(validator.ValidationErrors); ok {
+			response.RenderBindingErrors(c, verrs)
+			return
+		}
+		response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+		return
+	}
+
+	userID := c.GetUint("userID")
+	movement, ierr := h.s.Adjust(c.Request.Context(), req.ProductID, req.Quantity, domain.StockChange{
+		WarehouseID: req.WarehouseID,
+		Type:        domain.MovementAdjustment,
+		UserID:      &userID,
+		Reason:      req.Reason,
+		Reference:   req.Reference,
+	})
+	if ierr != nil {
+		response.Error(c, ierr.Code, "Failed to adjust stock", ierr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusCreated, "Stock adjusted successfully", movement)
+}
+
+// ListMovements godoc
+// @Summary List stock movements
+// @Description List the most recent inventory ledger entries of a product (admin only)
+// @Tags inventory
+// @Produce json
+// @Security JWT
+// @Param id path int true "Product ID"
+// @Param limit query int false "Maximum entries to return, defaults to 100"
+// @Success 200 {array} domain.StockMovement
+// @Failure 400 {object} response.ErrorResponse
+// @Router /api/v1/inventory/products/{id}/movements [get]
+func (h *InventoryHandler) ListMovements(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid product ID", err.Error())
+		return
+	}
+
+	limit := parseInt(c.Query("limit"))
+	if limit < 1 || limit > 1000 {
+		limit = 100
+	}
+
+	movements, ierr := h.s.ListMovements(c.Request.Context(), uint(id), limit)
+	if ierr != nil {
+		response.Error(c, ierr.Code, "Failed to list stock movements", ierr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Stock movements retrieved successfully", movements)
+}
+
+// Reconcile godoc
+// @Summary Reconcile stock with the ledger
+// @Description Recompute every product's stock from the inventory ledger and flag drift (admin only)
+// @Tags inventory
+// @Produce json
+// @Security JWT
+// @Param drift_only query bool false "Only return products whose stock drifted"
+// @Success 200 {array} domain.StockReconciliation
+// @Router /api/v1/inventory/reconciliation [get]
+func (h *InventoryHandler) Reconcile(c *gin.Context) {
+	driftOnly, _ := strconv.ParseBool(c.Query("drift_only"))
+
+	report, ierr := h.s.Reconcile(c.Request.Context(), driftOnly)
+	if ierr != nil {
+		response.Error(c, ierr.Code, "Failed to reconcile stock", ierr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Stock reconciled successfully", report)
+}
+
+// TransferStock godoc
+// @Summary Transfer stock between warehouses
+// @Description Move available stock of a product from one warehouse to another (admin only)
+// @Tags inventory
+// @Accept json
+// @Produce json
+// @Security JWT
+// @Param transfer body domain.StockTransferRequest true "Stock transfer"
+// @Success 201 {object} domain.StockTransfer
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /api/v1/inventory/transfers [post]
+func (h *InventoryHandler) TransferStock(c *gin.Context) {
+	var req domain.StockTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if verrs, ok := err.(validator.ValidationErrors); ok {
+			response.RenderBindingErrors(c, verrs)
+			return
+		}
+		response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+		return
+	}
+
+	transfer, ierr := h.s.Transfer(c.Request.Context(), c.GetUint("userID"), &req)
+	if ierr != nil {
+		response.Error(c, ierr.Code, "Failed to transfer stock", ierr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusCreated, "Stock transferred successfully", transfer)
+}
+
+// ListTransfers godoc
+// @Summary List stock transfers
+// @Description List the most recent stock transfers between warehouses (admin only)
+// @Tags inventory
+// @Produce json
+// @Security JWT
+// @Param product_id query int false "Only transfers of this product"
+// @Param limit query int false "Maximum entries to return, defaults to 100"
+// @Success 200 {array} domain.StockTransfer
+// @Router /api/v1/inventory/transfers [get]
+func (h *InventoryHandler) ListTransfers(c *gin.Context) {
+	limit := parseInt(c.Query("limit"))
+	if limit < 1 || limit > 1000 {
+		limit = 100
+	}
+
+	transfers, ierr := h.s.ListTransfers(c.Request.Context(), uint(parseInt(c.Query("product_id"))), limit)
+	if ierr != nil {
+		response.Error(c, ierr.Code, "Failed to list stock transfers", ierr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Stock transfers retrieved successfully", transfers)
+}
+
+// WarehouseStock godoc
+// @Summary Get stock per warehouse
+// @Description Get a product's stock and reservations in each warehouse (admin only)
+// @Tags inventory
+// @Produce json
+// @Security JWT
+// @Param id path int true "Product ID"
+// @Success 200 {array} domain.WarehouseStock
+// @Failure 400 {object} response.ErrorResponse
+// @Router /api/v1/inventory/products/{id}/warehouses [get]
+func (h *InventoryHandler) WarehouseStock(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid product ID", err.Error())
+		return
+	}
+
+	stocks, ierr := h.s.WarehouseStock(c.Request.Context(), uint(id))
+	if ierr != nil {
+		response.Error(c, ierr.Code, "Failed to get warehouse stock", ierr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Warehouse stock retrieved successfully", stocks)
+}
+
+// ListLowStock godoc
+// @Summary List low stock products
+// @Description List products whose available stock is at or below their reorder threshold, lowest first (admin only)
+// @Tags inventory
+// @Produce json
+// @Security JWT
+// @Success 200 {array} domain.Product
+// @Router /api/v1/inventory/low-stock [get]
+func (h *InventoryHandler) ListLowStock(c *gin.Context) {
+	products, ierr := h.alerts.ListLowStock(c.Request.Context())
+	if ierr != nil {
+		response.Error(c, ierr.Code, "Failed to list low stock products", ierr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Low stock products retrieved successfully", products)
+}
+
+// RegisterRoutes registers inventory-related routes
+func (h *InventoryHandler) RegisterRoutes() {
+	adminRoutes := h.r.Group("/inventory")
+	adminRoutes.Use(middleware.AdminMiddleware())
+	adminRoutes.POST("/adjustments", h.AdjustStock)
+	adminRoutes.GET("/products/:id/movements", h.ListMovements)
+	adminRoutes.GET("/products/:id/warehouses", h.WarehouseStock)
+	adminRoutes.POST("/transfers", h.TransferStock)
+	adminRoutes.GET("/transfers", h.ListTransfers)
+	adminRoutes.GET("/reconciliation", h.Reconcile)
+	adminRoutes.GET("/low-stock", h.ListLowStock)
+}
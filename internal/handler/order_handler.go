@@ -1,40 +1,47 @@
 package handler
 
 import (
+	"context"
 	"net/http"
 	"strconv"
 
 	"github.com/Dubjay18/ecom-api/internal/domain"
 	"github.com/Dubjay18/ecom-api/internal/middleware"
 	"github.com/Dubjay18/ecom-api/internal/service"
+	"github.com/Dubjay18/ecom-api/pkg/common"
 	"github.com/Dubjay18/ecom-api/pkg/common/response"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 )
 
 type OrderHandler struct {
-	r *gin.RouterGroup
-	s *service.OrderService
+	r           *gin.RouterGroup
+	s           *service.OrderService
+	payments    *service.PaymentService
+	idempotency *service.IdempotencyService
 }
 
-func NewOrderHandler(r *gin.RouterGroup, s *service.OrderService, secretKey string) *OrderHandler {
+func NewOrderHandler(r *gin.RouterGroup, s *service.OrderService, payments *service.PaymentService, idempotency *service.IdempotencyService, secretKey string) *OrderHandler {
 	handler := &OrderHandler{
-		r: r,
-		s: s,
+		r:           r.Group("", middleware.AuthMiddleware(secretKey)),
+		s:           s,
+		payments:    payments,
+		idempotency: idempotency,
 	}
-	r.Use(middleware.AuthMiddleware(secretKey))
 	handler.RegisterRoutes()
 	return handler
 }
 
 // CreateOrder godoc
 // @Summary Place an order for one or more products
-// @Description Create a new order for authenticated user
+// @Description Create a new order for authenticated user. Running promotions the order qualifies for and the coupon, if any, are taken off its lines.
 // @Tags orders
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param order body domain.CreateOrderRequest true "Order details"
+// @Param X-Currency header string false "ISO 4217 currency to price the order in, defaults to the user's preferred currency"
+// @Param Idempotency-Key header string false "Unique key that makes retries of the request return the first response"
 // @Success 201 {object} domain.Order
 // @Example JSON Response - Success
 //
@@ -67,6 +74,8 @@ func NewOrderHandler(r *gin.RouterGroup, s *service.OrderService, secretKey stri
 //	}
 //
 // @Failure 401 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse "A request with the same Idempotency-Key is in progress"
+// @Failure 422 {object} response.ErrorResponse "The Idempotency-Key was used for a different request"
 // @Router /api/v1/orders [post]
 func (h *OrderHandler) CreateOrder(c *gin.Context) {
 	var req domain.CreateOrderRequest
@@ -75,8 +84,12 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 		return
 	}
 
+	if req.Currency == "" {
+		req.Currency = requestCurrency(c)
+	}
+
 	userID, _ := c.Get("userID")
-	order, err := h.s.PlaceOrder(c.Request.Context(), userID.(uint), &req)
+	order, err := h.s.PlaceOrder(actorContext(c), userID.(uint), &req)
 	if err != nil {
 		response.Error(c, err.Code, "Failed to create order", err.Message)
 		return
@@ -118,12 +131,66 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 //
 // @Router /api/v1/orders [get]
 func (h *OrderHandler) GetUserOrders(c *gin.Context) {
-	// ...
+	orders, err := h.s.ListUserOrders(c.Request.Context(), c.GetUint("userID"))
+	if err != nil {
+		response.Error(c, err.Code, "Failed to get orders", err.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Orders retrieved successfully", orders)
+}
+
+// GetOrder godoc
+// @Summary Get an order
+// @Description Get an order with its items, products and shipping address. Customers can only get their own orders.
+// @Tags orders
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Success 200 {object} domain.Order
+// @Example JSON Response - Success
+//
+//	{
+//	  "status": 200,
+//	  "message": "Order retrieved successfully",
+//	  "data": {
+//	    "id": 1,
+//	    "user_id": 42,
+//	    "status": "pending",
+//	    "items": [
+//	      {
+//	        "product": {..},
+//	        "quantity": 2,
+//	        "price": {"amount": 5998, "currency": "USD", "formatted": "59.98"}
+//	      }
+//	    ],
+//	    "shipping_address": {..}
+//	  }
+//	}
+//
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /api/v1/orders/:id [get]
+func (h *OrderHandler) GetOrder(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid order ID", err.Error())
+		return
+	}
+
+	order, oerr := h.s.GetOrder(c.Request.Context(), uint(id), requestActor(c))
+	if oerr != nil {
+		response.Error(c, oerr.Code, "Failed to get order", oerr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Order retrieved successfully", order)
 }
 
 // CancelOrder godoc
 // @Summary Cancel an order
-// @Description Cancel an order if it is still pending
+// @Description Cancel an order that has not shipped yet, the payment of a paid order is refunded in full
 // @Tags orders
 // @Accept json
 // @Produce json
@@ -140,6 +207,8 @@ func (h *OrderHandler) GetUserOrders(c *gin.Context) {
 // @Failure 400 {object} response.ErrorResponse
 // @Failure 401 {object} response.ErrorResponse
 // @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 502 {object} response.ErrorResponse
 // @Example JSON Response - Error
 //
 //	{
@@ -157,9 +226,9 @@ func (h *OrderHandler) CancelOrder(c *gin.Context) {
 		return
 	}
 
-	oerr := h.s.CancelOrder(c.Request.Context(), uint(id))
+	oerr := h.payments.CancelOrder(actorContext(c), uint(id), requestActor(c))
 	if oerr != nil {
-		response.Error(c, oerr.Code, "Failed to cancel order", oerr.Message)
+		response.Error(c, oerr.Code, "Failed to cancel order", errorBody(oerr))
 		return
 	}
 
@@ -168,7 +237,7 @@ func (h *OrderHandler) CancelOrder(c *gin.Context) {
 
 // UpdateOrderStatus godoc
 // @Summary Update order status
-// @Description Update the status of an order (admin only)
+// @Description Update the status of an order (admin only). Orders ship through their shipments, they can only be marked shipped once every item has shipped.
 // @Tags orders
 // @Accept json
 // @Produce json
@@ -184,7 +253,7 @@ func (h *OrderHandler) CancelOrder(c *gin.Context) {
 //	  "message": "Order status updated successfully",
 //	  "data": {
 //	    "id": 1,
-//	    "status": "shipped"
+//	    "status": "cancelled"
 //	  }
 //	}
 //
@@ -200,6 +269,23 @@ func (h *OrderHandler) CancelOrder(c *gin.Context) {
 // @Failure 401 {object} response.ErrorResponse
 // @Failure 403 {object} response.ErrorResponse
 // @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.Response
+// @Example JSON Response - Invalid transition
+//
+//	{
+//	  "status": 409,
+//	  "message": "Failed to update order status",
+//	  "error": {
+//	    "message": "Cannot move order from delivered to pending",
+//	    "details": {
+//	      "from": "delivered",
+//	      "to": "pending",
+//	      "reason": "Cannot move order from delivered to pending",
+//	      "allowed": []
+//	    }
+//	  }
+//	}
+//
 // @Router /api/v1/orders/:id/status [put]
 func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
 	var req domain.UpdateOrderStatusRequest
@@ -215,22 +301,140 @@ func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
 		return
 	}
 
-	oerr := h.s.UpdateOrderStatus(c.Request.Context(), uint(id), req.Status)
+	oerr := h.s.UpdateOrderStatus(actorContext(c), uint(id), req.Status)
 	if oerr != nil {
-		response.Error(c, oerr.Code, "Failed to update order status", oerr.Message)
+		response.Error(c, oerr.Code, "Failed to update order status", errorBody(oerr))
 		return
 	}
 
 	response.Success(c, http.StatusOK, "Order status updated successfully", nil)
 }
 
+// GetOrderEvents godoc
+// @Summary Get the history of an order
+// @Description List the events of an order oldest first. Customers see the events of their own orders, admins also see internal notes and who made each change.
+// @Tags orders
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Success 200 {array} domain.OrderEvent
+// @Example JSON Response - Success
+//
+//	{
+//	  "status": 200,
+//	  "message": "Order events retrieved successfully",
+//	  "data": [
+//	    {
+//	      "id": 1,
+//	      "order_id": 1,
+//	      "type": "placed",
+//	      "to_status": "pending",
+//	      "message": "Order placed",
+//	      "internal": false,
+//	      "actor_type": "customer",
+//	      "occurred_at": "2024-05-01T10:00:00Z"
+//	    },
+//	    {
+//	      "id": 2,
+//	      "order_id": 1,
+//	      "type": "payment",
+//	      "from_status": "pending",
+//	      "to_status": "completed",
+//	      "message": "Payment completed",
+//	      "internal": false,
+//	      "actor_type": "system",
+//	      "occurred_at": "2024-05-01T10:02:00Z"
+//	    }
+//	  ]
+//	}
+//
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /api/v1/orders/:id/events [get]
+func (h *OrderHandler) GetOrderEvents(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid order ID", err.Error())
+		return
+	}
+
+	events, oerr := h.s.ListEvents(c.Request.Context(), uint(id), requestActor(c))
+	if oerr != nil {
+		response.Error(c, oerr.Code, "Failed to get order events", oerr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Order events retrieved successfully", events)
+}
+
+// AddOrderNote godoc
+// @Summary Add a note to an order
+// @Description Add a note to the history of an order, internal notes are hidden from the customer (admin only)
+// @Tags orders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Param note body domain.CreateOrderNoteRequest true "Note"
+// @Success 201 {object} domain.OrderEvent
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /api/v1/orders/:id/notes [post]
+func (h *OrderHandler) AddOrderNote(c *gin.Context) {
+	var req domain.CreateOrderNoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid order ID", err.Error())
+		return
+	}
+
+	event, oerr := h.s.AddNote(actorContext(c), uint(id), &req)
+	if oerr != nil {
+		response.Error(c, oerr.Code, "Failed to add order note", oerr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusCreated, "Order note added successfully", event)
+}
+
+// requestActor returns the authenticated user of a request
+func requestActor(c *gin.Context) service.Actor {
+	return service.Actor{UserID: c.GetUint("userID"), IsAdmin: c.GetBool("isAdmin")}
+}
+
+// actorContext returns the request context carrying the authenticated user,
+// who the order events it causes are attributed to
+func actorContext(c *gin.Context) context.Context {
+	return service.WithActor(c.Request.Context(), requestActor(c))
+}
+
+// errorBody is the error of a response, with the structured details of the
+// AppError when it has them
+func errorBody(err *common.AppError) any {
+	if err.Details == nil {
+		return err.Message
+	}
+	return gin.H{"message": err.Message, "details": err.Details}
+}
+
 // RegisterRoutes registers order-related routes
 func (h *OrderHandler) RegisterRoutes() {
-	h.r.POST("/orders", h.CreateOrder)
+	h.r.POST("/orders", middleware.Idempotency(h.idempotency), h.CreateOrder)
 	h.r.GET("/orders", h.GetUserOrders)
+	h.r.GET("/orders/:id", h.GetOrder)
 	h.r.DELETE("/orders/:id", h.CancelOrder)
+	h.r.GET("/orders/:id/events", h.GetOrderEvents)
 
 	adminRoutes := h.r.Group("/")
 	adminRoutes.Use(middleware.AdminMiddleware())
 	adminRoutes.PUT("/orders/:id/status", h.UpdateOrderStatus)
+	adminRoutes.POST("/orders/:id/notes", h.AddOrderNote)
 }
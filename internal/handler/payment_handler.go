@@ -0,0 +1,265 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/internal/middleware"
+	"github.com/Dubjay18/ecom-api/internal/service"
+	"github.com/Dubjay18/ecom-api/pkg/common/response"
+	"github.com/gin-gonic/gin"
+)
+
+type PaymentHandler struct {
+	r           *gin.RouterGroup
+	s           *service.PaymentService
+	idempotency *service.IdempotencyService
+}
+
+func NewPaymentHandler(r *gin.RouterGroup, s *service.PaymentService, idempotency *service.IdempotencyService, secretKey string) *PaymentHandler {
+	handler := &PaymentHandler{
+		r:           r.Group("", middleware.AuthMiddleware(secretKey)),
+		s:           s,
+		idempotency: idempotency,
+	}
+	handler.RegisterRoutes()
+	return handler
+}
+
+// StartPayment godoc
+// @Summary Pay for an order
+// @Description Start paying for an order with the payment method it was placed with, or another one. Payments that are authorized straight away are captured and confirm the order. A payment that needs the customer to act, such as 3-D Secure, is returned pending with a client_secret for the provider's client library, then synced.
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Param payment body domain.StartPaymentRequest false "Payment method"
+// @Param Idempotency-Key header string false "Unique key that makes retries of the request return the first response"
+// @Success 201 {object} domain.Payment
+// @Example JSON Response - Success
+//
+//	{
+//	  "status": 201,
+//	  "message": "Payment started successfully",
+//	  "data": {
+//	    "id": 1,
+//	    "order_id": 1,
+//	    "provider": "stripe",
+//	    "provider_ref": "pi_3Mtw",
+//	    "method": "pm_card_visa",
+//	    "status": "captured",
+//	    "amount": {"amount": 5998, "currency": "USD", "formatted": "59.98"},
+//	    "refunded_amount": {"amount": 0, "currency": "USD", "formatted": "0.00"}
+//	  }
+//	}
+//
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse "The order is cancelled, paid or has a payment in progress"
+// @Failure 502 {object} response.ErrorResponse "The payment provider failed"
+// @Router /api/v1/orders/:id/payments [post]
+func (h *PaymentHandler) StartPayment(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid order ID", err.Error())
+		return
+	}
+
+	var req domain.StartPaymentRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+			return
+		}
+	}
+
+	payment, perr := h.s.Start(actorContext(c), uint(id), requestActor(c), &req)
+	if perr != nil {
+		response.Error(c, perr.Code, "Failed to start payment", perr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusCreated, "Payment started successfully", payment)
+}
+
+// ListPayments godoc
+// @Summary List the payments of an order
+// @Tags payments
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Success 200 {array} domain.Payment
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /api/v1/orders/:id/payments [get]
+func (h *PaymentHandler) ListPayments(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid order ID", err.Error())
+		return
+	}
+
+	payments, perr := h.s.ListPayments(c.Request.Context(), uint(id), requestActor(c))
+	if perr != nil {
+		response.Error(c, perr.Code, "Failed to list payments", perr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Payments retrieved successfully", payments)
+}
+
+// SyncPayment godoc
+// @Summary Sync a payment with the provider
+// @Description Fetch the state of a payment in progress from the provider and apply it to the order, such as after the customer completed 3-D Secure
+// @Tags payments
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Param payment_id path int true "Payment ID"
+// @Success 200 {object} domain.Payment
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 502 {object} response.ErrorResponse
+// @Router /api/v1/orders/:id/payments/:payment_id/sync [post]
+func (h *PaymentHandler) SyncPayment(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid order ID", err.Error())
+		return
+	}
+	paymentID, err := strconv.ParseUint(c.Param("payment_id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid payment ID", err.Error())
+		return
+	}
+
+	payment, perr := h.s.Sync(actorContext(c), uint(id), uint(paymentID), requestActor(c))
+	if perr != nil {
+		response.Error(c, perr.Code, "Failed to sync payment", perr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Payment synced successfully", payment)
+}
+
+// RefundPayment godoc
+// @Summary Refund a payment
+// @Description Refund some or all of a captured payment, the order is marked refunded once all of it is (admin only)
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Payment ID"
+// @Param refund body domain.RefundPaymentRequest false "Amount to refund, defaults to everything not refunded yet"
+// @Param Idempotency-Key header string false "Unique key that makes retries of the request return the first response"
+// @Success 200 {object} domain.Payment
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 502 {object} response.ErrorResponse
+// @Router /api/v1/payments/:id/refunds [post]
+func (h *PaymentHandler) RefundPayment(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid payment ID", err.Error())
+		return
+	}
+
+	var req domain.RefundPaymentRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+			return
+		}
+	}
+
+	payment, perr := h.s.Refund(actorContext(c), uint(id), req.Amount, "")
+	if perr != nil {
+		response.Error(c, perr.Code, "Failed to refund payment", perr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Payment refunded successfully", payment)
+}
+
+// ListWebhookEvents godoc
+// @Summary List payment webhook events
+// @Description List the latest webhook events received from the payment provider, newest first (admin only)
+// @Tags payments
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Only events with this status: received, processed, ignored or failed"
+// @Param limit query int false "Maximum number of events, 50 by default"
+// @Success 200 {array} domain.PaymentWebhookEvent
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Router /api/v1/payments/webhook-events [get]
+func (h *PaymentHandler) ListWebhookEvents(c *gin.Context) {
+	limit := 50
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > 500 {
+			response.Error(c, http.StatusBadRequest, "Invalid limit", "limit must be between 1 and 500")
+			return
+		}
+		limit = n
+	}
+
+	events, perr := h.s.ListWebhookEvents(c.Request.Context(), domain.WebhookEventStatus(c.Query("status")), limit)
+	if perr != nil {
+		response.Error(c, perr.Code, "Failed to list webhook events", perr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Webhook events retrieved successfully", events)
+}
+
+// ReplayWebhookEvent godoc
+// @Summary Replay a payment webhook event
+// @Description Apply a stored webhook event again, such as one that failed. Applying an event twice has no further effect (admin only)
+// @Tags payments
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Webhook event ID"
+// @Success 200 {object} domain.PaymentWebhookEvent
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /api/v1/payments/webhook-events/:id/replay [post]
+func (h *PaymentHandler) ReplayWebhookEvent(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid webhook event ID", err.Error())
+		return
+	}
+
+	event, perr := h.s.ReplayWebhookEvent(actorContext(c), uint(id))
+	if perr != nil {
+		response.Error(c, perr.Code, "Failed to replay webhook event", perr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Webhook event replayed successfully", event)
+}
+
+// RegisterRoutes registers payment-related routes
+func (h *PaymentHandler) RegisterRoutes() {
+	h.r.POST("/orders/:id/payments", middleware.Idempotency(h.idempotency), h.StartPayment)
+	h.r.GET("/orders/:id/payments", h.ListPayments)
+	h.r.POST("/orders/:id/payments/:payment_id/sync", h.SyncPayment)
+
+	adminRoutes := h.r.Group("/")
+	adminRoutes.Use(middleware.AdminMiddleware())
+	adminRoutes.POST("/payments/:id/refunds", middleware.Idempotency(h.idempotency), h.RefundPayment)
+	adminRoutes.GET("/payments/webhook-events", h.ListWebhookEvents)
+	adminRoutes.POST("/payments/webhook-events/:id/replay", h.ReplayWebhookEvent)
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/Dubjay18/ecom-api/internal/service"
+	"github.com/Dubjay18/ecom-api/pkg/common/response"
+	"github.com/gin-gonic/gin"
+)
+
+type PaymentWebhookHandler struct {
+	r *gin.RouterGroup
+	s *service.PaymentService
+}
+
+// NewPaymentWebhookHandler registers the endpoint the payment provider
+// posts webhooks to. Requests are verified by their signature, so r must
+// not require authentication.
+func NewPaymentWebhookHandler(r *gin.RouterGroup, s *service.PaymentService) *PaymentWebhookHandler {
+	handler := &PaymentWebhookHandler{
+		r: r,
+		s: s,
+	}
+	handler.RegisterRoutes()
+	return handler
+}
+
+// ReceiveWebhook godoc
+// @Summary Receive a payment provider webhook
+// @Description Endpoint for the payment provider's webhooks. The signature is verified, the event stored and its payment and order brought up to date. Redelivered events are acknowledged without being applied again, a failure asks the provider to retry.
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Param Stripe-Signature header string false "Signature of a Stripe webhook"
+// @Param X-Webhook-Signature header string false "Signature of a fake provider webhook"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.ErrorResponse "Invalid signature or payload"
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/v1/payments/webhook [post]
+func (h *PaymentWebhookHandler) ReceiveWebhook(c *gin.Context) {
+	payload, err := c.GetRawData()
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid webhook payload", err.Error())
+		return
+	}
+
+	if perr := h.s.HandleWebhook(c.Request.Context(), payload, c.Request.Header); perr != nil {
+		response.Error(c, perr.Code, "Failed to handle webhook", perr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Webhook received", nil)
+}
+
+// RegisterRoutes registers the payment webhook route
+func (h *PaymentWebhookHandler) RegisterRoutes() {
+	h.r.POST("/payments/webhook", h.ReceiveWebhook)
+}
@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/internal/repository"
+	"github.com/Dubjay18/ecom-api/internal/service"
+	"github.com/Dubjay18/ecom-api/pkg/money"
+	"github.com/Dubjay18/ecom-api/pkg/payment"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const webhookSecret = "whsec_test"
+
+// The fixture is a payment_intent.processing event for intent pi_3PmTest
+const webhookFixture = "../../pkg/payment/testdata/stripe_payment_intent_processing.json"
+
+type memTx struct{}
+
+func (memTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func (memTx) AfterCommit(_ context.Context, fn func()) {
+	fn()
+}
+
+type memWebhooks struct {
+	repository.PaymentWebhookRepository
+	events []domain.PaymentWebhookEvent
+}
+
+func (r *memWebhooks) Store(_ context.Context, event *domain.PaymentWebhookEvent) (bool, error) {
+	for _, e := range r.events {
+		if e.Provider == event.Provider && e.EventID == event.EventID {
+			return false, nil
+		}
+	}
+	event.ID = uint(len(r.events) + 1)
+	r.events = append(r.events, *event)
+	return true, nil
+}
+
+func (r *memWebhooks) GetByEventID(_ context.Context, provider, eventID string) (*domain.PaymentWebhookEvent, error) {
+	for _, e := range r.events {
+		if e.Provider == provider && e.EventID == eventID {
+			return &e, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memWebhooks) Update(_ context.Context, event *domain.PaymentWebhookEvent) error {
+	r.events[event.ID-1] = *event
+	return nil
+}
+
+type memPayments struct {
+	repository.PaymentRepository
+	payment *domain.Payment
+	updates int
+}
+
+func (r *memPayments) GetByProviderRef(_ context.Context, provider, ref string) (*domain.Payment, error) {
+	if r.payment.Provider != provider || r.payment.ProviderRef != ref {
+		return nil, gorm.ErrRecordNotFound
+	}
+	pay := *r.payment
+	return &pay, nil
+}
+
+func (r *memPayments) GetForUpdate(_ context.Context, id uint) (*domain.Payment, error) {
+	if r.payment.ID != id {
+		return nil, gorm.ErrRecordNotFound
+	}
+	pay := *r.payment
+	return &pay, nil
+}
+
+func (r *memPayments) Update(_ context.Context, pay *domain.Payment) error {
+	r.updates++
+	*r.payment = *pay
+	return nil
+}
+
+type webhookTest struct {
+	router   *gin.Engine
+	webhooks *memWebhooks
+	payments *memPayments
+	payload  []byte
+}
+
+func newWebhookTest(t *testing.T) *webhookTest {
+	t.Helper()
+	payload, err := os.ReadFile(webhookFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt := &webhookTest{
+		webhooks: &memWebhooks{},
+		payments: &memPayments{payment: &domain.Payment{
+			Base:        domain.Base{ID: 1},
+			OrderID:     1,
+			Provider:    "stripe",
+			ProviderRef: "pi_3PmTest",
+			Status:      domain.PaymentRecordAuthorized,
+			Amount:      money.New(5998, "USD"),
+			Currency:    "USD",
+		}},
+		payload: payload,
+	}
+	s := service.NewPaymentService(memTx{}, wt.payments, nil, nil, payment.NewStripe("sk_test", webhookSecret), wt.webhooks)
+
+	gin.SetMode(gin.TestMode)
+	wt.router = gin.New()
+	NewPaymentWebhookHandler(wt.router.Group("/api/v1"), s)
+	return wt
+}
+
+func (wt *webhookTest) deliver(payload []byte, signedAt time.Time, signed []byte) int {
+	timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(webhookSecret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(signed)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/payments/webhook", bytes.NewReader(payload))
+	req.Header.Set("Stripe-Signature", fmt.Sprintf("t=%s,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil))))
+	rec := httptest.NewRecorder()
+	wt.router.ServeHTTP(rec, req)
+	io.Copy(io.Discard, rec.Body)
+	return rec.Code
+}
+
+func TestWebhookValidSignatureIsApplied(t *testing.T) {
+	wt := newWebhookTest(t)
+	if code := wt.deliver(wt.payload, time.Now(), wt.payload); code != http.StatusOK {
+		t.Fatalf("webhook = %d, want 200", code)
+	}
+	if wt.payments.payment.Status != domain.PaymentRecordPending || wt.payments.updates != 1 {
+		t.Fatalf("payment %s after %d updates, want pending after 1", wt.payments.payment.Status, wt.payments.updates)
+	}
+	if len(wt.webhooks.events) != 1 || wt.webhooks.events[0].Status != domain.WebhookProcessed {
+		t.Fatalf("stored events = %+v, want one processed", wt.webhooks.events)
+	}
+}
+
+func TestWebhookRefusesBadSignatures(t *testing.T) {
+	tests := []struct {
+		name     string
+		signedAt time.Time
+		tamper   bool
+	}{
+		{"tampered body", time.Now(), true},
+		{"expired timestamp", time.Now().Add(-time.Hour), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wt := newWebhookTest(t)
+			body := wt.payload
+			if tt.tamper {
+				body = bytes.Replace(wt.payload, []byte("5998"), []byte("1"), 1)
+			}
+			if code := wt.deliver(body, tt.signedAt, wt.payload); code != http.StatusBadRequest {
+				t.Fatalf("webhook = %d, want 400", code)
+			}
+			if len(wt.webhooks.events) != 0 || wt.payments.updates != 0 {
+				t.Fatalf("refused webhook stored %d events and made %d updates", len(wt.webhooks.events), wt.payments.updates)
+			}
+		})
+	}
+}
+
+func TestWebhookReplayedEventIsProcessedOnce(t *testing.T) {
+	wt := newWebhookTest(t)
+	for i := 0; i < 3; i++ {
+		// Each redelivery is signed afresh, only the event id repeats
+		if code := wt.deliver(wt.payload, time.Now(), wt.payload); code != http.StatusOK {
+			t.Fatalf("delivery %d = %d, want 200", i+1, code)
+		}
+	}
+	if wt.payments.updates != 1 {
+		t.Fatalf("payment updated %d times, want once", wt.payments.updates)
+	}
+	if len(wt.webhooks.events) != 1 {
+		t.Fatalf("stored %d events, want 1", len(wt.webhooks.events))
+	}
+}
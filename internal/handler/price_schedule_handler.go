@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/internal/middleware"
+	"github.com/Dubjay18/ecom-api/internal/service"
+	"github.com/Dubjay18/ecom-api/pkg/common/response"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type PriceScheduleHandler struct {
+	r *gin.RouterGroup
+	s *service.PriceScheduleService
+}
+
+func NewPriceScheduleHandler(r *gin.RouterGroup, s *service.PriceScheduleService, secretKey string) *PriceScheduleHandler {
+	handler := &PriceScheduleHandler{
+		r: r.Group("", middleware.AuthMiddleware(secretKey)),
+		s: s,
+	}
+	handler.RegisterRoutes()
+	return handler
+}
+
+// GetPriceHistory godoc
+// @Summary Get product price history
+// @Description List every price change of a product, newest first. With at, returns the change that set the price in effect at that time (admin only)
+// @Tags pricing
+// @Produce json
+// @Security JWT
+// @Param id path int true "Product ID"
+// @Param at query string false "RFC3339 time to get the price at"
+// @Success 200 {array} domain.PriceChange
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /api/v1/products/{id}/price-history [get]
+func (h *PriceScheduleHandler) GetPriceHistory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid product ID", err.Error())
+		return
+	}
+
+	if at := c.Query("at"); at != "" {
+		t, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, "Invalid time, use RFC3339", err.Error())
+			return
+		}
+		change, perr := h.s.PriceAt(c.Request.Context(), uint(id), t)
+		if perr != nil {
+			response.Error(c, perr.Code, "Failed to get price", perr.Message)
+			return
+		}
+		response.Success(c, http.StatusOK, "Price retrieved successfully", change)
+		return
+	}
+
+	changes, perr := h.s.History(c.Request.Context(), uint(id))
+	if perr != nil {
+		response.Error(c, perr.Code, "Failed to get price history", perr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Price history retrieved successfully", changes)
+}
+
+// SchedulePrice godoc
+// @Summary Schedule a price change
+// @Description Plan a price change for a product. With ends_at it is a sale, the regular price is shown as compare_at_price until it ends and is then restored (admin only)
+// @Tags pricing
+// @Accept json
+// @Produce json
+// @Security JWT
+// @Param id path int true "Product ID"
+// @Param schedule body domain.CreateScheduledPriceRequest true "Scheduled price"
+// @Success 201 {object} domain.ScheduledPrice
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Router /api/v1/products/{id}/price-schedules [post]
+func (h *PriceScheduleHandler) SchedulePrice(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid product ID", err.Error())
+		return
+	}
+
+	var req domain.CreateScheduledPriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if verrs, ok := err.(validator.ValidationErrors); ok {
+			response.RenderBindingErrors(c, verrs)
+			return
+		}
+		response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+		return
+	}
+
+	schedule, perr := h.s.Schedule(c.Request.Context(), uint(id), c.GetUint("userID"), &req)
+	if perr != nil {
+		response.Error(c, perr.Code, "Failed to schedule price", perr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusCreated, "Price scheduled successfully", schedule)
+}
+
+// ListPriceSchedules godoc
+// @Summary List scheduled prices
+// @Description List the scheduled price changes of a product (admin only)
+// @Tags pricing
+// @Produce json
+// @Security JWT
+// @Param id path int true "Product ID"
+// @Success 200 {array} domain.ScheduledPrice
+// @Failure 400 {object} response.ErrorResponse
+// @Router /api/v1/products/{id}/price-schedules [get]
+func (h *PriceScheduleHandler) ListPriceSchedules(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid product ID", err.Error())
+		return
+	}
+
+	schedules, perr := h.s.ListSchedules(c.Request.Context(), uint(id))
+	if perr != nil {
+		response.Error(c, perr.Code, "Failed to list scheduled prices", perr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Scheduled prices retrieved successfully", schedules)
+}
+
+// CancelPriceSchedule godoc
+// @Summary Cancel a scheduled price
+// @Description Cancel a scheduled price change, a running sale ends immediately and the regular price is restored (admin only)
+// @Tags pricing
+// @Produce json
+// @Security JWT
+// @Param id path int true "Product ID"
+// @Param schedule_id path int true "Scheduled price ID"
+// @Success 200 {object} domain.ScheduledPrice
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Router /api/v1/products/{id}/price-schedules/{schedule_id} [delete]
+func (h *PriceScheduleHandler) CancelPriceSchedule(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid product ID", err.Error())
+		return
+	}
+	scheduleID, err := strconv.Atoi(c.Param("schedule_id"))
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid scheduled price ID", err.Error())
+		return
+	}
+
+	schedule, perr := h.s.Cancel(c.Request.Context(), uint(id), uint(scheduleID), c.GetUint("userID"))
+	if perr != nil {
+		response.Error(c, perr.Code, "Failed to cancel scheduled price", perr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Scheduled price cancelled successfully", schedule)
+}
+
+// RegisterRoutes registers price history and schedule routes
+func (h *PriceScheduleHandler) RegisterRoutes() {
+	adminRoutes := h.r.Group("/products/:id")
+	adminRoutes.Use(middleware.AdminMiddleware())
+	adminRoutes.GET("/price-history", h.GetPriceHistory)
+	adminRoutes.POST("/price-schedules", h.SchedulePrice)
+	adminRoutes.GET("/price-schedules", h.ListPriceSchedules)
+	adminRoutes.DELETE("/price-schedules/:schedule_id", h.CancelPriceSchedule)
+}
@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/internal/middleware"
+	"github.com/Dubjay18/ecom-api/internal/service"
+	"github.com/Dubjay18/ecom-api/pkg/common/response"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// currencyHeader selects the currency a request is priced in
+const currencyHeader = "X-Currency"
+
+// requestCurrency returns the currency asked for by the client, if any
+func requestCurrency(c *gin.Context) string {
+	return c.GetHeader(currencyHeader)
+}
+
+type PricingHandler struct {
+	r *gin.RouterGroup
+	s *service.PricingService
+}
+
+func NewPricingHandler(r *gin.RouterGroup, s *service.PricingService, secretKey string) *PricingHandler {
+	handler := &PricingHandler{
+		r: r.Group("", middleware.AuthMiddleware(secretKey)),
+		s: s,
+	}
+	handler.RegisterRoutes()
+	return handler
+}
+
+// ListExchangeRates godoc
+// @Summary List exchange rates
+// @Description List the rates from the base currency to every currency the catalog is sold in
+// @Tags pricing
+// @Produce json
+// @Security JWT
+// @Success 200 {array} domain.ExchangeRate
+// @Router /api/v1/exchange-rates [get]
+func (h *PricingHandler) ListExchangeRates(c *gin.Context) {
+	rates, perr := h.s.ListRates(c.Request.Context())
+	if perr != nil {
+		response.Error(c, perr.Code, "Failed to list exchange rates", perr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Exchange rates retrieved successfully", gin.H{
+		"base_currency": h.s.BaseCurrency(),
+		"rates":         rates,
+	})
+}
+
+// SetExchangeRate godoc
+// @Summary Set an exchange rate
+// @Description Set the rate from the base currency to a currency, products without an explicit price in it are converted with this rate (admin only)
+// @Tags pricing
+// @Accept json
+// @Produce json
+// @Security JWT
+// @Param currency path string true "ISO 4217 currency code"
+// @Param rate body domain.SetExchangeRateRequest true "Exchange rate"
+// @Success 200 {object} domain.ExchangeRate
+// @Failure 400 {object} response.ErrorResponse
+// @Router /api/v1/exchange-rates/{currency} [put]
+func (h *PricingHandler) SetExchangeRate(c *gin.Context) {
+	var req domain.SetExchangeRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if verrs, ok := err.(validator.ValidationErrors); ok {
+			response.RenderBindingErrors(c, verrs)
+			return
+		}
+		response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+		return
+	}
+
+	rate, perr := h.s.SetRate(c.Request.Context(), c.GetUint("userID"), c.Param("currency"), req.Rate)
+	if perr != nil {
+		response.Error(c, perr.Code, "Failed to set exchange rate", perr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Exchange rate set successfully", rate)
+}
+
+// ListProductPrices godoc
+// @Summary List product prices
+// @Description List the explicit prices of a product in other currencies (admin only)
+// @Tags pricing
+// @Produce json
+// @Security JWT
+// @Param id path int true "Product ID"
+// @Success 200 {array} domain.ProductPrice
+// @Failure 400 {object} response.ErrorResponse
+// @Router /api/v1/products/{id}/prices [get]
+func (h *PricingHandler) ListProductPrices(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid product ID", err.Error())
+		return
+	}
+
+	prices, perr := h.s.ListProductPrices(c.Request.Context(), uint(id))
+	if perr != nil {
+		response.Error(c, perr.Code, "Failed to list product prices", perr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Product prices retrieved successfully", prices)
+}
+
+// SetProductPrice godoc
+// @Summary Set a product price
+// @Description Set the explicit price of a product in a currency, it takes precedence over converting the base price (admin only)
+// @Tags pricing
+// @Accept json
+// @Produce json
+// @Security JWT
+// @Param id path int true "Product ID"
+// @Param currency path string true "ISO 4217 currency code"
+// @Param price body domain.SetProductPriceRequest true "Price"
+// @Success 200 {object} domain.ProductPrice
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /api/v1/products/{id}/prices/{currency} [put]
+func (h *PricingHandler) SetProductPrice(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid product ID", err.Error())
+		return
+	}
+
+	var req domain.SetProductPriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if verrs, ok := err.(validator.ValidationErrors); ok {
+			response.RenderBindingErrors(c, verrs)
+			return
+		}
+		response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+		return
+	}
+
+	price, perr := h.s.SetProductPrice(c.Request.Context(), uint(id), c.Param("currency"), req.Amount)
+	if perr != nil {
+		response.Error(c, perr.Code, "Failed to set product price", perr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Product price set successfully", price)
+}
+
+// DeleteProductPrice godoc
+// @Summary Delete a product price
+// @Description Remove the explicit price of a product in a currency, it is converted from the base price again (admin only)
+// @Tags pricing
+// @Produce json
+// @Security JWT
+// @Param id path int true "Product ID"
+// @Param currency path string true "ISO 4217 currency code"
+// @Success 200 {object} response.Response
+// @Failure 404 {object} response.ErrorResponse
+// @Router /api/v1/products/{id}/prices/{currency} [delete]
+func (h *PricingHandler) DeleteProductPrice(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid product ID", err.Error())
+		return
+	}
+
+	if perr := h.s.DeleteProductPrice(c.Request.Context(), uint(id), c.Param("currency")); perr != nil {
+		response.Error(c, perr.Code, "Failed to delete product price", perr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Product price deleted successfully", nil)
+}
+
+// RegisterRoutes registers pricing-related routes
+func (h *PricingHandler) RegisterRoutes() {
+	h.r.GET("/exchange-rates", h.ListExchangeRates)
+
+	adminRoutes := h.r.Group("/")
+	adminRoutes.Use(middleware.AdminMiddleware())
+	adminRoutes.PUT("/exchange-rates/:currency", h.SetExchangeRate)
+	adminRoutes.GET("/products/:id/prices", h.ListProductPrices)
+	adminRoutes.PUT("/products/:id/prices/:currency", h.SetProductPrice)
+	adminRoutes.DELETE("/products/:id/prices/:currency", h.DeleteProductPrice)
+}
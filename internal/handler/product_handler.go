@@ -1,15 +1,20 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/Dubjay18/ecom-api/internal/config"
 	"github.com/Dubjay18/ecom-api/internal/domain"
 	"github.com/Dubjay18/ecom-api/internal/middleware"
 	"github.com/Dubjay18/ecom-api/internal/service"
+	"github.com/Dubjay18/ecom-api/pkg/common"
 	"github.com/Dubjay18/ecom-api/pkg/common/response"
+	"github.com/Dubjay18/ecom-api/pkg/money"
 	"github.com/Dubjay18/ecom-api/pkg/upload"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
@@ -17,27 +22,40 @@ import (
 )
 
 type ProductHandler struct {
-	r      *gin.RouterGroup
-	s      *service.ProductService
-	logger *logrus.Logger
-	cf     config.APIKeysConfig
+	r       *gin.RouterGroup
+	s       *service.ProductService
+	csv     *service.ProductCSVService
+	pricing *service.PricingService
+	logger  *logrus.Logger
+	cf      config.APIKeysConfig
+	imports config.ImportsConfig
 }
 
-func NewProductHandler(r *gin.RouterGroup, s *service.ProductService, logger *logrus.Logger, secretKey string, cfg config.APIKeysConfig) {
+func NewProductHandler(r *gin.RouterGroup, s *service.ProductService, csv *service.ProductCSVService, pricing *service.PricingService, logger *logrus.Logger, secretKey string, cfg config.APIKeysConfig, imports config.ImportsConfig) {
 	handler := &ProductHandler{
-		r:      r,
-		s:      s,
-		logger: logger,
-		cf:     cfg,
+		r:       r,
+		s:       s,
+		csv:     csv,
+		pricing: pricing,
+		logger:  logger,
+		cf:      cfg,
+		imports: imports,
 	}
-	r.Use(middleware.AuthMiddleware(secretKey))
-	ar := r.Use(middleware.AdminMiddleware())
+	// Both groups are the handler's own, the api group is shared with
+	// routes that must stay open
+	authed := r.Group("", middleware.AuthMiddleware(secretKey))
+	authed.GET("/products", handler.ListProducts)
+	authed.GET("/products/:id", handler.GetProduct)
 
-	ar.GET("/products", handler.ListProducts)
+	ar := r.Group("", middleware.AuthMiddleware(secretKey), middleware.AdminMiddleware())
 	ar.POST("/products", handler.CreateProduct)
-	ar.GET("/products/:id", handler.GetProduct)
 	ar.PUT("/products/:id", handler.UpdateProduct)
 	ar.DELETE("/products/:id", handler.DeleteProduct)
+	ar.POST("/products/import", handler.ImportProducts)
+	ar.GET("/products/import/:id", handler.GetImportJob)
+	ar.GET("/products/export", handler.ExportProducts)
+	ar.GET("/products/archived", handler.ListArchivedProducts)
+	ar.POST("/products/:id/restore", handler.RestoreProduct)
 }
 
 // Create Product godoc
@@ -53,7 +71,9 @@ func NewProductHandler(r *gin.RouterGroup, s *service.ProductService, logger *lo
 // @Param stock formData int true "Product stock"
 // @Param sku formData string true "Product SKU"
 // @Param category formData string true "Product category"
-// @Param image formData file true "Product image"
+// @Param status formData string false "Lifecycle status, defaults to draft" Enums(draft, scheduled, published, discontinued)
+// @Param publish_at formData string false "RFC3339 publish time, required when scheduled"
+// @Param image formData file true "Product image (jpeg, png or gif, max 10MB and 6000x6000)"
 // @Success 201 {object} domain.Product
 // @Failure 400 {object} response.Response
 // @Router /api/v1/products [post]
@@ -78,23 +98,30 @@ func (h *ProductHandler) CreateProduct(c *gin.Context) {
 		return
 	}
 
-	imagePath, err := upload.UploadImage(c, file, h.cf.CloudinaryCloudName, h.cf.CloudinaryKey, h.cf.CloudinarySecret)
+	images, err := upload.UploadImage(c, file, h.cf.CloudinaryCloudName, h.cf.CloudinaryKey, h.cf.CloudinarySecret)
 	if err != nil {
-		h.logger.Error(err.Error())
-		response.Error(c, http.StatusBadRequest, "Failed to upload image", err.Error())
+		h.renderUploadError(c, err)
 		return
 	}
 
 	product := &domain.Product{
-		Name:     req.Name,
-		Price:    req.Price,
-		Stock:    req.Stock,
-		SKU:      req.SKU,
-		Category: req.Category,
-		ImageURL: imagePath,
+		Name:      req.Name,
+		Price:     req.Price,
+		Stock:     req.Stock,
+		SKU:       req.SKU,
+		Category:  req.Category,
+		Status:    req.Status,
+		PublishAt: req.PublishAt,
+
+		ReorderThreshold: req.ReorderThreshold,
+		BackorderPolicy:  req.BackorderPolicy,
+		BackorderLimit:   req.BackorderLimit,
+		PreOrder:         req.PreOrder,
+		AvailableAt:      req.AvailableAt,
 	}
+	setProductImages(product, images)
 
-	perr := h.s.Create(c.Request.Context(), product)
+	perr := h.s.Create(c.Request.Context(), product, stockChange(c, "product created"))
 	if perr != nil {
 		response.Error(c, perr.Code, perr.Message, perr.Error())
 		return
@@ -105,13 +132,14 @@ func (h *ProductHandler) CreateProduct(c *gin.Context) {
 
 // Get Product godoc
 // @Summary Get a product by ID
-// @Description Returns the product that matches the given ID
+// @Description Returns the product that matches the given ID, customers only see published products
 // @Tags products
 // @Accept json
 // @Produce json
 // @Security Bearer
 // @Security JWT
 // @Param id path int true "Product ID"
+// @Param X-Currency header string false "ISO 4217 currency to show display_price in, defaults to the user's preferred currency"
 // @Success 200 {object} domain.Product
 // @Failure 400 {object} response.Response
 // @Failure 404 {object} response.Response
@@ -123,12 +151,24 @@ func (h *ProductHandler) GetProduct(c *gin.Context) {
 		return
 	}
 
-	product, perr := h.s.GetByID(c.Request.Context(), uint(id))
+	getProduct := h.s.GetVisibleByID
+	if c.GetBool("isAdmin") {
+		getProduct = h.s.GetByID
+	}
+
+	product, perr := getProduct(c.Request.Context(), uint(id))
 	if perr != nil {
 		response.Error(c, perr.Code, perr.Message, perr.Error())
 		return
 	}
 
+	products := []domain.Product{*product}
+	if perr := h.localize(c, products); perr != nil {
+		response.Error(c, perr.Code, perr.Message, perr.Error())
+		return
+	}
+	product = &products[0]
+
 	response.Success(c, http.StatusOK, "Product retrieved successfully", product)
 }
 
@@ -143,10 +183,12 @@ func (h *ProductHandler) GetProduct(c *gin.Context) {
 // @Param id path int true "Product ID"
 // @Param name formData string false "Product name"
 // @Param price formData number false "Product price"
-// @Param stock formData int false "Product stock"
+// @Param stock formData int false "Product stock, 0 is a stock level and an omitted one is left as it is"
 // @Param sku formData string false "Product SKU"
 // @Param category formData string false "Product category"
-// @Param image formData file false "Product image"
+// @Param status formData string false "Lifecycle status" Enums(draft, scheduled, published, discontinued)
+// @Param publish_at formData string false "RFC3339 publish time, required when scheduled"
+// @Param image formData file false "Product image (jpeg, png or gif, max 10MB and 6000x6000)"
 // @Success 200 {object} domain.Product
 // @Failure 400 {object} response.Response
 // @Failure 404 {object} response.Response
@@ -158,7 +200,7 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 		return
 	}
 
-	var req domain.CreateProductRequest
+	var req domain.UpdateProductRequest
 	if err := c.ShouldBind(&req); err != nil {
 		if _, ok := err.(validator.ValidationErrors); ok {
 			response.RenderBindingErrors(c, err.(validator.ValidationErrors))
@@ -177,31 +219,49 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 	if req.Name != "" {
 		existingProduct.Name = req.Name
 	}
-	if req.Price != 0 {
+	if !req.Price.IsZero() {
 		existingProduct.Price = req.Price
 	}
-	if req.Stock != 0 {
-		existingProduct.Stock = req.Stock
-	}
 	if req.SKU != "" {
 		existingProduct.SKU = req.SKU
 	}
 	if req.Category != "" {
 		existingProduct.Category = req.Category
 	}
+	if req.Status != "" {
+		existingProduct.Status = req.Status
+	}
+	if req.PublishAt != nil {
+		existingProduct.PublishAt = req.PublishAt
+	}
+	// Zero turns alerts off, so only an omitted threshold keeps the old one
+	if _, ok := c.GetPostForm("reorder_threshold"); ok {
+		existingProduct.ReorderThreshold = req.ReorderThreshold
+	}
+	if req.BackorderPolicy != "" {
+		existingProduct.BackorderPolicy = req.BackorderPolicy
+	}
+	if _, ok := c.GetPostForm("backorder_limit"); ok {
+		existingProduct.BackorderLimit = req.BackorderLimit
+	}
+	if _, ok := c.GetPostForm("pre_order"); ok {
+		existingProduct.PreOrder = req.PreOrder
+	}
+	if req.AvailableAt != nil {
+		existingProduct.AvailableAt = req.AvailableAt
+	}
 
 	file, err := c.FormFile("image")
 	if err == nil {
-		imagePath, err := upload.UploadImage(c, file, h.cf.CloudinaryCloudName, h.cf.CloudinaryKey, h.cf.CloudinarySecret)
+		images, err := upload.UploadImage(c, file, h.cf.CloudinaryCloudName, h.cf.CloudinaryKey, h.cf.CloudinarySecret)
 		if err != nil {
-			h.logger.Error(err.Error())
-			response.Error(c, http.StatusBadRequest, "Failed to upload image", err.Error())
+			h.renderUploadError(c, err)
 			return
 		}
-		existingProduct.ImageURL = imagePath
+		setProductImages(existingProduct, images)
 	}
 
-	perr = h.s.Update(c.Request.Context(), existingProduct)
+	perr = h.s.Update(c.Request.Context(), existingProduct, req.Stock, stockChange(c, "product updated"))
 	if perr != nil {
 		response.Error(c, perr.Code, perr.Message, perr.Error())
 		return
@@ -211,8 +271,8 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 }
 
 // Delete Product godoc
-// @Summary Delete a product
-// @Description Deletes a product by ID
+// @Summary Archive a product
+// @Description Archives a product by ID, it stays resolvable from past orders
 // @Tags products
 // @Security Bearer
 // @Security JWT
@@ -236,12 +296,59 @@ func (h *ProductHandler) DeleteProduct(c *gin.Context) {
 		return
 	}
 
-	response.Success(c, http.StatusOK, "Product deleted successfully", nil)
+	response.Success(c, http.StatusOK, "Product archived successfully", nil)
+}
+
+// List Archived Products godoc
+// @Summary List archived products
+// @Description Lists products that have been archived
+// @Tags products
+// @Security Bearer
+// @Security JWT
+// @Produce json
+// @Success 200 {array} domain.Product
+// @Router /api/v1/products/archived [get]
+func (h *ProductHandler) ListArchivedProducts(c *gin.Context) {
+	products, perr := h.s.ListArchived(c.Request.Context())
+	if perr != nil {
+		response.Error(c, perr.Code, perr.Message, perr.Error())
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Archived products retrieved successfully", products)
+}
+
+// Restore Product godoc
+// @Summary Restore an archived product
+// @Description Restores an archived product to the catalog
+// @Tags products
+// @Security Bearer
+// @Security JWT
+// @Produce json
+// @Param id path int true "Product ID"
+// @Success 200 {object} domain.Product
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /api/v1/products/{id}/restore [post]
+func (h *ProductHandler) RestoreProduct(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid product ID", err.Error())
+		return
+	}
+
+	product, perr := h.s.Restore(c.Request.Context(), uint(id))
+	if perr != nil {
+		response.Error(c, perr.Code, perr.Message, perr.Error())
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Product restored successfully", product)
 }
 
 // List Products godoc
 // @Summary List products
-// @Description Lists products with optional filtering
+// @Description Lists products with optional filtering, customers only see published products
 // @Tags products
 // @Security Bearer
 // @Security JWT
@@ -250,26 +357,196 @@ func (h *ProductHandler) DeleteProduct(c *gin.Context) {
 // @Param name query string false "Product name"
 // @Param min_price query number false "Minimum price"
 // @Param max_price query number false "Maximum price"
+// @Param status query string false "Comma separated lifecycle statuses (admin only)"
+// @Param sort query string false "Sort order" Enums(newest, price_asc, price_desc, rating)
+// @Param X-Currency header string false "ISO 4217 currency to show display_price in, defaults to the user's preferred currency"
 // @Success 200 {array} domain.Product
 // @Failure 400 {object} response.Response
 // @Router /api/v1/products [get]
 func (h *ProductHandler) ListProducts(c *gin.Context) {
-	var filter domain.ProductFilter
-	filter.Name = c.Query("name")
-	filter.MinPrice = parseFloat64(c.Query("min_price"))
-	filter.MaxPrice = parseFloat64(c.Query("max_price"))
+	filter := productFilterFromQuery(c)
 
 	products, perr := h.s.List(c.Request.Context(), filter)
 	if perr != nil {
 		response.Error(c, perr.Code, perr.Message, perr.Error())
 		return
 	}
+	if perr := h.localize(c, products); perr != nil {
+		response.Error(c, perr.Code, perr.Message, perr.Error())
+		return
+	}
 
 	response.Success(c, http.StatusOK, "Products retrieved successfully", products)
 }
 
-func parseFloat64(s string) float64 {
-	v, _ := strconv.ParseFloat(s, 64)
+// Import Products godoc
+// @Summary Import products from CSV
+// @Description Upserts products by SKU from a CSV file in the background. Columns: sku, name, description, price, currency, stock, category, image_url, status, publish_at. Prices are in the base currency, currency defaults to it
+// @Tags products
+// @Accept multipart/form-data
+// @Produce json
+// @Security Bearer
+// @Security JWT
+// @Param file formData file true "Product CSV file"
+// @Param dry_run query bool false "Validate rows without saving"
+// @Success 202 {object} domain.ImportJob
+// @Failure 400 {object} response.Response
+// @Failure 413 {object} response.Response
+// @Router /api/v1/products/import [post]
+func (h *ProductHandler) ImportProducts(c *gin.Context) {
+	// The form is read before the file can be looked at, so its size is
+	// capped while it is. The multipart framing needs a little room.
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.imports.MaxFileSize+1<<20)
+	file, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			response.Error(c, http.StatusRequestEntityTooLarge, "CSV file is too large", err.Error())
+			return
+		}
+		response.Error(c, http.StatusBadRequest, "CSV file is required", "csv file is required")
+		return
+	}
+	if file.Size > h.imports.MaxFileSize {
+		response.Error(c, http.StatusRequestEntityTooLarge, "CSV file is too large", fmt.Sprintf("csv file is larger than %d bytes", h.imports.MaxFileSize))
+		return
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		h.logger.Error(err.Error())
+		response.Error(c, http.StatusBadRequest, "Failed to read CSV file", err.Error())
+		return
+	}
+	defer src.Close()
+
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+	job, perr := h.csv.StartImport(c.Request.Context(), c.GetUint("userID"), src, dryRun)
+	if perr != nil {
+		response.Error(c, perr.Code, perr.Message, perr.Error())
+		return
+	}
+
+	response.Success(c, http.StatusAccepted, "Product import started", job)
+}
+
+// Get Import Job godoc
+// @Summary Get a product import job
+// @Description Returns the progress and per-row errors of a product import
+// @Tags products
+// @Produce json
+// @Security Bearer
+// @Security JWT
+// @Param id path int true "Import job ID"
+// @Success 200 {object} domain.ImportJob
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Router /api/v1/products/import/{id} [get]
+func (h *ProductHandler) GetImportJob(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid import job ID", err.Error())
+		return
+	}
+
+	job, perr := h.csv.GetImportJob(c.Request.Context(), uint(id))
+	if perr != nil {
+		response.Error(c, perr.Code, perr.Message, perr.Error())
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Import job retrieved successfully", job)
+}
+
+// Export Products godoc
+// @Summary Export products as CSV
+// @Description Streams the catalog as CSV with the same filters as List Products
+// @Tags products
+// @Produce text/csv
+// @Security Bearer
+// @Security JWT
+// @Param name query string false "Product name"
+// @Param min_price query number false "Minimum price"
+// @Param max_price query number false "Maximum price"
+// @Param status query string false "Comma separated lifecycle statuses"
+// @Success 200 {file} file
+// @Router /api/v1/products/export [get]
+func (h *ProductHandler) ExportProducts(c *gin.Context) {
+	filter := productFilterFromQuery(c)
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", `attachment; filename="products.csv"`)
+	c.Status(http.StatusOK)
+
+	// Headers are already sent, so failures can only be logged
+	if err := h.csv.Export(c.Request.Context(), filter, c.Writer); err != nil {
+		h.logger.Error(err.Error())
+	}
+}
+
+// productFilterFromQuery builds a product filter from the query string.
+// Customers are always limited to published products.
+func productFilterFromQuery(c *gin.Context) domain.ProductFilter {
+	var filter domain.ProductFilter
+	filter.Name = c.Query("name")
+	filter.MinPrice = parseMoney(c.Query("min_price"))
+	filter.MaxPrice = parseMoney(c.Query("max_price"))
+	filter.Sort = domain.ProductSort(c.Query("sort"))
+
+	if !c.GetBool("isAdmin") {
+		filter.Statuses = []domain.ProductStatus{domain.ProductPublished}
+		return filter
+	}
+	if status := c.Query("status"); status != "" {
+		for _, st := range strings.Split(status, ",") {
+			filter.Statuses = append(filter.Statuses, domain.ProductStatus(strings.TrimSpace(st)))
+		}
+	}
+	return filter
+}
+
+// stockChange attributes a stock change made through the product endpoints
+// to the calling admin
+func stockChange(c *gin.Context, reason string) domain.StockChange {
+	userID := c.GetUint("userID")
+	change := domain.StockChange{
+		Type:   domain.MovementAdjustment,
+		UserID: &userID,
+		Reason: reason,
+	}
+	if id := c.Param("id"); id != "" {
+		change.Reference = "product:" + id
+	}
+	return change
+}
+
+func (h *ProductHandler) renderUploadError(c *gin.Context, err error) {
+	h.logger.Error(err.Error())
+	if upload.IsValidationError(err) {
+		response.Error(c, http.StatusBadRequest, "Invalid image", err.Error())
+		return
+	}
+	response.Error(c, http.StatusInternalServerError, "Failed to upload image", err.Error())
+}
+
+// localize prices products in the currency of the request
+func (h *ProductHandler) localize(c *gin.Context, products []domain.Product) *common.AppError {
+	currency, perr := h.pricing.ResolveCurrency(c.Request.Context(), requestCurrency(c), c.GetUint("userID"))
+	if perr != nil {
+		return perr
+	}
+	return h.pricing.Localize(c.Request.Context(), products, currency)
+}
+
+func setProductImages(product *domain.Product, images *upload.ImageURLs) {
+	product.ImageURL = images.Large
+	product.ThumbnailURL = images.Thumbnail
+	product.MediumURL = images.Medium
+	product.LargeURL = images.Large
+}
+
+func parseMoney(s string) money.Money {
+	v, _ := money.Parse(s, money.DefaultCurrency)
 	return v
 }
 
@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/internal/middleware"
+	"github.com/Dubjay18/ecom-api/internal/service"
+	"github.com/Dubjay18/ecom-api/pkg/common/response"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type PromotionHandler struct {
+	r *gin.RouterGroup
+	s *service.PromotionService
+}
+
+func NewPromotionHandler(r *gin.RouterGroup, s *service.PromotionService, secretKey string) *PromotionHandler {
+	handler := &PromotionHandler{
+		r: r.Group("", middleware.AuthMiddleware(secretKey)),
+		s: s,
+	}
+	handler.RegisterRoutes()
+	return handler
+}
+
+// CreatePromotion godoc
+// @Summary Create a promotion
+// @Description Create a coupon, or an automatic promotion when no code is given. Amounts are in the base currency (admin only)
+// @Tags promotions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param promotion body domain.PromotionRequest true "Promotion details"
+// @Success 201 {object} domain.Promotion
+// @Example JSON Response - Success
+//
+//	{
+//	  "status": 201,
+//	  "message": "Promotion created successfully",
+//	  "data": {
+//	    "id": 1,
+//	    "name": "Spring sale",
+//	    "code": "SPRING10",
+//	    "type": "percent_off",
+//	    "percent_off": 10,
+//	    "categories": ["shoes"],
+//	    "usage_limit": 500,
+//	    "per_user_limit": 1,
+//	    "usage_count": 0,
+//	    "ends_at": "2024-04-30T23:59:59Z",
+//	    "active": true
+//	  }
+//	}
+//
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse "A coupon with the code already exists"
+// @Router /api/v1/promotions [post]
+func (h *PromotionHandler) CreatePromotion(c *gin.Context) {
+	var req domain.PromotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if verrs, ok := err.(validator.ValidationErrors); ok {
+			response.RenderBindingErrors(c, verrs)
+			return
+		}
+		response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+		return
+	}
+
+	promotion, perr := h.s.Create(c.Request.Context(), &req)
+	if perr != nil {
+		response.Error(c, perr.Code, "Failed to create promotion", perr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusCreated, "Promotion created successfully", promotion)
+}
+
+// ListPromotions godoc
+// @Summary List promotions
+// @Description List every coupon and automatic promotion with its usage (admin only)
+// @Tags promotions
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} domain.Promotion
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Router /api/v1/promotions [get]
+func (h *PromotionHandler) ListPromotions(c *gin.Context) {
+	promotions, perr := h.s.List(c.Request.Context())
+	if perr != nil {
+		response.Error(c, perr.Code, "Failed to list promotions", perr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Promotions retrieved successfully", promotions)
+}
+
+// GetPromotion godoc
+// @Summary Get a promotion
+// @Tags promotions
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Promotion ID"
+// @Success 200 {object} domain.Promotion
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /api/v1/promotions/:id [get]
+func (h *PromotionHandler) GetPromotion(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid promotion ID", err.Error())
+		return
+	}
+
+	promotion, perr := h.s.Get(c.Request.Context(), uint(id))
+	if perr != nil {
+		response.Error(c, perr.Code, "Failed to get promotion", perr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Promotion retrieved successfully", promotion)
+}
+
+// UpdatePromotion godoc
+// @Summary Update a promotion
+// @Description Replace the settings of a promotion, its usage count is kept. Set active to false to end it early (admin only)
+// @Tags promotions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Promotion ID"
+// @Param promotion body domain.PromotionRequest true "Promotion details"
+// @Success 200 {object} domain.Promotion
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse "A coupon with the code already exists"
+// @Router /api/v1/promotions/:id [put]
+func (h *PromotionHandler) UpdatePromotion(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid promotion ID", err.Error())
+		return
+	}
+
+	var req domain.PromotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if verrs, ok := err.(validator.ValidationErrors); ok {
+			response.RenderBindingErrors(c, verrs)
+			return
+		}
+		response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+		return
+	}
+
+	promotion, perr := h.s.Update(c.Request.Context(), uint(id), &req)
+	if perr != nil {
+		response.Error(c, perr.Code, "Failed to update promotion", perr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Promotion updated successfully", promotion)
+}
+
+// RegisterRoutes registers promotion routes, all of them for admins
+func (h *PromotionHandler) RegisterRoutes() {
+	adminRoutes := h.r.Group("/promotions")
+	adminRoutes.Use(middleware.AdminMiddleware())
+	adminRoutes.POST("", h.CreatePromotion)
+	adminRoutes.GET("", h.ListPromotions)
+	adminRoutes.GET("/:id", h.GetPromotion)
+	adminRoutes.PUT("/:id", h.UpdatePromotion)
+}
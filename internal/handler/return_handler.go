@@ -0,0 +1,326 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/internal/middleware"
+	"github.com/Dubjay18/ecom-api/internal/service"
+	"github.com/Dubjay18/ecom-api/pkg/common/response"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type ReturnHandler struct {
+	r           *gin.RouterGroup
+	s           *service.ReturnService
+	idempotency *service.IdempotencyService
+}
+
+func NewReturnHandler(r *gin.RouterGroup, s *service.ReturnService, idempotency *service.IdempotencyService, secretKey string) *ReturnHandler {
+	handler := &ReturnHandler{
+		r:           r.Group("", middleware.AuthMiddleware(secretKey)),
+		s:           s,
+		idempotency: idempotency,
+	}
+	handler.RegisterRoutes()
+	return handler
+}
+
+// RequestReturn godoc
+// @Summary Request a return
+// @Description Ask to send back items of a delivered order for a refund
+// @Tags returns
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Param return body domain.CreateReturnRequest true "Items to return and why"
+// @Success 201 {object} domain.Return
+// @Example JSON Response - Success
+//
+//	{
+//	  "status": 201,
+//	  "message": "Return requested successfully",
+//	  "data": {
+//	    "id": 1,
+//	    "order_id": 7,
+//	    "user_id": 42,
+//	    "status": "requested",
+//	    "reason": "Wrong size",
+//	    "items": [
+//	      {"order_item_id": 12, "quantity": 1, "received_quantity": 0, "restocked": 0}
+//	    ]
+//	  }
+//	}
+//
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse "The order has not been delivered"
+// @Router /api/v1/orders/:id/returns [post]
+func (h *ReturnHandler) RequestReturn(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid order ID", err.Error())
+		return
+	}
+
+	var req domain.CreateReturnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if verrs, ok := err.(validator.ValidationErrors); ok {
+			response.RenderBindingErrors(c, verrs)
+			return
+		}
+		response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+		return
+	}
+
+	ret, rerr := h.s.Request(actorContext(c), uint(id), requestActor(c), &req)
+	if rerr != nil {
+		response.Error(c, rerr.Code, "Failed to request return", rerr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusCreated, "Return requested successfully", ret)
+}
+
+// ListOrderReturns godoc
+// @Summary List the returns of an order
+// @Tags returns
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Success 200 {array} domain.Return
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /api/v1/orders/:id/returns [get]
+func (h *ReturnHandler) ListOrderReturns(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid order ID", err.Error())
+		return
+	}
+
+	returns, rerr := h.s.ListByOrder(c.Request.Context(), uint(id), requestActor(c))
+	if rerr != nil {
+		response.Error(c, rerr.Code, "Failed to list returns", rerr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Returns retrieved successfully", returns)
+}
+
+// GetReturn godoc
+// @Summary Get a return
+// @Description Get a return with its items, customers can only get returns of their own orders
+// @Tags returns
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Return ID"
+// @Success 200 {object} domain.Return
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /api/v1/returns/:id [get]
+func (h *ReturnHandler) GetReturn(c *gin.Context) {
+	id, ok := returnID(c)
+	if !ok {
+		return
+	}
+
+	ret, rerr := h.s.Get(c.Request.Context(), id, requestActor(c))
+	if rerr != nil {
+		response.Error(c, rerr.Code, "Failed to get return", rerr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Return retrieved successfully", ret)
+}
+
+// ListReturns godoc
+// @Summary List returns
+// @Description List every return oldest first, such as the requested ones waiting for a decision (admin only)
+// @Tags returns
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Only returns with this status: requested, approved, rejected, received or refunded"
+// @Success 200 {array} domain.Return
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Router /api/v1/returns [get]
+func (h *ReturnHandler) ListReturns(c *gin.Context) {
+	returns, rerr := h.s.List(c.Request.Context(), domain.ReturnStatus(c.Query("status")))
+	if rerr != nil {
+		response.Error(c, rerr.Code, "Failed to list returns", rerr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Returns retrieved successfully", returns)
+}
+
+// ApproveReturn godoc
+// @Summary Approve a return
+// @Description Accept a requested return, the customer can send the items back (admin only)
+// @Tags returns
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Return ID"
+// @Success 200 {object} domain.Return
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Router /api/v1/returns/:id/approve [post]
+func (h *ReturnHandler) ApproveReturn(c *gin.Context) {
+	id, ok := returnID(c)
+	if !ok {
+		return
+	}
+
+	ret, rerr := h.s.Approve(actorContext(c), id)
+	if rerr != nil {
+		response.Error(c, rerr.Code, "Failed to approve return", rerr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Return approved successfully", ret)
+}
+
+// RejectReturn godoc
+// @Summary Reject a return
+// @Description Refuse a requested return (admin only)
+// @Tags returns
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Return ID"
+// @Param rejection body domain.RejectReturnRequest true "Why the return is rejected"
+// @Success 200 {object} domain.Return
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Router /api/v1/returns/:id/reject [post]
+func (h *ReturnHandler) RejectReturn(c *gin.Context) {
+	id, ok := returnID(c)
+	if !ok {
+		return
+	}
+
+	var req domain.RejectReturnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+		return
+	}
+
+	ret, rerr := h.s.Reject(actorContext(c), id, req.Reason)
+	if rerr != nil {
+		response.Error(c, rerr.Code, "Failed to reject return", rerr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Return rejected successfully", ret)
+}
+
+// ReceiveReturn godoc
+// @Summary Receive a return
+// @Description Check in the items of an approved return that arrived back, restocking the ones marked for it. Items not listed are taken as not received (admin only)
+// @Tags returns
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Return ID"
+// @Param receipt body domain.ReceiveReturnRequest true "Units received per return item"
+// @Success 200 {object} domain.Return
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Router /api/v1/returns/:id/receive [post]
+func (h *ReturnHandler) ReceiveReturn(c *gin.Context) {
+	id, ok := returnID(c)
+	if !ok {
+		return
+	}
+
+	var req domain.ReceiveReturnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if verrs, ok := err.(validator.ValidationErrors); ok {
+			response.RenderBindingErrors(c, verrs)
+			return
+		}
+		response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+		return
+	}
+
+	ret, rerr := h.s.Receive(actorContext(c), id, &req)
+	if rerr != nil {
+		response.Error(c, rerr.Code, "Failed to receive return", rerr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Return received successfully", ret)
+}
+
+// RefundReturn godoc
+// @Summary Refund a return
+// @Description Refund a return through the order's payment, each line at the price paid for it: the units received, or all units of an approved return that was not sent back (admin only)
+// @Tags returns
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Return ID"
+// @Param Idempotency-Key header string false "Unique key that makes retries of the request return the first response"
+// @Success 200 {object} domain.Return
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 502 {object} response.ErrorResponse "The payment provider failed"
+// @Router /api/v1/returns/:id/refund [post]
+func (h *ReturnHandler) RefundReturn(c *gin.Context) {
+	id, ok := returnID(c)
+	if !ok {
+		return
+	}
+
+	ret, rerr := h.s.Refund(actorContext(c), id)
+	if rerr != nil {
+		response.Error(c, rerr.Code, "Failed to refund return", rerr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Return refunded successfully", ret)
+}
+
+// returnID reads the return ID path parameter, responding with an error if
+// it is invalid
+func returnID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid return ID", err.Error())
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// RegisterRoutes registers return-related routes
+func (h *ReturnHandler) RegisterRoutes() {
+	h.r.POST("/orders/:id/returns", middleware.Idempotency(h.idempotency), h.RequestReturn)
+	h.r.GET("/orders/:id/returns", h.ListOrderReturns)
+	h.r.GET("/returns/:id", h.GetReturn)
+
+	adminRoutes := h.r.Group("/")
+	adminRoutes.Use(middleware.AdminMiddleware())
+	adminRoutes.GET("/returns", h.ListReturns)
+	adminRoutes.POST("/returns/:id/approve", h.ApproveReturn)
+	adminRoutes.POST("/returns/:id/reject", h.RejectReturn)
+	adminRoutes.POST("/returns/:id/receive", h.ReceiveReturn)
+	adminRoutes.POST("/returns/:id/refund", middleware.Idempotency(h.idempotency), h.RefundReturn)
+}
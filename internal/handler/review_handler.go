@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/internal/middleware"
+	"github.com/Dubjay18/ecom-api/internal/service"
+	"github.com/Dubjay18/ecom-api/pkg/common/response"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type ReviewHandler struct {
+	r *gin.RouterGroup
+	s *service.ReviewService
+}
+
+func NewReviewHandler(r *gin.RouterGroup, s *service.ReviewService, secretKey string) *ReviewHandler {
+	handler := &ReviewHandler{
+		r: r.Group("", middleware.AuthMiddleware(secretKey)),
+		s: s,
+	}
+	handler.RegisterRoutes()
+	return handler
+}
+
+// CreateReview godoc
+// @Summary Review a product
+// @Description Submit a 1-5 star review for moderation, requires a delivered order containing the product
+// @Tags reviews
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Param review body domain.CreateReviewRequest true "Review"
+// @Success 201 {object} domain.Review
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Router /api/v1/products/{id}/reviews [post]
+func (h *ReviewHandler) CreateReview(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid product ID", err.Error())
+		return
+	}
+
+	var req domain.CreateReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if verrs, ok := err.(validator.ValidationErrors); ok {
+			response.RenderBindingErrors(c, verrs)
+			return
+		}
+		response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+		return
+	}
+
+	review, rerr := h.s.Create(c.Request.Context(), c.GetUint("userID"), uint(productID), &req)
+	if rerr != nil {
+		response.Error(c, rerr.Code, "Failed to create review", rerr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusCreated, "Review submitted for moderation", review)
+}
+
+// ListProductReviews godoc
+// @Summary List product reviews
+// @Description List the approved reviews of a product
+// @Tags reviews
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Success 200 {array} domain.Review
+// @Failure 400 {object} response.ErrorResponse
+// @Router /api/v1/products/{id}/reviews [get]
+func (h *ReviewHandler) ListProductReviews(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid product ID", err.Error())
+		return
+	}
+
+	reviews, rerr := h.s.ListApproved(c.Request.Context(), uint(productID))
+	if rerr != nil {
+		response.Error(c, rerr.Code, "Failed to list reviews", rerr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Reviews retrieved successfully", reviews)
+}
+
+// ListReviews godoc
+// @Summary List reviews for moderation
+// @Description List reviews in a moderation state (admin only)
+// @Tags reviews
+// @Produce json
+// @Security JWT
+// @Param status query string false "Moderation status, defaults to pending" Enums(pending, approved, rejected)
+// @Success 200 {array} domain.Review
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Router /api/v1/reviews [get]
+func (h *ReviewHandler) ListReviews(c *gin.Context) {
+	status := domain.ReviewStatus(c.DefaultQuery("status", string(domain.ReviewPending)))
+
+	reviews, rerr := h.s.ListByStatus(c.Request.Context(), status)
+	if rerr != nil {
+		response.Error(c, rerr.Code, "Failed to list reviews", rerr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Reviews retrieved successfully", reviews)
+}
+
+// ModerateReview godoc
+// @Summary Moderate a review
+// @Description Approve or reject a review (admin only)
+// @Tags reviews
+// @Accept json
+// @Produce json
+// @Security JWT
+// @Param id path int true "Review ID"
+// @Param status body domain.ModerateReviewRequest true "New moderation status"
+// @Success 200 {object} domain.Review
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /api/v1/reviews/{id}/status [put]
+func (h *ReviewHandler) ModerateReview(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid review ID", err.Error())
+		return
+	}
+
+	var req domain.ModerateReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if verrs, ok := err.(validator.ValidationErrors); ok {
+			response.RenderBindingErrors(c, verrs)
+			return
+		}
+		response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+		return
+	}
+
+	review, rerr := h.s.Moderate(c.Request.Context(), uint(id), req.Status)
+	if rerr != nil {
+		response.Error(c, rerr.Code, "Failed to moderate review", rerr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Review moderated successfully", review)
+}
+
+// RegisterRoutes registers review-related routes
+func (h *ReviewHandler) RegisterRoutes() {
+	h.r.POST("/products/:id/reviews", h.CreateReview)
+	h.r.GET("/products/:id/reviews", h.ListProductReviews)
+
+	adminRoutes := h.r.Group("/")
+	adminRoutes.Use(middleware.AdminMiddleware())
+	adminRoutes.GET("/reviews", h.ListReviews)
+	adminRoutes.PUT("/reviews/:id/status", h.ModerateReview)
+}
@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/internal/middleware"
+	"github.com/Dubjay18/ecom-api/internal/service"
+	"github.com/Dubjay18/ecom-api/pkg/common/response"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type ShipmentHandler struct {
+	r *gin.RouterGroup
+	s *service.ShipmentService
+}
+
+func NewShipmentHandler(r *gin.RouterGroup, s *service.ShipmentService, secretKey string) *ShipmentHandler {
+	handler := &ShipmentHandler{
+		r: r.Group("", middleware.AuthMiddleware(secretKey)),
+		s: s,
+	}
+	handler.RegisterRoutes()
+	return handler
+}
+
+// CreateShipment godoc
+// @Summary Ship order items
+// @Description Ship items of a confirmed or partially shipped order with a carrier. Only units in stock that have not shipped yet can ship, the order becomes partially shipped or shipped (admin only)
+// @Tags shipments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Param shipment body domain.CreateShipmentRequest true "Carrier, tracking number and shipped items"
+// @Success 201 {object} domain.Shipment
+// @Example JSON Response - Success
+//
+//	{
+//	  "status": 201,
+//	  "message": "Shipment created successfully",
+//	  "data": {
+//	    "id": 3,
+//	    "order_id": 7,
+//	    "status": "shipped",
+//	    "carrier": "UPS",
+//	    "tracking_number": "1Z999AA10123456784",
+//	    "tracking_url": "https://www.ups.com/track?tracknum=1Z999AA10123456784",
+//	    "items": [
+//	      {"order_item_id": 12, "quantity": 2}
+//	    ],
+//	    "shipped_at": "2024-03-01T10:00:00Z"
+//	  }
+//	}
+//
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse "The order cannot ship"
+// @Router /api/v1/orders/:id/shipments [post]
+func (h *ShipmentHandler) CreateShipment(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid order ID", err.Error())
+		return
+	}
+
+	var req domain.CreateShipmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if verrs, ok := err.(validator.ValidationErrors); ok {
+			response.RenderBindingErrors(c, verrs)
+			return
+		}
+		response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+		return
+	}
+
+	shipment, serr := h.s.Create(actorContext(c), uint(id), &req)
+	if serr != nil {
+		response.Error(c, serr.Code, "Failed to create shipment", errorBody(serr))
+		return
+	}
+
+	response.Success(c, http.StatusCreated, "Shipment created successfully", shipment)
+}
+
+// ListOrderShipments godoc
+// @Summary List the shipments of an order
+// @Description List the shipments of an order with their carriers and tracking numbers, customers can only list shipments of their own orders
+// @Tags shipments
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Success 200 {array} domain.Shipment
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /api/v1/orders/:id/shipments [get]
+func (h *ShipmentHandler) ListOrderShipments(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid order ID", err.Error())
+		return
+	}
+
+	shipments, serr := h.s.ListByOrder(c.Request.Context(), uint(id), requestActor(c))
+	if serr != nil {
+		response.Error(c, serr.Code, "Failed to list shipments", serr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Shipments retrieved successfully", shipments)
+}
+
+// UpdateShipment godoc
+// @Summary Update the tracking details of a shipment
+// @Description Correct the carrier, tracking number or tracking URL of a shipment, unset fields are left alone (admin only)
+// @Tags shipments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Shipment ID"
+// @Param shipment body domain.UpdateShipmentRequest true "Tracking details"
+// @Success 200 {object} domain.Shipment
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /api/v1/shipments/:id [patch]
+func (h *ShipmentHandler) UpdateShipment(c *gin.Context) {
+	id, ok := shipmentID(c)
+	if !ok {
+		return
+	}
+
+	var req domain.UpdateShipmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+		return
+	}
+
+	shipment, serr := h.s.Update(actorContext(c), id, &req)
+	if serr != nil {
+		response.Error(c, serr.Code, "Failed to update shipment", serr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Shipment updated successfully", shipment)
+}
+
+// DeliverShipment godoc
+// @Summary Mark a shipment as delivered
+// @Description Record that the carrier delivered a shipment. The order is delivered once all of its items have shipped and every shipment has been delivered (admin only)
+// @Tags shipments
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Shipment ID"
+// @Success 200 {object} domain.Shipment
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse "The shipment has already been delivered"
+// @Router /api/v1/shipments/:id/deliver [post]
+func (h *ShipmentHandler) DeliverShipment(c *gin.Context) {
+	id, ok := shipmentID(c)
+	if !ok {
+		return
+	}
+
+	shipment, serr := h.s.Deliver(actorContext(c), id)
+	if serr != nil {
+		response.Error(c, serr.Code, "Failed to deliver shipment", errorBody(serr))
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Shipment delivered successfully", shipment)
+}
+
+// shipmentID reads the shipment ID path parameter, responding with an
+// error if it is invalid
+func shipmentID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid shipment ID", err.Error())
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// RegisterRoutes registers shipment-related routes
+func (h *ShipmentHandler) RegisterRoutes() {
+	h.r.GET("/orders/:id/shipments", h.ListOrderShipments)
+
+	adminRoutes := h.r.Group("/")
+	adminRoutes.Use(middleware.AdminMiddleware())
+	adminRoutes.POST("/orders/:id/shipments", h.CreateShipment)
+	adminRoutes.PATCH("/shipments/:id", h.UpdateShipment)
+	adminRoutes.POST("/shipments/:id/deliver", h.DeliverShipment)
+}
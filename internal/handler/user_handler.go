@@ -16,13 +16,15 @@ import (
 type UserHandler struct {
 	r      *gin.RouterGroup
 	s      service.UserService
+	carts  *service.CartService
 	logger *logrus.Logger
 }
 
-func NewUserHandler(r *gin.RouterGroup, s service.UserService, logger *logrus.Logger, jwtSecret string) {
+func NewUserHandler(r *gin.RouterGroup, s service.UserService, carts *service.CartService, logger *logrus.Logger, jwtSecret string) {
 	handler := &UserHandler{
 		r:      r,
 		s:      s,
+		carts:  carts,
 		logger: logger,
 	}
 
@@ -38,6 +40,7 @@ func NewUserHandler(r *gin.RouterGroup, s service.UserService, logger *logrus.Lo
 	{
 		users.GET("/me", handler.GetProfile)
 		// users.PUT("/me", handler.UpdateProfile)
+		users.PUT("/me/currency", handler.UpdateCurrency)
 	}
 }
 
@@ -84,6 +87,7 @@ func (h *UserHandler) Register(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param credentials body domain.LoginRequest true "Login credentials"
+// @Param X-Cart-Token header string false "Anonymous cart to merge into the user's cart"
 // @Success 200 {object} domain.LoginResponse
 // @Failure 400 {object} response.ErrorResponse
 // @Failure 401 {object} response.ErrorResponse
@@ -100,6 +104,14 @@ func (h *UserHandler) Login(c *gin.Context) {
 		response.Error(c, err.Code, "Invalid credentials", err.Error())
 		return
 	}
+
+	// A failed merge leaves the anonymous cart in place, it is retried on the
+	// next cart request that sends the token
+	if token := c.GetHeader(cartTokenHeader); token != "" {
+		if err := h.carts.Merge(c.Request.Context(), token, resp.User.ID); err != nil {
+			h.logger.Error(err)
+		}
+	}
 	c.JSON(http.StatusOK, resp)
 }
 
@@ -123,6 +135,37 @@ func (h *UserHandler) GetProfile(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
+// UpdateCurrency godoc
+// @Summary Set preferred currency
+// @Description Set the currency the catalog and orders are priced in for the authenticated user, an empty currency resets it to the base currency
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param currency body domain.UpdateCurrencyRequest true "Preferred currency"
+// @Success 200 {object} domain.User
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Router /users/me/currency [put]
+func (h *UserHandler) UpdateCurrency(c *gin.Context) {
+	var req domain.UpdateCurrencyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if verrs, ok := err.(validator.ValidationErrors); ok {
+			response.RenderBindingErrors(c, verrs)
+			return
+		}
+		response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+		return
+	}
+
+	user, err := h.s.UpdatePreferredCurrency(c.Request.Context(), c.GetUint("userID"), req.Currency)
+	if err != nil {
+		response.Error(c, err.Code, err.Message, err.Error())
+		return
+	}
+	response.Success(c, http.StatusOK, "Preferred currency updated successfully", user)
+}
+
 // RegisterAdmin godoc
 // @Summary Register new admin
 // @Description Register a new admin with email and password
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/internal/middleware"
+	"github.com/Dubjay18/ecom-api/internal/service"
+	"github.com/Dubjay18/ecom-api/pkg/common/response"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type WarehouseHandler struct {
+	r *gin.RouterGroup
+	s *service.WarehouseService
+}
+
+func NewWarehouseHandler(r *gin.RouterGroup, s *service.WarehouseService, secretKey string) *WarehouseHandler {
+	handler := &WarehouseHandler{
+		r: r.Group("", middleware.AuthMiddleware(secretKey)),
+		s: s,
+	}
+	handler.RegisterRoutes()
+	return handler
+}
+
+// CreateWarehouse godoc
+// @Summary Create a warehouse
+// @Description Add a warehouse that can hold stock and fulfil orders (admin only)
+// @Tags warehouses
+// @Accept json
+// @Produce json
+// @Security JWT
+// @Param warehouse body domain.CreateWarehouseRequest true "Warehouse"
+// @Success 201 {object} domain.Warehouse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Router /api/v1/warehouses [post]
+func (h *WarehouseHandler) CreateWarehouse(c *gin.Context) {
+	var req domain.CreateWarehouseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if verrs, ok := err.(validator.ValidationErrors); ok {
+			response.RenderBindingErrors(c, verrs)
+			return
+		}
+		response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+		return
+	}
+
+	warehouse, werr := h.s.Create(c.Request.Context(), &req)
+	if werr != nil {
+		response.Error(c, werr.Code, "Failed to create warehouse", werr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusCreated, "Warehouse created successfully", warehouse)
+}
+
+// ListWarehouses godoc
+// @Summary List warehouses
+// @Description List every warehouse (admin only)
+// @Tags warehouses
+// @Produce json
+// @Security JWT
+// @Success 200 {array} domain.Warehouse
+// @Router /api/v1/warehouses [get]
+func (h *WarehouseHandler) ListWarehouses(c *gin.Context) {
+	warehouses, werr := h.s.List(c.Request.Context())
+	if werr != nil {
+		response.Error(c, werr.Code, "Failed to list warehouses", werr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Warehouses retrieved successfully", warehouses)
+}
+
+// UpdateWarehouse godoc
+// @Summary Update a warehouse
+// @Description Update a warehouse's details, activate or deactivate it or make it the default (admin only)
+// @Tags warehouses
+// @Accept json
+// @Produce json
+// @Security JWT
+// @Param id path int true "Warehouse ID"
+// @Param warehouse body domain.UpdateWarehouseRequest true "Warehouse changes"
+// @Success 200 {object} domain.Warehouse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /api/v1/warehouses/{id} [put]
+func (h *WarehouseHandler) UpdateWarehouse(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid warehouse ID", err.Error())
+		return
+	}
+
+	var req domain.UpdateWarehouseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if verrs, ok := err.(validator.ValidationErrors); ok {
+			response.RenderBindingErrors(c, verrs)
+			return
+		}
+		response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+		return
+	}
+
+	warehouse, werr := h.s.Update(c.Request.Context(), uint(id), &req)
+	if werr != nil {
+		response.Error(c, werr.Code, "Failed to update warehouse", werr.Message)
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Warehouse updated successfully", warehouse)
+}
+
+// RegisterRoutes registers warehouse-related routes
+func (h *WarehouseHandler) RegisterRoutes() {
+	adminRoutes := h.r.Group("/warehouses")
+	adminRoutes.Use(middleware.AdminMiddleware())
+	adminRoutes.POST("", h.CreateWarehouse)
+	adminRoutes.GET("", h.ListWarehouses)
+	adminRoutes.PUT("/:id", h.UpdateWarehouse)
+}
@@ -16,7 +16,7 @@ func AdminMiddleware() gin.HandlerFunc {
 		}
 
 		// Check if user is admin
-		if IsAdmin.(bool) {
+		if !IsAdmin.(bool) {
 			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin access required"})
 			return
 		}
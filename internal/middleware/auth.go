@@ -77,3 +77,16 @@ func AuthMiddleware(secretKey string) gin.HandlerFunc {
 		}
 	}
 }
+
+// OptionalAuthMiddleware authenticates requests that carry an Authorization
+// header and lets anonymous requests through without a userID
+func OptionalAuthMiddleware(secretKey string) gin.HandlerFunc {
+	auth := AuthMiddleware(secretKey)
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.Next()
+			return
+		}
+		auth(c)
+	}
+}
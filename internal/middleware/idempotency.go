@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/Dubjay18/ecom-api/internal/service"
+	"github.com/Dubjay18/ecom-api/pkg/common/response"
+	"github.com/gin-gonic/gin"
+)
+
+// IdempotencyKeyHeader names the header clients set to make a request safe
+// to retry
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotencyReplayedHeader is set on responses replayed for a retry
+const IdempotencyReplayedHeader = "Idempotent-Replayed"
+
+// Idempotency makes requests carrying an Idempotency-Key header run once per
+// user and key. Retries get the stored response of the first request, a
+// retry while it is still running is a 409 and reusing the key for a
+// different request a 422. Requests without the header run as usual. It
+// must come after AuthMiddleware.
+func Idempotency(s *service.IdempotencyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		userID := c.GetUint("userID")
+		if key == "" || userID == 0 {
+			c.Next()
+			return
+		}
+		if len(key) > 255 {
+			response.Error(c, http.StatusBadRequest, "Invalid idempotency key", "Idempotency-Key must be at most 255 characters")
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, "Invalid input", err.Error())
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		record, aerr := s.Begin(c.Request.Context(), userID, key, fingerprint(c.Request, body))
+		if aerr != nil {
+			response.Error(c, aerr.Code, "Idempotency key conflict", aerr.Message)
+			return
+		}
+		if record.IsComplete() {
+			c.Header(IdempotencyReplayedHeader, "true")
+			c.Data(record.StatusCode, record.ContentType, record.ResponseBody)
+			c.Abort()
+			return
+		}
+
+		writer := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		// The outcome must be stored even if the client has gone away, or
+		// its retry would run the request again
+		ctx := context.WithoutCancel(c.Request.Context())
+		status := writer.Status()
+		if status >= http.StatusInternalServerError {
+			// Failures of the server are not results, the retry runs again
+			err = s.Release(ctx, record)
+		} else {
+			err = s.Complete(ctx, record, status, writer.Header().Get("Content-Type"), writer.body.Bytes())
+		}
+		if err != nil {
+			log.Printf("Failed to store idempotency key %q of user %d: %v", key, userID, err)
+		}
+	}
+}
+
+// fingerprint identifies a request by its method, URI and body
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recordingWriter keeps a copy of the response body it writes
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CartRepository interface {
+	Create(ctx context.Context, cart *domain.Cart) error
+	// GetByUser returns a user's cart with its items and their products
+	GetByUser(ctx context.Context, userID uint) (*domain.Cart, error)
+	// GetByToken returns an anonymous cart with its items and their products
+	GetByToken(ctx context.Context, token string) (*domain.Cart, error)
+	// AssignToUser turns an anonymous cart into the user's cart
+	AssignToUser(ctx context.Context, cartID, userID uint) error
+	Delete(ctx context.Context, cartID uint) error
+	// Touch marks a cart as recently used
+	Touch(ctx context.Context, cartID uint) error
+
+	SaveItem(ctx context.Context, item *domain.CartItem) error
+	DeleteItem(ctx context.Context, cartID, productID uint) (int64, error)
+	ClearItems(ctx context.Context, cartID uint) error
+
+	// PurgeAnonymous deletes anonymous carts unused since before
+	PurgeAnonymous(ctx context.Context, before time.Time) (int64, error)
+}
+
+type cartRepository struct {
+	DB *gorm.DB
+}
+
+func NewCartRepository(db *gorm.DB) CartRepository {
+	return &cartRepository{DB: db}
+}
+
+func (r *cartRepository) Create(ctx context.Context, cart *domain.Cart) error {
+	return conn(ctx, r.DB).Create(cart).Error
+}
+
+func (r *cartRepository) GetByUser(ctx context.Context, userID uint) (*domain.Cart, error) {
+	return r.get(ctx, "user_id = ?", userID)
+}
+
+func (r *cartRepository) GetByToken(ctx context.Context, token string) (*domain.Cart, error) {
+	return r.get(ctx, "token = ? AND user_id IS NULL", token)
+}
+
+func (r *cartRepository) get(ctx context.Context, query string, args ...interface{}) (*domain.Cart, error) {
+	cart := &domain.Cart{}
+	err := conn(ctx, r.DB).
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Items.Product").
+		Where(query, args...).First(cart).Error
+	if err != nil {
+		return nil, err
+	}
+	return cart, nil
+}
+
+func (r *cartRepository) AssignToUser(ctx context.Context, cartID, userID uint) error {
+	return conn(ctx, r.DB).Model(&domain.Cart{}).Where("id = ?", cartID).
+		Updates(map[string]interface{}{"user_id": userID, "token": nil, "updated_at": time.Now()}).Error
+}
+
+func (r *cartRepository) Delete(ctx context.Context, cartID uint) error {
+	db := conn(ctx, r.DB)
+	if err := db.Where("cart_id = ?", cartID).Delete(&domain.CartItem{}).Error; err != nil {
+		return err
+	}
+	return db.Delete(&domain.Cart{}, cartID).Error
+}
+
+func (r *cartRepository) Touch(ctx context.Context, cartID uint) error {
+	return conn(ctx, r.DB).Model(&domain.Cart{}).Where("id = ?", cartID).
+		Update("updated_at", time.Now()).Error
+}
+
+// SaveItem sets the quantity of a product in a cart, adding the item if
+// the cart does not have it yet
+func (r *cartRepository) SaveItem(ctx context.Context, item *domain.CartItem) error {
+	return conn(ctx, r.DB).Omit(clause.Associations).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "cart_id"}, {Name: "product_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"quantity", "updated_at"}),
+	}).Create(item).Error
+}
+
+func (r *cartRepository) DeleteItem(ctx context.Context, cartID, productID uint) (int64, error) {
+	result := conn(ctx, r.DB).Where("cart_id = ? AND product_id = ?", cartID, productID).Delete(&domain.CartItem{})
+	return result.RowsAffected, result.Error
+}
+
+func (r *cartRepository) ClearItems(ctx context.Context, cartID uint) error {
+	return conn(ctx, r.DB).Where("cart_id = ?", cartID).Delete(&domain.CartItem{}).Error
+}
+
+func (r *cartRepository) PurgeAnonymous(ctx context.Context, before time.Time) (int64, error) {
+	db := conn(ctx, r.DB)
+	stale := db.Model(&domain.Cart{}).Select("id").Where("user_id IS NULL AND updated_at < ?", before)
+	if err := db.Where("cart_id IN (?)", stale).Delete(&domain.CartItem{}).Error; err != nil {
+		return 0, err
+	}
+	result := db.Where("user_id IS NULL AND updated_at < ?", before).Delete(&domain.Cart{})
+	return result.RowsAffected, result.Error
+}
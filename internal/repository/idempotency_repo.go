@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdempotencyRepository interface {
+	// Claim stores key as in flight unless an unexpired record of the same
+	// user and key exists, it reports whether the key was claimed
+	Claim(ctx context.Context, key *domain.IdempotencyKey) (bool, error)
+	Get(ctx context.Context, userID uint, key string) (*domain.IdempotencyKey, error)
+	// Complete stores the response of a claimed key and keeps it until
+	// expiresAt
+	Complete(ctx context.Context, id uint, statusCode int, contentType string, body []byte, expiresAt time.Time) error
+	Delete(ctx context.Context, id uint) error
+	PurgeExpired(ctx context.Context, before time.Time) (int64, error)
+}
+
+type idempotencyRepository struct {
+	DB *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) IdempotencyRepository {
+	return &idempotencyRepository{DB: db}
+}
+
+func (r *idempotencyRepository) Claim(ctx context.Context, key *domain.IdempotencyKey) (bool, error) {
+	// An expired record is taken over as if it did not exist
+	result := conn(ctx, r.DB).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"fingerprint", "status_code", "content_type", "response_body", "expires_at", "created_at", "updated_at",
+		}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Lt{Column: clause.Column{Table: "idempotency_keys", Name: "expires_at"}, Value: time.Now()},
+		}},
+	}).Create(key)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *idempotencyRepository) Get(ctx context.Context, userID uint, key string) (*domain.IdempotencyKey, error) {
+	record := &domain.IdempotencyKey{}
+	err := conn(ctx, r.DB).Where("user_id = ? AND key = ?", userID, key).First(record).Error
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (r *idempotencyRepository) Complete(ctx context.Context, id uint, statusCode int, contentType string, body []byte, expiresAt time.Time) error {
+	return conn(ctx, r.DB).Model(&domain.IdempotencyKey{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status_code":   statusCode,
+		"content_type":  contentType,
+		"response_body": body,
+		"expires_at":    expiresAt,
+	}).Error
+}
+
+func (r *idempotencyRepository) Delete(ctx context.Context, id uint) error {
+	return conn(ctx, r.DB).Delete(&domain.IdempotencyKey{}, id).Error
+}
+
+func (r *idempotencyRepository) PurgeExpired(ctx context.Context, before time.Time) (int64, error) {
+	result := conn(ctx, r.DB).Where("expires_at < ?", before).Delete(&domain.IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"gorm.io/gorm"
+)
+
+type ImportJobRepository interface {
+	Create(ctx context.Context, job *domain.ImportJob) error
+	GetByID(ctx context.Context, id uint) (*domain.ImportJob, error)
+	Update(ctx context.Context, job *domain.ImportJob) error
+}
+
+type importJobRepository struct {
+	DB *gorm.DB
+}
+
+func NewImportJobRepository(db *gorm.DB) ImportJobRepository {
+	return &importJobRepository{DB: db}
+}
+
+func (r *importJobRepository) Create(ctx context.Context, job *domain.ImportJob) error {
+	return conn(ctx, r.DB).Create(job).Error
+}
+
+func (r *importJobRepository) GetByID(ctx context.Context, id uint) (*domain.ImportJob, error) {
+	job := &domain.ImportJob{}
+	err := conn(ctx, r.DB).First(job, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (r *importJobRepository) Update(ctx context.Context, job *domain.ImportJob) error {
+	return conn(ctx, r.DB).Save(job).Error
+}
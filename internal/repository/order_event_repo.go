@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"gorm.io/gorm"
+)
+
+type OrderEventRepository interface {
+	Create(ctx context.Context, event *domain.OrderEvent) error
+	// ListByOrder returns the events of an order oldest first, internal
+	// events only if includeInternal is set
+	ListByOrder(ctx context.Context, orderID uint, includeInternal bool) ([]domain.OrderEvent, error)
+}
+
+type orderEventRepository struct {
+	DB *gorm.DB
+}
+
+func NewOrderEventRepository(db *gorm.DB) OrderEventRepository {
+	return &orderEventRepository{DB: db}
+}
+
+func (r *orderEventRepository) Create(ctx context.Context, event *domain.OrderEvent) error {
+	return conn(ctx, r.DB).Create(event).Error
+}
+
+func (r *orderEventRepository) ListByOrder(ctx context.Context, orderID uint, includeInternal bool) ([]domain.OrderEvent, error) {
+	var events []domain.OrderEvent
+	query := conn(ctx, r.DB).Where("order_id = ?", orderID)
+	if !includeInternal {
+		query = query.Where("internal = ?", false)
+	}
+	err := query.Order("occurred_at, id").Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
@@ -5,15 +5,33 @@ import (
 
 	"github.com/Dubjay18/ecom-api/internal/domain"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type OrderRepository interface {
-	BeginTx(ctx context.Context) *gorm.DB
 	Create(ctx context.Context, order *domain.Order) error
 	GetByID(ctx context.Context, id uint) (*domain.Order, error)
+	// GetDetail returns an order with its items, their products, the
+	// shipping address, the discounts and the shipments
+	GetDetail(ctx context.Context, id uint) (*domain.Order, error)
+	// GetForUpdate returns an order with its items and locks it until the
+	// transaction ends, so status changes are made one at a time
+	GetForUpdate(ctx context.Context, id uint) (*domain.Order, error)
+	// GetForUpdateSkipLocked is GetForUpdate for an order nobody else has
+	// locked, it returns gorm.ErrRecordNotFound if someone has
+	GetForUpdateSkipLocked(ctx context.Context, id uint) (*domain.Order, error)
 	Update(ctx context.Context, order *domain.Order) error
 	List(ctx context.Context, userID uint) ([]domain.Order, error)
 	CreatAddress(ctx context.Context, address *domain.Address) error
+	HasDeliveredProduct(ctx context.Context, userID, productID uint) (bool, error)
+	GetAddress(ctx context.Context, id uint) (*domain.Address, error)
+	UpdateItem(ctx context.Context, item *domain.OrderItem) error
+	// UpdateItemAllocation saves only the backordered quantity and the
+	// warehouse of an item
+	UpdateItemAllocation(ctx context.Context, item *domain.OrderItem) error
+	// ListBackorderedItems returns the items of open orders waiting for stock
+	// of a product, oldest first
+	ListBackorderedItems(ctx context.Context, productID uint) ([]domain.OrderItem, error)
 }
 
 type orderRepository struct {
@@ -21,12 +39,52 @@ type orderRepository struct {
 }
 
 func (r *orderRepository) Create(ctx context.Context, order *domain.Order) error {
-	return r.DB.WithContext(ctx).Create(order).Error
+	return conn(ctx, r.DB).Create(order).Error
 }
 
 func (r *orderRepository) GetByID(ctx context.Context, id uint) (*domain.Order, error) {
 	order := &domain.Order{}
-	err := r.DB.WithContext(ctx).First(order, id).Error
+	err := conn(ctx, r.DB).Preload("Items").First(order, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+func (r *orderRepository) GetDetail(ctx context.Context, id uint) (*domain.Order, error) {
+	order := &domain.Order{}
+	err := conn(ctx, r.DB).
+		Preload("Items.Product", func(db *gorm.DB) *gorm.DB {
+			// Archived products must still resolve from past orders
+			return db.Unscoped()
+		}).
+		Preload("ShippingAddress").
+		Preload("Discounts", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id")
+		}).
+		Preload("Shipments", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id")
+		}).
+		Preload("Shipments.Items").
+		First(order, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+func (r *orderRepository) GetForUpdate(ctx context.Context, id uint) (*domain.Order, error) {
+	order := &domain.Order{}
+	err := conn(ctx, r.DB).Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items").First(order, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+func (r *orderRepository) GetForUpdateSkipLocked(ctx context.Context, id uint) (*domain.Order, error) {
+	order := &domain.Order{}
+	err := conn(ctx, r.DB).Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).Preload("Items").First(order, id).Error
 	if err != nil {
 		return nil, err
 	}
@@ -34,12 +92,19 @@ func (r *orderRepository) GetByID(ctx context.Context, id uint) (*domain.Order,
 }
 
 func (r *orderRepository) Update(ctx context.Context, order *domain.Order) error {
-	return r.DB.WithContext(ctx).Model(order).Updates(order).Error
+	// Items are written once when the order is placed
+	return conn(ctx, r.DB).Model(order).Omit(clause.Associations).Updates(order).Error
 }
 
 func (r *orderRepository) List(ctx context.Context, userID uint) ([]domain.Order, error) {
 	var orders []domain.Order
-	err := r.DB.WithContext(ctx).Where("user_id = ?", userID).Find(&orders).Error
+	err := conn(ctx, r.DB).
+		Preload("Items.Product", func(db *gorm.DB) *gorm.DB {
+			// Archived products must still resolve from past orders
+			return db.Unscoped()
+		}).
+		Where("user_id = ?", userID).
+		Find(&orders).Error
 	if err != nil {
 		return nil, err
 	}
@@ -47,13 +112,57 @@ func (r *orderRepository) List(ctx context.Context, userID uint) ([]domain.Order
 }
 
 func (r *orderRepository) CreatAddress(ctx context.Context, address *domain.Address) error {
-	return r.DB.WithContext(ctx).Create(address).Error
+	return conn(ctx, r.DB).Create(address).Error
+}
+
+// HasDeliveredProduct reports whether the user has a delivered order
+// containing the product
+func (r *orderRepository) HasDeliveredProduct(ctx context.Context, userID, productID uint) (bool, error) {
+	var count int64
+	err := conn(ctx, r.DB).Model(&domain.Order{}).
+		Joins("JOIN order_items ON order_items.order_id = orders.id").
+		Where("orders.user_id = ? AND orders.status = ? AND order_items.product_id = ?", userID, domain.StatusDelivered, productID).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
 }
 
 func NewOrderRepository(db *gorm.DB) OrderRepository {
 	return &orderRepository{DB: db}
 }
 
-func (r *orderRepository) BeginTx(ctx context.Context) *gorm.DB {
-	return r.DB.WithContext(ctx).Begin()
+func (r *orderRepository) GetAddress(ctx context.Context, id uint) (*domain.Address, error) {
+	address := &domain.Address{}
+	err := conn(ctx, r.DB).First(address, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return address, nil
+}
+
+func (r *orderRepository) UpdateItem(ctx context.Context, item *domain.OrderItem) error {
+	return conn(ctx, r.DB).Omit(clause.Associations).Save(item).Error
+}
+
+func (r *orderRepository) UpdateItemAllocation(ctx context.Context, item *domain.OrderItem) error {
+	return conn(ctx, r.DB).Model(item).UpdateColumns(map[string]any{
+		"backordered_quantity": item.BackorderedQuantity,
+		"warehouse_id":         item.WarehouseID,
+	}).Error
+}
+
+func (r *orderRepository) ListBackorderedItems(ctx context.Context, productID uint) ([]domain.OrderItem, error) {
+	var items []domain.OrderItem
+	err := conn(ctx, r.DB).
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("order_items.product_id = ? AND order_items.backordered_quantity > 0", productID).
+		Where("orders.status <> ?", domain.StatusCancelled).
+		Order("order_items.id").
+		Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
 }
@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PaymentRepository interface {
+	Create(ctx context.Context, payment *domain.Payment) error
+	GetByID(ctx context.Context, id uint) (*domain.Payment, error)
+	// GetForUpdate returns a payment and locks it until the transaction
+	// ends, so results from the provider are applied one at a time
+	GetForUpdate(ctx context.Context, id uint) (*domain.Payment, error)
+	GetByProviderRef(ctx context.Context, provider, ref string) (*domain.Payment, error)
+	ListByOrder(ctx context.Context, orderID uint) ([]domain.Payment, error)
+	// HasActive reports whether an order has a payment in progress
+	HasActive(ctx context.Context, orderID uint) (bool, error)
+	Update(ctx context.Context, payment *domain.Payment) error
+
+	CreateRefund(ctx context.Context, refund *domain.PaymentRefund) error
+	// GetRefundForUpdate returns a refund and locks it until the
+	// transaction ends, lock its payment first
+	GetRefundForUpdate(ctx context.Context, id uint) (*domain.PaymentRefund, error)
+	GetRefundByReference(ctx context.Context, paymentID uint, reference string) (*domain.PaymentRefund, error)
+	// ListRefunds returns the refunds of a payment in one status
+	ListRefunds(ctx context.Context, paymentID uint, status domain.RefundStatus) ([]domain.PaymentRefund, error)
+	UpdateRefund(ctx context.Context, refund *domain.PaymentRefund) error
+}
+
+type paymentRepository struct {
+	DB *gorm.DB
+}
+
+func NewPaymentRepository(db *gorm.DB) PaymentRepository {
+	return &paymentRepository{DB: db}
+}
+
+func (r *paymentRepository) Create(ctx context.Context, payment *domain.Payment) error {
+	return conn(ctx, r.DB).Create(payment).Error
+}
+
+func (r *paymentRepository) GetByID(ctx context.Context, id uint) (*domain.Payment, error) {
+	payment := &domain.Payment{}
+	err := conn(ctx, r.DB).First(payment, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+func (r *paymentRepository) GetForUpdate(ctx context.Context, id uint) (*domain.Payment, error) {
+	payment := &domain.Payment{}
+	err := conn(ctx, r.DB).Clauses(clause.Locking{Strength: "UPDATE"}).First(payment, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+func (r *paymentRepository) GetByProviderRef(ctx context.Context, provider, ref string) (*domain.Payment, error) {
+	payment := &domain.Payment{}
+	err := conn(ctx, r.DB).Where("provider = ? AND provider_ref = ?", provider, ref).First(payment).Error
+	if err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+func (r *paymentRepository) ListByOrder(ctx context.Context, orderID uint) ([]domain.Payment, error) {
+	var payments []domain.Payment
+	err := conn(ctx, r.DB).Where("order_id = ?", orderID).Order("id").Find(&payments).Error
+	if err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+func (r *paymentRepository) HasActive(ctx context.Context, orderID uint) (bool, error) {
+	var count int64
+	err := conn(ctx, r.DB).Model(&domain.Payment{}).
+		Where("order_id = ? AND status IN ?", orderID, []domain.PaymentRecordStatus{domain.PaymentRecordPending, domain.PaymentRecordAuthorized}).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *paymentRepository) Update(ctx context.Context, payment *domain.Payment) error {
+	return conn(ctx, r.DB).Save(payment).Error
+}
+
+func (r *paymentRepository) CreateRefund(ctx context.Context, refund *domain.PaymentRefund) error {
+	return conn(ctx, r.DB).Create(refund).Error
+}
+
+func (r *paymentRepository) GetRefundForUpdate(ctx context.Context, id uint) (*domain.PaymentRefund, error) {
+	refund := &domain.PaymentRefund{}
+	err := conn(ctx, r.DB).Clauses(clause.Locking{Strength: "UPDATE"}).First(refund, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+func (r *paymentRepository) GetRefundByReference(ctx context.Context, paymentID uint, reference string) (*domain.PaymentRefund, error) {
+	refund := &domain.PaymentRefund{}
+	err := conn(ctx, r.DB).Where("payment_id = ? AND reference = ?", paymentID, reference).First(refund).Error
+	if err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+func (r *paymentRepository) ListRefunds(ctx context.Context, paymentID uint, status domain.RefundStatus) ([]domain.PaymentRefund, error) {
+	var refunds []domain.PaymentRefund
+	err := conn(ctx, r.DB).Where("payment_id = ? AND status = ?", paymentID, status).Order("id").Find(&refunds).Error
+	if err != nil {
+		return nil, err
+	}
+	return refunds, nil
+}
+
+func (r *paymentRepository) UpdateRefund(ctx context.Context, refund *domain.PaymentRefund) error {
+	return conn(ctx, r.DB).Save(refund).Error
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PaymentWebhookRepository interface {
+	// Store saves a received event unless the provider sent it before, it
+	// reports whether the event is new
+	Store(ctx context.Context, event *domain.PaymentWebhookEvent) (bool, error)
+	GetByID(ctx context.Context, id uint) (*domain.PaymentWebhookEvent, error)
+	GetByEventID(ctx context.Context, provider, eventID string) (*domain.PaymentWebhookEvent, error)
+	// List returns events newest first, of one status if status is set
+	List(ctx context.Context, status domain.WebhookEventStatus, limit int) ([]domain.PaymentWebhookEvent, error)
+	Update(ctx context.Context, event *domain.PaymentWebhookEvent) error
+}
+
+type paymentWebhookRepository struct {
+	DB *gorm.DB
+}
+
+func NewPaymentWebhookRepository(db *gorm.DB) PaymentWebhookRepository {
+	return &paymentWebhookRepository{DB: db}
+}
+
+func (r *paymentWebhookRepository) Store(ctx context.Context, event *domain.PaymentWebhookEvent) (bool, error) {
+	result := conn(ctx, r.DB).Clauses(clause.OnConflict{DoNothing: true}).Create(event)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *paymentWebhookRepository) GetByID(ctx context.Context, id uint) (*domain.PaymentWebhookEvent, error) {
+	event := &domain.PaymentWebhookEvent{}
+	err := conn(ctx, r.DB).First(event, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+func (r *paymentWebhookRepository) GetByEventID(ctx context.Context, provider, eventID string) (*domain.PaymentWebhookEvent, error) {
+	event := &domain.PaymentWebhookEvent{}
+	err := conn(ctx, r.DB).Where("provider = ? AND event_id = ?", provider, eventID).First(event).Error
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+func (r *paymentWebhookRepository) List(ctx context.Context, status domain.WebhookEventStatus, limit int) ([]domain.PaymentWebhookEvent, error) {
+	var events []domain.PaymentWebhookEvent
+	query := conn(ctx, r.DB).Order("id DESC").Limit(limit)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (r *paymentWebhookRepository) Update(ctx context.Context, event *domain.PaymentWebhookEvent) error {
+	return conn(ctx, r.DB).Save(event).Error
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"gorm.io/gorm"
+)
+
+type PriceHistoryRepository interface {
+	// Record appends a price change to the history
+	Record(ctx context.Context, change *domain.PriceChange) error
+	// List returns the price changes of a product, newest first
+	List(ctx context.Context, productID uint) ([]domain.PriceChange, error)
+	// GetAt returns the change that set the price in effect at a time
+	GetAt(ctx context.Context, productID uint, at time.Time) (*domain.PriceChange, error)
+
+	CreateSchedule(ctx context.Context, schedule *domain.ScheduledPrice) error
+	GetSchedule(ctx context.Context, id uint) (*domain.ScheduledPrice, error)
+	ListSchedules(ctx context.Context, productID uint) ([]domain.ScheduledPrice, error)
+	// CountOverlapping counts pending and active schedules of a product that
+	// conflict with a new one from start to end, a nil end is permanent
+	CountOverlapping(ctx context.Context, productID uint, start time.Time, end *time.Time) (int64, error)
+	// TransitionSchedule saves the status and original price of a schedule
+	// if it is still in status from, reporting whether it was
+	TransitionSchedule(ctx context.Context, schedule *domain.ScheduledPrice, from domain.ScheduleStatus) (bool, error)
+	// ListDueStarts returns pending schedules whose start time has passed
+	ListDueStarts(ctx context.Context, now time.Time, limit int) ([]domain.ScheduledPrice, error)
+	// ListDueEnds returns active sales whose end time has passed
+	ListDueEnds(ctx context.Context, now time.Time, limit int) ([]domain.ScheduledPrice, error)
+}
+
+type priceHistoryRepository struct {
+	DB *gorm.DB
+}
+
+func NewPriceHistoryRepository(db *gorm.DB) PriceHistoryRepository {
+	return &priceHistoryRepository{DB: db}
+}
+
+func (r *priceHistoryRepository) Record(ctx context.Context, change *domain.PriceChange) error {
+	return conn(ctx, r.DB).Create(change).Error
+}
+
+func (r *priceHistoryRepository) List(ctx context.Context, productID uint) ([]domain.PriceChange, error) {
+	var changes []domain.PriceChange
+	err := conn(ctx, r.DB).Where("product_id = ?", productID).
+		Order("changed_at DESC, id DESC").Find(&changes).Error
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func (r *priceHistoryRepository) GetAt(ctx context.Context, productID uint, at time.Time) (*domain.PriceChange, error) {
+	change := &domain.PriceChange{}
+	err := conn(ctx, r.DB).Where("product_id = ? AND changed_at <= ?", productID, at).
+		Order("changed_at DESC, id DESC").First(change).Error
+	if err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+func (r *priceHistoryRepository) CreateSchedule(ctx context.Context, schedule *domain.ScheduledPrice) error {
+	return conn(ctx, r.DB).Create(schedule).Error
+}
+
+func (r *priceHistoryRepository) GetSchedule(ctx context.Context, id uint) (*domain.ScheduledPrice, error) {
+	schedule := &domain.ScheduledPrice{}
+	err := conn(ctx, r.DB).First(schedule, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func (r *priceHistoryRepository) ListSchedules(ctx context.Context, productID uint) ([]domain.ScheduledPrice, error) {
+	var schedules []domain.ScheduledPrice
+	err := conn(ctx, r.DB).Where("product_id = ?", productID).Order("starts_at DESC").Find(&schedules).Error
+	if err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// CountOverlapping treats a permanent change as an instant, it only
+// conflicts with sales running at its start time
+func (r *priceHistoryRepository) CountOverlapping(ctx context.Context, productID uint, start time.Time, end *time.Time) (int64, error) {
+	query := conn(ctx, r.DB).Model(&domain.ScheduledPrice{}).
+		Where("product_id = ? AND status IN ?", productID, []domain.ScheduleStatus{domain.SchedulePending, domain.ScheduleActive})
+	if end == nil {
+		query = query.Where("ends_at IS NOT NULL AND starts_at <= ? AND ends_at > ?", start, start)
+	} else {
+		query = query.Where(
+			"(ends_at IS NULL AND starts_at >= ? AND starts_at < ?) OR (ends_at IS NOT NULL AND starts_at < ? AND ends_at > ?)",
+			start, *end, *end, start,
+		)
+	}
+
+	var count int64
+	err := query.Count(&count).Error
+	return count, err
+}
+
+func (r *priceHistoryRepository) TransitionSchedule(ctx context.Context, schedule *domain.ScheduledPrice, from domain.ScheduleStatus) (bool, error) {
+	result := conn(ctx, r.DB).Model(&domain.ScheduledPrice{}).
+		Where("id = ? AND status = ?", schedule.ID, from).
+		Updates(map[string]interface{}{
+			"status":         schedule.Status,
+			"original_price": schedule.OriginalPrice,
+			"updated_at":     time.Now(),
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *priceHistoryRepository) ListDueStarts(ctx context.Context, now time.Time, limit int) ([]domain.ScheduledPrice, error) {
+	var schedules []domain.ScheduledPrice
+	err := conn(ctx, r.DB).Where("status = ? AND starts_at <= ?", domain.SchedulePending, now).
+		Order("starts_at").Limit(limit).Find(&schedules).Error
+	if err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func (r *priceHistoryRepository) ListDueEnds(ctx context.Context, now time.Time, limit int) ([]domain.ScheduledPrice, error) {
+	var schedules []domain.ScheduledPrice
+	err := conn(ctx, r.DB).Where("status = ? AND ends_at <= ?", domain.ScheduleActive, now).
+		Order("ends_at").Limit(limit).Find(&schedules).Error
+	if err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
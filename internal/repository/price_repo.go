@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PriceRepository interface {
+	// ListProductPrices returns every explicit price of a product
+	ListProductPrices(ctx context.Context, productID uint) ([]domain.ProductPrice, error)
+	// GetProductPrices returns the explicit prices of products in a currency
+	GetProductPrices(ctx context.Context, productIDs []uint, currency string) ([]domain.ProductPrice, error)
+	// UpsertProductPrice creates or replaces a product's price in a currency
+	UpsertProductPrice(ctx context.Context, price *domain.ProductPrice) error
+	DeleteProductPrice(ctx context.Context, productID uint, currency string) (int64, error)
+
+	ListRates(ctx context.Context) ([]domain.ExchangeRate, error)
+	GetRate(ctx context.Context, currency string) (*domain.ExchangeRate, error)
+	// UpsertRate creates or replaces the exchange rate of a currency
+	UpsertRate(ctx context.Context, rate *domain.ExchangeRate) error
+}
+
+type priceRepository struct {
+	DB *gorm.DB
+}
+
+func NewPriceRepository(db *gorm.DB) PriceRepository {
+	return &priceRepository{DB: db}
+}
+
+func (r *priceRepository) ListProductPrices(ctx context.Context, productID uint) ([]domain.ProductPrice, error) {
+	var prices []domain.ProductPrice
+	err := conn(ctx, r.DB).Where("product_id = ?", productID).Order("currency").Find(&prices).Error
+	if err != nil {
+		return nil, err
+	}
+	return prices, nil
+}
+
+func (r *priceRepository) GetProductPrices(ctx context.Context, productIDs []uint, currency string) ([]domain.ProductPrice, error) {
+	var prices []domain.ProductPrice
+	err := conn(ctx, r.DB).Where("product_id IN ? AND currency = ?", productIDs, currency).Find(&prices).Error
+	if err != nil {
+		return nil, err
+	}
+	return prices, nil
+}
+
+func (r *priceRepository) UpsertProductPrice(ctx context.Context, price *domain.ProductPrice) error {
+	return conn(ctx, r.DB).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "product_id"}, {Name: "currency"}},
+		DoUpdates: clause.AssignmentColumns([]string{"amount", "updated_at"}),
+	}).Create(price).Error
+}
+
+func (r *priceRepository) DeleteProductPrice(ctx context.Context, productID uint, currency string) (int64, error) {
+	result := conn(ctx, r.DB).
+		Where("product_id = ? AND currency = ?", productID, currency).
+		Delete(&domain.ProductPrice{})
+	return result.RowsAffected, result.Error
+}
+
+func (r *priceRepository) ListRates(ctx context.Context) ([]domain.ExchangeRate, error) {
+	var rates []domain.ExchangeRate
+	err := conn(ctx, r.DB).Order("currency").Find(&rates).Error
+	if err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+func (r *priceRepository) GetRate(ctx context.Context, currency string) (*domain.ExchangeRate, error) {
+	rate := &domain.ExchangeRate{}
+	err := conn(ctx, r.DB).Where("currency = ?", currency).First(rate).Error
+	if err != nil {
+		return nil, err
+	}
+	return rate, nil
+}
+
+func (r *priceRepository) UpsertRate(ctx context.Context, rate *domain.ExchangeRate) error {
+	return conn(ctx, r.DB).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "currency"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "updated_by", "updated_at"}),
+	}).Create(rate).Error
+}
@@ -2,10 +2,18 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"time"
+
 	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/pkg/money"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrInsufficientStock is returned when a stock decrement would go negative
+var ErrInsufficientStock = errors.New("insufficient stock")
+
 type ProductRepository interface {
 	Create(ctx context.Context, product *domain.Product) error
 	GetByID(ctx context.Context, id uint) (*domain.Product, error)
@@ -14,6 +22,60 @@ type ProductRepository interface {
 	Delete(ctx context.Context, id uint) error
 	List(ctx context.Context, filter domain.ProductFilter) ([]domain.Product, error)
 	GetByIDs(ctx context.Context, ids []uint) ([]domain.Product, error)
+	ListInBatches(ctx context.Context, filter domain.ProductFilter, batchSize int, fn func([]domain.Product) error) error
+	// GetByIDIncludingArchived returns a product by ID even if it is archived
+	GetByIDIncludingArchived(ctx context.Context, id uint) (*domain.Product, error)
+	// ListArchived returns all archived products
+	ListArchived(ctx context.Context) ([]domain.Product, error)
+	// Restore un-archives a product
+	Restore(ctx context.Context, id uint) error
+	// PurgeArchived permanently removes products archived before the cutoff
+	// that are not referenced by any order, along with their stock, price
+	// and review history
+	PurgeArchived(ctx context.Context, before time.Time) (int64, error)
+	// PublishScheduled publishes scheduled products whose publish time has passed
+	PublishScheduled(ctx context.Context, now time.Time) (int64, error)
+	// ReserveStock atomically holds quantity units of available stock,
+	// returning ErrInsufficientStock if not enough is available
+	ReserveStock(ctx context.Context, id uint, quantity int) error
+	// ReleaseStock returns quantity reserved units to available stock
+	ReleaseStock(ctx context.Context, id uint, quantity int) error
+	// CommitStock turns quantity reserved units into a permanent decrement
+	CommitStock(ctx context.Context, id uint, quantity int) error
+	// AdjustStock atomically changes on-hand stock by delta, returning
+	// ErrInsufficientStock if stock would drop below what is reserved
+	AdjustStock(ctx context.Context, id uint, delta int) error
+	// GetForUpdate returns a product locked until the surrounding transaction ends
+	GetForUpdate(ctx context.Context, id uint) (*domain.Product, error)
+	// ListLowStock returns products whose available stock is at or below
+	// their reorder threshold, limited to ids unless ids is nil
+	ListLowStock(ctx context.Context, ids []uint) ([]domain.Product, error)
+	// MarkLowStockAlerted records that managers were alerted about a product,
+	// it returns false if another alert for the product is already recorded
+	MarkLowStockAlerted(ctx context.Context, id uint, at time.Time) (bool, error)
+	// MarkLowStockWebhookSent records that the stock alert webhook was sent
+	// for a product, it returns false if one is already recorded
+	MarkLowStockWebhookSent(ctx context.Context, id uint, at time.Time) (bool, error)
+	// ClearRecoveredLowStock clears the email and webhook alerts of products
+	// whose available stock is back above their threshold, limited to ids
+	// unless ids is nil
+	ClearRecoveredLowStock(ctx context.Context, ids []uint) error
+	// ResetLowStockAlerts clears the email alert of the given products so the
+	// next evaluation alerts about them again
+	ResetLowStockAlerts(ctx context.Context, ids []uint) error
+	// ResetLowStockWebhooks does the same for the webhook
+	ResetLowStockWebhooks(ctx context.Context, ids []uint) error
+	// HoldBackorder atomically counts quantity more units as backordered,
+	// returning ErrInsufficientStock if the backorder policy does not allow it
+	HoldBackorder(ctx context.Context, id uint, quantity int) error
+	// ReleaseBackorder stops counting quantity units as backordered
+	ReleaseBackorder(ctx context.Context, id uint, quantity int) error
+	// ListAllocatableBackorders returns products with backordered units and
+	// available stock to fill them
+	ListAllocatableBackorders(ctx context.Context, limit int) ([]uint, error)
+	// SetPrice sets the price of a product and its compare-at price, nil
+	// clears it
+	SetPrice(ctx context.Context, id uint, price money.Money, compareAt *money.Money) error
 }
 
 type productRepository struct {
@@ -21,66 +83,308 @@ type productRepository struct {
 }
 
 func (p *productRepository) Create(ctx context.Context, product *domain.Product) error {
-	return p.DB.WithContext(ctx).Create(product).Error
+	return conn(ctx, p.DB).Create(product).Error
 }
 
 func (p *productRepository) GetByID(ctx context.Context, id uint) (*domain.Product, error) {
 	product := &domain.Product{}
-	err := p.DB.WithContext(ctx).First(product, id).Error
+	err := conn(ctx, p.DB).First(product, id).Error
 	if err != nil {
 		return nil, err
 	}
 	return product, nil
 }
 
+// GetBySKU includes archived products since SKUs stay unique across archival
 func (p *productRepository) GetBySKU(ctx context.Context, sku string) (*domain.Product, error) {
 	product := &domain.Product{}
-	err := p.DB.WithContext(ctx).Where("sku = ?", sku).First(product).Error
+	err := conn(ctx, p.DB).Unscoped().Where("sku = ?", sku).First(product).Error
 	if err != nil {
 		return nil, err
 	}
 	return product, nil
 }
 
+// Update saves a product. Stock and price are only changed through their
+// own methods so that every change is recorded, and ratings only when
+// reviews change, so a stale copy never overwrites them.
 func (p *productRepository) Update(ctx context.Context, product *domain.Product) error {
-	return p.DB.WithContext(ctx).Save(product).Error
+	return conn(ctx, p.DB).
+		Omit("stock", "reserved", "backordered", "low_stock_alerted_at", "low_stock_webhook_at", "price", "compare_at_price",
+			"rating_average", "rating_count").
+		Save(product).Error
 }
 
 func (p *productRepository) Delete(ctx context.Context, id uint) error {
-	return p.DB.WithContext(ctx).Delete(&domain.Product{}, id).Error
+	return conn(ctx, p.DB).Delete(&domain.Product{}, id).Error
 }
 
 func (p *productRepository) List(ctx context.Context, filter domain.ProductFilter) ([]domain.Product, error) {
 	var products []domain.Product
-	query := p.DB.WithContext(ctx)
+	query := applyProductFilter(conn(ctx, p.DB), filter)
+	if order, ok := productSortOrders[filter.Sort]; ok {
+		query = query.Order(order)
+	}
+	err := query.Find(&products).Error
+	if err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// ListInBatches walks every product matching the filter in ID order, calling
+// fn with at most batchSize products at a time
+func (p *productRepository) ListInBatches(ctx context.Context, filter domain.ProductFilter, batchSize int, fn func([]domain.Product) error) error {
+	var products []domain.Product
+	return applyProductFilter(conn(ctx, p.DB), filter).
+		Order("id").
+		FindInBatches(&products, batchSize, func(tx *gorm.DB, batch int) error {
+			return fn(products)
+		}).Error
+}
+
+func (p *productRepository) GetByIDIncludingArchived(ctx context.Context, id uint) (*domain.Product, error) {
+	product := &domain.Product{}
+	err := conn(ctx, p.DB).Unscoped().First(product, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+func (p *productRepository) ListArchived(ctx context.Context) ([]domain.Product, error) {
+	var products []domain.Product
+	err := conn(ctx, p.DB).Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&products).Error
+	if err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+func (p *productRepository) Restore(ctx context.Context, id uint) error {
+	return conn(ctx, p.DB).Unscoped().Model(&domain.Product{}).Where("id = ?", id).Update("deleted_at", nil).Error
+}
+
+// purgedProductTables hold rows that only matter while their product
+// exists, in the order they are deleted. Cart items cascade on their own.
+var purgedProductTables = []string{
+	"stock_reservations",
+	"stock_movements",
+	"stock_transfers",
+	"warehouse_stocks",
+	"product_prices",
+	"price_changes",
+	"scheduled_prices",
+	"reviews",
+}
+
+func (p *productRepository) PurgeArchived(ctx context.Context, before time.Time) (int64, error) {
+	var purged int64
+	err := conn(ctx, p.DB).Transaction(func(tx *gorm.DB) error {
+		var ids []uint
+		err := tx.Unscoped().Model(&domain.Product{}).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+			Where("NOT EXISTS (SELECT 1 FROM order_items WHERE order_items.product_id = products.id)").
+			Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+
+		for _, table := range purgedProductTables {
+			if err := tx.Exec("DELETE FROM "+table+" WHERE product_id IN ?", ids).Error; err != nil {
+				return err
+			}
+		}
+		result := tx.Unscoped().Delete(&domain.Product{}, ids)
+		purged = result.RowsAffected
+		return result.Error
+	})
+	return purged, err
+}
+
+func (p *productRepository) PublishScheduled(ctx context.Context, now time.Time) (int64, error) {
+	result := conn(ctx, p.DB).Model(&domain.Product{}).
+		Where("status = ? AND publish_at <= ?", domain.ProductScheduled, now).
+		Update("status", domain.ProductPublished)
+	return result.RowsAffected, result.Error
+}
 
+func (p *productRepository) ReserveStock(ctx context.Context, id uint, quantity int) error {
+	// The availability check and the write happen in one statement, so
+	// concurrent orders cannot both pass the check and oversell
+	result := conn(ctx, p.DB).Unscoped().Model(&domain.Product{}).
+		Where("id = ? AND stock - reserved >= ?", id, quantity).
+		UpdateColumn("reserved", gorm.Expr("reserved + ?", quantity))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInsufficientStock
+	}
+	return nil
+}
+
+func (p *productRepository) ReleaseStock(ctx context.Context, id uint, quantity int) error {
+	return conn(ctx, p.DB).Unscoped().Model(&domain.Product{}).
+		Where("id = ?", id).
+		UpdateColumn("reserved", gorm.Expr("GREATEST(reserved - ?, 0)", quantity)).Error
+}
+
+func (p *productRepository) CommitStock(ctx context.Context, id uint, quantity int) error {
+	return conn(ctx, p.DB).Unscoped().Model(&domain.Product{}).
+		Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{
+			"stock":    gorm.Expr("stock - ?", quantity),
+			"reserved": gorm.Expr("GREATEST(reserved - ?, 0)", quantity),
+		}).Error
+}
+
+func (p *productRepository) AdjustStock(ctx context.Context, id uint, delta int) error {
+	result := conn(ctx, p.DB).Unscoped().Model(&domain.Product{}).
+		Where("id = ? AND stock + ? >= reserved", id, delta).
+		UpdateColumn("stock", gorm.Expr("stock + ?", delta))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInsufficientStock
+	}
+	return nil
+}
+
+func (p *productRepository) GetForUpdate(ctx context.Context, id uint) (*domain.Product, error) {
+	product := &domain.Product{}
+	err := conn(ctx, p.DB).Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).First(product, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+var productSortOrders = map[domain.ProductSort]string{
+	domain.SortNewest:    "created_at DESC",
+	domain.SortPriceAsc:  "price ASC",
+	domain.SortPriceDesc: "price DESC",
+	domain.SortRating:    "rating_average DESC, rating_count DESC",
+}
+
+func applyProductFilter(query *gorm.DB, filter domain.ProductFilter) *gorm.DB {
 	if filter.Name != "" {
 		query = query.Where("name LIKE ?", "%"+filter.Name+"%")
 	}
 
-	if filter.MinPrice > 0 {
+	if filter.MinPrice.IsPositive() {
 		query = query.Where("price >= ?", filter.MinPrice)
 	}
 
-	if filter.MaxPrice > 0 {
+	if filter.MaxPrice.IsPositive() {
 		query = query.Where("price <= ?", filter.MaxPrice)
 	}
 
-	err := query.Find(&products).Error
-	if err != nil {
-		return nil, err
+	if len(filter.Statuses) > 0 {
+		query = query.Where("status IN ?", filter.Statuses)
 	}
-	return products, nil
+	return query
 }
 
 func (p *productRepository) GetByIDs(ctx context.Context, ids []uint) ([]domain.Product, error) {
 	var products []domain.Product
-	err := p.DB.WithContext(ctx).Where("id IN ?", ids).Find(&products).Error
+	err := conn(ctx, p.DB).Where("id IN ?", ids).Find(&products).Error
 	if err != nil {
 		return nil, err
 	}
 	return products, nil
 }
+func (p *productRepository) ListLowStock(ctx context.Context, ids []uint) ([]domain.Product, error) {
+	var products []domain.Product
+	query := conn(ctx, p.DB).
+		Where("reorder_threshold > 0 AND stock - reserved <= reorder_threshold").
+		Order("stock - reserved - reorder_threshold, id")
+	if ids != nil {
+		query = query.Where("id IN ?", ids)
+	}
+	if err := query.Find(&products).Error; err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+func (p *productRepository) MarkLowStockAlerted(ctx context.Context, id uint, at time.Time) (bool, error) {
+	result := conn(ctx, p.DB).Model(&domain.Product{}).
+		Where("id = ? AND low_stock_alerted_at IS NULL", id).
+		UpdateColumn("low_stock_alerted_at", at)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (p *productRepository) MarkLowStockWebhookSent(ctx context.Context, id uint, at time.Time) (bool, error) {
+	result := conn(ctx, p.DB).Model(&domain.Product{}).
+		Where("id = ? AND low_stock_webhook_at IS NULL", id).
+		UpdateColumn("low_stock_webhook_at", at)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (p *productRepository) ClearRecoveredLowStock(ctx context.Context, ids []uint) error {
+	query := conn(ctx, p.DB).Unscoped().Model(&domain.Product{}).
+		Where("(low_stock_alerted_at IS NOT NULL OR low_stock_webhook_at IS NOT NULL)").
+		Where("(reorder_threshold = 0 OR stock - reserved > reorder_threshold)")
+	if ids != nil {
+		query = query.Where("id IN ?", ids)
+	}
+	return query.UpdateColumns(map[string]interface{}{"low_stock_alerted_at": nil, "low_stock_webhook_at": nil}).Error
+}
+
+func (p *productRepository) ResetLowStockAlerts(ctx context.Context, ids []uint) error {
+	return conn(ctx, p.DB).Unscoped().Model(&domain.Product{}).
+		Where("id IN ?", ids).
+		UpdateColumn("low_stock_alerted_at", nil).Error
+}
+
+func (p *productRepository) ResetLowStockWebhooks(ctx context.Context, ids []uint) error {
+	return conn(ctx, p.DB).Unscoped().Model(&domain.Product{}).
+		Where("id IN ?", ids).
+		UpdateColumn("low_stock_webhook_at", nil).Error
+}
+
+func (p *productRepository) HoldBackorder(ctx context.Context, id uint, quantity int) error {
+	result := conn(ctx, p.DB).Model(&domain.Product{}).
+		Where("id = ?", id).
+		Where("backorder_policy = ? OR (backorder_policy = ? AND backordered + ? <= backorder_limit)",
+			domain.BackorderUnlimited, domain.BackorderLimited, quantity).
+		UpdateColumn("backordered", gorm.Expr("backordered + ?", quantity))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInsufficientStock
+	}
+	return nil
+}
+
+func (p *productRepository) ReleaseBackorder(ctx context.Context, id uint, quantity int) error {
+	return conn(ctx, p.DB).Unscoped().Model(&domain.Product{}).
+		Where("id = ?", id).
+		UpdateColumn("backordered", gorm.Expr("GREATEST(backordered - ?, 0)", quantity)).Error
+}
+
+func (p *productRepository) ListAllocatableBackorders(ctx context.Context, limit int) ([]uint, error) {
+	var ids []uint
+	err := conn(ctx, p.DB).Model(&domain.Product{}).
+		Where("backordered > 0 AND stock - reserved > 0").
+		Order("id").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (p *productRepository) SetPrice(ctx context.Context, id uint, price money.Money, compareAt *money.Money) error {
+	return conn(ctx, p.DB).Model(&domain.Product{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"price": price, "compare_at_price": compareAt}).Error
+}
+
 func NewProductRepository(db *gorm.DB) ProductRepository {
 	return &productRepository{DB: db}
 }
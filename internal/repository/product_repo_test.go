@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Dubjay18/ecom-api/internal/testutil"
+	"gorm.io/gorm"
+)
+
+// seedArchivedProduct creates a product archived a year ago with a row in
+// every table that refers to products
+func seedArchivedProduct(t *testing.T, db *gorm.DB, sku string) uint {
+	t.Helper()
+	var id, scheduleID, cartID uint
+	steps := []func() error{
+		func() error {
+			return db.Raw(`INSERT INTO products (name, price, sku, stock, category, deleted_at)
+				VALUES (?, 10, ?, 5, 'test', now() - interval '1 year') RETURNING id`, sku, sku).Scan(&id).Error
+		},
+		func() error {
+			return db.Exec(`INSERT INTO stock_movements (product_id, quantity, type, warehouse_id)
+				SELECT ?, 5, 'adjustment', id FROM warehouses WHERE is_default`, id).Error
+		},
+		func() error {
+			return db.Exec(`INSERT INTO warehouse_stocks (product_id, stock, warehouse_id)
+				SELECT ?, 5, id FROM warehouses WHERE is_default`, id).Error
+		},
+		func() error {
+			return db.Exec(`INSERT INTO product_prices (product_id, currency, amount) VALUES (?, 'EUR', 9)`, id).Error
+		},
+		func() error {
+			return db.Raw(`INSERT INTO scheduled_prices (product_id, price, starts_at)
+				VALUES (?, 8, now()) RETURNING id`, id).Scan(&scheduleID).Error
+		},
+		func() error {
+			return db.Exec(`INSERT INTO price_changes (product_id, new_price, source, schedule_id, changed_at)
+				VALUES (?, 8, 'schedule', ?, now())`, id, scheduleID).Error
+		},
+		func() error {
+			return db.Raw(`INSERT INTO carts (token) VALUES (?) RETURNING id`, sku).Scan(&cartID).Error
+		},
+		func() error {
+			return db.Exec(`INSERT INTO cart_items (cart_id, product_id, quantity) VALUES (?, ?, 1)`, cartID, id).Error
+		},
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			t.Fatalf("seed %s: %v", sku, err)
+		}
+	}
+	return id
+}
+
+func TestPurgeArchivedRemovesDependentRows(t *testing.T) {
+	db := testutil.NewPostgresDB(t)
+	repo := NewProductRepository(db)
+
+	purgeable := seedArchivedProduct(t, db, "PURGE-1")
+	ordered := seedArchivedProduct(t, db, "ORDERED-1")
+	err := db.Exec(`WITH o AS (INSERT INTO orders (total_amount, subtotal, currency, items)
+		VALUES (10, 10, 'USD', '[]') RETURNING id)
+		INSERT INTO order_items (order_id, product_id, quantity, price, currency)
+		SELECT id, ?, 1, 10, 'USD' FROM o`, ordered).Error
+	if err != nil {
+		t.Fatalf("seed order: %v", err)
+	}
+
+	purged, err := repo.PurgeArchived(context.Background(), time.Now().Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("PurgeArchived: %v", err)
+	}
+	if purged != 1 {
+		t.Fatalf("purged %d products, want 1", purged)
+	}
+
+	for _, table := range append(purgedProductTables, "cart_items", "products") {
+		column := "product_id"
+		if table == "products" {
+			column = "id"
+		}
+		var left, kept int64
+		db.Table(table).Where(column+" = ?", purgeable).Count(&left)
+		db.Table(table).Where(column+" = ?", ordered).Count(&kept)
+		if left != 0 {
+			t.Errorf("%s still has %d rows of the purged product", table, left)
+		}
+		if kept == 0 && table != "stock_reservations" && table != "stock_transfers" && table != "reviews" {
+			t.Errorf("%s lost the rows of the ordered product", table)
+		}
+	}
+}
+
+func TestUpdateKeepsRatings(t *testing.T) {
+	db := testutil.NewPostgresDB(t)
+	repo := NewProductRepository(db)
+	ctx := context.Background()
+
+	var id uint
+	err := db.Raw(`INSERT INTO products (name, price, sku, stock, category)
+		VALUES ('Rated', 10, 'RATED-1', 5, 'test') RETURNING id`).Scan(&id).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale, err := repo.GetByID(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A review is approved while the stale copy is being edited
+	if err := db.Exec(`UPDATE products SET rating_average = 4.5, rating_count = 2 WHERE id = ?`, id).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	stale.Name = "Renamed"
+	if err := repo.Update(ctx, stale); err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Name          string
+		RatingAverage float64
+		RatingCount   int
+	}
+	if err := db.Raw(`SELECT name, rating_average, rating_count FROM products WHERE id = ?`, id).Scan(&got).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "Renamed" || got.RatingAverage != 4.5 || got.RatingCount != 2 {
+		t.Errorf("product = %+v, want renamed with a 4.5 rating from 2 reviews", got)
+	}
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"gorm.io/gorm"
+)
+
+// ErrUsageLimitReached is returned when a promotion has been used as many
+// times as its usage limit allows
+var ErrUsageLimitReached = errors.New("promotion usage limit reached")
+
+type PromotionRepository interface {
+	Create(ctx context.Context, promotion *domain.Promotion) error
+	GetByID(ctx context.Context, id uint) (*domain.Promotion, error)
+	// GetByCode returns the coupon with an upper case code
+	GetByCode(ctx context.Context, code string) (*domain.Promotion, error)
+	List(ctx context.Context) ([]domain.Promotion, error)
+	// ListAutomatic returns the promotions without a code that are running
+	// at now, oldest first
+	ListAutomatic(ctx context.Context, now time.Time) ([]domain.Promotion, error)
+	Update(ctx context.Context, promotion *domain.Promotion) error
+	// Claim counts a use of a promotion, it returns ErrUsageLimitReached if
+	// the promotion has no uses left
+	Claim(ctx context.Context, id uint) error
+	// Unclaim gives back a use of a promotion
+	Unclaim(ctx context.Context, id uint) error
+	// CountUserUses counts the orders of a user a promotion applies to that
+	// were not cancelled
+	CountUserUses(ctx context.Context, promotionID, userID uint) (int64, error)
+	// ListOrderDiscounts returns the discounts of an order that have not
+	// been released
+	ListOrderDiscounts(ctx context.Context, orderID uint) ([]domain.OrderDiscount, error)
+	UpdateOrderDiscount(ctx context.Context, discount *domain.OrderDiscount) error
+}
+
+type promotionRepository struct {
+	DB *gorm.DB
+}
+
+func NewPromotionRepository(db *gorm.DB) PromotionRepository {
+	return &promotionRepository{DB: db}
+}
+
+func (r *promotionRepository) Create(ctx context.Context, promotion *domain.Promotion) error {
+	return conn(ctx, r.DB).Create(promotion).Error
+}
+
+func (r *promotionRepository) GetByID(ctx context.Context, id uint) (*domain.Promotion, error) {
+	promotion := &domain.Promotion{}
+	err := conn(ctx, r.DB).First(promotion, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return promotion, nil
+}
+
+func (r *promotionRepository) GetByCode(ctx context.Context, code string) (*domain.Promotion, error) {
+	promotion := &domain.Promotion{}
+	err := conn(ctx, r.DB).Where("code = ?", code).First(promotion).Error
+	if err != nil {
+		return nil, err
+	}
+	return promotion, nil
+}
+
+func (r *promotionRepository) List(ctx context.Context) ([]domain.Promotion, error) {
+	var promotions []domain.Promotion
+	err := conn(ctx, r.DB).Order("id").Find(&promotions).Error
+	if err != nil {
+		return nil, err
+	}
+	return promotions, nil
+}
+
+func (r *promotionRepository) ListAutomatic(ctx context.Context, now time.Time) ([]domain.Promotion, error) {
+	var promotions []domain.Promotion
+	err := conn(ctx, r.DB).
+		Where("code IS NULL AND active").
+		Where("starts_at IS NULL OR starts_at <= ?", now).
+		Where("ends_at IS NULL OR ends_at > ?", now).
+		Order("id").
+		Find(&promotions).Error
+	if err != nil {
+		return nil, err
+	}
+	return promotions, nil
+}
+
+func (r *promotionRepository) Update(ctx context.Context, promotion *domain.Promotion) error {
+	// The usage count is only changed by claims
+	return conn(ctx, r.DB).Omit("usage_count").Save(promotion).Error
+}
+
+func (r *promotionRepository) Claim(ctx context.Context, id uint) error {
+	// The limit check and the count happen in one statement, so concurrent
+	// orders cannot both take the last use
+	result := conn(ctx, r.DB).Model(&domain.Promotion{}).
+		Where("id = ? AND (usage_limit = 0 OR usage_count < usage_limit)", id).
+		UpdateColumn("usage_count", gorm.Expr("usage_count + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUsageLimitReached
+	}
+	return nil
+}
+
+func (r *promotionRepository) Unclaim(ctx context.Context, id uint) error {
+	return conn(ctx, r.DB).Model(&domain.Promotion{}).
+		Where("id = ?", id).
+		UpdateColumn("usage_count", gorm.Expr("GREATEST(usage_count - 1, 0)")).Error
+}
+
+func (r *promotionRepository) CountUserUses(ctx context.Context, promotionID, userID uint) (int64, error) {
+	var count int64
+	err := conn(ctx, r.DB).Model(&domain.OrderDiscount{}).
+		Joins("JOIN orders ON orders.id = order_discounts.order_id").
+		Where("order_discounts.promotion_id = ? AND orders.user_id = ?", promotionID, userID).
+		Where("order_discounts.released_at IS NULL").
+		Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *promotionRepository) ListOrderDiscounts(ctx context.Context, orderID uint) ([]domain.OrderDiscount, error) {
+	var discounts []domain.OrderDiscount
+	err := conn(ctx, r.DB).Where("order_id = ? AND released_at IS NULL", orderID).Order("id").Find(&discounts).Error
+	if err != nil {
+		return nil, err
+	}
+	return discounts, nil
+}
+
+func (r *promotionRepository) UpdateOrderDiscount(ctx context.Context, discount *domain.OrderDiscount) error {
+	return conn(ctx, r.DB).Save(discount).Error
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ReservationRepository interface {
+	CreateBatch(ctx context.Context, reservations []domain.StockReservation) error
+	// LockActiveByOrder returns an order's active reservations, locking them
+	// until the surrounding transaction ends
+	LockActiveByOrder(ctx context.Context, orderID uint) ([]domain.StockReservation, error)
+	// ListExpiredOrderIDs returns orders holding active reservations that
+	// expired before now
+	ListExpiredOrderIDs(ctx context.Context, now time.Time, limit int) ([]uint, error)
+	UpdateStatus(ctx context.Context, ids []uint, status domain.ReservationStatus) error
+}
+
+type reservationRepository struct {
+	DB *gorm.DB
+}
+
+func NewReservationRepository(db *gorm.DB) ReservationRepository {
+	return &reservationRepository{DB: db}
+}
+
+func (r *reservationRepository) CreateBatch(ctx context.Context, reservations []domain.StockReservation) error {
+	if len(reservations) == 0 {
+		return nil
+	}
+	return conn(ctx, r.DB).Create(&reservations).Error
+}
+
+func (r *reservationRepository) LockActiveByOrder(ctx context.Context, orderID uint) ([]domain.StockReservation, error) {
+	var reservations []domain.StockReservation
+	err := conn(ctx, r.DB).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("order_id = ? AND status = ?", orderID, domain.ReservationActive).
+		Find(&reservations).Error
+	if err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
+
+func (r *reservationRepository) ListExpiredOrderIDs(ctx context.Context, now time.Time, limit int) ([]uint, error) {
+	var ids []uint
+	err := conn(ctx, r.DB).Model(&domain.StockReservation{}).
+		Distinct("order_id").
+		Where("status = ? AND expires_at < ?", domain.ReservationActive, now).
+		Limit(limit).
+		Pluck("order_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (r *reservationRepository) UpdateStatus(ctx context.Context, ids []uint, status domain.ReservationStatus) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return conn(ctx, r.DB).Model(&domain.StockReservation{}).
+		Where("id IN ?", ids).
+		Update("status", status).Error
+}
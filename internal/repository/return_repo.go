@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ReturnRepository interface {
+	Create(ctx context.Context, ret *domain.Return) error
+	// GetByID returns a return with its items and the order items they are of
+	GetByID(ctx context.Context, id uint) (*domain.Return, error)
+	// GetForUpdate is GetByID that locks the return until the transaction
+	// ends, so it changes status one step at a time
+	GetForUpdate(ctx context.Context, id uint) (*domain.Return, error)
+	ListByOrder(ctx context.Context, orderID uint) ([]domain.Return, error)
+	// List returns returns oldest first, of one status if status is set
+	List(ctx context.Context, status domain.ReturnStatus) ([]domain.Return, error)
+	Update(ctx context.Context, ret *domain.Return) error
+	UpdateItem(ctx context.Context, item *domain.ReturnItem) error
+	// ReturnedQuantities sums the units of each order item of an order in
+	// returns that were not rejected, keyed by order item ID
+	ReturnedQuantities(ctx context.Context, orderID uint) (map[uint]int, error)
+	// RefundedQuantities sums the units of each order item of an order
+	// refunded by returns other than return exceptID, keyed by order item
+	// ID
+	RefundedQuantities(ctx context.Context, orderID, exceptID uint) (map[uint]int, error)
+}
+
+type returnRepository struct {
+	DB *gorm.DB
+}
+
+func NewReturnRepository(db *gorm.DB) ReturnRepository {
+	return &returnRepository{DB: db}
+}
+
+func (r *returnRepository) Create(ctx context.Context, ret *domain.Return) error {
+	return conn(ctx, r.DB).Create(ret).Error
+}
+
+func (r *returnRepository) GetByID(ctx context.Context, id uint) (*domain.Return, error) {
+	return r.get(ctx, conn(ctx, r.DB), id)
+}
+
+func (r *returnRepository) GetForUpdate(ctx context.Context, id uint) (*domain.Return, error) {
+	return r.get(ctx, conn(ctx, r.DB).Clauses(clause.Locking{Strength: "UPDATE"}), id)
+}
+
+func (r *returnRepository) get(ctx context.Context, db *gorm.DB, id uint) (*domain.Return, error) {
+	ret := &domain.Return{}
+	err := db.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).Preload("Items.OrderItem").First(ret, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (r *returnRepository) ListByOrder(ctx context.Context, orderID uint) ([]domain.Return, error) {
+	var returns []domain.Return
+	err := conn(ctx, r.DB).Preload("Items").Where("order_id = ?", orderID).Order("id").Find(&returns).Error
+	if err != nil {
+		return nil, err
+	}
+	return returns, nil
+}
+
+func (r *returnRepository) List(ctx context.Context, status domain.ReturnStatus) ([]domain.Return, error) {
+	var returns []domain.Return
+	query := conn(ctx, r.DB).Preload("Items").Order("id")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Find(&returns).Error; err != nil {
+		return nil, err
+	}
+	return returns, nil
+}
+
+func (r *returnRepository) Update(ctx context.Context, ret *domain.Return) error {
+	return conn(ctx, r.DB).Omit(clause.Associations).Save(ret).Error
+}
+
+func (r *returnRepository) UpdateItem(ctx context.Context, item *domain.ReturnItem) error {
+	return conn(ctx, r.DB).Omit(clause.Associations).Save(item).Error
+}
+
+func (r *returnRepository) ReturnedQuantities(ctx context.Context, orderID uint) (map[uint]int, error) {
+	var rows []struct {
+		OrderItemID uint
+		Quantity    int
+	}
+	err := conn(ctx, r.DB).Model(&domain.ReturnItem{}).
+		Select("return_items.order_item_id, SUM(return_items.quantity) AS quantity").
+		Joins("JOIN returns ON returns.id = return_items.return_id").
+		Where("returns.order_id = ? AND returns.status <> ?", orderID, domain.ReturnRejected).
+		Group("return_items.order_item_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	quantities := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		quantities[row.OrderItemID] = row.Quantity
+	}
+	return quantities, nil
+}
+
+func (r *returnRepository) RefundedQuantities(ctx context.Context, orderID, exceptID uint) (map[uint]int, error) {
+	var rows []struct {
+		OrderItemID uint
+		Quantity    int
+	}
+	err := conn(ctx, r.DB).Model(&domain.ReturnItem{}).
+		Select("return_items.order_item_id, SUM(return_items.refunded_quantity) AS quantity").
+		Joins("JOIN returns ON returns.id = return_items.return_id").
+		Where("returns.order_id = ? AND returns.id <> ? AND returns.status <> ?", orderID, exceptID, domain.ReturnRejected).
+		Group("return_items.order_item_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	quantities := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		quantities[row.OrderItemID] = row.Quantity
+	}
+	return quantities, nil
+}
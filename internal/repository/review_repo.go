@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"gorm.io/gorm"
+)
+
+type ReviewRepository interface {
+	Create(ctx context.Context, review *domain.Review) error
+	GetByID(ctx context.Context, id uint) (*domain.Review, error)
+	GetByUserAndProduct(ctx context.Context, userID, productID uint) (*domain.Review, error)
+	Update(ctx context.Context, review *domain.Review) error
+	// ListByProduct returns a product's reviews in the given status, newest first
+	ListByProduct(ctx context.Context, productID uint, status domain.ReviewStatus) ([]domain.Review, error)
+	// ListByStatus returns reviews in the given status, oldest first
+	ListByStatus(ctx context.Context, status domain.ReviewStatus) ([]domain.Review, error)
+	// RefreshProductRating recomputes the product's rating from approved reviews
+	RefreshProductRating(ctx context.Context, productID uint) (*domain.RatingSummary, error)
+}
+
+type reviewRepository struct {
+	DB *gorm.DB
+}
+
+func NewReviewRepository(db *gorm.DB) ReviewRepository {
+	return &reviewRepository{DB: db}
+}
+
+func (r *reviewRepository) Create(ctx context.Context, review *domain.Review) error {
+	return conn(ctx, r.DB).Create(review).Error
+}
+
+func (r *reviewRepository) GetByID(ctx context.Context, id uint) (*domain.Review, error) {
+	review := &domain.Review{}
+	err := conn(ctx, r.DB).First(review, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+func (r *reviewRepository) GetByUserAndProduct(ctx context.Context, userID, productID uint) (*domain.Review, error) {
+	review := &domain.Review{}
+	err := conn(ctx, r.DB).Where("user_id = ? AND product_id = ?", userID, productID).First(review).Error
+	if err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+func (r *reviewRepository) Update(ctx context.Context, review *domain.Review) error {
+	return conn(ctx, r.DB).Save(review).Error
+}
+
+func (r *reviewRepository) ListByProduct(ctx context.Context, productID uint, status domain.ReviewStatus) ([]domain.Review, error) {
+	var reviews []domain.Review
+	err := conn(ctx, r.DB).
+		Where("product_id = ? AND status = ?", productID, status).
+		Order("created_at DESC").
+		Find(&reviews).Error
+	if err != nil {
+		return nil, err
+	}
+	return reviews, nil
+}
+
+func (r *reviewRepository) ListByStatus(ctx context.Context, status domain.ReviewStatus) ([]domain.Review, error) {
+	var reviews []domain.Review
+	err := conn(ctx, r.DB).Where("status = ?", status).Order("created_at ASC").Find(&reviews).Error
+	if err != nil {
+		return nil, err
+	}
+	return reviews, nil
+}
+
+func (r *reviewRepository) RefreshProductRating(ctx context.Context, productID uint) (*domain.RatingSummary, error) {
+	summary := &domain.RatingSummary{}
+	err := conn(ctx, r.DB).Model(&domain.Review{}).
+		Select("COALESCE(AVG(rating), 0) AS average, COUNT(*) AS count").
+		Where("product_id = ? AND status = ?", productID, domain.ReviewApproved).
+		Scan(summary).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = conn(ctx, r.DB).Unscoped().Model(&domain.Product{}).
+		Where("id = ?", productID).
+		Updates(map[string]interface{}{
+			"rating_average": summary.Average,
+			"rating_count":   summary.Count,
+		}).Error
+	if err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
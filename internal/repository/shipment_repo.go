@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ShipmentRepository interface {
+	Create(ctx context.Context, shipment *domain.Shipment) error
+	// GetByID returns a shipment with its items
+	GetByID(ctx context.Context, id uint) (*domain.Shipment, error)
+	// GetForUpdate is GetByID that locks the shipment until the transaction
+	// ends
+	GetForUpdate(ctx context.Context, id uint) (*domain.Shipment, error)
+	// ListByOrder returns the shipments of an order with their items, oldest
+	// first
+	ListByOrder(ctx context.Context, orderID uint) ([]domain.Shipment, error)
+	Update(ctx context.Context, shipment *domain.Shipment) error
+}
+
+type shipmentRepository struct {
+	DB *gorm.DB
+}
+
+func NewShipmentRepository(db *gorm.DB) ShipmentRepository {
+	return &shipmentRepository{DB: db}
+}
+
+func (r *shipmentRepository) Create(ctx context.Context, shipment *domain.Shipment) error {
+	return conn(ctx, r.DB).Create(shipment).Error
+}
+
+func (r *shipmentRepository) GetByID(ctx context.Context, id uint) (*domain.Shipment, error) {
+	return r.get(conn(ctx, r.DB), id)
+}
+
+func (r *shipmentRepository) GetForUpdate(ctx context.Context, id uint) (*domain.Shipment, error) {
+	return r.get(conn(ctx, r.DB).Clauses(clause.Locking{Strength: "UPDATE"}), id)
+}
+
+func (r *shipmentRepository) get(db *gorm.DB, id uint) (*domain.Shipment, error) {
+	shipment := &domain.Shipment{}
+	err := db.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).First(shipment, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return shipment, nil
+}
+
+func (r *shipmentRepository) ListByOrder(ctx context.Context, orderID uint) ([]domain.Shipment, error) {
+	var shipments []domain.Shipment
+	err := conn(ctx, r.DB).
+		Preload("Items", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id")
+		}).
+		Where("order_id = ?", orderID).
+		Order("id").
+		Find(&shipments).Error
+	if err != nil {
+		return nil, err
+	}
+	return shipments, nil
+}
+
+func (r *shipmentRepository) Update(ctx context.Context, shipment *domain.Shipment) error {
+	// Items are written once when the shipment is created
+	return conn(ctx, r.DB).Omit(clause.Associations).Save(shipment).Error
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"gorm.io/gorm"
+)
+
+type StockMovementRepository interface {
+	Create(ctx context.Context, movement *domain.StockMovement) error
+	// ListByProduct returns a product's ledger, newest first
+	ListByProduct(ctx context.Context, productID uint, limit int) ([]domain.StockMovement, error)
+	// Reconcile recomputes every product's stock from the ledger
+	Reconcile(ctx context.Context) ([]domain.StockReconciliation, error)
+}
+
+type stockMovementRepository struct {
+	DB *gorm.DB
+}
+
+func NewStockMovementRepository(db *gorm.DB) StockMovementRepository {
+	return &stockMovementRepository{DB: db}
+}
+
+func (r *stockMovementRepository) Create(ctx context.Context, movement *domain.StockMovement) error {
+	return conn(ctx, r.DB).Create(movement).Error
+}
+
+func (r *stockMovementRepository) ListByProduct(ctx context.Context, productID uint, limit int) ([]domain.StockMovement, error) {
+	var movements []domain.StockMovement
+	err := conn(ctx, r.DB).
+		Where("product_id = ?", productID).
+		Order("id DESC").
+		Limit(limit).
+		Find(&movements).Error
+	if err != nil {
+		return nil, err
+	}
+	return movements, nil
+}
+
+func (r *stockMovementRepository) Reconcile(ctx context.Context) ([]domain.StockReconciliation, error) {
+	var report []domain.StockReconciliation
+	err := conn(ctx, r.DB).Table("products").
+		Select(`products.id AS product_id, products.sku, products.stock,
+			COALESCE(SUM(stock_movements.quantity), 0) AS ledger_stock,
+			products.stock - COALESCE(SUM(stock_movements.quantity), 0) AS drift,
+			products.stock <> COALESCE(SUM(stock_movements.quantity), 0) AS has_drift`).
+		Joins("LEFT JOIN stock_movements ON stock_movements.product_id = products.id").
+		Group("products.id").
+		Order("products.id").
+		Scan(&report).Error
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
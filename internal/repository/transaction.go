@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type txKey struct{}
+
+// txState is the transaction carried in a context along with the callbacks
+// waiting for it to commit
+type txState struct {
+	tx          *gorm.DB
+	afterCommit []func()
+}
+
+// TxManager runs units of work inside a database transaction. The
+// transaction travels in the context, so every repository call made with
+// that context takes part in it.
+type TxManager interface {
+	// WithinTx runs fn in a transaction, committing if it returns nil and
+	// rolling back otherwise. Nested calls run in a savepoint of the outer
+	// transaction, so an inner failure only undoes the inner work.
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+	// AfterCommit runs fn once the outermost transaction in ctx commits, it
+	// is dropped if the work it belongs to rolls back. Without a transaction
+	// fn runs straight away.
+	AfterCommit(ctx context.Context, fn func())
+}
+
+type txManager struct {
+	DB *gorm.DB
+}
+
+func NewTxManager(db *gorm.DB) TxManager {
+	return &txManager{DB: db}
+}
+
+func (m *txManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	parent, nested := ctx.Value(txKey{}).(*txState)
+	state := &txState{}
+	err := conn(ctx, m.DB).Transaction(func(tx *gorm.DB) error {
+		state.tx = tx
+		return fn(context.WithValue(ctx, txKey{}, state))
+	})
+	if err != nil {
+		return err
+	}
+
+	// A savepoint hands its callbacks to the enclosing transaction
+	if nested {
+		parent.afterCommit = append(parent.afterCommit, state.afterCommit...)
+		return nil
+	}
+	for _, fn := range state.afterCommit {
+		fn()
+	}
+	return nil
+}
+
+func (m *txManager) AfterCommit(ctx context.Context, fn func()) {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		state.afterCommit = append(state.afterCommit, fn)
+		return
+	}
+	fn()
+}
+
+// conn returns the transaction carried by ctx, or db if there is none
+func conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return state.tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
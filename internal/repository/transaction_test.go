@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/internal/testutil"
+	"gorm.io/gorm"
+)
+
+var errStep = errors.New("step failed")
+
+type txFixture struct {
+	db        *gorm.DB
+	tx        TxManager
+	orders    OrderRepository
+	products  ProductRepository
+	userID    uint
+	productID uint
+}
+
+func newTxFixture(t *testing.T) *txFixture {
+	t.Helper()
+	db := testutil.NewPostgresDB(t)
+	f := &txFixture{
+		db:       db,
+		tx:       NewTxManager(db),
+		orders:   NewOrderRepository(db),
+		products: NewProductRepository(db),
+	}
+	err := db.Raw(`INSERT INTO users (email, password, first_name, last_name)
+		VALUES ('tx@example.com', 'x', 'Tx', 'Test') RETURNING id`).Scan(&f.userID).Error
+	if err == nil {
+		err = db.Raw(`INSERT INTO products (name, price, sku, stock, category)
+			VALUES ('Widget', 10, 'TX-1', 5, 'test') RETURNING id`).Scan(&f.productID).Error
+	}
+	if err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	return f
+}
+
+// placeOrder writes an address, an order and a stock reservation the way
+// checkout does
+func (f *txFixture) placeOrder(ctx context.Context) error {
+	addr := &domain.Address{UserID: f.userID, Street: "1 Main St", City: "Springfield", State: "IL", Country: "US", PostalCode: "62701"}
+	if err := f.orders.CreatAddress(ctx, addr); err != nil {
+		return err
+	}
+	err := conn(ctx, f.db).Exec(`INSERT INTO orders (user_id, total_amount, subtotal, currency, shipping_address_id, items)
+		VALUES (?, 10, 10, 'USD', ?, '[]')`, f.userID, addr.ID).Error
+	if err != nil {
+		return err
+	}
+	return f.products.ReserveStock(ctx, f.productID, 1)
+}
+
+type txCounts struct {
+	Addresses int
+	Orders    int
+	Reserved  int
+}
+
+func (f *txFixture) counts(t *testing.T) txCounts {
+	t.Helper()
+	var c txCounts
+	err := f.db.Raw(`SELECT
+		(SELECT COUNT(*) FROM addresses) AS addresses,
+		(SELECT COUNT(*) FROM orders) AS orders,
+		(SELECT reserved FROM products WHERE id = ?) AS reserved`, f.productID).Scan(&c).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestWithinTxCommits(t *testing.T) {
+	f := newTxFixture(t)
+	err := f.tx.WithinTx(context.Background(), f.placeOrder)
+	if err != nil {
+		t.Fatalf("WithinTx: %v", err)
+	}
+	if got, want := f.counts(t), (txCounts{1, 1, 1}); got != want {
+		t.Fatalf("counts = %+v, want %+v", got, want)
+	}
+}
+
+func TestWithinTxRollsBackEveryWriteOnFailure(t *testing.T) {
+	f := newTxFixture(t)
+	err := f.tx.WithinTx(context.Background(), func(ctx context.Context) error {
+		if err := f.placeOrder(ctx); err != nil {
+			return err
+		}
+		return errStep
+	})
+	if !errors.Is(err, errStep) {
+		t.Fatalf("WithinTx = %v, want %v", err, errStep)
+	}
+	if got := f.counts(t); got != (txCounts{}) {
+		t.Fatalf("counts after rollback = %+v, want none", got)
+	}
+}
+
+func TestNestedWithinTxRollsBackToSavepoint(t *testing.T) {
+	f := newTxFixture(t)
+	err := f.tx.WithinTx(context.Background(), func(ctx context.Context) error {
+		if err := f.placeOrder(ctx); err != nil {
+			return err
+		}
+		inner := f.tx.WithinTx(ctx, func(ctx context.Context) error {
+			if err := f.placeOrder(ctx); err != nil {
+				return err
+			}
+			return errStep
+		})
+		if !errors.Is(inner, errStep) {
+			t.Errorf("inner WithinTx = %v, want %v", inner, errStep)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithinTx: %v", err)
+	}
+	if got, want := f.counts(t), (txCounts{1, 1, 1}); got != want {
+		t.Fatalf("counts = %+v, want only the outer order %+v", got, want)
+	}
+}
+
+func TestNestedFailureRollsBackOuterTx(t *testing.T) {
+	f := newTxFixture(t)
+	err := f.tx.WithinTx(context.Background(), func(ctx context.Context) error {
+		if err := f.placeOrder(ctx); err != nil {
+			return err
+		}
+		return f.tx.WithinTx(ctx, func(ctx context.Context) error {
+			return errStep
+		})
+	})
+	if !errors.Is(err, errStep) {
+		t.Fatalf("WithinTx = %v, want %v", err, errStep)
+	}
+	if got := f.counts(t); got != (txCounts{}) {
+		t.Fatalf("counts after rollback = %+v, want none", got)
+	}
+}
+
+func TestAfterCommitRunsOnlyOnCommit(t *testing.T) {
+	f := newTxFixture(t)
+	var ran []string
+	record := func(name string) func() {
+		return func() { ran = append(ran, name) }
+	}
+
+	err := f.tx.WithinTx(context.Background(), func(ctx context.Context) error {
+		f.tx.AfterCommit(ctx, record("outer"))
+		f.tx.WithinTx(ctx, func(ctx context.Context) error {
+			f.tx.AfterCommit(ctx, record("released savepoint"))
+			return nil
+		})
+		f.tx.WithinTx(ctx, func(ctx context.Context) error {
+			f.tx.AfterCommit(ctx, record("rolled back savepoint"))
+			return errStep
+		})
+		if len(ran) != 0 {
+			t.Errorf("callbacks ran before commit: %v", ran)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithinTx: %v", err)
+	}
+	if len(ran) != 2 || ran[0] != "outer" || ran[1] != "released savepoint" {
+		t.Fatalf("callbacks = %v, want [outer released savepoint]", ran)
+	}
+
+	ran = nil
+	f.tx.WithinTx(context.Background(), func(ctx context.Context) error {
+		f.tx.AfterCommit(ctx, record("rolled back"))
+		return errStep
+	})
+	if len(ran) != 0 {
+		t.Fatalf("callbacks ran after rollback: %v", ran)
+	}
+}
+
+func TestAfterCommitWithoutTxRunsStraightAway(t *testing.T) {
+	var ran bool
+	NewTxManager(nil).AfterCommit(context.Background(), func() { ran = true })
+	if !ran {
+		t.Fatal("AfterCommit outside a transaction did not run")
+	}
+}
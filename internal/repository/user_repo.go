@@ -15,6 +15,9 @@ type UserRepository interface {
 	GetByEmail(ctx context.Context, email string) (*domain.User, error)
 	// Update updates a user
 	Update(ctx context.Context, user *domain.User) (*domain.User, error)
+	// UpdatePreferredCurrency sets the currency a user is priced in, empty
+	// clears it
+	UpdatePreferredCurrency(ctx context.Context, id uint, currency string) error
 }
 
 type userRepository struct {
@@ -26,7 +29,7 @@ func NewUserRepository(db *gorm.DB) UserRepository {
 }
 
 func (r *userRepository) Create(ctx context.Context, user *domain.User) (*domain.User, error) {
-	err := r.DB.WithContext(ctx).Create(user).Error
+	err := conn(ctx, r.DB).Create(user).Error
 	if err != nil {
 		return nil, err
 	}
@@ -35,7 +38,7 @@ func (r *userRepository) Create(ctx context.Context, user *domain.User) (*domain
 
 func (r *userRepository) GetByID(ctx context.Context, id uint) (*domain.User, error) {
 	user := &domain.User{}
-	err := r.DB.WithContext(ctx).First(user, id).Error
+	err := conn(ctx, r.DB).First(user, id).Error
 	if err != nil {
 		return nil, err
 	}
@@ -44,7 +47,7 @@ func (r *userRepository) GetByID(ctx context.Context, id uint) (*domain.User, er
 
 func (r *userRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
 	user := &domain.User{}
-	err := r.DB.WithContext(ctx).Where("email = ?", email).First(user).Error
+	err := conn(ctx, r.DB).Where("email = ?", email).First(user).Error
 	if err != nil {
 		return nil, err
 	}
@@ -52,9 +55,14 @@ func (r *userRepository) GetByEmail(ctx context.Context, email string) (*domain.
 }
 
 func (r *userRepository) Update(ctx context.Context, user *domain.User) (*domain.User, error) {
-	err := r.DB.WithContext(ctx).Model(user).Updates(user).Error
+	err := conn(ctx, r.DB).Model(user).Updates(user).Error
 	if err != nil {
 		return nil, err
 	}
 	return user, nil
 }
+
+func (r *userRepository) UpdatePreferredCurrency(ctx context.Context, id uint, currency string) error {
+	return conn(ctx, r.DB).Model(&domain.User{}).Where("id = ?", id).
+		Update("preferred_currency", currency).Error
+}
@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WarehouseRepository interface {
+	Create(ctx context.Context, warehouse *domain.Warehouse) error
+	GetByID(ctx context.Context, id uint) (*domain.Warehouse, error)
+	GetByCode(ctx context.Context, code string) (*domain.Warehouse, error)
+	GetDefault(ctx context.Context) (*domain.Warehouse, error)
+	List(ctx context.Context) ([]domain.Warehouse, error)
+	Update(ctx context.Context, warehouse *domain.Warehouse) error
+	// ClearDefault unsets the default flag on every warehouse
+	ClearDefault(ctx context.Context) error
+
+	// ListStock returns the per-warehouse stock of the given products
+	ListStock(ctx context.Context, productIDs []uint) ([]domain.WarehouseStock, error)
+	// ListStockByWarehouse returns every product stock held in a warehouse
+	ListStockByWarehouse(ctx context.Context, warehouseID uint) ([]domain.WarehouseStock, error)
+	// ReserveStock atomically holds quantity units of a warehouse's available
+	// stock, returning ErrInsufficientStock if not enough is available
+	ReserveStock(ctx context.Context, warehouseID, productID uint, quantity int) error
+	// ReleaseStock returns quantity reserved units to the warehouse
+	ReleaseStock(ctx context.Context, warehouseID, productID uint, quantity int) error
+	// CommitStock turns quantity reserved units into a permanent decrement
+	CommitStock(ctx context.Context, warehouseID, productID uint, quantity int) error
+	// AdjustStock atomically changes a warehouse's stock by delta, returning
+	// ErrInsufficientStock if it would drop below what is reserved
+	AdjustStock(ctx context.Context, warehouseID, productID uint, delta int) error
+
+	CreateTransfer(ctx context.Context, transfer *domain.StockTransfer) error
+	// ListTransfers returns the most recent transfers, optionally of one product
+	ListTransfers(ctx context.Context, productID uint, limit int) ([]domain.StockTransfer, error)
+}
+
+type warehouseRepository struct {
+	DB *gorm.DB
+}
+
+func NewWarehouseRepository(db *gorm.DB) WarehouseRepository {
+	return &warehouseRepository{DB: db}
+}
+
+func (r *warehouseRepository) Create(ctx context.Context, warehouse *domain.Warehouse) error {
+	return conn(ctx, r.DB).Create(warehouse).Error
+}
+
+func (r *warehouseRepository) GetByID(ctx context.Context, id uint) (*domain.Warehouse, error) {
+	warehouse := &domain.Warehouse{}
+	err := conn(ctx, r.DB).First(warehouse, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return warehouse, nil
+}
+
+func (r *warehouseRepository) GetByCode(ctx context.Context, code string) (*domain.Warehouse, error) {
+	warehouse := &domain.Warehouse{}
+	err := conn(ctx, r.DB).Where("code = ?", code).First(warehouse).Error
+	if err != nil {
+		return nil, err
+	}
+	return warehouse, nil
+}
+
+func (r *warehouseRepository) GetDefault(ctx context.Context) (*domain.Warehouse, error) {
+	warehouse := &domain.Warehouse{}
+	err := conn(ctx, r.DB).Where("is_default = ?", true).First(warehouse).Error
+	if err != nil {
+		return nil, err
+	}
+	return warehouse, nil
+}
+
+func (r *warehouseRepository) List(ctx context.Context) ([]domain.Warehouse, error) {
+	var warehouses []domain.Warehouse
+	err := conn(ctx, r.DB).Order("id").Find(&warehouses).Error
+	if err != nil {
+		return nil, err
+	}
+	return warehouses, nil
+}
+
+func (r *warehouseRepository) Update(ctx context.Context, warehouse *domain.Warehouse) error {
+	return conn(ctx, r.DB).Save(warehouse).Error
+}
+
+func (r *warehouseRepository) ClearDefault(ctx context.Context) error {
+	return conn(ctx, r.DB).Model(&domain.Warehouse{}).
+		Where("is_default = ?", true).
+		Update("is_default", false).Error
+}
+
+func (r *warehouseRepository) ListStock(ctx context.Context, productIDs []uint) ([]domain.WarehouseStock, error) {
+	var stocks []domain.WarehouseStock
+	err := conn(ctx, r.DB).
+		Preload("Warehouse").
+		Where("product_id IN ?", productIDs).
+		Order("warehouse_id").
+		Find(&stocks).Error
+	if err != nil {
+		return nil, err
+	}
+	return stocks, nil
+}
+
+func (r *warehouseRepository) ListStockByWarehouse(ctx context.Context, warehouseID uint) ([]domain.WarehouseStock, error) {
+	var stocks []domain.WarehouseStock
+	err := conn(ctx, r.DB).Where("warehouse_id = ?", warehouseID).Order("product_id").Find(&stocks).Error
+	if err != nil {
+		return nil, err
+	}
+	return stocks, nil
+}
+
+func (r *warehouseRepository) ReserveStock(ctx context.Context, warehouseID, productID uint, quantity int) error {
+	result := conn(ctx, r.DB).Model(&domain.WarehouseStock{}).
+		Where("warehouse_id = ? AND product_id = ? AND stock - reserved >= ?", warehouseID, productID, quantity).
+		UpdateColumn("reserved", gorm.Expr("reserved + ?", quantity))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInsufficientStock
+	}
+	return nil
+}
+
+func (r *warehouseRepository) ReleaseStock(ctx context.Context, warehouseID, productID uint, quantity int) error {
+	return conn(ctx, r.DB).Model(&domain.WarehouseStock{}).
+		Where("warehouse_id = ? AND product_id = ?", warehouseID, productID).
+		UpdateColumn("reserved", gorm.Expr("GREATEST(reserved - ?, 0)", quantity)).Error
+}
+
+func (r *warehouseRepository) CommitStock(ctx context.Context, warehouseID, productID uint, quantity int) error {
+	return conn(ctx, r.DB).Model(&domain.WarehouseStock{}).
+		Where("warehouse_id = ? AND product_id = ?", warehouseID, productID).
+		UpdateColumns(map[string]interface{}{
+			"stock":    gorm.Expr("stock - ?", quantity),
+			"reserved": gorm.Expr("GREATEST(reserved - ?, 0)", quantity),
+		}).Error
+}
+
+func (r *warehouseRepository) AdjustStock(ctx context.Context, warehouseID, productID uint, delta int) error {
+	if delta > 0 {
+		// Incoming stock creates the warehouse's row for the product if needed
+		return conn(ctx, r.DB).Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "warehouse_id"}, {Name: "product_id"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"stock":      gorm.Expr("warehouse_stocks.stock + ?", delta),
+				"updated_at": gorm.Expr("CURRENT_TIMESTAMP"),
+			}),
+		}).Create(&domain.WarehouseStock{
+			WarehouseID: warehouseID,
+			ProductID:   productID,
+			Stock:       delta,
+		}).Error
+	}
+
+	result := conn(ctx, r.DB).Model(&domain.WarehouseStock{}).
+		Where("warehouse_id = ? AND product_id = ? AND stock + ? >= reserved", warehouseID, productID, delta).
+		UpdateColumn("stock", gorm.Expr("stock + ?", delta))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInsufficientStock
+	}
+	return nil
+}
+
+func (r *warehouseRepository) CreateTransfer(ctx context.Context, transfer *domain.StockTransfer) error {
+	return conn(ctx, r.DB).Create(transfer).Error
+}
+
+func (r *warehouseRepository) ListTransfers(ctx context.Context, productID uint, limit int) ([]domain.StockTransfer, error) {
+	var transfers []domain.StockTransfer
+	query := conn(ctx, r.DB).Order("id DESC").Limit(limit)
+	if productID != 0 {
+		query = query.Where("product_id = ?", productID)
+	}
+	if err := query.Find(&transfers).Error; err != nil {
+		return nil, err
+	}
+	return transfers, nil
+}
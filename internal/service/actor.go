@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+)
+
+// Actor is the user a request is made by, it is recorded on the events the
+// request causes
+type Actor struct {
+	UserID  uint
+	IsAdmin bool
+}
+
+type actorKey struct{}
+
+// WithActor returns a context carrying the actor of a request. Work done
+// without one, such as background jobs, is attributed to the system.
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// actorFrom returns the actor of ctx as recorded on events
+func actorFrom(ctx context.Context) (domain.ActorType, *uint) {
+	actor, ok := ctx.Value(actorKey{}).(Actor)
+	if !ok || actor.UserID == 0 {
+		return domain.ActorSystem, nil
+	}
+	if actor.IsAdmin {
+		return domain.ActorAdmin, &actor.UserID
+	}
+	return domain.ActorCustomer, &actor.UserID
+}
+
+// actorID returns the user ID of the actor of ctx, nil for the system
+func actorID(ctx context.Context) *uint {
+	_, id := actorFrom(ctx)
+	return id
+}
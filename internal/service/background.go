@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"sync"
+)
+
+// background runs work that outlives the request that started it and lets
+// shutdown wait for it
+type background struct {
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newBackground() *background {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &background{ctx: ctx, cancel: cancel}
+}
+
+// Go runs fn in a goroutine. Its context is cancelled when a shutdown gives
+// up waiting.
+func (b *background) Go(fn func(ctx context.Context)) {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		fn(b.ctx)
+	}()
+}
+
+// Shutdown waits for the running work to finish. If ctx ends first the
+// work is cancelled and waited for again.
+func (b *background) Shutdown(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		b.cancel()
+		<-done
+	}
+}
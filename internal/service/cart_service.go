@@ -0,0 +1,354 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/internal/repository"
+	"github.com/Dubjay18/ecom-api/pkg/common"
+	"github.com/Dubjay18/ecom-api/pkg/money"
+	"gorm.io/gorm"
+)
+
+type CartService struct {
+	tx          repository.TxManager
+	repo        repository.CartRepository
+	productRepo repository.ProductRepository
+	pricing     *PricingService
+	orders      *OrderService
+}
+
+func NewCartService(tx repository.TxManager, repo repository.CartRepository, pr repository.ProductRepository, pricing *PricingService, orders *OrderService) *CartService {
+	return &CartService{tx: tx, repo: repo, productRepo: pr, pricing: pricing, orders: orders}
+}
+
+// Get returns the owner's cart priced in currency, an owner without a cart
+// gets an empty one
+func (s *CartService) Get(ctx context.Context, owner domain.CartOwner, currency string) (*domain.Cart, *common.AppError) {
+	cart, aerr := s.find(ctx, owner)
+	if aerr != nil {
+		return nil, aerr
+	}
+	if cart == nil {
+		cart = &domain.Cart{}
+	}
+	return cart, s.price(ctx, cart, owner, currency)
+}
+
+// AddItem adds quantity units of a product to the owner's cart, creating
+// the cart if needed
+func (s *CartService) AddItem(ctx context.Context, owner domain.CartOwner, req *domain.AddCartItemRequest, currency string) (*domain.Cart, *common.AppError) {
+	cart, aerr := s.findOrCreate(ctx, owner)
+	if aerr != nil {
+		return nil, aerr
+	}
+
+	quantity := req.Quantity
+	for _, item := range cart.Items {
+		if item.ProductID == req.ProductID {
+			quantity += item.Quantity
+		}
+	}
+	return s.setQuantity(ctx, cart, owner, req.ProductID, quantity, currency)
+}
+
+// UpdateItem sets the quantity of a product already in the owner's cart
+func (s *CartService) UpdateItem(ctx context.Context, owner domain.CartOwner, productID uint, quantity int, currency string) (*domain.Cart, *common.AppError) {
+	cart, aerr := s.find(ctx, owner)
+	if aerr != nil {
+		return nil, aerr
+	}
+	if cart == nil || !hasProduct(cart, productID) {
+		return nil, common.NewAppError(nil, "Product is not in the cart", http.StatusNotFound)
+	}
+	return s.setQuantity(ctx, cart, owner, productID, quantity, currency)
+}
+
+// RemoveItem takes a product out of the owner's cart
+func (s *CartService) RemoveItem(ctx context.Context, owner domain.CartOwner, productID uint, currency string) (*domain.Cart, *common.AppError) {
+	cart, aerr := s.find(ctx, owner)
+	if aerr != nil {
+		return nil, aerr
+	}
+	if cart == nil {
+		return nil, common.NewAppError(nil, "Product is not in the cart", http.StatusNotFound)
+	}
+	deleted, err := s.repo.DeleteItem(ctx, cart.ID, productID)
+	if err != nil {
+		return nil, common.NewAppError(err, "Failed to remove cart item", common.ErrInternalServer.Code)
+	}
+	if deleted == 0 {
+		return nil, common.NewAppError(nil, "Product is not in the cart", http.StatusNotFound)
+	}
+	return s.reload(ctx, cart, owner, currency)
+}
+
+// Clear empties the owner's cart
+func (s *CartService) Clear(ctx context.Context, owner domain.CartOwner) *common.AppError {
+	cart, aerr := s.find(ctx, owner)
+	if aerr != nil || cart == nil {
+		return aerr
+	}
+	if err := s.repo.ClearItems(ctx, cart.ID); err != nil {
+		return common.NewAppError(err, "Failed to clear cart", common.ErrInternalServer.Code)
+	}
+	return nil
+}
+
+// Merge moves the items of an anonymous cart into the user's cart, adding
+// up quantities of products in both. The anonymous cart is removed.
+func (s *CartService) Merge(ctx context.Context, token string, userID uint) *common.AppError {
+	return inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+		anonymous, err := s.repo.GetByToken(ctx, token)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return common.NewAppError(err, "Failed to get cart", common.ErrInternalServer.Code)
+		}
+
+		cart, err := s.repo.GetByUser(ctx, userID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// The anonymous cart simply becomes the user's
+			if err := s.repo.AssignToUser(ctx, anonymous.ID, userID); err != nil {
+				return common.NewAppError(err, "Failed to merge cart", common.ErrInternalServer.Code)
+			}
+			return nil
+		}
+		if err != nil {
+			return common.NewAppError(err, "Failed to get cart", common.ErrInternalServer.Code)
+		}
+
+		quantities := make(map[uint]int, len(cart.Items))
+		for _, item := range cart.Items {
+			quantities[item.ProductID] = item.Quantity
+		}
+		for _, item := range anonymous.Items {
+			merged := &domain.CartItem{
+				CartID:    cart.ID,
+				ProductID: item.ProductID,
+				Quantity:  quantities[item.ProductID] + item.Quantity,
+			}
+			if err := s.repo.SaveItem(ctx, merged); err != nil {
+				return common.NewAppError(err, "Failed to merge cart", common.ErrInternalServer.Code)
+			}
+		}
+		if err := s.repo.Delete(ctx, anonymous.ID); err != nil {
+			return common.NewAppError(err, "Failed to merge cart", common.ErrInternalServer.Code)
+		}
+		return s.touch(ctx, cart.ID)
+	})
+}
+
+// Checkout places an order for everything in the user's cart and empties
+// it, both or neither happen
+func (s *CartService) Checkout(ctx context.Context, userID uint, req *domain.CheckoutRequest) (*domain.Order, *common.AppError) {
+	cart, err := s.repo.GetByUser(ctx, userID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, common.NewAppError(err, "Failed to get cart", common.ErrInternalServer.Code)
+	}
+	if cart == nil || len(cart.Items) == 0 {
+		return nil, common.NewAppError(nil, "Cart is empty", http.StatusBadRequest)
+	}
+
+	orderReq := &domain.CreateOrderRequest{
+		Items:         make([]domain.CreateOrderItem, len(cart.Items)),
+		ShippingAddr:  req.ShippingAddr,
+		PaymentMethod: req.PaymentMethod,
+		Currency:      req.Currency,
+		CouponCode:    req.CouponCode,
+	}
+	for i, item := range cart.Items {
+		orderReq.Items[i] = domain.CreateOrderItem{ProductID: item.ProductID, Quantity: item.Quantity}
+	}
+
+	var order *domain.Order
+	aerr := inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+		var aerr *common.AppError
+		order, aerr = s.orders.PlaceOrder(ctx, userID, orderReq)
+		if aerr != nil {
+			return aerr
+		}
+		if err := s.repo.ClearItems(ctx, cart.ID); err != nil {
+			return common.NewAppError(err, "Failed to clear cart", common.ErrInternalServer.Code)
+		}
+		return nil
+	})
+	if aerr != nil {
+		return nil, aerr
+	}
+	return order, nil
+}
+
+// PurgeAbandoned deletes anonymous carts unused for longer than retention
+func (s *CartService) PurgeAbandoned(ctx context.Context, retention time.Duration) (int64, error) {
+	return s.repo.PurgeAnonymous(ctx, time.Now().Add(-retention))
+}
+
+// setQuantity checks the product can be ordered in quantity and saves it
+func (s *CartService) setQuantity(ctx context.Context, cart *domain.Cart, owner domain.CartOwner, productID uint, quantity int, currency string) (*domain.Cart, *common.AppError) {
+	product, err := s.productRepo.GetByID(ctx, productID)
+	if err != nil || !product.IsVisible() {
+		return nil, common.NewAppError(err, "Product not found", http.StatusNotFound)
+	}
+	if _, ok := product.Backorders(quantity); !ok {
+		return nil, common.NewAppError(nil, fmt.Sprintf("Insufficient stock for product, %d available", max(product.Available(), 0)), http.StatusBadRequest)
+	}
+
+	item := &domain.CartItem{CartID: cart.ID, ProductID: productID, Quantity: quantity}
+	if err := s.repo.SaveItem(ctx, item); err != nil {
+		return nil, common.NewAppError(err, "Failed to save cart item", common.ErrInternalServer.Code)
+	}
+	if aerr := s.touch(ctx, cart.ID); aerr != nil {
+		return nil, aerr
+	}
+	return s.reload(ctx, cart, owner, currency)
+}
+
+// price works out the unit prices, line totals and subtotal of a cart from
+// live prices, flagging items that can no longer be ordered as they are
+func (s *CartService) price(ctx context.Context, cart *domain.Cart, owner domain.CartOwner, currency string) *common.AppError {
+	currency, aerr := s.pricing.ResolveCurrency(ctx, currency, owner.UserID)
+	if aerr != nil {
+		return aerr
+	}
+	cart.Currency = currency
+	cart.CheckoutReady = len(cart.Items) > 0
+	cart.ItemCount = 0
+
+	var products []domain.Product
+	for _, item := range cart.Items {
+		if item.Product != nil && item.Product.IsVisible() {
+			products = append(products, *item.Product)
+		}
+	}
+	prices, _, aerr := s.pricing.Quote(ctx, products, currency)
+	if aerr != nil {
+		return aerr
+	}
+
+	lines := make([]money.Money, 0, len(cart.Items))
+	for i := range cart.Items {
+		item := &cart.Items[i]
+		cart.ItemCount += item.Quantity
+
+		if item.Product == nil || !item.Product.IsVisible() {
+			item.Issues = append(item.Issues, "Product is no longer available")
+			cart.CheckoutReady = false
+			continue
+		}
+		if _, ok := item.Product.Backorders(item.Quantity); !ok {
+			item.Issues = append(item.Issues, fmt.Sprintf("Only %d in stock", max(item.Product.Available(), 0)))
+			cart.CheckoutReady = false
+		}
+
+		unitPrice := prices[item.ProductID]
+		lineTotal, err := unitPrice.Mul(int64(item.Quantity))
+		if err != nil {
+			return common.NewAppError(err, "Cart total is too large", http.StatusBadRequest)
+		}
+		item.UnitPrice = unitPrice
+		item.LineTotal = lineTotal
+		if currency != item.Product.Price.Currency {
+			item.Product.DisplayPrice = &unitPrice
+		}
+		lines = append(lines, lineTotal)
+	}
+
+	subtotal, err := money.Sum(currency, lines...)
+	if err != nil {
+		return common.NewAppError(err, "Cart total is too large", http.StatusBadRequest)
+	}
+	cart.Subtotal = subtotal
+	return nil
+}
+
+// find returns the owner's cart, or nil if they do not have one
+func (s *CartService) find(ctx context.Context, owner domain.CartOwner) (*domain.Cart, *common.AppError) {
+	var cart *domain.Cart
+	var err error
+	switch {
+	case owner.UserID != 0:
+		cart, err = s.repo.GetByUser(ctx, owner.UserID)
+	case owner.Token != "":
+		cart, err = s.repo.GetByToken(ctx, owner.Token)
+	default:
+		return nil, nil
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, common.NewAppError(err, "Failed to get cart", common.ErrInternalServer.Code)
+	}
+	return cart, nil
+}
+
+// findOrCreate returns the owner's cart, creating it if they do not have
+// one. Anonymous owners are given a new token.
+func (s *CartService) findOrCreate(ctx context.Context, owner domain.CartOwner) (*domain.Cart, *common.AppError) {
+	cart, aerr := s.find(ctx, owner)
+	if aerr != nil || cart != nil {
+		return cart, aerr
+	}
+
+	cart = &domain.Cart{}
+	if owner.UserID != 0 {
+		cart.UserID = &owner.UserID
+	} else {
+		token, err := newCartToken()
+		if err != nil {
+			return nil, common.NewAppError(err, "Failed to create cart", common.ErrInternalServer.Code)
+		}
+		cart.Token = &token
+	}
+	if err := s.repo.Create(ctx, cart); err != nil {
+		return nil, common.NewAppError(err, "Failed to create cart", common.ErrInternalServer.Code)
+	}
+	return cart, nil
+}
+
+// reload reads a cart back after a change and prices it
+func (s *CartService) reload(ctx context.Context, cart *domain.Cart, owner domain.CartOwner, currency string) (*domain.Cart, *common.AppError) {
+	if cart.Token != nil {
+		owner.Token = *cart.Token
+	}
+	reloaded, aerr := s.find(ctx, owner)
+	if aerr != nil {
+		return nil, aerr
+	}
+	if reloaded == nil {
+		return nil, common.NewAppError(nil, "Cart not found", http.StatusNotFound)
+	}
+	return reloaded, s.price(ctx, reloaded, owner, currency)
+}
+
+func (s *CartService) touch(ctx context.Context, cartID uint) *common.AppError {
+	if err := s.repo.Touch(ctx, cartID); err != nil {
+		return common.NewAppError(err, "Failed to update cart", common.ErrInternalServer.Code)
+	}
+	return nil
+}
+
+func hasProduct(cart *domain.Cart, productID uint) bool {
+	for _, item := range cart.Items {
+		if item.ProductID == productID {
+			return true
+		}
+	}
+	return false
+}
+
+func newCartToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/internal/repository"
+	"github.com/Dubjay18/ecom-api/pkg/common"
+)
+
+// idempotencyLockTimeout is how long an in-flight request holds its key. It
+// is well past the server's write timeout, so a key is only freed early if
+// the process died before storing the response.
+const idempotencyLockTimeout = time.Minute
+
+type IdempotencyService struct {
+	repo repository.IdempotencyRepository
+	ttl  time.Duration
+}
+
+func NewIdempotencyService(repo repository.IdempotencyRepository, ttl time.Duration) *IdempotencyService {
+	return &IdempotencyService{repo: repo, ttl: ttl}
+}
+
+// Begin claims key for a request of userID with fingerprint. It returns the
+// completed record to replay if the request was already made, otherwise the
+// claimed record and the caller runs the request. A different request under
+// the same key is a 422 and a retry of a request still in flight a 409.
+func (s *IdempotencyService) Begin(ctx context.Context, userID uint, key, fingerprint string) (*domain.IdempotencyKey, *common.AppError) {
+	record := &domain.IdempotencyKey{
+		UserID:      userID,
+		Key:         key,
+		Fingerprint: fingerprint,
+		ExpiresAt:   time.Now().Add(idempotencyLockTimeout),
+	}
+	claimed, err := s.repo.Claim(ctx, record)
+	if err != nil {
+		return nil, common.NewAppError(err, "Failed to store idempotency key", common.ErrInternalServer.Code)
+	}
+	if claimed {
+		return record, nil
+	}
+
+	existing, err := s.repo.Get(ctx, userID, key)
+	if err != nil {
+		return nil, common.NewAppError(err, "Failed to get idempotency key", common.ErrInternalServer.Code)
+	}
+	if existing.Fingerprint != fingerprint {
+		return nil, common.NewAppError(nil, "Idempotency key was already used for a different request", http.StatusUnprocessableEntity)
+	}
+	if !existing.IsComplete() {
+		return nil, common.NewAppError(nil, "A request with this idempotency key is still in progress", http.StatusConflict)
+	}
+	return existing, nil
+}
+
+// Complete stores the response of the request that claimed key, retries
+// get it until the key expires
+func (s *IdempotencyService) Complete(ctx context.Context, record *domain.IdempotencyKey, statusCode int, contentType string, body []byte) error {
+	return s.repo.Complete(ctx, record.ID, statusCode, contentType, body, time.Now().Add(s.ttl))
+}
+
+// Release frees key so the request can be retried, it is used when the
+// request failed without a result worth replaying
+func (s *IdempotencyService) Release(ctx context.Context, record *domain.IdempotencyKey) error {
+	return s.repo.Delete(ctx, record.ID)
+}
+
+// PurgeExpired deletes expired keys
+func (s *IdempotencyService) PurgeExpired(ctx context.Context) (int64, error) {
+	return s.repo.PurgeExpired(ctx, time.Now())
+}
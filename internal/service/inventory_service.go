@@ -0,0 +1,588 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/internal/repository"
+	"github.com/Dubjay18/ecom-api/pkg/common"
+)
+
+type InventoryService struct {
+	tx              repository.TxManager
+	productRepo     repository.ProductRepository
+	reservationRepo repository.ReservationRepository
+	ledger          repository.StockMovementRepository
+	warehouses      repository.WarehouseRepository
+	reservationTTL  time.Duration
+	observers       []func(productIDs []uint)
+}
+
+func NewInventoryService(tx repository.TxManager, pr repository.ProductRepository, rr repository.ReservationRepository, ledger repository.StockMovementRepository, wr repository.WarehouseRepository, reservationTTL time.Duration) *InventoryService {
+	return &InventoryService{tx: tx, productRepo: pr, reservationRepo: rr, ledger: ledger, warehouses: wr, reservationTTL: reservationTTL}
+}
+
+// OnStockChange registers fn to be called with the products whose available
+// stock changed, once the change has committed
+func (s *InventoryService) OnStockChange(fn func(productIDs []uint)) {
+	s.observers = append(s.observers, fn)
+}
+
+// StockChanged tells the registered observers that the available stock of
+// the given products changed in ctx's unit of work
+func (s *InventoryService) StockChanged(ctx context.Context, productIDs ...uint) {
+	if len(s.observers) == 0 || len(productIDs) == 0 {
+		return
+	}
+	s.tx.AfterCommit(ctx, func() {
+		for _, fn := range s.observers {
+			fn(productIDs)
+		}
+	})
+}
+
+// Allocate picks the warehouse each order item ships its in-stock part
+// from and sets its WarehouseID. The nearest active warehouse that can fill
+// the whole order is preferred, otherwise each item ships from the nearest
+// warehouse that has enough of it available.
+func (s *InventoryService) Allocate(ctx context.Context, addr *domain.Address, items []domain.OrderItem) *common.AppError {
+	productIDs := make([]uint, len(items))
+	for i, item := range items {
+		productIDs[i] = item.ProductID
+	}
+	candidates, available, aerr := s.candidateWarehouses(ctx, addr, productIDs)
+	if aerr != nil {
+		return aerr
+	}
+
+	// Fully backordered items wait for stock and are allocated later
+	canFill := func(warehouseID uint, item domain.OrderItem) bool {
+		return item.InStockQuantity() == 0 || available[warehouseID][item.ProductID] >= item.InStockQuantity()
+	}
+
+	// Items of the same product draw on the same stock, a warehouse fills
+	// the order if it has all of each product's units
+	needed := make(map[uint]int, len(items))
+	for _, item := range items {
+		needed[item.ProductID] += item.InStockQuantity()
+	}
+
+	for _, w := range candidates {
+		fillsOrder := true
+		for productID, quantity := range needed {
+			if available[w.ID][productID] < quantity {
+				fillsOrder = false
+				break
+			}
+		}
+		if fillsOrder {
+			for i := range items {
+				if items[i].InStockQuantity() > 0 {
+					items[i].WarehouseID = w.ID
+				}
+			}
+			return nil
+		}
+	}
+
+	for i, item := range items {
+		if item.InStockQuantity() == 0 {
+			continue
+		}
+		for _, w := range candidates {
+			if canFill(w.ID, item) {
+				items[i].WarehouseID = w.ID
+				available[w.ID][item.ProductID] -= item.InStockQuantity()
+				break
+			}
+		}
+		if items[i].WarehouseID == 0 {
+			return common.NewAppError(nil, "Insufficient stock for product", http.StatusBadRequest)
+		}
+	}
+	return nil
+}
+
+// candidateWarehouses returns the active warehouses holding any of the
+// products, nearest to addr first, along with their available stock per
+// warehouse and product
+func (s *InventoryService) candidateWarehouses(ctx context.Context, addr *domain.Address, productIDs []uint) ([]domain.Warehouse, map[uint]map[uint]int, *common.AppError) {
+	stocks, err := s.warehouses.ListStock(ctx, productIDs)
+	if err != nil {
+		return nil, nil, common.NewAppError(err, "Failed to get warehouse stock", common.ErrInternalServer.Code)
+	}
+
+	available := make(map[uint]map[uint]int)
+	var candidates []domain.Warehouse
+	for _, stock := range stocks {
+		if !stock.Warehouse.IsActive {
+			continue
+		}
+		if _, ok := available[stock.WarehouseID]; !ok {
+			available[stock.WarehouseID] = make(map[uint]int)
+			candidates = append(candidates, stock.Warehouse)
+		}
+		available[stock.WarehouseID][stock.ProductID] = stock.Available()
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		di, dj := candidates[i].Distance(addr), candidates[j].Distance(addr)
+		if di != dj {
+			return di < dj
+		}
+		return candidates[i].ID < candidates[j].ID
+	})
+	return candidates, available, nil
+}
+
+// Reserve holds stock for the in-stock part of every order item in the
+// warehouse it was allocated to until the reservation TTL passes, and counts
+// the rest as backordered. It fails without holding anything if any item is
+// short and cannot be backordered.
+func (s *InventoryService) Reserve(ctx context.Context, orderID uint, items []domain.OrderItem) *common.AppError {
+	expiresAt := time.Now().Add(s.reservationTTL)
+	reservations := make([]domain.StockReservation, 0, len(items))
+
+	// Rows are locked in product and warehouse order so that concurrent
+	// orders over the same products cannot deadlock each other
+	items = slices.Clone(items)
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].ProductID != items[j].ProductID {
+			return items[i].ProductID < items[j].ProductID
+		}
+		return items[i].WarehouseID < items[j].WarehouseID
+	})
+
+	return inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+		for _, item := range items {
+			if item.IsBackordered() {
+				if err := s.productRepo.HoldBackorder(ctx, item.ProductID, item.BackorderedQuantity); err != nil {
+					if errors.Is(err, repository.ErrInsufficientStock) {
+						return common.NewAppError(err, "Insufficient stock for product", http.StatusBadRequest)
+					}
+					return common.NewAppError(err, "Failed to backorder product", common.ErrInternalServer.Code)
+				}
+			}
+
+			quantity := item.InStockQuantity()
+			if quantity == 0 {
+				continue
+			}
+			err := s.productRepo.ReserveStock(ctx, item.ProductID, quantity)
+			if err == nil {
+				err = s.warehouses.ReserveStock(ctx, item.WarehouseID, item.ProductID, quantity)
+			}
+			if err != nil {
+				if errors.Is(err, repository.ErrInsufficientStock) {
+					return common.NewAppError(err, "Insufficient stock for product", http.StatusBadRequest)
+				}
+				return common.NewAppError(err, "Failed to reserve product stock", common.ErrInternalServer.Code)
+			}
+			reservations = append(reservations, domain.StockReservation{
+				OrderID:     orderID,
+				ProductID:   item.ProductID,
+				WarehouseID: item.WarehouseID,
+				Quantity:    quantity,
+				Status:      domain.ReservationActive,
+				ExpiresAt:   expiresAt,
+			})
+		}
+		if len(reservations) == 0 {
+			return nil
+		}
+		if err := s.reservationRepo.CreateBatch(ctx, reservations); err != nil {
+			return common.NewAppError(err, "Failed to create stock reservations", common.ErrInternalServer.Code)
+		}
+
+		productIDs := make([]uint, len(reservations))
+		for i, r := range reservations {
+			productIDs[i] = r.ProductID
+		}
+		s.StockChanged(ctx, productIDs...)
+		return nil
+	})
+}
+
+// ReserveBackorder reserves up to quantity units of a product for a
+// backordered order item from the warehouses nearest to addr, taking from
+// several warehouses if needed. The reserved units stop counting as
+// backordered. It returns the units reserved and the first warehouse used.
+func (s *InventoryService) ReserveBackorder(ctx context.Context, orderID uint, addr *domain.Address, productID uint, quantity int) (int, uint, *common.AppError) {
+	var reserved int
+	var firstWarehouseID uint
+	aerr := inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+		candidates, available, aerr := s.candidateWarehouses(ctx, addr, []uint{productID})
+		if aerr != nil {
+			return aerr
+		}
+
+		expiresAt := time.Now().Add(s.reservationTTL)
+		var reservations []domain.StockReservation
+		for _, w := range candidates {
+			take := available[w.ID][productID]
+			if take > quantity-reserved {
+				take = quantity - reserved
+			}
+			if take <= 0 {
+				continue
+			}
+
+			// A savepoint keeps a warehouse that ran short since it was listed
+			// from leaving the product total reserved
+			err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+				if err := s.productRepo.ReserveStock(ctx, productID, take); err != nil {
+					return err
+				}
+				return s.warehouses.ReserveStock(ctx, w.ID, productID, take)
+			})
+			if errors.Is(err, repository.ErrInsufficientStock) {
+				continue
+			}
+			if err != nil {
+				return common.NewAppError(err, "Failed to reserve product stock", common.ErrInternalServer.Code)
+			}
+
+			reservations = append(reservations, domain.StockReservation{
+				OrderID:     orderID,
+				ProductID:   productID,
+				WarehouseID: w.ID,
+				Quantity:    take,
+				Status:      domain.ReservationActive,
+				ExpiresAt:   expiresAt,
+			})
+			if firstWarehouseID == 0 {
+				firstWarehouseID = w.ID
+			}
+			reserved += take
+			if reserved == quantity {
+				break
+			}
+		}
+		if reserved == 0 {
+			return nil
+		}
+
+		if err := s.reservationRepo.CreateBatch(ctx, reservations); err != nil {
+			return common.NewAppError(err, "Failed to create stock reservations", common.ErrInternalServer.Code)
+		}
+		if err := s.productRepo.ReleaseBackorder(ctx, productID, reserved); err != nil {
+			return common.NewAppError(err, "Failed to update backordered stock", common.ErrInternalServer.Code)
+		}
+		s.StockChanged(ctx, productID)
+		return nil
+	})
+	if aerr != nil {
+		return 0, 0, aerr
+	}
+	return reserved, firstWarehouseID, nil
+}
+
+// CancelBackorders stops counting the waiting units of order items as
+// backordered and clears them from the items
+func (s *InventoryService) CancelBackorders(ctx context.Context, items []domain.OrderItem) *common.AppError {
+	for i := range items {
+		if !items[i].IsBackordered() {
+			continue
+		}
+		if err := s.productRepo.ReleaseBackorder(ctx, items[i].ProductID, items[i].BackorderedQuantity); err != nil {
+			return common.NewAppError(err, "Failed to update backordered stock", common.ErrInternalServer.Code)
+		}
+		items[i].BackorderedQuantity = 0
+	}
+	return nil
+}
+
+// Commit permanently takes an order's reserved stock and records the sale
+// in the ledger, it returns the number of reservations committed
+func (s *InventoryService) Commit(ctx context.Context, orderID uint) (int, *common.AppError) {
+	return s.settle(ctx, orderID, domain.ReservationCommitted, func(ctx context.Context, r domain.StockReservation) error {
+		if err := s.productRepo.CommitStock(ctx, r.ProductID, r.Quantity); err != nil {
+			return err
+		}
+		if err := s.warehouses.CommitStock(ctx, r.WarehouseID, r.ProductID, r.Quantity); err != nil {
+			return err
+		}
+		return s.record(ctx, r.ProductID, -r.Quantity, domain.StockChange{
+			WarehouseID: r.WarehouseID,
+			Type:        domain.MovementSale,
+			Reference:   OrderReference(orderID),
+		})
+	})
+}
+
+// Release returns an order's reserved stock to available stock, it returns
+// the number of reservations released
+func (s *InventoryService) Release(ctx context.Context, orderID uint) (int, *common.AppError) {
+	return s.settle(ctx, orderID, domain.ReservationReleased, func(ctx context.Context, r domain.StockReservation) error {
+		if err := s.productRepo.ReleaseStock(ctx, r.ProductID, r.Quantity); err != nil {
+			return err
+		}
+		s.StockChanged(ctx, r.ProductID)
+		return s.warehouses.ReleaseStock(ctx, r.WarehouseID, r.ProductID, r.Quantity)
+	})
+}
+
+// Adjust changes a product's on-hand stock in a warehouse by delta and
+// records why, stock changes without a warehouse go to the default one
+func (s *InventoryService) Adjust(ctx context.Context, productID uint, delta int, change domain.StockChange) (*domain.StockMovement, *common.AppError) {
+	if delta == 0 {
+		return nil, common.NewAppError(nil, "Adjustment quantity must not be zero", http.StatusBadRequest)
+	}
+
+	var movement *domain.StockMovement
+	aerr := inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+		if _, err := s.productRepo.GetByIDIncludingArchived(ctx, productID); err != nil {
+			return common.NewAppError(err, "Product not found", http.StatusNotFound)
+		}
+		warehouseID, aerr := s.resolveWarehouse(ctx, change.WarehouseID)
+		if aerr != nil {
+			return aerr
+		}
+		change.WarehouseID = warehouseID
+
+		err := s.productRepo.AdjustStock(ctx, productID, delta)
+		if err == nil {
+			err = s.warehouses.AdjustStock(ctx, warehouseID, productID, delta)
+		}
+		if err != nil {
+			if errors.Is(err, repository.ErrInsufficientStock) {
+				return common.NewAppError(err, "Stock cannot drop below reserved or zero", http.StatusBadRequest)
+			}
+			return common.NewAppError(err, "Failed to adjust product stock", common.ErrInternalServer.Code)
+		}
+
+		movement = newMovement(productID, delta, change)
+		if err := s.ledger.Create(ctx, movement); err != nil {
+			return common.NewAppError(err, "Failed to record stock movement", common.ErrInternalServer.Code)
+		}
+		s.StockChanged(ctx, productID)
+		return nil
+	})
+	return movement, aerr
+}
+
+// SetStock moves a product's on-hand stock to an absolute level, recording
+// the difference as a single movement in the change's warehouse
+func (s *InventoryService) SetStock(ctx context.Context, productID uint, stock int, change domain.StockChange) *common.AppError {
+	return inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+		product, err := s.productRepo.GetForUpdate(ctx, productID)
+		if err != nil {
+			return common.NewAppError(err, "Product not found", http.StatusNotFound)
+		}
+		if product.Stock == stock {
+			return nil
+		}
+		_, aerr := s.Adjust(ctx, productID, stock-product.Stock, change)
+		return aerr
+	})
+}
+
+// RecordOpeningStock places the stock a product was created with in a
+// warehouse and records it
+func (s *InventoryService) RecordOpeningStock(ctx context.Context, product *domain.Product, change domain.StockChange) *common.AppError {
+	if product.Stock == 0 {
+		return nil
+	}
+	return inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+		warehouseID, aerr := s.resolveWarehouse(ctx, change.WarehouseID)
+		if aerr != nil {
+			return aerr
+		}
+		change.WarehouseID = warehouseID
+
+		if err := s.warehouses.AdjustStock(ctx, warehouseID, product.ID, product.Stock); err != nil {
+			return common.NewAppError(err, "Failed to update warehouse stock", common.ErrInternalServer.Code)
+		}
+		if err := s.record(ctx, product.ID, product.Stock, change); err != nil {
+			return common.NewAppError(err, "Failed to record stock movement", common.ErrInternalServer.Code)
+		}
+		return nil
+	})
+}
+
+// Transfer moves stock of a product from one warehouse to another. The
+// product's total stock is unchanged, both sides are written to the ledger.
+func (s *InventoryService) Transfer(ctx context.Context, userID uint, req *domain.StockTransferRequest) (*domain.StockTransfer, *common.AppError) {
+	transfer := &domain.StockTransfer{
+		ProductID:       req.ProductID,
+		FromWarehouseID: req.FromWarehouseID,
+		ToWarehouseID:   req.ToWarehouseID,
+		Quantity:        req.Quantity,
+		UserID:          userID,
+		Reason:          req.Reason,
+	}
+
+	aerr := inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+		if _, err := s.productRepo.GetByIDIncludingArchived(ctx, req.ProductID); err != nil {
+			return common.NewAppError(err, "Product not found", http.StatusNotFound)
+		}
+		if _, err := s.warehouses.GetByID(ctx, req.FromWarehouseID); err != nil {
+			return common.NewAppError(err, "Source warehouse not found", http.StatusNotFound)
+		}
+		to, err := s.warehouses.GetByID(ctx, req.ToWarehouseID)
+		if err != nil {
+			return common.NewAppError(err, "Destination warehouse not found", http.StatusNotFound)
+		}
+		if !to.IsActive {
+			return common.NewAppError(nil, "Destination warehouse is not active", http.StatusBadRequest)
+		}
+
+		if err := s.warehouses.AdjustStock(ctx, req.FromWarehouseID, req.ProductID, -req.Quantity); err != nil {
+			if errors.Is(err, repository.ErrInsufficientStock) {
+				return common.NewAppError(err, "Insufficient available stock in source warehouse", http.StatusBadRequest)
+			}
+			return common.NewAppError(err, "Failed to update warehouse stock", common.ErrInternalServer.Code)
+		}
+		if err := s.warehouses.AdjustStock(ctx, req.ToWarehouseID, req.ProductID, req.Quantity); err != nil {
+			return common.NewAppError(err, "Failed to update warehouse stock", common.ErrInternalServer.Code)
+		}
+		if err := s.warehouses.CreateTransfer(ctx, transfer); err != nil {
+			return common.NewAppError(err, "Failed to record stock transfer", common.ErrInternalServer.Code)
+		}
+
+		change := domain.StockChange{
+			Type:      domain.MovementTransfer,
+			UserID:    &userID,
+			Reason:    req.Reason,
+			Reference: fmt.Sprintf("transfer:%d", transfer.ID),
+		}
+		change.WarehouseID = req.FromWarehouseID
+		if err := s.record(ctx, req.ProductID, -req.Quantity, change); err != nil {
+			return common.NewAppError(err, "Failed to record stock movement", common.ErrInternalServer.Code)
+		}
+		change.WarehouseID = req.ToWarehouseID
+		if err := s.record(ctx, req.ProductID, req.Quantity, change); err != nil {
+			return common.NewAppError(err, "Failed to record stock movement", common.ErrInternalServer.Code)
+		}
+		return nil
+	})
+	if aerr != nil {
+		return nil, aerr
+	}
+	return transfer, nil
+}
+
+// ListTransfers returns the most recent stock transfers, optionally of one
+// product
+func (s *InventoryService) ListTransfers(ctx context.Context, productID uint, limit int) ([]domain.StockTransfer, *common.AppError) {
+	transfers, err := s.warehouses.ListTransfers(ctx, productID, limit)
+	if err != nil {
+		return nil, common.NewAppError(err, "Failed to list stock transfers", common.ErrInternalServer.Code)
+	}
+	return transfers, nil
+}
+
+// WarehouseStock returns a product's stock in each warehouse
+func (s *InventoryService) WarehouseStock(ctx context.Context, productID uint) ([]domain.WarehouseStock, *common.AppError) {
+	stocks, err := s.warehouses.ListStock(ctx, []uint{productID})
+	if err != nil {
+		return nil, common.NewAppError(err, "Failed to get warehouse stock", common.ErrInternalServer.Code)
+	}
+	return stocks, nil
+}
+
+// ListMovements returns the most recent ledger entries of a product
+func (s *InventoryService) ListMovements(ctx context.Context, productID uint, limit int) ([]domain.StockMovement, *common.AppError) {
+	movements, err := s.ledger.ListByProduct(ctx, productID, limit)
+	if err != nil {
+		return nil, common.NewAppError(err, "Failed to list stock movements", common.ErrInternalServer.Code)
+	}
+	return movements, nil
+}
+
+// Reconcile recomputes stock from the ledger for every product, optionally
+// returning only the products whose stock has drifted from it
+func (s *InventoryService) Reconcile(ctx context.Context, driftOnly bool) ([]domain.StockReconciliation, *common.AppError) {
+	report, err := s.ledger.Reconcile(ctx)
+	if err != nil {
+		return nil, common.NewAppError(err, "Failed to reconcile stock", common.ErrInternalServer.Code)
+	}
+	if !driftOnly {
+		return report, nil
+	}
+
+	drifted := make([]domain.StockReconciliation, 0)
+	for _, r := range report {
+		if r.HasDrift {
+			drifted = append(drifted, r)
+		}
+	}
+	return drifted, nil
+}
+
+func (s *InventoryService) record(ctx context.Context, productID uint, delta int, change domain.StockChange) error {
+	return s.ledger.Create(ctx, newMovement(productID, delta, change))
+}
+
+// resolveWarehouse returns warehouseID, or the default warehouse if it is zero
+func (s *InventoryService) resolveWarehouse(ctx context.Context, warehouseID uint) (uint, *common.AppError) {
+	if warehouseID == 0 {
+		warehouse, err := s.warehouses.GetDefault(ctx)
+		if err != nil {
+			return 0, common.NewAppError(err, "No default warehouse is configured", common.ErrInternalServer.Code)
+		}
+		return warehouse.ID, nil
+	}
+	if _, err := s.warehouses.GetByID(ctx, warehouseID); err != nil {
+		return 0, common.NewAppError(err, "Warehouse not found", http.StatusNotFound)
+	}
+	return warehouseID, nil
+}
+
+func newMovement(productID uint, delta int, change domain.StockChange) *domain.StockMovement {
+	return &domain.StockMovement{
+		ProductID:   productID,
+		WarehouseID: change.WarehouseID,
+		Quantity:    delta,
+		Type:        change.Type,
+		UserID:      change.UserID,
+		Reason:      change.Reason,
+		Reference:   change.Reference,
+	}
+}
+
+// OrderReference is the ledger reference of stock changes caused by an order
+func OrderReference(orderID uint) string {
+	return fmt.Sprintf("order:%d", orderID)
+}
+
+// ExpiredOrderIDs returns orders whose reservations have expired
+func (s *InventoryService) ExpiredOrderIDs(ctx context.Context, limit int) ([]uint, *common.AppError) {
+	ids, err := s.reservationRepo.ListExpiredOrderIDs(ctx, time.Now(), limit)
+	if err != nil {
+		return nil, common.NewAppError(err, "Failed to list expired reservations", common.ErrInternalServer.Code)
+	}
+	return ids, nil
+}
+
+// settle moves every active reservation of an order to status, applying
+// adjust to the product stock of each one. Reservations are locked first so
+// a payment and the expiry sweeper cannot both settle the same reservation.
+func (s *InventoryService) settle(ctx context.Context, orderID uint, status domain.ReservationStatus, adjust func(ctx context.Context, r domain.StockReservation) error) (int, *common.AppError) {
+	var settled int
+	aerr := inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+		reservations, err := s.reservationRepo.LockActiveByOrder(ctx, orderID)
+		if err != nil {
+			return common.NewAppError(err, "Failed to get stock reservations", common.ErrInternalServer.Code)
+		}
+
+		ids := make([]uint, len(reservations))
+		for i, r := range reservations {
+			if err := adjust(ctx, r); err != nil {
+				return common.NewAppError(err, "Failed to update product stock", common.ErrInternalServer.Code)
+			}
+			ids[i] = r.ID
+		}
+		if err := s.reservationRepo.UpdateStatus(ctx, ids, status); err != nil {
+			return common.NewAppError(err, "Failed to update stock reservations", common.ErrInternalServer.Code)
+		}
+		settled = len(reservations)
+		return nil
+	})
+	return settled, aerr
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/internal/repository"
+	"github.com/Dubjay18/ecom-api/internal/testutil"
+	"github.com/Dubjay18/ecom-api/pkg/common"
+	"gorm.io/gorm"
+)
+
+func newTestInventory(db *gorm.DB) *InventoryService {
+	return NewInventoryService(
+		repository.NewTxManager(db),
+		repository.NewProductRepository(db),
+		repository.NewReservationRepository(db),
+		repository.NewStockMovementRepository(db),
+		repository.NewWarehouseRepository(db),
+		time.Hour,
+	)
+}
+
+// seedProduct creates a product holding stock units, all of them in the
+// default warehouse, and returns the product and warehouse ids
+func seedProduct(t *testing.T, db *gorm.DB, sku string, stock int) (uint, uint) {
+	t.Helper()
+	var productID, warehouseID uint
+	err := db.Raw(`INSERT INTO products (name, price, sku, stock, category)
+		VALUES (?, 10, ?, ?, 'test') RETURNING id`, sku, sku, stock).Scan(&productID).Error
+	if err == nil {
+		err = db.Raw(`SELECT id FROM warehouses WHERE is_default`).Scan(&warehouseID).Error
+	}
+	if err == nil {
+		err = db.Exec(`INSERT INTO warehouse_stocks (warehouse_id, product_id, stock) VALUES (?, ?, ?)`,
+			warehouseID, productID, stock).Error
+	}
+	if err != nil {
+		t.Fatalf("seed product: %v", err)
+	}
+	return productID, warehouseID
+}
+
+func seedOrder(t *testing.T, db *gorm.DB) uint {
+	t.Helper()
+	var orderID uint
+	err := db.Raw(`INSERT INTO orders (total_amount, subtotal, currency, items)
+		VALUES (0, 0, 'USD', '[]') RETURNING id`).Scan(&orderID).Error
+	if err != nil {
+		t.Fatalf("seed order: %v", err)
+	}
+	return orderID
+}
+
+func TestReserveLastUnitGoesToOneOrder(t *testing.T) {
+	db := testutil.NewPostgresDB(t)
+	inventory := newTestInventory(db)
+	productID, warehouseID := seedProduct(t, db, "LAST-UNIT", 1)
+
+	const orders = 10
+	orderIDs := make([]uint, orders)
+	for i := range orderIDs {
+		orderIDs[i] = seedOrder(t, db)
+	}
+
+	errs := make([]*common.AppError, orders)
+	var wg sync.WaitGroup
+	for i, orderID := range orderIDs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			item := domain.OrderItem{ProductID: productID, WarehouseID: warehouseID, Quantity: 1}
+			errs[i] = inventory.Reserve(context.Background(), orderID, []domain.OrderItem{item})
+		}()
+	}
+	wg.Wait()
+
+	var reserved int
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			reserved++
+		case err.Code != http.StatusBadRequest:
+			t.Errorf("Reserve failed with %d: %v", err.Code, err)
+		}
+	}
+	if reserved != 1 {
+		t.Fatalf("%d orders reserved the last unit, want 1", reserved)
+	}
+
+	var counts struct {
+		Product      int
+		Warehouse    int
+		Reservations int
+	}
+	db.Raw(`SELECT
+		(SELECT reserved FROM products WHERE id = ?) AS product,
+		(SELECT reserved FROM warehouse_stocks WHERE product_id = ?) AS warehouse,
+		(SELECT COUNT(*) FROM stock_reservations WHERE product_id = ?) AS reservations`,
+		productID, productID, productID).Scan(&counts)
+	if counts.Product != 1 || counts.Warehouse != 1 || counts.Reservations != 1 {
+		t.Fatalf("reserved counts = %+v, want 1 each", counts)
+	}
+}
+
+func TestReserveOverlappingOrdersDoNotDeadlock(t *testing.T) {
+	db := testutil.NewPostgresDB(t)
+	inventory := newTestInventory(db)
+	first, warehouseID := seedProduct(t, db, "FIRST", 100)
+	second, _ := seedProduct(t, db, "SECOND", 100)
+
+	// Half the orders list the products the other way round, locking them
+	// in item order would deadlock
+	const orders = 20
+	errs := make([]*common.AppError, orders)
+	var wg sync.WaitGroup
+	for i := 0; i < orders; i++ {
+		orderID := seedOrder(t, db)
+		items := []domain.OrderItem{
+			{ProductID: first, WarehouseID: warehouseID, Quantity: 1},
+			{ProductID: second, WarehouseID: warehouseID, Quantity: 1},
+		}
+		if i%2 == 1 {
+			items[0], items[1] = items[1], items[0]
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = inventory.Reserve(context.Background(), orderID, items)
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("order %d: Reserve failed: %v", i, err)
+		}
+	}
+}
+
+// memWarehouses holds warehouse stock in memory
+type memWarehouses struct {
+	repository.WarehouseRepository
+	stocks []domain.WarehouseStock
+}
+
+func (r *memWarehouses) ListStock(context.Context, []uint) ([]domain.WarehouseStock, error) {
+	return r.stocks, nil
+}
+
+func TestAllocateCountsItemsOfTheSameProductTogether(t *testing.T) {
+	near := domain.Warehouse{Base: domain.Base{ID: 1}, Country: "US", State: "CA", IsActive: true}
+	far := domain.Warehouse{Base: domain.Base{ID: 2}, Country: "CA", IsActive: true}
+	inventory := NewInventoryService(nil, nil, nil, nil, &memWarehouses{stocks: []domain.WarehouseStock{
+		{WarehouseID: near.ID, Warehouse: near, ProductID: 7, Stock: 3},
+		{WarehouseID: far.ID, Warehouse: far, ProductID: 7, Stock: 5},
+	}}, time.Hour)
+
+	// Each item fits in the near warehouse, both together do not
+	items := []domain.OrderItem{{ProductID: 7, Quantity: 2}, {ProductID: 7, Quantity: 2}}
+	if aerr := inventory.Allocate(context.Background(), &domain.Address{Country: "US", State: "CA"}, items); aerr != nil {
+		t.Fatal(aerr)
+	}
+	for i, item := range items {
+		if item.WarehouseID != far.ID {
+			t.Errorf("item %d ships from warehouse %d, want %d", i, item.WarehouseID, far.ID)
+		}
+	}
+}
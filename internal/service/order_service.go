@@ -2,30 +2,69 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/Dubjay18/ecom-api/internal/domain"
 	"github.com/Dubjay18/ecom-api/internal/repository"
 	"github.com/Dubjay18/ecom-api/pkg/common"
+	"github.com/Dubjay18/ecom-api/pkg/money"
+	"gorm.io/gorm"
 )
 
+// errOrderCancelled is the cause of confirming the payment of an order that
+// was cancelled, whose payment is then refunded
+var errOrderCancelled = errors.New("order cancelled")
+
 type OrderService struct {
+	tx          repository.TxManager
 	orderRepo   repository.OrderRepository
 	productRepo repository.ProductRepository
+	inventory   *InventoryService
+	pricing     *PricingService
+	events      repository.OrderEventRepository
+	promotions  *PromotionService
+	status      *StateMachine[domain.OrderStatus]
+	payment     *StateMachine[domain.PaymentStatus]
+	// allocations are the backorder allocations started by stock changes
+	allocations *background
 }
 
-func NewOrderService(or repository.OrderRepository, pr repository.ProductRepository) *OrderService {
-	return &OrderService{orderRepo: or, productRepo: pr}
+func NewOrderService(tx repository.TxManager, or repository.OrderRepository, pr repository.ProductRepository, inventory *InventoryService, pricing *PricingService, events repository.OrderEventRepository, promotions *PromotionService) *OrderService {
+	s := &OrderService{
+		tx:          tx,
+		orderRepo:   or,
+		productRepo: pr,
+		inventory:   inventory,
+		pricing:     pricing,
+		events:      events,
+		promotions:  promotions,
+		status:      NewOrderStateMachine(),
+		payment:     NewPaymentStateMachine(),
+		allocations: newBackground(),
+	}
+	s.status.OnEnter(domain.StatusCancelled, s.onCancelled)
+	s.payment.OnEnter(domain.PaymentCompleted, s.onPaid)
+	s.status.OnTransition(func(ctx context.Context, order *domain.Order, from domain.OrderStatus) *common.AppError {
+		return s.record(ctx, order.ID, domain.EventStatusChanged, string(from), string(order.Status), fmt.Sprintf("Order %s", order.Status), false)
+	})
+	s.payment.OnTransition(func(ctx context.Context, order *domain.Order, from domain.PaymentStatus) *common.AppError {
+		return s.record(ctx, order.ID, domain.EventPayment, string(from), string(order.PaymentStatus), fmt.Sprintf("Payment %s", order.PaymentStatus), false)
+	})
+	return s
 }
 
 // Place an order for one or more products (authenticated users)
 func (s *OrderService) PlaceOrder(ctx context.Context, userID uint, req *domain.CreateOrderRequest) (*domain.Order, *common.AppError) {
-	tx := s.orderRepo.BeginTx(ctx)
-	defer tx.Rollback()
+	// Lines of the same product are checked against its stock together
+	lines := mergeOrderLines(req.Items)
 
 	// Fetch all product details in a single query
-	productIDs := make([]uint, len(req.Items))
-	for i, item := range req.Items {
+	productIDs := make([]uint, len(lines))
+	for i, item := range lines {
 		productIDs[i] = item.ProductID
 	}
 
@@ -40,28 +79,82 @@ func (s *OrderService) PlaceOrder(ctx context.Context, userID uint, req *domain.
 		productMap[product.ID] = &product
 	}
 
-	// Validate stock and calculate total
-	var total float64
-	orderItems := make([]domain.OrderItem, len(req.Items))
+	// Price the order in the requested currency, or the user's preferred one
+	currency, aerr := s.pricing.ResolveCurrency(ctx, req.Currency, userID)
+	if aerr != nil {
+		return nil, aerr
+	}
+	unitPrices, rate, aerr := s.pricing.Quote(ctx, products, currency)
+	if aerr != nil {
+		return nil, aerr
+	}
+	shipping, aerr := s.pricing.Shipping(currency, rate)
+	if aerr != nil {
+		return nil, aerr
+	}
 
-	for i, item := range req.Items {
+	// Validate stock and price each line
+	orderItems := make([]domain.OrderItem, len(lines))
+
+	for i, item := range lines {
 		product, exists := productMap[item.ProductID]
 		if !exists {
 			return nil, common.NewAppError(nil, "Product not found", http.StatusBadRequest)
 		}
-		if product.Stock < item.Quantity {
+		if !product.IsVisible() {
+			return nil, common.NewAppError(nil, "Product is not available", http.StatusBadRequest)
+		}
+
+		// Units beyond available stock are backordered if the product allows it
+		backordered, ok := product.Backorders(item.Quantity)
+		if !ok {
 			return nil, common.NewAppError(nil, "Insufficient stock for product", http.StatusBadRequest)
 		}
 
+		lineTotal, err := unitPrices[product.ID].Mul(int64(item.Quantity))
+		if err != nil {
+			return nil, common.NewAppError(err, "Order total is too large", http.StatusBadRequest)
+		}
+
 		orderItems[i] = domain.OrderItem{
-			Product:  *product,
-			Quantity: item.Quantity,
-			Price:    product.Price * float64(item.Quantity),
+			ProductID:           product.ID,
+			Quantity:            item.Quantity,
+			Price:               lineTotal,
+			BackorderedQuantity: backordered,
+			Currency:            currency,
+		}
+		if product.PreOrder {
+			orderItems[i].ExpectedAt = product.AvailableAt
 		}
-		total += orderItems[i].Price
 	}
 
-	// Create shipping address
+	// The subtotal is the exact sum of the line totals
+	subtotal, aerr := orderTotal(currency, orderItems)
+	if aerr != nil {
+		return nil, aerr
+	}
+	total, err := subtotal.Add(shipping)
+	if err != nil {
+		return nil, common.NewAppError(err, "Order total is too large", http.StatusBadRequest)
+	}
+	order := &domain.Order{
+		UserID:           userID,
+		Status:           domain.StatusPending,
+		Subtotal:         subtotal,
+		ShippingAmount:   shipping,
+		ShippingDiscount: money.New(0, currency),
+		TotalAmount:      total,
+		Currency:         currency,
+		ExchangeRate:     rate,
+		PaymentMethod:    req.PaymentMethod,
+		Items:            orderItems,
+	}
+
+	// Take the discounts of running promotions and the coupon off the lines
+	if aerr := s.promotions.Apply(ctx, order, productMap, req.CouponCode); aerr != nil {
+		return nil, aerr
+	}
+
 	shippingAddr := &domain.Address{
 		UserID:     userID,
 		Street:     req.ShippingAddr.Street,
@@ -70,39 +163,87 @@ func (s *OrderService) PlaceOrder(ctx context.Context, userID uint, req *domain.
 		Country:    req.ShippingAddr.Country,
 		PostalCode: req.ShippingAddr.PostalCode,
 	}
-	if err := s.orderRepo.CreatAddress(ctx, shippingAddr); err != nil {
-		return nil, common.NewAppError(err, "Failed to create shipping address", common.ErrInternalServer.Code)
+
+	// Pick the warehouse each item ships from
+	if aerr := s.inventory.Allocate(ctx, shippingAddr, orderItems); aerr != nil {
+		return nil, aerr
 	}
 
-	// Update product stocks
-	for _, item := range req.Items {
-		product := productMap[item.ProductID]
-		product.Stock -= item.Quantity
-		if err := s.productRepo.Update(ctx, product); err != nil {
-			return nil, common.NewAppError(err, "Failed to update product stock", common.ErrInternalServer.Code)
+	// Address, stock, promotion and order writes share one transaction so a
+	// failure part way through leaves nothing behind
+	aerr = inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+		// Create shipping address
+		if err := s.orderRepo.CreatAddress(ctx, shippingAddr); err != nil {
+			return common.NewAppError(err, "Failed to create shipping address", common.ErrInternalServer.Code)
 		}
-	}
 
-	// Create order
-	order := &domain.Order{
-		UserID:            userID,
-		Status:            domain.StatusPending,
-		TotalAmount:       total,
-		Items:             orderItems,
-		ShippingAddressID: shippingAddr.ID,
-	}
-	if err := s.orderRepo.Create(ctx, order); err != nil {
-		return nil, common.NewAppError(err, "Failed to create order", common.ErrInternalServer.Code)
+		// Create order
+		order.ShippingAddressID = shippingAddr.ID
+		if err := s.orderRepo.Create(ctx, order); err != nil {
+			return common.NewAppError(err, "Failed to create order", common.ErrInternalServer.Code)
+		}
+		if aerr := s.record(ctx, order.ID, domain.EventOrderPlaced, "", string(order.Status), "Order placed", false); aerr != nil {
+			return aerr
+		}
+		if aerr := s.promotions.Redeem(ctx, order); aerr != nil {
+			return aerr
+		}
+
+		// Hold the stock until the order is paid for, the availability check
+		// above is only a fast path and concurrent orders are resolved here
+		return s.inventory.Reserve(ctx, order.ID, order.Items)
+	})
+	if aerr != nil {
+		return nil, aerr
 	}
 
-	// Commit transaction
-	if err := tx.Commit(); err.Error != nil {
-		return nil, common.NewAppError(err.Error, "Failed to commit transaction", common.ErrInternalServer.Code)
+	for i := range order.Items {
+		product := *productMap[order.Items[i].ProductID]
+		product.Reserved += order.Items[i].InStockQuantity()
+		product.Backordered += order.Items[i].BackorderedQuantity
+		if currency != product.Price.Currency {
+			unitPrice := unitPrices[product.ID]
+			product.DisplayPrice = &unitPrice
+		}
+		order.Items[i].Product = product
 	}
 
 	return order, nil
 }
 
+// mergeOrderLines adds up the quantities of lines of the same product into
+// one line, in the order the products first appear
+func mergeOrderLines(items []domain.CreateOrderItem) []domain.CreateOrderItem {
+	lines := make([]domain.CreateOrderItem, 0, len(items))
+	index := make(map[uint]int, len(items))
+	for _, item := range items {
+		if i, ok := index[item.ProductID]; ok {
+			lines[i].Quantity += item.Quantity
+			continue
+		}
+		index[item.ProductID] = len(lines)
+		lines = append(lines, item)
+	}
+	return lines
+}
+
+// orderTotal sums the line totals of an order, every line must be in the
+// order's currency
+func orderTotal(currency string, items []domain.OrderItem) (money.Money, *common.AppError) {
+	lines := make([]money.Money, len(items))
+	for i, item := range items {
+		lines[i] = item.Price
+	}
+	total, err := money.Sum(currency, lines...)
+	if errors.Is(err, money.ErrCurrencyMismatch) {
+		return money.Money{}, common.NewAppError(err, "All products in an order must be priced in the same currency", http.StatusBadRequest)
+	}
+	if err != nil {
+		return money.Money{}, common.NewAppError(err, "Order total is too large", http.StatusBadRequest)
+	}
+	return total, nil
+}
+
 // List all orders for a user (authenticated)
 func (s *OrderService) ListUserOrders(ctx context.Context, userID uint) ([]domain.Order, *common.AppError) {
 	orders, err := s.orderRepo.List(ctx, userID)
@@ -112,39 +253,404 @@ func (s *OrderService) ListUserOrders(ctx context.Context, userID uint) ([]domai
 	return orders, nil
 }
 
-// Cancel an order if still Pending (authenticated)
-func (s *OrderService) CancelOrder(ctx context.Context, id uint) *common.AppError {
+// GetOrder returns an order with its items and shipping address, to its
+// owner or an admin
+func (s *OrderService) GetOrder(ctx context.Context, id uint, actor Actor) (*domain.Order, *common.AppError) {
+	if _, aerr := s.getOwned(ctx, id, actor); aerr != nil {
+		return nil, aerr
+	}
+	order, err := s.orderRepo.GetDetail(ctx, id)
+	if err != nil {
+		return nil, common.NewAppError(err, "Failed to get order", common.ErrInternalServer.Code)
+	}
+	return order, nil
+}
+
+// Cancel an order that has not shipped yet, its owner or an admin may
+// cancel it. Paid orders are refused, PaymentService.CancelOrder refunds
+// them instead.
+func (s *OrderService) CancelOrder(ctx context.Context, id uint, actor Actor) *common.AppError {
+	if _, aerr := s.getOwned(ctx, id, actor); aerr != nil {
+		return aerr
+	}
+	return s.transition(ctx, id, domain.StatusCancelled)
+}
+
+// getOwned returns an order if actor may see it. Other users' orders are
+// reported as not found so their IDs are not revealed.
+func (s *OrderService) getOwned(ctx context.Context, id uint, actor Actor) (*domain.Order, *common.AppError) {
 	order, err := s.orderRepo.GetByID(ctx, id)
 	if err != nil {
-		return common.NewAppError(err, "Failed to get order", common.ErrInternalServer.Code)
+		return nil, common.NewAppError(err, "Order not found", http.StatusNotFound)
+	}
+	if !actor.IsAdmin && order.UserID != actor.UserID {
+		return nil, common.NewAppError(nil, "Order not found", http.StatusNotFound)
+	}
+	return order, nil
+}
+
+// transition moves an order into status to through the order state machine
+// and saves it
+func (s *OrderService) transition(ctx context.Context, id uint, to domain.OrderStatus) *common.AppError {
+	return inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+		order, err := s.orderRepo.GetForUpdate(ctx, id)
+		if err != nil {
+			return common.NewAppError(err, "Order not found", http.StatusNotFound)
+		}
+		if aerr := s.status.Transition(ctx, order, to); aerr != nil {
+			return aerr
+		}
+		if err := s.orderRepo.Update(ctx, order); err != nil {
+			return common.NewAppError(err, "Failed to update order", common.ErrInternalServer.Code)
+		}
+		return nil
+	})
+}
+
+// onCancelled returns the stock held for a cancelled order and the
+// promotion uses it took
+func (s *OrderService) onCancelled(ctx context.Context, order *domain.Order, _ domain.OrderStatus) *common.AppError {
+	if _, aerr := s.inventory.Release(ctx, order.ID); aerr != nil {
+		return aerr
 	}
-	if order.Status != domain.StatusPending {
-		return common.NewAppError(nil, "Order cannot be cancelled", http.StatusBadRequest)
+	if aerr := s.promotions.Release(ctx, order.ID); aerr != nil {
+		return aerr
 	}
-	order.Status = domain.StatusCancelled
-	if err := s.orderRepo.Update(ctx, order); err != nil {
-		return common.NewAppError(err, "Failed to update order", common.ErrInternalServer.Code)
+
+	// Stock of paid orders, refunded by the time they are cancelled, was
+	// already committed and goes back on hand, backordered units never
+	// left it
+	if order.PaymentStatus == domain.PaymentRefunded {
+		if aerr := s.restock(ctx, order); aerr != nil {
+			return aerr
+		}
 	}
+	return s.cancelBackorders(ctx, order)
+}
+
+// restock returns the committed stock of a paid order
+func (s *OrderService) restock(ctx context.Context, order *domain.Order) *common.AppError {
 	for _, item := range order.Items {
-		p, _ := s.productRepo.GetByID(ctx, item.ProductID)
-		p.Stock += item.Quantity
-		_ = s.productRepo.Update(ctx, p)
+		if item.InStockQuantity() == 0 {
+			continue
+		}
+		_, aerr := s.inventory.Adjust(ctx, item.ProductID, item.InStockQuantity(), domain.StockChange{
+			WarehouseID: item.WarehouseID,
+			Type:        domain.MovementCancellation,
+			Reason:      "order cancelled",
+			Reference:   OrderReference(order.ID),
+		})
+		if aerr != nil {
+			return aerr
+		}
 	}
 	return nil
 }
 
-// Update the status of an order (admin privilege)
+// ConfirmPayment marks an order as paid, which commits its reserved stock
+// and confirms it. It fails with errOrderCancelled if the order was
+// cancelled first.
+func (s *OrderService) ConfirmPayment(ctx context.Context, id uint) *common.AppError {
+	return inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+		order, err := s.orderRepo.GetForUpdate(ctx, id)
+		if err != nil {
+			return common.NewAppError(err, "Order not found", http.StatusNotFound)
+		}
+		if order.Status == domain.StatusCancelled {
+			return common.NewAppError(errOrderCancelled, "Order has been cancelled", http.StatusConflict)
+		}
+		if order.IsPaid() {
+			return nil
+		}
+
+		if aerr := s.payment.Transition(ctx, order, domain.PaymentCompleted); aerr != nil {
+			return aerr
+		}
+		if err := s.orderRepo.Update(ctx, order); err != nil {
+			return common.NewAppError(err, "Failed to update order", common.ErrInternalServer.Code)
+		}
+		return nil
+	})
+}
+
+// UpdatePaymentStatus moves the payment status of an order through the
+// payment state machine, an order already in status to is left alone. Use
+// ConfirmPayment to mark an order as paid.
+func (s *OrderService) UpdatePaymentStatus(ctx context.Context, id uint, to domain.PaymentStatus) *common.AppError {
+	return inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+		order, err := s.orderRepo.GetForUpdate(ctx, id)
+		if err != nil {
+			return common.NewAppError(err, "Order not found", http.StatusNotFound)
+		}
+		if order.PaymentStatus == to {
+			return nil
+		}
+		if aerr := s.payment.Transition(ctx, order, to); aerr != nil {
+			return aerr
+		}
+		if err := s.orderRepo.Update(ctx, order); err != nil {
+			return common.NewAppError(err, "Failed to update order", common.ErrInternalServer.Code)
+		}
+		return nil
+	})
+}
+
+// onPaid commits the stock reserved for a paid order and confirms it
+func (s *OrderService) onPaid(ctx context.Context, order *domain.Order, _ domain.PaymentStatus) *common.AppError {
+	if _, aerr := s.inventory.Commit(ctx, order.ID); aerr != nil {
+		return aerr
+	}
+	if order.Status == domain.StatusPending {
+		return s.status.Transition(ctx, order, domain.StatusConfirmed)
+	}
+	return nil
+}
+
+// ExpireUnpaidOrders cancels pending orders whose stock reservations have
+// expired and returns the reserved stock, it returns the number of orders
+// cancelled
+func (s *OrderService) ExpireUnpaidOrders(ctx context.Context, limit int) (int, *common.AppError) {
+	ids, aerr := s.inventory.ExpiredOrderIDs(ctx, limit)
+	if aerr != nil {
+		return 0, aerr
+	}
+
+	expired := 0
+	for _, id := range ids {
+		aerr := inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+			order, err := s.orderRepo.GetForUpdate(ctx, id)
+			if err != nil {
+				return common.NewAppError(err, "Failed to get order", common.ErrInternalServer.Code)
+			}
+
+			// Orders that moved on without a payment keep their stock
+			if order.Status != domain.StatusPending {
+				_, aerr := s.inventory.Commit(ctx, id)
+				return aerr
+			}
+
+			released, aerr := s.inventory.Release(ctx, id)
+			if aerr != nil || released == 0 {
+				// Settled by a payment or cancellation in the meantime
+				return aerr
+			}
+			if aerr := s.status.Transition(ctx, order, domain.StatusCancelled); aerr != nil {
+				return aerr
+			}
+			if err := s.orderRepo.Update(ctx, order); err != nil {
+				return common.NewAppError(err, "Failed to update order", common.ErrInternalServer.Code)
+			}
+			expired++
+			return nil
+		})
+		if aerr != nil {
+			return expired, aerr
+		}
+	}
+	return expired, nil
+}
+
+// AllocateBackorders fills the backordered items of a product from its
+// available stock, oldest order first. Paid orders take the stock for good,
+// unpaid ones hold it for the reservation TTL. It returns the number of
+// units allocated.
+func (s *OrderService) AllocateBackorders(ctx context.Context, productID uint) (int, *common.AppError) {
+	allocated := 0
+	aerr := inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+		// Locking the product makes concurrent allocations take turns
+		product, err := s.productRepo.GetForUpdate(ctx, productID)
+		if err != nil {
+			return common.NewAppError(err, "Product not found", http.StatusNotFound)
+		}
+		available := product.Available()
+		if product.Backordered == 0 || available <= 0 {
+			return nil
+		}
+
+		items, err := s.orderRepo.ListBackorderedItems(ctx, productID)
+		if err != nil {
+			return common.NewAppError(err, "Failed to list backordered items", common.ErrInternalServer.Code)
+		}
+		for _, listed := range items {
+			if available <= 0 {
+				break
+			}
+			// The order is locked so a concurrent cancellation or shipment
+			// does not work from the same item. An order locked by one is
+			// skipped rather than waited for, it holds its lock while
+			// waiting for this product, and is picked up by the next sweep.
+			order, err := s.orderRepo.GetForUpdateSkipLocked(ctx, listed.OrderID)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			if err != nil {
+				return common.NewAppError(err, "Failed to get order", common.ErrInternalServer.Code)
+			}
+			item := order.Item(listed.ID)
+			if order.Status == domain.StatusCancelled || item == nil || item.BackorderedQuantity == 0 {
+				continue
+			}
+			addr, err := s.orderRepo.GetAddress(ctx, order.ShippingAddressID)
+			if err != nil {
+				return common.NewAppError(err, "Failed to get shipping address", common.ErrInternalServer.Code)
+			}
+
+			reserved, warehouseID, aerr := s.inventory.ReserveBackorder(ctx, order.ID, addr, productID, item.BackorderedQuantity)
+			if aerr != nil {
+				return aerr
+			}
+			if reserved == 0 {
+				// The remaining stock is in warehouses that cannot ship
+				break
+			}
+
+			item.BackorderedQuantity -= reserved
+			if item.WarehouseID == 0 {
+				item.WarehouseID = warehouseID
+			}
+			if err := s.orderRepo.UpdateItemAllocation(ctx, item); err != nil {
+				return common.NewAppError(err, "Failed to update order item", common.ErrInternalServer.Code)
+			}
+			message := fmt.Sprintf("%d backordered units of %s allocated", reserved, product.Name)
+			if item.BackorderedQuantity == 0 {
+				message = fmt.Sprintf("Backordered %s ready to ship", product.Name)
+			}
+			if aerr := s.record(ctx, order.ID, domain.EventFulfillment, "", "", message, false); aerr != nil {
+				return aerr
+			}
+			if order.PaymentStatus == domain.PaymentCompleted {
+				if _, aerr := s.inventory.Commit(ctx, order.ID); aerr != nil {
+					return aerr
+				}
+			}
+			available -= reserved
+			allocated += reserved
+		}
+		return nil
+	})
+	return allocated, aerr
+}
+
+// AllocatePendingBackorders allocates stock to the backorders of every
+// product that has both, it returns the number of units allocated
+func (s *OrderService) AllocatePendingBackorders(ctx context.Context, limit int) (int, *common.AppError) {
+	ids, err := s.productRepo.ListAllocatableBackorders(ctx, limit)
+	if err != nil {
+		return 0, common.NewAppError(err, "Failed to list backordered products", common.ErrInternalServer.Code)
+	}
+
+	allocated := 0
+	for _, id := range ids {
+		n, aerr := s.AllocateBackorders(ctx, id)
+		allocated += n
+		if aerr != nil {
+			return allocated, aerr
+		}
+	}
+	return allocated, nil
+}
+
+// StockChanged allocates replenished stock to waiting backorders in the
+// background, it is called after the available stock of products changes
+func (s *OrderService) StockChanged(productIDs []uint) {
+	s.allocations.Go(func(ctx context.Context) {
+		for _, id := range productIDs {
+			if ctx.Err() != nil {
+				return
+			}
+			if _, aerr := s.AllocateBackorders(ctx, id); aerr != nil {
+				log.Printf("Failed to allocate backorders of product %d: %v", id, aerr)
+			}
+		}
+	})
+}
+
+// Shutdown waits for the backorder allocations started by stock changes to
+// finish, they are cancelled if ctx ends first. Backorders left waiting are
+// allocated by the next backorder job.
+func (s *OrderService) Shutdown(ctx context.Context) {
+	s.allocations.Shutdown(ctx)
+}
+
+// cancelBackorders drops the waiting units of a cancelled order
+func (s *OrderService) cancelBackorders(ctx context.Context, order *domain.Order) *common.AppError {
+	var backordered []int
+	for i, item := range order.Items {
+		if item.IsBackordered() {
+			backordered = append(backordered, i)
+		}
+	}
+	if len(backordered) == 0 {
+		return nil
+	}
+
+	if aerr := s.inventory.CancelBackorders(ctx, order.Items); aerr != nil {
+		return aerr
+	}
+	for _, i := range backordered {
+		if err := s.orderRepo.UpdateItem(ctx, &order.Items[i]); err != nil {
+			return common.NewAppError(err, "Failed to update order item", common.ErrInternalServer.Code)
+		}
+	}
+	return nil
+}
+
+// Update the status of an order (admin privilege). Only transitions allowed
+// by the order state machine are made.
 func (s *OrderService) UpdateOrderStatus(ctx context.Context, id uint, newStatus domain.OrderStatus) *common.AppError {
-	if newStatus != domain.StatusPending && newStatus != domain.StatusShipped && newStatus != domain.StatusDelivered {
-		return common.NewAppError(nil, "Invalid status", http.StatusBadRequest)
+	return s.transition(ctx, id, newStatus)
+}
+
+// ListEvents returns the history of an order oldest first. Customers see
+// the public events of their own orders, admins every event of any order.
+func (s *OrderService) ListEvents(ctx context.Context, id uint, actor Actor) ([]domain.OrderEvent, *common.AppError) {
+	if _, aerr := s.getOwned(ctx, id, actor); aerr != nil {
+		return nil, aerr
 	}
-	order, err := s.orderRepo.GetByID(ctx, id)
+	events, err := s.events.ListByOrder(ctx, id, actor.IsAdmin)
 	if err != nil {
-		return common.NewAppError(err, "Failed to get order", common.ErrInternalServer.Code)
+		return nil, common.NewAppError(err, "Failed to list order events", common.ErrInternalServer.Code)
+	}
+	if !actor.IsAdmin {
+		for i := range events {
+			events[i].ActorID = nil
+		}
+	}
+	return events, nil
+}
+
+// AddNote adds a note to the history of an order (admin privilege)
+func (s *OrderService) AddNote(ctx context.Context, id uint, req *domain.CreateOrderNoteRequest) (*domain.OrderEvent, *common.AppError) {
+	if _, err := s.orderRepo.GetByID(ctx, id); err != nil {
+		return nil, common.NewAppError(err, "Order not found", http.StatusNotFound)
 	}
-	order.Status = newStatus
-	if err := s.orderRepo.Update(ctx, order); err != nil {
-		return common.NewAppError(err, "Failed to update order", common.ErrInternalServer.Code)
+	event := s.event(ctx, id, domain.EventNote, "", "", req.Message, req.Internal)
+	if err := s.events.Create(ctx, event); err != nil {
+		return nil, common.NewAppError(err, "Failed to add order note", common.ErrInternalServer.Code)
+	}
+	return event, nil
+}
+
+// record adds an event to the history of an order, attributed to the actor
+// of ctx
+func (s *OrderService) record(ctx context.Context, orderID uint, typ domain.OrderEventType, from, to, message string, internal bool) *common.AppError {
+	if err := s.events.Create(ctx, s.event(ctx, orderID, typ, from, to, message, internal)); err != nil {
+		return common.NewAppError(err, "Failed to record order event", common.ErrInternalServer.Code)
 	}
 	return nil
 }
+
+func (s *OrderService) event(ctx context.Context, orderID uint, typ domain.OrderEventType, from, to, message string, internal bool) *domain.OrderEvent {
+	actorType, actorID := actorFrom(ctx)
+	return &domain.OrderEvent{
+		OrderID:    orderID,
+		Type:       typ,
+		FromStatus: from,
+		ToStatus:   to,
+		Message:    message,
+		Internal:   internal,
+		ActorType:  actorType,
+		ActorID:    actorID,
+		OccurredAt: time.Now(),
+	}
+}
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+)
+
+func TestMergeOrderLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		items []domain.CreateOrderItem
+		want  []domain.CreateOrderItem
+	}{
+		{
+			name:  "distinct products",
+			items: []domain.CreateOrderItem{{ProductID: 1, Quantity: 2}, {ProductID: 2, Quantity: 1}},
+			want:  []domain.CreateOrderItem{{ProductID: 1, Quantity: 2}, {ProductID: 2, Quantity: 1}},
+		},
+		{
+			name:  "repeated product",
+			items: []domain.CreateOrderItem{{ProductID: 1, Quantity: 2}, {ProductID: 2, Quantity: 1}, {ProductID: 1, Quantity: 3}},
+			want:  []domain.CreateOrderItem{{ProductID: 1, Quantity: 5}, {ProductID: 2, Quantity: 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeOrderLines(tt.items); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lines = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
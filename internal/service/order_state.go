@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/pkg/common"
+)
+
+// Guard vetoes a transition, returning why the order cannot make it or an
+// empty string to allow it
+type Guard func(order *domain.Order) string
+
+// Hook runs a side effect of a transition inside the caller's transaction,
+// after the status on order has been changed
+type Hook[S ~string] func(ctx context.Context, order *domain.Order, from S) *common.AppError
+
+// StateMachine moves one status of an order through its legal transitions.
+// Guards registered for a target status can refuse a transition and hooks
+// registered for it run its side effects.
+type StateMachine[S ~string] struct {
+	name        string
+	transitions map[S][]S
+	status      func(order *domain.Order) *S
+	guards      map[S][]Guard
+	hooks       map[S][]Hook[S]
+	anyHooks    []Hook[S]
+}
+
+// TransitionError is the detail of a refused transition, Allowed lists the
+// statuses the order can move to instead
+type TransitionError struct {
+	From    string   `json:"from"`
+	To      string   `json:"to"`
+	Reason  string   `json:"reason"`
+	Allowed []string `json:"allowed"`
+}
+
+// NewOrderStateMachine returns the state machine of domain.OrderStatus.
+// Orders are confirmed and shipped only once paid, and not shipped while
+// items are waiting for stock. An order is partially shipped while only
+// some of its items have shipped and shipped once all of them have. Paid
+// orders are cancelled by refunding their payment, see
+// PaymentService.CancelOrder.
+func NewOrderStateMachine() *StateMachine[domain.OrderStatus] {
+	m := &StateMachine[domain.OrderStatus]{
+		name:        "order",
+		transitions: domain.OrderTransitions,
+		status:      func(order *domain.Order) *domain.OrderStatus { return &order.Status },
+		guards:      make(map[domain.OrderStatus][]Guard),
+		hooks:       make(map[domain.OrderStatus][]Hook[domain.OrderStatus]),
+	}
+	requirePayment := func(order *domain.Order) string {
+		if !order.IsPaid() {
+			return "Order has not been paid"
+		}
+		return ""
+	}
+	m.Guard(domain.StatusConfirmed, requirePayment)
+	m.Guard(domain.StatusPartiallyShipped, requirePayment)
+	m.Guard(domain.StatusPartiallyShipped, func(order *domain.Order) string {
+		some, all := order.ShippedItems()
+		switch {
+		case !some:
+			return "Order has no shipped items"
+		case all:
+			return "Every item of the order has shipped"
+		}
+		return ""
+	})
+	m.Guard(domain.StatusShipped, requirePayment)
+	m.Guard(domain.StatusShipped, func(order *domain.Order) string {
+		if order.HasBackorders() {
+			return "Order has items waiting for stock"
+		}
+		return ""
+	})
+	m.Guard(domain.StatusShipped, func(order *domain.Order) string {
+		if _, all := order.ShippedItems(); !all {
+			return "Order has items that have not shipped"
+		}
+		return ""
+	})
+	m.Guard(domain.StatusCancelled, func(order *domain.Order) string {
+		if order.IsPaid() {
+			return "Paid orders are cancelled by refunding their payment"
+		}
+		return ""
+	})
+	return m
+}
+
+// NewPaymentStateMachine returns the state machine of domain.PaymentStatus
+func NewPaymentStateMachine() *StateMachine[domain.PaymentStatus] {
+	return &StateMachine[domain.PaymentStatus]{
+		name:        "payment",
+		transitions: domain.PaymentTransitions,
+		status:      func(order *domain.Order) *domain.PaymentStatus { return &order.PaymentStatus },
+		guards:      make(map[domain.PaymentStatus][]Guard),
+		hooks:       make(map[domain.PaymentStatus][]Hook[domain.PaymentStatus]),
+	}
+}
+
+// Guard registers a guard for transitions into status to
+func (m *StateMachine[S]) Guard(to S, guard Guard) {
+	m.guards[to] = append(m.guards[to], guard)
+}
+
+// OnEnter registers a hook run whenever an order moves into status to
+func (m *StateMachine[S]) OnEnter(to S, hook Hook[S]) {
+	m.hooks[to] = append(m.hooks[to], hook)
+}
+
+// OnTransition registers a hook run on every transition, after the hooks
+// of the target status
+func (m *StateMachine[S]) OnTransition(hook Hook[S]) {
+	m.anyHooks = append(m.anyHooks, hook)
+}
+
+// Allowed returns the statuses order can move to now, legal transitions
+// whose guards pass
+func (m *StateMachine[S]) Allowed(order *domain.Order) []S {
+	allowed := []S{}
+	for _, to := range m.transitions[*m.status(order)] {
+		if m.refusal(order, to) == "" {
+			allowed = append(allowed, to)
+		}
+	}
+	return allowed
+}
+
+// Transition moves order into status to and runs the transition's hooks.
+// The caller saves the order. An unknown status is a 400, an illegal or
+// guarded transition a 409 whose details list the allowed statuses.
+func (m *StateMachine[S]) Transition(ctx context.Context, order *domain.Order, to S) *common.AppError {
+	if _, known := m.transitions[to]; !known {
+		return common.NewAppError(nil, fmt.Sprintf("Invalid %s status %q", m.name, to), http.StatusBadRequest)
+	}
+
+	current := m.status(order)
+	from := *current
+	if !slices.Contains(m.transitions[from], to) {
+		return m.refuse(order, to, fmt.Sprintf("Cannot move %s from %s to %s", m.name, from, to))
+	}
+	if reason := m.refusal(order, to); reason != "" {
+		return m.refuse(order, to, reason)
+	}
+
+	*current = to
+	for _, hook := range m.hooks[to] {
+		if aerr := hook(ctx, order, from); aerr != nil {
+			return aerr
+		}
+	}
+	for _, hook := range m.anyHooks {
+		if aerr := hook(ctx, order, from); aerr != nil {
+			return aerr
+		}
+	}
+	return nil
+}
+
+func (m *StateMachine[S]) refusal(order *domain.Order, to S) string {
+	for _, guard := range m.guards[to] {
+		if reason := guard(order); reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
+
+func (m *StateMachine[S]) refuse(order *domain.Order, to S, reason string) *common.AppError {
+	allowed := m.Allowed(order)
+	detail := TransitionError{
+		From:    string(*m.status(order)),
+		To:      string(to),
+		Reason:  reason,
+		Allowed: make([]string, len(allowed)),
+	}
+	for i, s := range allowed {
+		detail.Allowed[i] = string(s)
+	}
+	return common.NewAppError(nil, reason, http.StatusConflict).WithDetails(detail)
+}
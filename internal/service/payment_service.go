@@ -0,0 +1,510 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/internal/repository"
+	"github.com/Dubjay18/ecom-api/pkg/common"
+	"github.com/Dubjay18/ecom-api/pkg/money"
+	"github.com/Dubjay18/ecom-api/pkg/payment"
+	"gorm.io/gorm"
+)
+
+// PaymentService takes payment for orders through a payment provider.
+// Authorized payments are captured straight away and the order is marked
+// paid, an authorization for an order cancelled in the meantime is voided.
+type PaymentService struct {
+	tx        repository.TxManager
+	payments  repository.PaymentRepository
+	orderRepo repository.OrderRepository
+	orders    *OrderService
+	provider  payment.Provider
+	webhooks  repository.PaymentWebhookRepository
+}
+
+func NewPaymentService(tx repository.TxManager, payments repository.PaymentRepository, orderRepo repository.OrderRepository, orders *OrderService, provider payment.Provider, webhooks repository.PaymentWebhookRepository) *PaymentService {
+	return &PaymentService{
+		tx:        tx,
+		payments:  payments,
+		orderRepo: orderRepo,
+		orders:    orders,
+		provider:  provider,
+		webhooks:  webhooks,
+	}
+}
+
+// Start starts paying for an order with the payment method it was placed
+// with, or the one in req. A payment that needs the customer to act is
+// returned pending with the provider's client secret.
+func (s *PaymentService) Start(ctx context.Context, orderID uint, actor Actor, req *domain.StartPaymentRequest) (*domain.Payment, *common.AppError) {
+	if _, aerr := s.orders.getOwned(ctx, orderID, actor); aerr != nil {
+		return nil, aerr
+	}
+
+	pay := &domain.Payment{
+		OrderID:  orderID,
+		Provider: s.provider.Name(),
+		Status:   domain.PaymentRecordPending,
+	}
+	aerr := inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+		order, err := s.orderRepo.GetForUpdate(ctx, orderID)
+		if err != nil {
+			return common.NewAppError(err, "Order not found", http.StatusNotFound)
+		}
+		if order.Status == domain.StatusCancelled {
+			return common.NewAppError(nil, "Order has been cancelled", http.StatusConflict)
+		}
+		if order.PaymentStatus != domain.PaymentPending && order.PaymentStatus != domain.PaymentFailed {
+			return common.NewAppError(nil, "Order has already been paid", http.StatusConflict)
+		}
+		active, err := s.payments.HasActive(ctx, orderID)
+		if err != nil {
+			return common.NewAppError(err, "Failed to get payments", common.ErrInternalServer.Code)
+		}
+		if active {
+			return common.NewAppError(nil, "A payment for this order is already in progress", http.StatusConflict)
+		}
+
+		// Another try after a declined payment
+		if order.PaymentStatus == domain.PaymentFailed {
+			if aerr := s.orders.payment.Transition(ctx, order, domain.PaymentPending); aerr != nil {
+				return aerr
+			}
+			if err := s.orderRepo.Update(ctx, order); err != nil {
+				return common.NewAppError(err, "Failed to update order", common.ErrInternalServer.Code)
+			}
+		}
+
+		pay.Method = order.PaymentMethod
+		if req.PaymentMethod != "" {
+			pay.Method = req.PaymentMethod
+		}
+		pay.Amount = order.TotalAmount
+		pay.RefundedAmount = money.New(0, order.Currency)
+		pay.Currency = order.Currency
+		if err := s.payments.Create(ctx, pay); err != nil {
+			return common.NewAppError(err, "Failed to create payment", common.ErrInternalServer.Code)
+		}
+		return nil
+	})
+	if aerr != nil {
+		return nil, aerr
+	}
+
+	intent, err := s.provider.CreateIntent(ctx, payment.IntentRequest{
+		Amount:         pay.Amount,
+		Method:         pay.Method,
+		Reference:      OrderReference(orderID),
+		IdempotencyKey: fmt.Sprintf("payment:%d", pay.ID),
+	})
+	if err != nil {
+		pay.Status = domain.PaymentRecordFailed
+		pay.FailureReason = "The payment provider could not be reached"
+		if err := s.payments.Update(ctx, pay); err != nil {
+			return nil, common.NewAppError(err, "Failed to update payment", common.ErrInternalServer.Code)
+		}
+		return nil, common.NewAppError(err, "Payment provider is unavailable", http.StatusBadGateway)
+	}
+
+	pay.ProviderRef = intent.ID
+	if err := s.payments.Update(ctx, pay); err != nil {
+		return nil, common.NewAppError(err, "Failed to update payment", common.ErrInternalServer.Code)
+	}
+	pay, aerr = s.settle(ctx, pay.ID, intent)
+	if aerr != nil {
+		return nil, aerr
+	}
+	if pay.Status == domain.PaymentRecordPending {
+		pay.ClientSecret = intent.ClientSecret
+	}
+	return pay, nil
+}
+
+// ListPayments returns the payments of an order, to its owner or an admin
+func (s *PaymentService) ListPayments(ctx context.Context, orderID uint, actor Actor) ([]domain.Payment, *common.AppError) {
+	if _, aerr := s.orders.getOwned(ctx, orderID, actor); aerr != nil {
+		return nil, aerr
+	}
+	payments, err := s.payments.ListByOrder(ctx, orderID)
+	if err != nil {
+		return nil, common.NewAppError(err, "Failed to list payments", common.ErrInternalServer.Code)
+	}
+	return payments, nil
+}
+
+// Sync fetches the state of a payment from the provider and applies it,
+// such as after the customer completed a payment that required action
+func (s *PaymentService) Sync(ctx context.Context, orderID, paymentID uint, actor Actor) (*domain.Payment, *common.AppError) {
+	if _, aerr := s.orders.getOwned(ctx, orderID, actor); aerr != nil {
+		return nil, aerr
+	}
+	pay, err := s.payments.GetByID(ctx, paymentID)
+	if err != nil || pay.OrderID != orderID {
+		return nil, common.NewAppError(err, "Payment not found", http.StatusNotFound)
+	}
+	// Settled payments no longer follow the provider, a refunded Stripe
+	// payment still reads as succeeded there
+	if pay.ProviderRef == "" || !pay.IsActive() {
+		return pay, nil
+	}
+
+	intent, err := s.provider.GetIntent(ctx, pay.ProviderRef)
+	if err != nil {
+		return nil, common.NewAppError(err, "Payment provider is unavailable", http.StatusBadGateway)
+	}
+	return s.settle(ctx, pay.ID, intent)
+}
+
+// settle brings payment id and its order up to date with intent, the
+// state of the payment at the provider. An authorized payment is captured,
+// or voided if its order was cancelled in the meantime. The provider is
+// called outside of any transaction, so no row stays locked while it
+// answers, and the outcome is applied in a transaction of its own.
+func (s *PaymentService) settle(ctx context.Context, id uint, intent *payment.Intent) (*domain.Payment, *common.AppError) {
+	if intent.Status == payment.IntentAuthorized {
+		pay, err := s.payments.GetByID(ctx, id)
+		if err != nil {
+			return nil, common.NewAppError(err, "Payment not found", http.StatusNotFound)
+		}
+		if !pay.IsActive() {
+			return pay, nil
+		}
+		order, err := s.orderRepo.GetByID(ctx, pay.OrderID)
+		if err != nil {
+			return nil, common.NewAppError(err, "Failed to get order", common.ErrInternalServer.Code)
+		}
+		// Take the money only for orders that still want it. Both calls
+		// are idempotent at the provider, so a concurrent settle of the
+		// same payment does no harm.
+		if order.Status == domain.StatusCancelled {
+			intent, err = s.provider.Void(ctx, intent.ID)
+		} else {
+			intent, err = s.provider.Capture(ctx, intent.ID)
+		}
+		if err != nil {
+			return nil, common.NewAppError(err, "Failed to capture payment", http.StatusBadGateway)
+		}
+	}
+
+	var pay *domain.Payment
+	refundDue := false
+	aerr := inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+		var err error
+		pay, err = s.payments.GetForUpdate(ctx, id)
+		if err != nil {
+			return common.NewAppError(err, "Payment not found", http.StatusNotFound)
+		}
+		if !pay.IsActive() {
+			return nil
+		}
+
+		switch intent.Status {
+		case payment.IntentRequiresAction:
+			pay.Status = domain.PaymentRecordPending
+		case payment.IntentAuthorized:
+			pay.Status = domain.PaymentRecordAuthorized
+		case payment.IntentCaptured:
+			pay.Status = domain.PaymentRecordCaptured
+		case payment.IntentFailed:
+			pay.Status = domain.PaymentRecordFailed
+			pay.FailureReason = intent.FailureReason
+		case payment.IntentCancelled:
+			pay.Status = domain.PaymentRecordVoided
+		}
+		if err := s.payments.Update(ctx, pay); err != nil {
+			return common.NewAppError(err, "Failed to update payment", common.ErrInternalServer.Code)
+		}
+
+		switch pay.Status {
+		case domain.PaymentRecordCaptured:
+			aerr := s.orders.ConfirmPayment(ctx, pay.OrderID)
+			if aerr != nil && errors.Is(aerr, errOrderCancelled) {
+				// The order was cancelled before the money was taken
+				refundDue = true
+				return nil
+			}
+			return aerr
+		case domain.PaymentRecordFailed:
+			return s.orders.UpdatePaymentStatus(ctx, pay.OrderID, domain.PaymentFailed)
+		}
+		return nil
+	})
+	if aerr != nil {
+		return nil, aerr
+	}
+	if refundDue {
+		return s.Refund(ctx, pay.ID, nil, OrderReference(pay.OrderID))
+	}
+	return pay, nil
+}
+
+// Refund returns amount of a captured payment to the customer, the whole
+// amount not refunded yet if amount is nil (admin privilege). The order is
+// marked refunded once all of its payment is. Asking again with the same
+// non-empty reference resumes the refund made for it instead of making
+// another one.
+//
+// The refund is recorded pending first, then the provider is asked for it
+// outside of any transaction, then the outcome is recorded.
+func (s *PaymentService) Refund(ctx context.Context, id uint, amount *money.Money, reference string) (*domain.Payment, *common.AppError) {
+	var pay *domain.Payment
+	var refund *domain.PaymentRefund
+	aerr := inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+		var err error
+		pay, err = s.payments.GetForUpdate(ctx, id)
+		if err != nil {
+			return common.NewAppError(err, "Payment not found", http.StatusNotFound)
+		}
+		if reference != "" {
+			refund, err = s.payments.GetRefundByReference(ctx, pay.ID, reference)
+			if err == nil {
+				return nil
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return common.NewAppError(err, "Failed to get refund", common.ErrInternalServer.Code)
+			}
+		}
+		if pay.Status != domain.PaymentRecordCaptured {
+			return common.NewAppError(nil, "Only captured payments can be refunded", http.StatusConflict)
+		}
+
+		remaining, aerr := s.refundable(ctx, pay)
+		if aerr != nil {
+			return aerr
+		}
+		value := remaining
+		if amount != nil {
+			value = *amount
+			if value.Currency != pay.Currency {
+				return common.NewAppError(nil, fmt.Sprintf("Refund must be in %s", pay.Currency), http.StatusBadRequest)
+			}
+			if !value.IsPositive() {
+				return common.NewAppError(nil, "Refund must be greater than zero", http.StatusBadRequest)
+			}
+			if cmp, _ := value.Cmp(remaining); cmp > 0 {
+				return common.NewAppError(nil, fmt.Sprintf("At most %s can be refunded", remaining), http.StatusBadRequest)
+			}
+		}
+		if !value.IsPositive() {
+			return common.NewAppError(nil, "Nothing is left to refund", http.StatusConflict)
+		}
+
+		refund = &domain.PaymentRefund{
+			PaymentID: pay.ID,
+			Reference: reference,
+			Amount:    value,
+			Currency:  pay.Currency,
+			Status:    domain.RefundPending,
+		}
+		if err := s.payments.CreateRefund(ctx, refund); err != nil {
+			return common.NewAppError(err, "Failed to create refund", common.ErrInternalServer.Code)
+		}
+		return nil
+	})
+	if aerr != nil {
+		return nil, aerr
+	}
+	if refund.Status == domain.RefundSucceeded {
+		return pay, nil
+	}
+
+	// The key stays the same when the refund is resumed, so the provider
+	// does not pay it twice if an earlier attempt went through after all
+	result, err := s.provider.Refund(ctx, pay.ProviderRef, refund.Amount, fmt.Sprintf("refund-%d", refund.ID))
+	return s.completeRefund(ctx, pay.ID, refund.ID, result, err)
+}
+
+// refundable returns what is left to refund on a payment: what was
+// captured less what was refunded, the refunds still waiting for the
+// provider and the failed refunds that can be resumed by their reference.
+// A resumed refund so always has room left for it.
+func (s *PaymentService) refundable(ctx context.Context, pay *domain.Payment) (money.Money, *common.AppError) {
+	pending, err := s.payments.ListRefunds(ctx, pay.ID, domain.RefundPending)
+	if err != nil {
+		return money.Money{}, common.NewAppError(err, "Failed to list refunds", common.ErrInternalServer.Code)
+	}
+	failed, err := s.payments.ListRefunds(ctx, pay.ID, domain.RefundFailed)
+	if err != nil {
+		return money.Money{}, common.NewAppError(err, "Failed to list refunds", common.ErrInternalServer.Code)
+	}
+	amounts := []money.Money{pay.RefundedAmount}
+	for _, refund := range pending {
+		amounts = append(amounts, refund.Amount)
+	}
+	for _, refund := range failed {
+		if refund.Reference != "" {
+			amounts = append(amounts, refund.Amount)
+		}
+	}
+	taken, err := money.Sum(pay.Currency, amounts...)
+	if err == nil {
+		var remaining money.Money
+		if remaining, err = pay.Amount.Sub(taken); err == nil {
+			return remaining, nil
+		}
+	}
+	return money.Money{}, common.NewAppError(err, "Failed to compute refundable amount", common.ErrInternalServer.Code)
+}
+
+// completeRefund records what the provider answered to refund refundID of
+// payment paymentID. A refund completed by a concurrent retry is left as
+// it is.
+func (s *PaymentService) completeRefund(ctx context.Context, paymentID, refundID uint, result *payment.Refund, providerErr error) (*domain.Payment, *common.AppError) {
+	var pay *domain.Payment
+	aerr := inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+		var err error
+		pay, err = s.payments.GetForUpdate(ctx, paymentID)
+		if err != nil {
+			return common.NewAppError(err, "Payment not found", http.StatusNotFound)
+		}
+		refund, err := s.payments.GetRefundForUpdate(ctx, refundID)
+		if err != nil {
+			return common.NewAppError(err, "Refund not found", http.StatusNotFound)
+		}
+		if refund.Status == domain.RefundSucceeded {
+			return nil
+		}
+
+		if providerErr != nil {
+			refund.Status = domain.RefundFailed
+			refund.FailureReason = providerErr.Error()
+		} else {
+			refund.Status = domain.RefundSucceeded
+			refund.ProviderRef = result.ID
+			refund.FailureReason = ""
+		}
+		if err := s.payments.UpdateRefund(ctx, refund); err != nil {
+			return common.NewAppError(err, "Failed to update refund", common.ErrInternalServer.Code)
+		}
+		if providerErr != nil {
+			return nil
+		}
+		return s.recordRefund(ctx, pay, refund.Amount)
+	})
+	if aerr != nil {
+		return nil, aerr
+	}
+	if providerErr != nil {
+		return nil, common.NewAppError(providerErr, "Failed to refund payment", http.StatusBadGateway)
+	}
+	return pay, nil
+}
+
+// RefundOrder refunds amount of the captured payment of an order. A
+// refund already made for reference is not made again.
+func (s *PaymentService) RefundOrder(ctx context.Context, orderID uint, amount money.Money, reference string) (*domain.Payment, *common.AppError) {
+	payments, err := s.payments.ListByOrder(ctx, orderID)
+	if err != nil {
+		return nil, common.NewAppError(err, "Failed to list payments", common.ErrInternalServer.Code)
+	}
+	for _, pay := range payments {
+		if _, err := s.payments.GetRefundByReference(ctx, pay.ID, reference); err == nil {
+			return s.Refund(ctx, pay.ID, &amount, reference)
+		}
+	}
+	for _, pay := range payments {
+		if pay.Status == domain.PaymentRecordCaptured {
+			return s.Refund(ctx, pay.ID, &amount, reference)
+		}
+	}
+	return nil, common.NewAppError(nil, "Order has no captured payment to refund", http.StatusConflict)
+}
+
+// recordRefund records amount refunded on a payment and its order. Once all
+// of the payment is refunded the order is too, and cancelled if it has not
+// shipped.
+func (s *PaymentService) recordRefund(ctx context.Context, pay *domain.Payment, amount money.Money) *common.AppError {
+	refunded, err := pay.RefundedAmount.Add(amount)
+	if err != nil {
+		return common.NewAppError(err, "Failed to record refund", common.ErrInternalServer.Code)
+	}
+	pay.RefundedAmount = refunded
+	if cmp, _ := pay.RefundedAmount.Cmp(pay.Amount); cmp >= 0 {
+		pay.Status = domain.PaymentRecordRefunded
+	}
+	if err := s.payments.Update(ctx, pay); err != nil {
+		return common.NewAppError(err, "Failed to update payment", common.ErrInternalServer.Code)
+	}
+	if aerr := s.orders.record(ctx, pay.OrderID, domain.EventPayment, "", "", fmt.Sprintf("Refunded %s", amount), false); aerr != nil {
+		return aerr
+	}
+
+	if pay.Status != domain.PaymentRecordRefunded {
+		return nil
+	}
+	order, err := s.orderRepo.GetByID(ctx, pay.OrderID)
+	if err != nil {
+		return common.NewAppError(err, "Failed to get order", common.ErrInternalServer.Code)
+	}
+	if !order.IsPaid() {
+		return nil
+	}
+	if aerr := s.orders.UpdatePaymentStatus(ctx, pay.OrderID, domain.PaymentRefunded); aerr != nil {
+		return aerr
+	}
+	// Once refunded, an order that has not shipped is cancelled and its
+	// committed stock goes back on hand
+	if order.Status == domain.StatusPending || order.Status == domain.StatusConfirmed {
+		return s.orders.transition(ctx, order.ID, domain.StatusCancelled)
+	}
+	return nil
+}
+
+// CancelOrder cancels an order that has not shipped yet, its owner or an
+// admin may cancel it. A paid order has its payment refunded in full,
+// which cancels it, and a payment of an unpaid order that is still waiting
+// to be captured is voided.
+func (s *PaymentService) CancelOrder(ctx context.Context, id uint, actor Actor) *common.AppError {
+	order, aerr := s.orders.getOwned(ctx, id, actor)
+	if aerr != nil {
+		return aerr
+	}
+	if !order.IsPaid() {
+		if aerr := s.orders.CancelOrder(ctx, id, actor); aerr != nil {
+			return aerr
+		}
+		return s.voidActive(ctx, id)
+	}
+
+	if order.Status != domain.StatusPending && order.Status != domain.StatusConfirmed {
+		return common.NewAppError(nil, "Shipped orders are refunded through a return", http.StatusConflict)
+	}
+	payments, err := s.payments.ListByOrder(ctx, id)
+	if err != nil {
+		return common.NewAppError(err, "Failed to list payments", common.ErrInternalServer.Code)
+	}
+	for _, pay := range payments {
+		if pay.Status == domain.PaymentRecordCaptured {
+			_, aerr := s.Refund(ctx, pay.ID, nil, OrderReference(id))
+			return aerr
+		}
+	}
+	return common.NewAppError(nil, "Order has no captured payment to refund", http.StatusConflict)
+}
+
+// voidActive settles the payments of a cancelled order that are still in
+// progress, which voids the authorized ones. A payment the provider could
+// not be asked about is voided by its next webhook or sync.
+func (s *PaymentService) voidActive(ctx context.Context, orderID uint) *common.AppError {
+	payments, err := s.payments.ListByOrder(ctx, orderID)
+	if err != nil {
+		return common.NewAppError(err, "Failed to list payments", common.ErrInternalServer.Code)
+	}
+	for _, pay := range payments {
+		if pay.ProviderRef == "" || !pay.IsActive() {
+			continue
+		}
+		intent, err := s.provider.GetIntent(ctx, pay.ProviderRef)
+		if err != nil {
+			return common.NewAppError(err, "Payment provider is unavailable", http.StatusBadGateway)
+		}
+		if _, aerr := s.settle(ctx, pay.ID, intent); aerr != nil {
+			return aerr
+		}
+	}
+	return nil
+}
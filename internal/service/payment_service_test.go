@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/internal/repository"
+	"github.com/Dubjay18/ecom-api/internal/testutil"
+	"github.com/Dubjay18/ecom-api/pkg/money"
+	"github.com/Dubjay18/ecom-api/pkg/payment"
+	"gorm.io/gorm"
+)
+
+// refundProvider refunds through fn and checks that the payment being
+// refunded is not locked while it does
+type refundProvider struct {
+	payment.Provider
+	t     *testing.T
+	db    *gorm.DB
+	calls int
+	keys  []string
+	fn    func(amount money.Money) (*payment.Refund, error)
+}
+
+func (p *refundProvider) Refund(ctx context.Context, id string, amount money.Money, idempotencyKey string) (*payment.Refund, error) {
+	p.calls++
+	p.keys = append(p.keys, idempotencyKey)
+	err := p.db.Transaction(func(tx *gorm.DB) error {
+		return tx.Exec(`SELECT id FROM payments WHERE provider_ref = ? FOR UPDATE NOWAIT`, id).Error
+	})
+	if err != nil {
+		p.t.Errorf("payment is locked while the provider refunds it: %v", err)
+	}
+	return p.fn(amount)
+}
+
+func newTestPayments(db *gorm.DB, provider payment.Provider) *PaymentService {
+	tx := repository.NewTxManager(db)
+	orderRepo := repository.NewOrderRepository(db)
+	orders := NewOrderService(tx, orderRepo, repository.NewProductRepository(db), newTestInventory(db), nil,
+		repository.NewOrderEventRepository(db), NewPromotionService(repository.NewPromotionRepository(db)))
+	return NewPaymentService(tx, repository.NewPaymentRepository(db), orderRepo, orders, provider, nil)
+}
+
+// seedCapturedPayment creates a payment of 50 USD captured for a new order
+func seedCapturedPayment(t *testing.T, db *gorm.DB) uint {
+	t.Helper()
+	var paymentID uint
+	err := db.Raw(`INSERT INTO payments (order_id, provider, provider_ref, status, amount, currency)
+		VALUES (?, 'test', 'pi_refund', ?, 50, 'USD') RETURNING id`,
+		seedOrder(t, db), domain.PaymentRecordCaptured).Scan(&paymentID).Error
+	if err != nil {
+		t.Fatalf("seed payment: %v", err)
+	}
+	return paymentID
+}
+
+func TestRefundIsResumedAfterProviderFailure(t *testing.T) {
+	db := testutil.NewPostgresDB(t)
+	provider := &refundProvider{t: t, db: db}
+	payments := newTestPayments(db, provider)
+	paymentID := seedCapturedPayment(t, db)
+	ctx := context.Background()
+	amount := money.New(2000, "USD")
+
+	provider.fn = func(money.Money) (*payment.Refund, error) {
+		return nil, errors.New("provider unavailable")
+	}
+	if _, aerr := payments.Refund(ctx, paymentID, &amount, "return:1"); aerr == nil || aerr.Code != http.StatusBadGateway {
+		t.Fatalf("refund with the provider down: got %v, want 502", aerr)
+	}
+	var status domain.RefundStatus
+	if err := db.Raw(`SELECT status FROM payment_refunds WHERE payment_id = ?`, paymentID).Scan(&status).Error; err != nil {
+		t.Fatal(err)
+	}
+	if status != domain.RefundFailed {
+		t.Fatalf("refund status = %q, want %q", status, domain.RefundFailed)
+	}
+
+	provider.fn = func(amount money.Money) (*payment.Refund, error) {
+		return &payment.Refund{ID: "re_1", Amount: amount}, nil
+	}
+	for i := 0; i < 2; i++ {
+		pay, aerr := payments.Refund(ctx, paymentID, &amount, "return:1")
+		if aerr != nil {
+			t.Fatalf("retry %d: %v", i+1, aerr)
+		}
+		if cmp, _ := pay.RefundedAmount.Cmp(amount); cmp != 0 {
+			t.Fatalf("retry %d: refunded %s, want %s", i+1, pay.RefundedAmount, amount)
+		}
+	}
+	if provider.calls != 2 {
+		t.Errorf("provider asked for %d refunds, want 2", provider.calls)
+	} else if provider.keys[0] != provider.keys[1] {
+		t.Errorf("retry sent idempotency key %q, want %q", provider.keys[1], provider.keys[0])
+	}
+
+	var refunds int64
+	if err := db.Raw(`SELECT COUNT(*) FROM payment_refunds WHERE payment_id = ?`, paymentID).Scan(&refunds).Error; err != nil {
+		t.Fatal(err)
+	}
+	if refunds != 1 {
+		t.Errorf("%d refunds recorded, want 1", refunds)
+	}
+}
+
+func TestRefundCountsPendingRefunds(t *testing.T) {
+	db := testutil.NewPostgresDB(t)
+	provider := &refundProvider{t: t, db: db}
+	payments := newTestPayments(db, provider)
+	paymentID := seedCapturedPayment(t, db)
+	ctx := context.Background()
+
+	// A refund of 40 is waiting for the provider when another one of 20
+	// is asked for
+	provider.fn = func(money.Money) (*payment.Refund, error) {
+		amount := money.New(2000, "USD")
+		_, aerr := payments.Refund(ctx, paymentID, &amount, "")
+		if aerr == nil || aerr.Code != http.StatusBadRequest {
+			t.Errorf("refund beyond the pending one: got %v, want 400", aerr)
+		}
+		return &payment.Refund{ID: "re_1", Amount: money.New(4000, "USD")}, nil
+	}
+	amount := money.New(4000, "USD")
+	if _, aerr := payments.Refund(ctx, paymentID, &amount, ""); aerr != nil {
+		t.Fatal(aerr)
+	}
+}
+
+func TestCancelPaidOrderRefundsIt(t *testing.T) {
+	db := testutil.NewPostgresDB(t)
+	provider := &refundProvider{t: t, db: db}
+	provider.fn = func(amount money.Money) (*payment.Refund, error) {
+		return &payment.Refund{ID: "re_1", Amount: amount}, nil
+	}
+	payments := newTestPayments(db, provider)
+	paymentID := seedCapturedPayment(t, db)
+	var orderID uint
+	err := db.Raw(`UPDATE orders SET status = ?, payment_status = ?, total_amount = 50
+		WHERE id = (SELECT order_id FROM payments WHERE id = ?) RETURNING id`,
+		domain.StatusConfirmed, domain.PaymentCompleted, paymentID).Scan(&orderID).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	admin := Actor{IsAdmin: true}
+
+	if aerr := payments.orders.CancelOrder(ctx, orderID, admin); aerr == nil || aerr.Code != http.StatusConflict {
+		t.Fatalf("cancelling a paid order without a refund: got %v, want 409", aerr)
+	}
+	if aerr := payments.CancelOrder(ctx, orderID, admin); aerr != nil {
+		t.Fatal(aerr)
+	}
+
+	var order struct {
+		Status        domain.OrderStatus
+		PaymentStatus domain.PaymentStatus
+	}
+	if err := db.Raw(`SELECT status, payment_status FROM orders WHERE id = ?`, orderID).Scan(&order).Error; err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != domain.StatusCancelled || order.PaymentStatus != domain.PaymentRefunded {
+		t.Errorf("order is %s and %s, want cancelled and refunded", order.Status, order.PaymentStatus)
+	}
+	if provider.calls != 1 {
+		t.Errorf("provider asked for %d refunds, want 1", provider.calls)
+	}
+}
+
+func TestRefundCountsResumableFailedRefunds(t *testing.T) {
+	db := testutil.NewPostgresDB(t)
+	provider := &refundProvider{t: t, db: db}
+	payments := newTestPayments(db, provider)
+	paymentID := seedCapturedPayment(t, db)
+	ctx := context.Background()
+
+	provider.fn = func(money.Money) (*payment.Refund, error) {
+		return nil, errors.New("provider unavailable")
+	}
+	failed := money.New(4000, "USD")
+	if _, aerr := payments.Refund(ctx, paymentID, &failed, "return:1"); aerr == nil || aerr.Code != http.StatusBadGateway {
+		t.Fatalf("refund with the provider down: got %v, want 502", aerr)
+	}
+
+	// The failed refund of 40 is resumed by its reference, so another one
+	// of 20 would have the payment refunded beyond what was captured
+	provider.fn = func(amount money.Money) (*payment.Refund, error) {
+		return &payment.Refund{ID: "re_1", Amount: amount}, nil
+	}
+	amount := money.New(2000, "USD")
+	if _, aerr := payments.Refund(ctx, paymentID, &amount, ""); aerr == nil || aerr.Code != http.StatusBadRequest {
+		t.Fatalf("refund beyond the failed one: got %v, want 400", aerr)
+	}
+	if _, aerr := payments.Refund(ctx, paymentID, &failed, "return:1"); aerr != nil {
+		t.Fatal(aerr)
+	}
+}
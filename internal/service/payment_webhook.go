@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/pkg/common"
+	"github.com/Dubjay18/ecom-api/pkg/money"
+	"github.com/Dubjay18/ecom-api/pkg/payment"
+	"gorm.io/gorm"
+)
+
+// HandleWebhook verifies, stores and applies a webhook request from the
+// payment provider. Events are stored before they are applied so they can
+// be replayed, and redeliveries of applied events are acknowledged without
+// applying them again. An error asks the provider to deliver the event
+// again later.
+func (s *PaymentService) HandleWebhook(ctx context.Context, payload []byte, header http.Header) *common.AppError {
+	if err := s.provider.VerifyWebhook(payload, header); err != nil {
+		return common.NewAppError(err, "Invalid webhook signature", http.StatusBadRequest)
+	}
+	event, err := s.provider.ParseWebhook(payload)
+	if err != nil || event.ID == "" {
+		return common.NewAppError(err, "Invalid webhook payload", http.StatusBadRequest)
+	}
+
+	record := &domain.PaymentWebhookEvent{
+		Provider:   s.provider.Name(),
+		EventID:    event.ID,
+		Type:       event.Type,
+		Payload:    payload,
+		Status:     domain.WebhookReceived,
+		ReceivedAt: time.Now(),
+	}
+	isNew, err := s.webhooks.Store(ctx, record)
+	if err != nil {
+		return common.NewAppError(err, "Failed to store webhook event", common.ErrInternalServer.Code)
+	}
+	if !isNew {
+		record, err = s.webhooks.GetByEventID(ctx, s.provider.Name(), event.ID)
+		if err != nil {
+			return common.NewAppError(err, "Failed to get webhook event", common.ErrInternalServer.Code)
+		}
+		if record.Status == domain.WebhookProcessed || record.Status == domain.WebhookIgnored {
+			return nil
+		}
+	}
+	return s.process(ctx, record, event)
+}
+
+// ListWebhookEvents returns the latest stored webhook events, of one status
+// if status is set (admin privilege)
+func (s *PaymentService) ListWebhookEvents(ctx context.Context, status domain.WebhookEventStatus, limit int) ([]domain.PaymentWebhookEvent, *common.AppError) {
+	events, err := s.webhooks.List(ctx, status, limit)
+	if err != nil {
+		return nil, common.NewAppError(err, "Failed to list webhook events", common.ErrInternalServer.Code)
+	}
+	return events, nil
+}
+
+// ReplayWebhookEvent applies a stored webhook event again, such as one that
+// failed (admin privilege). Applying an event is idempotent.
+func (s *PaymentService) ReplayWebhookEvent(ctx context.Context, id uint) (*domain.PaymentWebhookEvent, *common.AppError) {
+	record, err := s.webhooks.GetByID(ctx, id)
+	if err != nil {
+		return nil, common.NewAppError(err, "Webhook event not found", http.StatusNotFound)
+	}
+	if record.Provider != s.provider.Name() {
+		return nil, common.NewAppError(nil, "Webhook event is from another payment provider", http.StatusConflict)
+	}
+	event, err := s.provider.ParseWebhook(record.Payload)
+	if err != nil {
+		return nil, common.NewAppError(err, "Invalid webhook payload", http.StatusBadRequest)
+	}
+	if aerr := s.process(ctx, record, event); aerr != nil {
+		return nil, aerr
+	}
+	return record, nil
+}
+
+// process applies event and records the outcome on its stored record
+func (s *PaymentService) process(ctx context.Context, record *domain.PaymentWebhookEvent, event *payment.Event) *common.AppError {
+	handled, aerr := s.applyEvent(ctx, event)
+
+	now := time.Now()
+	record.ProcessedAt = &now
+	record.Error = ""
+	switch {
+	case aerr != nil:
+		record.Status = domain.WebhookFailed
+		record.Error = aerr.Message
+	case !handled:
+		record.Status = domain.WebhookIgnored
+	default:
+		record.Status = domain.WebhookProcessed
+	}
+	if err := s.webhooks.Update(ctx, record); err != nil {
+		return common.NewAppError(err, "Failed to update webhook event", common.ErrInternalServer.Code)
+	}
+	return aerr
+}
+
+// applyEvent brings the payment an event is about up to date, it reports
+// false for events that need no action, such as ones about payments this
+// application did not start
+func (s *PaymentService) applyEvent(ctx context.Context, event *payment.Event) (bool, *common.AppError) {
+	if event.Kind == payment.EventIgnored {
+		return false, nil
+	}
+	pay, err := s.payments.GetByProviderRef(ctx, s.provider.Name(), event.IntentID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, common.NewAppError(err, "Failed to get payment", common.ErrInternalServer.Code)
+	}
+
+	switch event.Kind {
+	case payment.EventIntentUpdated:
+		_, aerr := s.settle(ctx, pay.ID, event.Intent)
+		return true, aerr
+	case payment.EventRefunded:
+		return true, s.refunded(ctx, pay.ID, event.Refunded)
+	}
+	return false, nil
+}
+
+// refunded records refunds made at the provider, total is everything
+// refunded on the payment so far. Refunds made through Refund are already
+// recorded, or will be once the provider answers or they are resumed, and
+// not counted twice.
+func (s *PaymentService) refunded(ctx context.Context, id uint, total money.Money) *common.AppError {
+	return inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+		pay, err := s.payments.GetForUpdate(ctx, id)
+		if err != nil {
+			return common.NewAppError(err, "Payment not found", http.StatusNotFound)
+		}
+		if pay.Status != domain.PaymentRecordCaptured {
+			return nil
+		}
+		remaining, aerr := s.refundable(ctx, pay)
+		if aerr != nil {
+			return aerr
+		}
+		refunded, err := pay.Amount.Sub(remaining)
+		if err != nil {
+			return common.NewAppError(err, "Failed to compute refunded amount", common.ErrInternalServer.Code)
+		}
+		amount, err := total.Sub(refunded)
+		if err != nil {
+			return common.NewAppError(err, "Refund is in the wrong currency", http.StatusBadRequest)
+		}
+		if !amount.IsPositive() {
+			return nil
+		}
+		return s.recordRefund(ctx, pay, amount)
+	})
+}
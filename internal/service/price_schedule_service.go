@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/internal/repository"
+	"github.com/Dubjay18/ecom-api/pkg/common"
+	"github.com/Dubjay18/ecom-api/pkg/money"
+	"gorm.io/gorm"
+)
+
+type PriceScheduleService struct {
+	tx          repository.TxManager
+	productRepo repository.ProductRepository
+	history     repository.PriceHistoryRepository
+}
+
+func NewPriceScheduleService(tx repository.TxManager, pr repository.ProductRepository, history repository.PriceHistoryRepository) *PriceScheduleService {
+	return &PriceScheduleService{tx: tx, productRepo: pr, history: history}
+}
+
+// History returns every price change of a product, newest first
+func (s *PriceScheduleService) History(ctx context.Context, productID uint) ([]domain.PriceChange, *common.AppError) {
+	changes, err := s.history.List(ctx, productID)
+	if err != nil {
+		return nil, common.NewAppError(err, "Failed to list price history", common.ErrInternalServer.Code)
+	}
+	return changes, nil
+}
+
+// PriceAt returns the price change in effect for a product at a time
+func (s *PriceScheduleService) PriceAt(ctx context.Context, productID uint, at time.Time) (*domain.PriceChange, *common.AppError) {
+	change, err := s.history.GetAt(ctx, productID, at)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.NewAppError(err, "No price recorded at that time", http.StatusNotFound)
+		}
+		return nil, common.NewAppError(err, "Failed to get price history", common.ErrInternalServer.Code)
+	}
+	return change, nil
+}
+
+// Schedule plans a price change for a product. Sales, schedules with an
+// end time, may not overlap each other.
+func (s *PriceScheduleService) Schedule(ctx context.Context, productID, userID uint, req *domain.CreateScheduledPriceRequest) (*domain.ScheduledPrice, *common.AppError) {
+	if _, err := s.productRepo.GetByID(ctx, productID); err != nil {
+		return nil, common.NewAppError(err, "Product not found", http.StatusNotFound)
+	}
+	if !req.Price.IsPositive() {
+		return nil, common.NewAppError(nil, "Price must be greater than zero", http.StatusBadRequest)
+	}
+	if req.Price.Currency != money.DefaultCurrency {
+		return nil, common.NewAppError(nil, "Scheduled prices must be in the base currency", http.StatusBadRequest)
+	}
+	if req.EndsAt != nil {
+		if !req.EndsAt.After(req.StartsAt) {
+			return nil, common.NewAppError(nil, "ends_at must be after starts_at", http.StatusBadRequest)
+		}
+		if !req.EndsAt.After(time.Now()) {
+			return nil, common.NewAppError(nil, "ends_at must be in the future", http.StatusBadRequest)
+		}
+	}
+
+	schedule := &domain.ScheduledPrice{
+		ProductID: productID,
+		Price:     req.Price,
+		StartsAt:  req.StartsAt,
+		EndsAt:    req.EndsAt,
+		Status:    domain.SchedulePending,
+		CreatedBy: &userID,
+	}
+	aerr := inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+		// Lock the product so concurrent schedules are checked one at a time
+		if _, err := s.productRepo.GetForUpdate(ctx, productID); err != nil {
+			return common.NewAppError(err, "Failed to lock product", common.ErrInternalServer.Code)
+		}
+		overlapping, err := s.history.CountOverlapping(ctx, productID, req.StartsAt, req.EndsAt)
+		if err != nil {
+			return common.NewAppError(err, "Failed to check scheduled prices", common.ErrInternalServer.Code)
+		}
+		if overlapping > 0 {
+			return common.NewAppError(nil, "The schedule overlaps a sale already scheduled for the product", http.StatusConflict)
+		}
+		if err := s.history.CreateSchedule(ctx, schedule); err != nil {
+			return common.NewAppError(err, "Failed to schedule price", common.ErrInternalServer.Code)
+		}
+		return nil
+	})
+	if aerr != nil {
+		return nil, aerr
+	}
+	return schedule, nil
+}
+
+// ListSchedules returns the scheduled prices of a product
+func (s *PriceScheduleService) ListSchedules(ctx context.Context, productID uint) ([]domain.ScheduledPrice, *common.AppError) {
+	schedules, err := s.history.ListSchedules(ctx, productID)
+	if err != nil {
+		return nil, common.NewAppError(err, "Failed to list scheduled prices", common.ErrInternalServer.Code)
+	}
+	return schedules, nil
+}
+
+// Cancel cancels a scheduled price, a running sale ends straight away and
+// the original price is restored
+func (s *PriceScheduleService) Cancel(ctx context.Context, productID, scheduleID, userID uint) (*domain.ScheduledPrice, *common.AppError) {
+	schedule, err := s.history.GetSchedule(ctx, scheduleID)
+	if err != nil || schedule.ProductID != productID {
+		return nil, common.NewAppError(err, "Scheduled price not found", http.StatusNotFound)
+	}
+
+	switch schedule.Status {
+	case domain.SchedulePending:
+		schedule.Status = domain.ScheduleCancelled
+		claimed, err := s.history.TransitionSchedule(ctx, schedule, domain.SchedulePending)
+		if err != nil {
+			return nil, common.NewAppError(err, "Failed to cancel scheduled price", common.ErrInternalServer.Code)
+		}
+		if !claimed {
+			return nil, common.NewAppError(nil, "The scheduled price has already started, try again", http.StatusConflict)
+		}
+	case domain.ScheduleActive:
+		if aerr := s.end(ctx, schedule, domain.ScheduleCancelled, &userID); aerr != nil {
+			return nil, aerr
+		}
+	default:
+		return nil, common.NewAppError(nil, "The scheduled price is already "+string(schedule.Status), http.StatusConflict)
+	}
+	return schedule, nil
+}
+
+// ApplyDue ends sales whose time is up and starts schedules whose time has
+// come, returning how many schedules changed a price
+func (s *PriceScheduleService) ApplyDue(ctx context.Context, limit int) (int, *common.AppError) {
+	now := time.Now()
+	applied := 0
+
+	// End sales first so a sale starting as another ends sees the regular price
+	ending, err := s.history.ListDueEnds(ctx, now, limit)
+	if err != nil {
+		return 0, common.NewAppError(err, "Failed to list ending sales", common.ErrInternalServer.Code)
+	}
+	for i := range ending {
+		if aerr := s.end(ctx, &ending[i], domain.ScheduleCompleted, nil); aerr != nil {
+			return applied, aerr
+		}
+		applied++
+	}
+
+	starting, err := s.history.ListDueStarts(ctx, now, limit)
+	if err != nil {
+		return applied, common.NewAppError(err, "Failed to list scheduled prices", common.ErrInternalServer.Code)
+	}
+	for i := range starting {
+		if aerr := s.start(ctx, &starting[i], now); aerr != nil {
+			return applied, aerr
+		}
+		applied++
+	}
+	return applied, nil
+}
+
+// start applies a scheduled price, keeping the regular price as the
+// compare-at price for the length of a sale
+func (s *PriceScheduleService) start(ctx context.Context, schedule *domain.ScheduledPrice, now time.Time) *common.AppError {
+	return inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+		product, err := s.productRepo.GetForUpdate(ctx, schedule.ProductID)
+		if err != nil {
+			return common.NewAppError(err, "Failed to lock product", common.ErrInternalServer.Code)
+		}
+
+		// A sale that ended before it could start is skipped
+		missed := schedule.IsSale() && !schedule.EndsAt.After(now)
+
+		schedule.Status = domain.ScheduleCompleted
+		var compareAt *money.Money
+		if schedule.IsSale() && !missed {
+			original := product.Price
+			schedule.Status = domain.ScheduleActive
+			schedule.OriginalPrice = &original
+			compareAt = &original
+		}
+		claimed, err := s.history.TransitionSchedule(ctx, schedule, domain.SchedulePending)
+		if err != nil {
+			return common.NewAppError(err, "Failed to start scheduled price", common.ErrInternalServer.Code)
+		}
+		if !claimed || missed {
+			return nil
+		}
+
+		return changePrice(ctx, s.productRepo, s.history, product, schedule.Price, compareAt, domain.PriceChange{
+			Source:     domain.PriceSourceScheduleStart,
+			ScheduleID: &schedule.ID,
+			ChangedBy:  schedule.CreatedBy,
+		})
+	})
+}
+
+// end restores the price a sale replaced
+func (s *PriceScheduleService) end(ctx context.Context, schedule *domain.ScheduledPrice, status domain.ScheduleStatus, userID *uint) *common.AppError {
+	return inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+		product, err := s.productRepo.GetForUpdate(ctx, schedule.ProductID)
+		if err != nil {
+			return common.NewAppError(err, "Failed to lock product", common.ErrInternalServer.Code)
+		}
+
+		schedule.Status = status
+		claimed, err := s.history.TransitionSchedule(ctx, schedule, domain.ScheduleActive)
+		if err != nil {
+			return common.NewAppError(err, "Failed to end scheduled price", common.ErrInternalServer.Code)
+		}
+		if !claimed || schedule.OriginalPrice == nil {
+			return nil
+		}
+
+		changedBy := schedule.CreatedBy
+		if userID != nil {
+			changedBy = userID
+		}
+		return changePrice(ctx, s.productRepo, s.history, product, *schedule.OriginalPrice, nil, domain.PriceChange{
+			Source:     domain.PriceSourceScheduleEnd,
+			ScheduleID: &schedule.ID,
+			ChangedBy:  changedBy,
+		})
+	})
+}
+
+// changePrice sets the price of a locked product and records the change in
+// its price history
+func changePrice(ctx context.Context, products repository.ProductRepository, history repository.PriceHistoryRepository, product *domain.Product, price money.Money, compareAt *money.Money, change domain.PriceChange) *common.AppError {
+	if err := products.SetPrice(ctx, product.ID, price, compareAt); err != nil {
+		return common.NewAppError(err, "Failed to update price", common.ErrInternalServer.Code)
+	}
+
+	old := product.Price
+	change.ProductID = product.ID
+	change.OldPrice = &old
+	change.NewPrice = price
+	change.CompareAtPrice = compareAt
+	change.ChangedAt = time.Now()
+	if err := history.Record(ctx, &change); err != nil {
+		return common.NewAppError(err, "Failed to record price change", common.ErrInternalServer.Code)
+	}
+
+	product.Price = price
+	product.CompareAtPrice = compareAt
+	return nil
+}
+
+// priceSource says where a price change made alongside a stock change came from
+func priceSource(change domain.StockChange) domain.PriceChangeSource {
+	if change.Type == domain.MovementImport {
+		return domain.PriceSourceImport
+	}
+	return domain.PriceSourceManual
+}
@@ -0,0 +1,239 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/internal/repository"
+	"github.com/Dubjay18/ecom-api/pkg/common"
+	"github.com/Dubjay18/ecom-api/pkg/money"
+	"gorm.io/gorm"
+)
+
+// rateScale is the number of decimal places exchange rates are kept to
+const rateScale = 8
+
+type PricingService struct {
+	repo        repository.PriceRepository
+	productRepo repository.ProductRepository
+	userRepo    repository.UserRepository
+	base        string
+	shipping    money.Money
+}
+
+// NewPricingService returns a service pricing the catalog in other
+// currencies, product prices are stored in the base currency. Every order
+// is charged shipping, a flat rate in the base currency.
+func NewPricingService(repo repository.PriceRepository, pr repository.ProductRepository, ur repository.UserRepository, base string, shipping money.Money) *PricingService {
+	return &PricingService{repo: repo, productRepo: pr, userRepo: ur, base: base, shipping: shipping}
+}
+
+// Shipping returns the shipping charge of an order in currency, at the
+// exchange rate rate returned by Quote
+func (s *PricingService) Shipping(currency, rate string) (money.Money, *common.AppError) {
+	if currency == s.shipping.Currency {
+		return s.shipping, nil
+	}
+	rat, err := money.ParseRate(rate)
+	if err != nil {
+		return money.Money{}, common.NewAppError(err, "Invalid exchange rate", common.ErrInternalServer.Code)
+	}
+	shipping, err := s.shipping.Convert(rat, currency)
+	if err != nil {
+		return money.Money{}, common.NewAppError(err, "Failed to convert shipping", common.ErrInternalServer.Code)
+	}
+	return shipping, nil
+}
+
+// BaseCurrency returns the currency product prices are stored in
+func (s *PricingService) BaseCurrency() string {
+	return s.base
+}
+
+// ResolveCurrency picks the currency of a request: the requested one if
+// given, then the user's preference, then the base currency. A currency
+// other than the base one needs an exchange rate to be sold in.
+func (s *PricingService) ResolveCurrency(ctx context.Context, requested string, userID uint) (string, *common.AppError) {
+	currency := strings.ToUpper(strings.TrimSpace(requested))
+	if currency == "" {
+		if userID != 0 {
+			if user, err := s.userRepo.GetByID(ctx, userID); err == nil && user.PreferredCurrency != "" {
+				// A preference for a currency no longer sold falls back quietly
+				if s.isSold(ctx, user.PreferredCurrency) {
+					return user.PreferredCurrency, nil
+				}
+			}
+		}
+		return s.base, nil
+	}
+
+	if !money.IsSupported(currency) {
+		return "", common.NewAppError(nil, "Unsupported currency", http.StatusBadRequest)
+	}
+	if !s.isSold(ctx, currency) {
+		return "", common.NewAppError(nil, "Currency is not available, it has no exchange rate", http.StatusBadRequest)
+	}
+	return currency, nil
+}
+
+func (s *PricingService) isSold(ctx context.Context, currency string) bool {
+	if currency == s.base {
+		return true
+	}
+	_, err := s.repo.GetRate(ctx, currency)
+	return err == nil
+}
+
+// Quote prices products in currency, using each product's explicit price in
+// that currency if it has one and converting its base price otherwise. It
+// also returns the exchange rate from the base currency.
+func (s *PricingService) Quote(ctx context.Context, products []domain.Product, currency string) (map[uint]money.Money, string, *common.AppError) {
+	prices := make(map[uint]money.Money, len(products))
+	if currency == s.base {
+		for _, p := range products {
+			prices[p.ID] = p.Price
+		}
+		return prices, "1", nil
+	}
+
+	rate, err := s.repo.GetRate(ctx, currency)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", common.NewAppError(err, "Currency is not available, it has no exchange rate", http.StatusBadRequest)
+		}
+		return nil, "", common.NewAppError(err, "Failed to get exchange rate", common.ErrInternalServer.Code)
+	}
+	rat, err := money.ParseRate(rate.Rate)
+	if err != nil {
+		return nil, "", common.NewAppError(err, "Invalid exchange rate", common.ErrInternalServer.Code)
+	}
+
+	ids := make([]uint, len(products))
+	for i, p := range products {
+		ids[i] = p.ID
+	}
+	explicit, err := s.repo.GetProductPrices(ctx, ids, currency)
+	if err != nil {
+		return nil, "", common.NewAppError(err, "Failed to get product prices", common.ErrInternalServer.Code)
+	}
+	for _, price := range explicit {
+		prices[price.ProductID] = price.Amount
+	}
+
+	for _, p := range products {
+		if _, ok := prices[p.ID]; ok {
+			continue
+		}
+		converted, err := p.Price.Convert(rat, currency)
+		if err != nil {
+			return nil, "", common.NewAppError(err, "Failed to convert price", common.ErrInternalServer.Code)
+		}
+		prices[p.ID] = converted
+	}
+	return prices, rat.FloatString(rateScale), nil
+}
+
+// Localize sets the DisplayPrice of products priced in a currency other than
+// the base one
+func (s *PricingService) Localize(ctx context.Context, products []domain.Product, currency string) *common.AppError {
+	if currency == s.base || len(products) == 0 {
+		return nil
+	}
+	prices, _, aerr := s.Quote(ctx, products, currency)
+	if aerr != nil {
+		return aerr
+	}
+	for i := range products {
+		price := prices[products[i].ID]
+		products[i].DisplayPrice = &price
+	}
+	return nil
+}
+
+// ListProductPrices returns the explicit prices of a product
+func (s *PricingService) ListProductPrices(ctx context.Context, productID uint) ([]domain.ProductPrice, *common.AppError) {
+	prices, err := s.repo.ListProductPrices(ctx, productID)
+	if err != nil {
+		return nil, common.NewAppError(err, "Failed to list product prices", common.ErrInternalServer.Code)
+	}
+	return prices, nil
+}
+
+// SetProductPrice sets the explicit price of a product in a currency
+func (s *PricingService) SetProductPrice(ctx context.Context, productID uint, currency, amount string) (*domain.ProductPrice, *common.AppError) {
+	currency = strings.ToUpper(currency)
+	if aerr := s.validateForeignCurrency(currency); aerr != nil {
+		return nil, aerr
+	}
+	if _, err := s.productRepo.GetByIDIncludingArchived(ctx, productID); err != nil {
+		return nil, common.NewAppError(err, "Product not found", http.StatusNotFound)
+	}
+
+	parsed, err := money.Parse(amount, currency)
+	if err != nil || !parsed.IsPositive() {
+		return nil, common.NewAppError(err, "Amount must be a positive decimal", http.StatusBadRequest)
+	}
+
+	price := &domain.ProductPrice{ProductID: productID, Currency: currency, Amount: parsed}
+	if err := s.repo.UpsertProductPrice(ctx, price); err != nil {
+		return nil, common.NewAppError(err, "Failed to set product price", common.ErrInternalServer.Code)
+	}
+	return price, nil
+}
+
+// DeleteProductPrice removes an explicit price, the product is converted
+// from its base price again
+func (s *PricingService) DeleteProductPrice(ctx context.Context, productID uint, currency string) *common.AppError {
+	deleted, err := s.repo.DeleteProductPrice(ctx, productID, strings.ToUpper(currency))
+	if err != nil {
+		return common.NewAppError(err, "Failed to delete product price", common.ErrInternalServer.Code)
+	}
+	if deleted == 0 {
+		return common.NewAppError(nil, "Product price not found", http.StatusNotFound)
+	}
+	return nil
+}
+
+// ListRates returns every exchange rate from the base currency
+func (s *PricingService) ListRates(ctx context.Context) ([]domain.ExchangeRate, *common.AppError) {
+	rates, err := s.repo.ListRates(ctx)
+	if err != nil {
+		return nil, common.NewAppError(err, "Failed to list exchange rates", common.ErrInternalServer.Code)
+	}
+	return rates, nil
+}
+
+// SetRate sets the exchange rate from the base currency to currency
+func (s *PricingService) SetRate(ctx context.Context, userID uint, currency, rate string) (*domain.ExchangeRate, *common.AppError) {
+	currency = strings.ToUpper(currency)
+	if aerr := s.validateForeignCurrency(currency); aerr != nil {
+		return nil, aerr
+	}
+	rat, err := money.ParseRate(rate)
+	if err != nil {
+		return nil, common.NewAppError(err, "Rate must be a positive decimal", http.StatusBadRequest)
+	}
+
+	exchangeRate := &domain.ExchangeRate{
+		Currency:  currency,
+		Rate:      rat.FloatString(rateScale),
+		UpdatedBy: &userID,
+	}
+	if err := s.repo.UpsertRate(ctx, exchangeRate); err != nil {
+		return nil, common.NewAppError(err, "Failed to set exchange rate", common.ErrInternalServer.Code)
+	}
+	return exchangeRate, nil
+}
+
+func (s *PricingService) validateForeignCurrency(currency string) *common.AppError {
+	if !money.IsSupported(currency) {
+		return common.NewAppError(nil, "Unsupported currency", http.StatusBadRequest)
+	}
+	if currency == s.base {
+		return common.NewAppError(nil, "Prices in the base currency are set on the product", http.StatusBadRequest)
+	}
+	return nil
+}
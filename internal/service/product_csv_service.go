@@ -0,0 +1,352 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/internal/repository"
+	"github.com/Dubjay18/ecom-api/internal/util"
+	"github.com/Dubjay18/ecom-api/pkg/common"
+	"github.com/Dubjay18/ecom-api/pkg/money"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+const exportBatchSize = 500
+
+// errDryRun rolls back the transaction of a dry-run import row
+var errDryRun = errors.New("dry run")
+
+type ProductCSVService struct {
+	tx          repository.TxManager
+	products    *ProductService
+	productRepo repository.ProductRepository
+	jobRepo     repository.ImportJobRepository
+	// maxRows is the most rows an imported file may have
+	maxRows int
+
+	// imports are the imports running in the background
+	imports *background
+}
+
+func NewProductCSVService(tx repository.TxManager, ps *ProductService, pr repository.ProductRepository, jr repository.ImportJobRepository, maxRows int) *ProductCSVService {
+	return &ProductCSVService{tx: tx, products: ps, productRepo: pr, jobRepo: jr, maxRows: maxRows, imports: newBackground()}
+}
+
+// StartImport reads a product CSV, records an import job and processes the
+// rows in the background. In dry-run mode every row goes through the same
+// checks as a real import, in a transaction that is rolled back. Files
+// with more rows than the service allows are refused.
+func (s *ProductCSVService) StartImport(ctx context.Context, userID uint, r io.Reader, dryRun bool) (*domain.ImportJob, *common.AppError) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	var records [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, common.NewAppError(err, "Invalid CSV file", http.StatusBadRequest)
+		}
+		// The header is not a row
+		if len(records) > s.maxRows {
+			return nil, common.NewAppError(nil, fmt.Sprintf("CSV file has more than %d rows", s.maxRows), http.StatusRequestEntityTooLarge)
+		}
+		records = append(records, record)
+	}
+	if len(records) == 0 {
+		return nil, common.NewAppError(nil, "CSV file is empty", http.StatusBadRequest)
+	}
+
+	columns, err := csvColumns(records[0])
+	if err != nil {
+		return nil, common.NewAppError(err, err.Error(), http.StatusBadRequest)
+	}
+
+	job := &domain.ImportJob{
+		UserID:    userID,
+		Status:    domain.ImportPending,
+		DryRun:    dryRun,
+		TotalRows: len(records) - 1,
+	}
+	if err := s.jobRepo.Create(ctx, job); err != nil {
+		return nil, common.NewAppError(err, "Failed to create import job", common.ErrInternalServer.Code)
+	}
+
+	// The request context is cancelled once the response is written
+	s.imports.Go(func(ctx context.Context) {
+		s.runImport(ctx, *job, columns, records[1:])
+	})
+
+	return job, nil
+}
+
+// Shutdown waits for the imports running in the background to finish. If
+// ctx ends first they are stopped after their current row and recorded as
+// failed.
+func (s *ProductCSVService) Shutdown(ctx context.Context) {
+	s.imports.Shutdown(ctx)
+}
+
+// GetImportJob returns an import job with its per-row errors
+func (s *ProductCSVService) GetImportJob(ctx context.Context, id uint) (*domain.ImportJob, *common.AppError) {
+	job, err := s.jobRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, common.NewAppError(err, "Import job not found", http.StatusNotFound)
+	}
+	return job, nil
+}
+
+// Export streams every product matching the filter to w as CSV
+func (s *ProductCSVService) Export(ctx context.Context, filter domain.ProductFilter, w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(domain.ProductCSVHeader); err != nil {
+		return err
+	}
+
+	err := s.productRepo.ListInBatches(ctx, filter, exportBatchSize, func(products []domain.Product) error {
+		for _, p := range products {
+			err := writer.Write([]string{
+				p.SKU,
+				p.Name,
+				p.Description,
+				p.Price.Decimal(),
+				p.Price.Currency,
+				strconv.Itoa(p.Stock),
+				p.Category,
+				p.ImageURL,
+				string(p.Status),
+				formatPublishAt(p.PublishAt),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	})
+	if err != nil {
+		return err
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func (s *ProductCSVService) runImport(ctx context.Context, job domain.ImportJob, columns map[string]int, records [][]string) {
+	// The job is recorded even once ctx is cancelled
+	save := context.WithoutCancel(ctx)
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Import job %d panicked: %v", job.ID, r)
+			job.Status = domain.ImportFailed
+			if err := s.jobRepo.Update(save, &job); err != nil {
+				log.Printf("Failed to record failed import job %d: %v", job.ID, err)
+			}
+		}
+	}()
+
+	job.Status = domain.ImportRunning
+	if err := s.jobRepo.Update(ctx, &job); err != nil {
+		log.Printf("Failed to start import job %d: %v", job.ID, err)
+		return
+	}
+
+	job.Status = domain.ImportCompleted
+	for i, record := range records {
+		// Row 1 is the header
+		if ctx.Err() != nil {
+			job.Status = domain.ImportFailed
+			job.Errors = append(job.Errors, domain.ImportRowError{
+				Row:   i + 2,
+				Error: "import stopped by server shutdown, this row and the ones after it were not imported",
+			})
+			break
+		}
+
+		row, err := parseImportRow(columns, record)
+		if err == nil {
+			err = util.ValidateStruct(row)
+		}
+		if err == nil {
+			err = s.applyRow(ctx, &job, row)
+		}
+		if err != nil {
+			job.Failed++
+			job.Errors = append(job.Errors, domain.ImportRowError{
+				Row:   i + 2,
+				SKU:   row.SKU,
+				Error: importErrorMessage(err),
+			})
+		}
+	}
+
+	if err := s.jobRepo.Update(save, &job); err != nil {
+		log.Printf("Failed to complete import job %d: %v", job.ID, err)
+	}
+}
+
+// applyRow imports a single row, a dry run imports it in a transaction that
+// is rolled back. Each row has a transaction of its own, so a dry run does
+// not keep products locked for the whole file.
+func (s *ProductCSVService) applyRow(ctx context.Context, job *domain.ImportJob, row domain.ProductImportRow) error {
+	if !job.DryRun {
+		return s.importRow(ctx, job, row)
+	}
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.importRow(ctx, job, row); err != nil {
+			return err
+		}
+		return errDryRun
+	})
+	if errors.Is(err, errDryRun) {
+		return nil
+	}
+	return err
+}
+
+// importRow upserts a single row by SKU through the ProductService rules
+func (s *ProductCSVService) importRow(ctx context.Context, job *domain.ImportJob, row domain.ProductImportRow) error {
+	change := domain.StockChange{
+		Type:      domain.MovementImport,
+		UserID:    &job.UserID,
+		Reason:    "csv import",
+		Reference: fmt.Sprintf("import:%d", job.ID),
+	}
+
+	existing, err := s.productRepo.GetBySKU(ctx, row.SKU)
+	if err == nil {
+		applyImportRow(existing, row)
+		if aerr := s.products.Update(ctx, existing, &row.Stock, change); aerr != nil {
+			return aerr
+		}
+		job.Updated++
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to look up product: %w", err)
+	}
+
+	product := &domain.Product{}
+	applyImportRow(product, row)
+	if aerr := s.products.Create(ctx, product, change); aerr != nil {
+		return aerr
+	}
+	job.Created++
+	return nil
+}
+
+func applyImportRow(product *domain.Product, row domain.ProductImportRow) {
+	product.SKU = row.SKU
+	product.Name = row.Name
+	product.Description = row.Description
+	product.Price = row.Price
+	product.Stock = row.Stock
+	product.Category = row.Category
+	if row.ImageURL != "" {
+		product.ImageURL = row.ImageURL
+	}
+	if row.Status != "" {
+		product.Status = row.Status
+	}
+	if row.PublishAt != nil {
+		product.PublishAt = row.PublishAt
+	}
+}
+
+func formatPublishAt(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// csvColumns maps each known header to its column index
+func csvColumns(header []string) (map[string]int, error) {
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"sku", "name", "price", "stock"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing required column %q", required)
+		}
+	}
+	return columns, nil
+}
+
+func parseImportRow(columns map[string]int, record []string) (domain.ProductImportRow, error) {
+	get := func(name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	row := domain.ProductImportRow{
+		SKU:         get("sku"),
+		Name:        get("name"),
+		Description: get("description"),
+		Category:    get("category"),
+		ImageURL:    get("image_url"),
+		Status:      domain.ProductStatus(get("status")),
+	}
+
+	if publishAt := get("publish_at"); publishAt != "" {
+		t, err := time.Parse(time.RFC3339, publishAt)
+		if err != nil {
+			return row, fmt.Errorf("invalid publish_at %q, expected RFC3339", publishAt)
+		}
+		row.PublishAt = &t
+	}
+
+	// Prices are kept in the base currency, the column makes the file say
+	// which currency its prices are in
+	currency := strings.ToUpper(get("currency"))
+	if currency == "" {
+		currency = money.DefaultCurrency
+	}
+	if _, ok := money.Exponent(currency); !ok {
+		return row, fmt.Errorf("unknown currency %q", currency)
+	}
+	if currency != money.DefaultCurrency {
+		return row, fmt.Errorf("price must be in %s, not %s", money.DefaultCurrency, currency)
+	}
+
+	price, err := money.Parse(get("price"), currency)
+	if err != nil || !price.IsPositive() {
+		return row, fmt.Errorf("invalid price %q", get("price"))
+	}
+	row.Price = price
+
+	stock, err := strconv.Atoi(get("stock"))
+	if err != nil {
+		return row, fmt.Errorf("invalid stock %q", get("stock"))
+	}
+	row.Stock = stock
+
+	return row, nil
+}
+
+func importErrorMessage(err error) string {
+	var verrs validator.ValidationErrors
+	if errors.As(err, &verrs) {
+		msgs := make([]string, len(verrs))
+		for i, fe := range verrs {
+			msgs[i] = strings.ToLower(fe.Field()) + ": " + fe.ActualTag()
+		}
+		return strings.Join(msgs, ", ")
+	}
+	return err.Error()
+}
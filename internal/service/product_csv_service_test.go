@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/internal/repository"
+	"github.com/Dubjay18/ecom-api/internal/testutil"
+	"github.com/Dubjay18/ecom-api/pkg/money"
+)
+
+type memImportJobs struct {
+	repository.ImportJobRepository
+	mu   sync.Mutex
+	jobs []domain.ImportJob
+}
+
+func (r *memImportJobs) Create(_ context.Context, job *domain.ImportJob) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job.ID = uint(len(r.jobs) + 1)
+	r.jobs = append(r.jobs, *job)
+	return nil
+}
+
+func (r *memImportJobs) Update(_ context.Context, job *domain.ImportJob) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.jobs[job.ID-1] = *job
+	return nil
+}
+
+func (r *memImportJobs) get(id uint) domain.ImportJob {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.jobs[id-1]
+}
+
+// brokenProducts fails every product lookup
+type brokenProducts struct {
+	repository.ProductRepository
+}
+
+func (brokenProducts) GetBySKU(context.Context, string) (*domain.Product, error) {
+	return nil, errors.New("connection reset")
+}
+
+const importCSV = "sku,name,price,stock\nSKU-1,First,9.99,5\nSKU-2,Second,4.50,1\n"
+
+func TestImportStoppedByShutdownIsRecordedFailed(t *testing.T) {
+	jobs := &memImportJobs{}
+	csv := NewProductCSVService(nil, nil, nil, jobs, 100)
+	// Shutting down has already given up on running imports
+	csv.imports.cancel()
+
+	job, aerr := csv.StartImport(context.Background(), 1, strings.NewReader(importCSV), false)
+	if aerr != nil {
+		t.Fatal(aerr)
+	}
+	csv.Shutdown(context.Background())
+
+	got := jobs.get(job.ID)
+	if got.Status != domain.ImportFailed {
+		t.Errorf("status = %q, want %q", got.Status, domain.ImportFailed)
+	}
+	if len(got.Errors) != 1 || got.Errors[0].Row != 2 {
+		t.Errorf("errors = %+v, want one stopping at row 2", got.Errors)
+	}
+}
+
+func TestImportPanicIsRecordedFailed(t *testing.T) {
+	jobs := &memImportJobs{}
+	// Without a product repository the first row panics
+	csv := NewProductCSVService(nil, nil, nil, jobs, 100)
+
+	job, aerr := csv.StartImport(context.Background(), 1, strings.NewReader(importCSV), false)
+	if aerr != nil {
+		t.Fatal(aerr)
+	}
+	csv.Shutdown(context.Background())
+
+	if got := jobs.get(job.ID); got.Status != domain.ImportFailed {
+		t.Errorf("status = %q, want %q", got.Status, domain.ImportFailed)
+	}
+}
+
+func TestImportRefusesTooManyRows(t *testing.T) {
+	jobs := &memImportJobs{}
+	csv := NewProductCSVService(nil, nil, nil, jobs, 1)
+
+	_, aerr := csv.StartImport(context.Background(), 1, strings.NewReader(importCSV), false)
+	if aerr == nil || aerr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("import of 2 rows with a limit of 1: got %v, want 413", aerr)
+	}
+	if len(jobs.jobs) != 0 {
+		t.Errorf("%d import jobs created, want none", len(jobs.jobs))
+	}
+}
+
+func TestImportLookupErrorFailsRow(t *testing.T) {
+	jobs := &memImportJobs{}
+	// Without a product service creating a product panics
+	csv := NewProductCSVService(nil, nil, brokenProducts{}, jobs, 100)
+
+	job, aerr := csv.StartImport(context.Background(), 1, strings.NewReader(importCSV), false)
+	if aerr != nil {
+		t.Fatal(aerr)
+	}
+	csv.Shutdown(context.Background())
+
+	got := jobs.get(job.ID)
+	if got.Status != domain.ImportCompleted || got.Created != 0 || got.Failed != 2 {
+		t.Errorf("job = %s with %d created and %d failed, want completed with 0 and 2", got.Status, got.Created, got.Failed)
+	}
+}
+
+func TestDryRunImportAppliesServiceRulesWithoutSaving(t *testing.T) {
+	db := testutil.NewPostgresDB(t)
+	tx := repository.NewTxManager(db)
+	products := repository.NewProductRepository(db)
+	jobs := repository.NewImportJobRepository(db)
+	csv := NewProductCSVService(tx, NewProductService(tx, products, newTestInventory(db), repository.NewPriceHistoryRepository(db)), products, jobs, 100)
+
+	// A scheduled product needs a publish time, which only ProductService
+	// checks
+	const file = "sku,name,price,stock,status\nDRY-1,Fine,9.99,5,\nDRY-2,Scheduled,4.50,1,scheduled\n"
+	job, aerr := csv.StartImport(context.Background(), 1, strings.NewReader(file), true)
+	if aerr != nil {
+		t.Fatal(aerr)
+	}
+	csv.Shutdown(context.Background())
+
+	got, err := jobs.GetByID(context.Background(), job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != domain.ImportCompleted || got.Created != 1 || got.Failed != 1 {
+		t.Errorf("job = %s with %d created and %d failed, want completed with 1 and 1", got.Status, got.Created, got.Failed)
+	}
+	var saved int64
+	if err := db.Raw(`SELECT COUNT(*) FROM products WHERE sku LIKE 'DRY-%'`).Scan(&saved).Error; err != nil {
+		t.Fatal(err)
+	}
+	if saved != 0 {
+		t.Errorf("dry run saved %d products", saved)
+	}
+}
+
+func TestParseImportRowCurrency(t *testing.T) {
+	columns, err := csvColumns([]string{"sku", "name", "price", "currency", "stock"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		price    string
+		currency string
+		want     int64
+		wantErr  bool
+	}{
+		{name: "base currency", price: "9.99", currency: "USD", want: 999},
+		{name: "lower case", price: "9.99", currency: "usd", want: 999},
+		{name: "defaults to base", price: "9.99", currency: "", want: 999},
+		{name: "other currency", price: "9.99", currency: "EUR", wantErr: true},
+		{name: "unknown currency", price: "9.99", currency: "XYZ", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row, err := parseImportRow(columns, []string{"SKU-1", "First", tt.price, tt.currency, "5"})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("price %s %s parsed as %s, want an error", tt.price, tt.currency, row.Price)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if row.Price.Amount != tt.want || row.Price.Currency != money.DefaultCurrency {
+				t.Errorf("price = %s, want %d minor units of %s", row.Price, tt.want, money.DefaultCurrency)
+			}
+		})
+	}
+}
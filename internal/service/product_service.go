@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"github.com/Dubjay18/ecom-api/internal/domain"
 	"github.com/Dubjay18/ecom-api/internal/repository"
@@ -10,24 +11,47 @@ import (
 )
 
 type ProductService struct {
-	repo repository.ProductRepository
+	tx        repository.TxManager
+	repo      repository.ProductRepository
+	inventory *InventoryService
+	history   repository.PriceHistoryRepository
 }
 
-func NewProductService(repo repository.ProductRepository) *ProductService {
-	return &ProductService{repo: repo}
+func NewProductService(tx repository.TxManager, repo repository.ProductRepository, inventory *InventoryService, history repository.PriceHistoryRepository) *ProductService {
+	return &ProductService{tx: tx, repo: repo, inventory: inventory, history: history}
 }
 
-// Create creates a new product
-func (s *ProductService) Create(ctx context.Context, product *domain.Product) *common.AppError {
+// Create creates a new product, recording its opening stock with change
+func (s *ProductService) Create(ctx context.Context, product *domain.Product, change domain.StockChange) *common.AppError {
 	existingProduct, err := s.repo.GetBySKU(ctx, product.SKU)
 	if err == nil && existingProduct != nil {
 		return common.NewAppError(nil, "Product with this SKU already exists", http.StatusConflict)
 	}
-	err = s.repo.Create(ctx, product)
-	if err != nil {
-		return common.NewAppError(err, "Failed to create product", common.ErrInternalServer.Code)
+	if aerr := validateLifecycle(product); aerr != nil {
+		return aerr
 	}
-	return nil
+	if aerr := validateBackorders(product); aerr != nil {
+		return aerr
+	}
+	if !product.Price.IsPositive() {
+		return common.NewAppError(nil, "Price must be greater than zero", http.StatusBadRequest)
+	}
+	return inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+		if err := s.repo.Create(ctx, product); err != nil {
+			return common.NewAppError(err, "Failed to create product", common.ErrInternalServer.Code)
+		}
+		if err := s.history.Record(ctx, &domain.PriceChange{
+			ProductID: product.ID,
+			NewPrice:  product.Price,
+			Source:    priceSource(change),
+			ChangedBy: change.UserID,
+			ChangedAt: product.CreatedAt,
+		}); err != nil {
+			return common.NewAppError(err, "Failed to record price change", common.ErrInternalServer.Code)
+		}
+		s.inventory.StockChanged(ctx, product.ID)
+		return s.inventory.RecordOpeningStock(ctx, product, change)
+	})
 }
 
 // GetByID returns a product by ID
@@ -39,16 +63,68 @@ func (s *ProductService) GetByID(ctx context.Context, id uint) (*domain.Product,
 	return product, nil
 }
 
-// Update updates a product
-func (s *ProductService) Update(ctx context.Context, product *domain.Product) *common.AppError {
-	err := s.repo.Update(ctx, product)
-	if err != nil {
-		return common.NewAppError(err, "Failed to update product", common.ErrInternalServer.Code)
+// GetVisibleByID returns a product by ID if customers are allowed to see it
+func (s *ProductService) GetVisibleByID(ctx context.Context, id uint) (*domain.Product, *common.AppError) {
+	product, aerr := s.GetByID(ctx, id)
+	if aerr != nil {
+		return nil, aerr
 	}
-	return nil
+	if !product.IsVisible() {
+		return nil, common.NewAppError(nil, "Product not found", http.StatusNotFound)
+	}
+	return product, nil
 }
 
-// Delete deletes a product
+// Update updates a product. A new stock level is applied through the
+// inventory ledger with change, a nil stock leaves stock alone. A changed
+// price is recorded in the price history, it cannot change while a sale is
+// running.
+func (s *ProductService) Update(ctx context.Context, product *domain.Product, stock *int, change domain.StockChange) *common.AppError {
+	if aerr := validateLifecycle(product); aerr != nil {
+		return aerr
+	}
+	if aerr := validateBackorders(product); aerr != nil {
+		return aerr
+	}
+	if !product.Price.IsPositive() {
+		return common.NewAppError(nil, "Price must be greater than zero", http.StatusBadRequest)
+	}
+	return inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+		current, err := s.repo.GetForUpdate(ctx, product.ID)
+		if err != nil {
+			return common.NewAppError(err, "Product not found", http.StatusNotFound)
+		}
+		if product.Price != current.Price {
+			if current.CompareAtPrice != nil {
+				return common.NewAppError(nil, "A sale price is active, cancel its schedule before changing the price", http.StatusConflict)
+			}
+			aerr := changePrice(ctx, s.repo, s.history, current, product.Price, nil, domain.PriceChange{
+				Source:    priceSource(change),
+				ChangedBy: change.UserID,
+			})
+			if aerr != nil {
+				return aerr
+			}
+		}
+		product.CompareAtPrice = current.CompareAtPrice
+
+		if err := s.repo.Update(ctx, product); err != nil {
+			return common.NewAppError(err, "Failed to update product", common.ErrInternalServer.Code)
+		}
+		// The reorder threshold may have changed even if stock did not
+		s.inventory.StockChanged(ctx, product.ID)
+		if stock == nil {
+			return nil
+		}
+		if aerr := s.inventory.SetStock(ctx, product.ID, *stock, change); aerr != nil {
+			return aerr
+		}
+		product.Stock = *stock
+		return nil
+	})
+}
+
+// Delete archives a product, past orders can still resolve it
 func (s *ProductService) Delete(ctx context.Context, id uint) *common.AppError {
 	if _, err := s.repo.GetByID(ctx, id); err != nil {
 		return common.NewAppError(err, "Product not found", http.StatusNotFound)
@@ -68,3 +144,81 @@ func (s *ProductService) List(ctx context.Context, filter domain.ProductFilter)
 	}
 	return products, nil
 }
+
+// ListArchived returns archived products
+func (s *ProductService) ListArchived(ctx context.Context) ([]domain.Product, *common.AppError) {
+	products, err := s.repo.ListArchived(ctx)
+	if err != nil {
+		return nil, common.NewAppError(err, "Failed to list archived products", common.ErrInternalServer.Code)
+	}
+	return products, nil
+}
+
+// Restore un-archives a product
+func (s *ProductService) Restore(ctx context.Context, id uint) (*domain.Product, *common.AppError) {
+	product, err := s.repo.GetByIDIncludingArchived(ctx, id)
+	if err != nil {
+		return nil, common.NewAppError(err, "Product not found", http.StatusNotFound)
+	}
+	if !product.DeletedAt.Valid {
+		return nil, common.NewAppError(nil, "Product is not archived", http.StatusBadRequest)
+	}
+	if err := s.repo.Restore(ctx, id); err != nil {
+		return nil, common.NewAppError(err, "Failed to restore product", common.ErrInternalServer.Code)
+	}
+	product.DeletedAt.Valid = false
+	return product, nil
+}
+
+// PurgeArchived permanently deletes products archived longer than retention
+func (s *ProductService) PurgeArchived(ctx context.Context, retention time.Duration) (int64, error) {
+	return s.repo.PurgeArchived(ctx, time.Now().Add(-retention))
+}
+
+// PublishScheduled publishes every scheduled product whose publish time has passed
+func (s *ProductService) PublishScheduled(ctx context.Context) (int64, error) {
+	return s.repo.PublishScheduled(ctx, time.Now())
+}
+
+// validateLifecycle defaults the product status and checks the publish time
+func validateLifecycle(product *domain.Product) *common.AppError {
+	switch product.Status {
+	case "":
+		product.Status = domain.ProductDraft
+	case domain.ProductScheduled:
+		if product.PublishAt == nil {
+			return common.NewAppError(nil, "Scheduled products require a publish time", http.StatusBadRequest)
+		}
+	case domain.ProductPublished:
+		if product.PublishAt == nil {
+			now := time.Now()
+			product.PublishAt = &now
+		}
+	case domain.ProductDraft, domain.ProductDiscontinued:
+	default:
+		return common.NewAppError(nil, "Invalid product status", http.StatusBadRequest)
+	}
+	return nil
+}
+
+func validateBackorders(product *domain.Product) *common.AppError {
+	switch product.BackorderPolicy {
+	case "":
+		product.BackorderPolicy = domain.BackorderDeny
+	case domain.BackorderDeny, domain.BackorderLimited, domain.BackorderUnlimited:
+	default:
+		return common.NewAppError(nil, "Invalid backorder policy", http.StatusBadRequest)
+	}
+	if product.BackorderPolicy == domain.BackorderLimited && product.BackorderLimit <= 0 {
+		return common.NewAppError(nil, "Limited backorders require a backorder limit", http.StatusBadRequest)
+	}
+	if product.PreOrder {
+		if product.AvailableAt == nil {
+			return common.NewAppError(nil, "Pre-order products require an availability date", http.StatusBadRequest)
+		}
+		if product.BackorderPolicy == domain.BackorderDeny {
+			return common.NewAppError(nil, "Pre-order products must allow backorders", http.StatusBadRequest)
+		}
+	}
+	return nil
+}
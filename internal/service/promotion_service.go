@@ -0,0 +1,420 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/internal/repository"
+	"github.com/Dubjay18/ecom-api/pkg/common"
+	"github.com/Dubjay18/ecom-api/pkg/money"
+	"gorm.io/gorm"
+)
+
+// PromotionService manages coupons and automatic promotions and works out
+// the discounts of orders. Every running automatic promotion an order
+// qualifies for applies, oldest first, followed by its coupon. Each
+// discounts what the ones before it left of the line prices.
+type PromotionService struct {
+	repo repository.PromotionRepository
+}
+
+func NewPromotionService(repo repository.PromotionRepository) *PromotionService {
+	return &PromotionService{repo: repo}
+}
+
+// Create creates a promotion (admin privilege)
+func (s *PromotionService) Create(ctx context.Context, req *domain.PromotionRequest) (*domain.Promotion, *common.AppError) {
+	promotion := &domain.Promotion{Active: true}
+	if aerr := applyPromotionRequest(promotion, req); aerr != nil {
+		return nil, aerr
+	}
+	if promotion.IsCoupon() {
+		if _, err := s.repo.GetByCode(ctx, *promotion.Code); err == nil {
+			return nil, common.NewAppError(nil, "A coupon with this code already exists", http.StatusConflict)
+		}
+	}
+	if err := s.repo.Create(ctx, promotion); err != nil {
+		return nil, common.NewAppError(err, "Failed to create promotion", common.ErrInternalServer.Code)
+	}
+	return promotion, nil
+}
+
+// Get returns a promotion by ID (admin privilege)
+func (s *PromotionService) Get(ctx context.Context, id uint) (*domain.Promotion, *common.AppError) {
+	promotion, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, common.NewAppError(err, "Promotion not found", http.StatusNotFound)
+	}
+	return promotion, nil
+}
+
+// List returns every promotion (admin privilege)
+func (s *PromotionService) List(ctx context.Context) ([]domain.Promotion, *common.AppError) {
+	promotions, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, common.NewAppError(err, "Failed to list promotions", common.ErrInternalServer.Code)
+	}
+	return promotions, nil
+}
+
+// Update replaces the settings of a promotion, its usage count is kept
+// (admin privilege)
+func (s *PromotionService) Update(ctx context.Context, id uint, req *domain.PromotionRequest) (*domain.Promotion, *common.AppError) {
+	promotion, aerr := s.Get(ctx, id)
+	if aerr != nil {
+		return nil, aerr
+	}
+	if aerr := applyPromotionRequest(promotion, req); aerr != nil {
+		return nil, aerr
+	}
+	if promotion.IsCoupon() {
+		if other, err := s.repo.GetByCode(ctx, *promotion.Code); err == nil && other.ID != promotion.ID {
+			return nil, common.NewAppError(nil, "A coupon with this code already exists", http.StatusConflict)
+		}
+	}
+	if err := s.repo.Update(ctx, promotion); err != nil {
+		return nil, common.NewAppError(err, "Failed to update promotion", common.ErrInternalServer.Code)
+	}
+	return promotion, nil
+}
+
+// applyPromotionRequest validates req and copies it onto promotion
+func applyPromotionRequest(promotion *domain.Promotion, req *domain.PromotionRequest) *common.AppError {
+	switch req.Type {
+	case domain.PromotionPercentOff:
+		if req.PercentOff <= 0 {
+			return common.NewAppError(nil, "Percent off promotions require percent_off", http.StatusBadRequest)
+		}
+	case domain.PromotionFixedAmount:
+		if req.AmountOff == nil || !req.AmountOff.IsPositive() {
+			return common.NewAppError(nil, "Fixed amount promotions require a positive amount_off", http.StatusBadRequest)
+		}
+	case domain.PromotionBuyXGetY:
+		if req.BuyQuantity <= 0 || req.GetQuantity <= 0 {
+			return common.NewAppError(nil, "Buy X get Y promotions require buy_quantity and get_quantity", http.StatusBadRequest)
+		}
+	case domain.PromotionFreeShipping:
+	default:
+		return common.NewAppError(nil, "Invalid promotion type", http.StatusBadRequest)
+	}
+	for _, amount := range []*money.Money{req.AmountOff, req.MinSubtotal} {
+		if amount != nil && amount.Currency != money.DefaultCurrency {
+			return common.NewAppError(nil, "Promotion amounts must be in the base currency", http.StatusBadRequest)
+		}
+	}
+	if req.StartsAt != nil && req.EndsAt != nil && !req.EndsAt.After(*req.StartsAt) {
+		return common.NewAppError(nil, "ends_at must be after starts_at", http.StatusBadRequest)
+	}
+
+	promotion.Name = req.Name
+	promotion.Code = nil
+	if code := normalizeCouponCode(req.Code); code != "" {
+		promotion.Code = &code
+	}
+	promotion.Type = req.Type
+	promotion.PercentOff = req.PercentOff
+	promotion.AmountOff = req.AmountOff
+	promotion.BuyQuantity = req.BuyQuantity
+	promotion.GetQuantity = req.GetQuantity
+	promotion.MinSubtotal = req.MinSubtotal
+	promotion.ProductIDs = req.ProductIDs
+	promotion.Categories = req.Categories
+	promotion.UsageLimit = req.UsageLimit
+	promotion.PerUserLimit = req.PerUserLimit
+	promotion.StartsAt = req.StartsAt
+	promotion.EndsAt = req.EndsAt
+	if req.Active != nil {
+		promotion.Active = *req.Active
+	}
+	return nil
+}
+
+// normalizeCouponCode returns the stored form of a coupon code
+func normalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Apply works out the discounts of an order from the automatic promotions
+// running now and the coupon code, if any. It sets the discount of each
+// line, the shipping discount, the order's discounts and its total. An
+// unusable coupon is a 400, automatic promotions the order does not
+// qualify for are skipped.
+func (s *PromotionService) Apply(ctx context.Context, order *domain.Order, products map[uint]*domain.Product, code string) *common.AppError {
+	now := time.Now()
+	promotions, err := s.repo.ListAutomatic(ctx, now)
+	if err != nil {
+		return common.NewAppError(err, "Failed to list promotions", common.ErrInternalServer.Code)
+	}
+	candidates := make([]domain.Promotion, 0, len(promotions)+1)
+	for _, promotion := range promotions {
+		reason, aerr := s.unusable(ctx, &promotion, order, now)
+		if aerr != nil {
+			return aerr
+		}
+		if reason == "" {
+			candidates = append(candidates, promotion)
+		}
+	}
+	if code = normalizeCouponCode(code); code != "" {
+		coupon, err := s.repo.GetByCode(ctx, code)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return common.NewAppError(err, "Invalid coupon code", http.StatusBadRequest)
+		}
+		if err != nil {
+			return common.NewAppError(err, "Failed to get coupon", common.ErrInternalServer.Code)
+		}
+		reason, aerr := s.unusable(ctx, coupon, order, now)
+		if aerr != nil {
+			return aerr
+		}
+		if reason != "" {
+			return common.NewAppError(nil, reason, http.StatusBadRequest)
+		}
+		candidates = append(candidates, *coupon)
+	}
+
+	// remaining is what is left of each line price to discount
+	remaining := make([]int64, len(order.Items))
+	for i, item := range order.Items {
+		remaining[i] = item.Price.Amount
+	}
+	discounts := make([]money.Money, 0, len(candidates))
+	for _, promotion := range candidates {
+		var total int64
+		if promotion.Type == domain.PromotionFreeShipping {
+			// Free shipping takes off what is left of the shipping charge
+			total = order.ShippingAmount.Amount - order.ShippingDiscount.Amount
+			if total == 0 {
+				if promotion.IsCoupon() {
+					return common.NewAppError(nil, "Coupon does not apply, the order has no shipping charge", http.StatusBadRequest)
+				}
+				continue
+			}
+			order.ShippingDiscount = order.ShippingAmount
+			order.FreeShipping = true
+		} else {
+			lines, aerr := lineDiscounts(&promotion, order, products, remaining)
+			if aerr != nil {
+				return aerr
+			}
+			for i, line := range lines {
+				remaining[i] -= line
+				total += line
+			}
+			if total == 0 {
+				if promotion.IsCoupon() {
+					return common.NewAppError(nil, "Coupon does not apply to any item of the order", http.StatusBadRequest)
+				}
+				continue
+			}
+		}
+
+		discount := domain.OrderDiscount{
+			PromotionID: promotion.ID,
+			Name:        promotion.Name,
+			Type:        promotion.Type,
+			Amount:      money.New(total, order.Currency),
+			Currency:    order.Currency,
+		}
+		if promotion.IsCoupon() {
+			discount.Code = *promotion.Code
+		}
+		order.Discounts = append(order.Discounts, discount)
+		discounts = append(discounts, discount.Amount)
+	}
+
+	for i := range order.Items {
+		order.Items[i].Discount = money.New(order.Items[i].Price.Amount-remaining[i], order.Currency)
+	}
+	discountTotal, err := money.Sum(order.Currency, discounts...)
+	if err != nil {
+		return common.NewAppError(err, "Failed to compute discounts", common.ErrInternalServer.Code)
+	}
+	charged, err := order.Subtotal.Add(order.ShippingAmount)
+	if err != nil {
+		return common.NewAppError(err, "Order total is too large", http.StatusBadRequest)
+	}
+	total, err := charged.Sub(discountTotal)
+	if err != nil {
+		return common.NewAppError(err, "Failed to compute discounts", common.ErrInternalServer.Code)
+	}
+	order.DiscountTotal = discountTotal
+	order.TotalAmount = total
+	return nil
+}
+
+// unusable returns why a promotion cannot apply to an order at now, or an
+// empty string if it can
+func (s *PromotionService) unusable(ctx context.Context, promotion *domain.Promotion, order *domain.Order, now time.Time) (string, *common.AppError) {
+	if !promotion.IsRunning(now) {
+		return "Coupon is not active", nil
+	}
+	if promotion.UsageLimit > 0 && promotion.UsageCount >= promotion.UsageLimit {
+		return "Coupon has reached its usage limit", nil
+	}
+	if promotion.PerUserLimit > 0 {
+		uses, err := s.repo.CountUserUses(ctx, promotion.ID, order.UserID)
+		if err != nil {
+			return "", common.NewAppError(err, "Failed to count promotion uses", common.ErrInternalServer.Code)
+		}
+		if uses >= int64(promotion.PerUserLimit) {
+			return "Coupon has already been used", nil
+		}
+	}
+	if promotion.MinSubtotal != nil {
+		minimum, aerr := orderAmount(*promotion.MinSubtotal, order)
+		if aerr != nil {
+			return "", aerr
+		}
+		if order.Subtotal.Amount < minimum.Amount {
+			return fmt.Sprintf("Coupon requires a subtotal of at least %s", minimum), nil
+		}
+	}
+	return "", nil
+}
+
+// lineDiscounts returns what a promotion takes off each line of an order,
+// given what is left of the line prices. A line is never discounted below
+// zero.
+func lineDiscounts(promotion *domain.Promotion, order *domain.Order, products map[uint]*domain.Product, remaining []int64) ([]int64, *common.AppError) {
+	lines := make([]int64, len(order.Items))
+	eligible := make([]bool, len(order.Items))
+	var eligibleTotal int64
+	for i, item := range order.Items {
+		product, ok := products[item.ProductID]
+		eligible[i] = ok && remaining[i] > 0 && promotion.IsEligible(product)
+		if eligible[i] {
+			eligibleTotal += remaining[i]
+		}
+	}
+
+	switch promotion.Type {
+	case domain.PromotionPercentOff:
+		for i := range lines {
+			if eligible[i] {
+				lines[i] = mulDiv(remaining[i], int64(promotion.PercentOff), 100)
+			}
+		}
+	case domain.PromotionFixedAmount:
+		amountOff, aerr := orderAmount(*promotion.AmountOff, order)
+		if aerr != nil {
+			return nil, aerr
+		}
+		if eligibleTotal == 0 {
+			break
+		}
+		// Each eligible line takes its share of what is left of the
+		// eligible lines, the amount off never exceeds that so no line
+		// goes below zero
+		off := money.New(min(amountOff.Amount, eligibleTotal), order.Currency)
+		weights := make([]int64, len(lines))
+		for i := range lines {
+			if eligible[i] {
+				weights[i] = remaining[i]
+			}
+		}
+		shares, err := off.Allocate(weights...)
+		if err != nil {
+			return nil, common.NewAppError(err, "Failed to split promotion amount", common.ErrInternalServer.Code)
+		}
+		for i, share := range shares {
+			lines[i] = share.Amount
+		}
+	case domain.PromotionBuyXGetY:
+		for i, item := range order.Items {
+			if !eligible[i] {
+				continue
+			}
+			free := item.Quantity / (promotion.BuyQuantity + promotion.GetQuantity) * promotion.GetQuantity
+			lines[i] = min(mulDiv(item.Price.Amount, int64(free), int64(item.Quantity)), remaining[i])
+		}
+	}
+	return lines, nil
+}
+
+// orderAmount converts a base currency amount into an order's currency at
+// the order's exchange rate
+func orderAmount(amount money.Money, order *domain.Order) (money.Money, *common.AppError) {
+	if amount.Currency == order.Currency {
+		return amount, nil
+	}
+	rate, err := money.ParseRate(order.ExchangeRate)
+	if err != nil {
+		return money.Money{}, common.NewAppError(err, "Invalid exchange rate", common.ErrInternalServer.Code)
+	}
+	converted, err := amount.Convert(rate, order.Currency)
+	if err != nil {
+		return money.Money{}, common.NewAppError(err, "Failed to convert promotion amount", common.ErrInternalServer.Code)
+	}
+	return converted, nil
+}
+
+// mulDiv returns a * b / c rounded down, without overflowing on the way
+func mulDiv(a, b, c int64) int64 {
+	if c == 0 {
+		return 0
+	}
+	v := new(big.Int).Mul(big.NewInt(a), big.NewInt(b))
+	return v.Quo(v, big.NewInt(c)).Int64()
+}
+
+// Redeem counts the uses of the promotions applied to a newly created
+// order, inside the transaction that creates it. Promotions used up or
+// used by the customer as often as allowed since the order was priced are
+// a 409.
+func (s *PromotionService) Redeem(ctx context.Context, order *domain.Order) *common.AppError {
+	for _, discount := range order.Discounts {
+		// The claim locks the promotion's row, so concurrent orders of the
+		// same user are counted one after the other
+		err := s.repo.Claim(ctx, discount.PromotionID)
+		if errors.Is(err, repository.ErrUsageLimitReached) {
+			return common.NewAppError(err, fmt.Sprintf("Promotion %s has reached its usage limit", discount.Name), http.StatusConflict)
+		}
+		if err != nil {
+			return common.NewAppError(err, "Failed to redeem promotion", common.ErrInternalServer.Code)
+		}
+
+		promotion, err := s.repo.GetByID(ctx, discount.PromotionID)
+		if err != nil {
+			return common.NewAppError(err, "Failed to get promotion", common.ErrInternalServer.Code)
+		}
+		if promotion.PerUserLimit == 0 {
+			continue
+		}
+		// The count includes the order being placed
+		uses, err := s.repo.CountUserUses(ctx, promotion.ID, order.UserID)
+		if err != nil {
+			return common.NewAppError(err, "Failed to count promotion uses", common.ErrInternalServer.Code)
+		}
+		if uses > int64(promotion.PerUserLimit) {
+			return common.NewAppError(nil, fmt.Sprintf("Promotion %s has already been used", promotion.Name), http.StatusConflict)
+		}
+	}
+	return nil
+}
+
+// Release gives back the promotion uses of a cancelled order, its
+// discounts stay on record
+func (s *PromotionService) Release(ctx context.Context, orderID uint) *common.AppError {
+	discounts, err := s.repo.ListOrderDiscounts(ctx, orderID)
+	if err != nil {
+		return common.NewAppError(err, "Failed to list order discounts", common.ErrInternalServer.Code)
+	}
+	now := time.Now()
+	for i := range discounts {
+		discounts[i].ReleasedAt = &now
+		if err := s.repo.UpdateOrderDiscount(ctx, &discounts[i]); err != nil {
+			return common.NewAppError(err, "Failed to release order discount", common.ErrInternalServer.Code)
+		}
+		if err := s.repo.Unclaim(ctx, discounts[i].PromotionID); err != nil {
+			return common.NewAppError(err, "Failed to release promotion", common.ErrInternalServer.Code)
+		}
+	}
+	return nil
+}
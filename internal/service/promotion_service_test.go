@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/internal/repository"
+	"github.com/Dubjay18/ecom-api/pkg/money"
+	"gorm.io/gorm"
+)
+
+type memPromotions struct {
+	repository.PromotionRepository
+	coupons map[string]domain.Promotion
+}
+
+func (r *memPromotions) ListAutomatic(context.Context, time.Time) ([]domain.Promotion, error) {
+	return nil, nil
+}
+
+func (r *memPromotions) GetByCode(_ context.Context, code string) (*domain.Promotion, error) {
+	promotion, ok := r.coupons[code]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &promotion, nil
+}
+
+func newFreeShippingTest() *PromotionService {
+	code := "SHIPFREE"
+	return NewPromotionService(&memPromotions{coupons: map[string]domain.Promotion{
+		code: {Base: domain.Base{ID: 1}, Name: "Free shipping", Code: &code, Type: domain.PromotionFreeShipping, Active: true},
+	}})
+}
+
+func TestFreeShippingCouponTakesShippingOff(t *testing.T) {
+	promotions := newFreeShippingTest()
+	order := &domain.Order{
+		Currency:         "USD",
+		Subtotal:         money.New(2000, "USD"),
+		ShippingAmount:   money.New(499, "USD"),
+		ShippingDiscount: money.New(0, "USD"),
+		Items:            []domain.OrderItem{{ProductID: 1, Quantity: 1, Price: money.New(2000, "USD"), Currency: "USD"}},
+	}
+	products := map[uint]*domain.Product{1: {Base: domain.Base{ID: 1}}}
+
+	if aerr := promotions.Apply(context.Background(), order, products, "shipfree"); aerr != nil {
+		t.Fatal(aerr)
+	}
+	if !order.FreeShipping || order.ShippingDiscount != order.ShippingAmount {
+		t.Errorf("shipping discount = %s, want all of %s", order.ShippingDiscount, order.ShippingAmount)
+	}
+	if order.TotalAmount.Amount != 2000 || order.DiscountTotal.Amount != 499 {
+		t.Errorf("total = %s with %s off, want 20.00 with 4.99 off", order.TotalAmount, order.DiscountTotal)
+	}
+	if len(order.Discounts) != 1 || order.Discounts[0].Amount.Amount != 499 {
+		t.Errorf("discounts = %+v, want one of 4.99", order.Discounts)
+	}
+	if order.Items[0].Discount.Amount != 0 {
+		t.Errorf("line discount = %s, want none", order.Items[0].Discount)
+	}
+}
+
+func TestFreeShippingCouponNeedsShippingCharge(t *testing.T) {
+	promotions := newFreeShippingTest()
+	order := &domain.Order{
+		Currency:         "USD",
+		Subtotal:         money.New(2000, "USD"),
+		ShippingAmount:   money.New(0, "USD"),
+		ShippingDiscount: money.New(0, "USD"),
+		Items:            []domain.OrderItem{{ProductID: 1, Quantity: 1, Price: money.New(2000, "USD"), Currency: "USD"}},
+	}
+	products := map[uint]*domain.Product{1: {Base: domain.Base{ID: 1}}}
+
+	aerr := promotions.Apply(context.Background(), order, products, "SHIPFREE")
+	if aerr == nil || aerr.Code != http.StatusBadRequest {
+		t.Fatalf("free shipping on an order without shipping: got %v, want 400", aerr)
+	}
+}
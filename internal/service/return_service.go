@@ -0,0 +1,382 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/internal/repository"
+	"github.com/Dubjay18/ecom-api/pkg/common"
+	"github.com/Dubjay18/ecom-api/pkg/money"
+)
+
+// ReturnService runs returns of delivered order items. A customer requests
+// a return, an admin approves or rejects it, checks in the items that
+// arrive, optionally restocking them, and refunds them through the payment
+// layer.
+type ReturnService struct {
+	tx        repository.TxManager
+	repo      repository.ReturnRepository
+	orderRepo repository.OrderRepository
+	orders    *OrderService
+	payments  *PaymentService
+	inventory *InventoryService
+}
+
+func NewReturnService(tx repository.TxManager, repo repository.ReturnRepository, orderRepo repository.OrderRepository, orders *OrderService, payments *PaymentService, inventory *InventoryService) *ReturnService {
+	return &ReturnService{
+		tx:        tx,
+		repo:      repo,
+		orderRepo: orderRepo,
+		orders:    orders,
+		payments:  payments,
+		inventory: inventory,
+	}
+}
+
+// Request creates a return for items of a delivered order of the actor.
+// Each item can only be returned up to the quantity ordered, counting
+// earlier returns that were not rejected.
+func (s *ReturnService) Request(ctx context.Context, orderID uint, actor Actor, req *domain.CreateReturnRequest) (*domain.Return, *common.AppError) {
+	if _, aerr := s.orders.getOwned(ctx, orderID, actor); aerr != nil {
+		return nil, aerr
+	}
+
+	var ret *domain.Return
+	aerr := inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+		// Locking the order makes concurrent requests count each other
+		order, err := s.orderRepo.GetForUpdate(ctx, orderID)
+		if err != nil {
+			return common.NewAppError(err, "Order not found", http.StatusNotFound)
+		}
+		if order.Status != domain.StatusDelivered {
+			return common.NewAppError(nil, "Only delivered orders can be returned", http.StatusConflict)
+		}
+		returned, err := s.repo.ReturnedQuantities(ctx, orderID)
+		if err != nil {
+			return common.NewAppError(err, "Failed to get returned quantities", common.ErrInternalServer.Code)
+		}
+
+		items := make(map[uint]domain.OrderItem, len(order.Items))
+		for _, item := range order.Items {
+			items[item.ID] = item
+		}
+		ret = &domain.Return{
+			OrderID:  order.ID,
+			UserID:   order.UserID,
+			Status:   domain.ReturnRequested,
+			Reason:   req.Reason,
+			Currency: order.Currency,
+			Items:    make([]domain.ReturnItem, len(req.Items)),
+		}
+		for i, line := range req.Items {
+			item, ok := items[line.OrderItemID]
+			if !ok {
+				return common.NewAppError(nil, fmt.Sprintf("Order item %d is not part of the order", line.OrderItemID), http.StatusBadRequest)
+			}
+			returned[item.ID] += line.Quantity
+			if returned[item.ID] > item.Quantity {
+				return common.NewAppError(nil, fmt.Sprintf("Order item %d has only %d units left to return", item.ID, item.Quantity-returned[item.ID]+line.Quantity), http.StatusBadRequest)
+			}
+			ret.Items[i] = domain.ReturnItem{
+				OrderItemID: item.ID,
+				Quantity:    line.Quantity,
+				Reason:      line.Reason,
+			}
+		}
+
+		if err := s.repo.Create(ctx, ret); err != nil {
+			return common.NewAppError(err, "Failed to create return", common.ErrInternalServer.Code)
+		}
+		return s.record(ctx, ret, fmt.Sprintf("Return %d requested: %s", ret.ID, ret.Reason))
+	})
+	if aerr != nil {
+		return nil, aerr
+	}
+	return ret, nil
+}
+
+// Get returns a return to the owner of its order or an admin
+func (s *ReturnService) Get(ctx context.Context, id uint, actor Actor) (*domain.Return, *common.AppError) {
+	ret, err := s.repo.GetByID(ctx, id)
+	if err != nil || (!actor.IsAdmin && ret.UserID != actor.UserID) {
+		return nil, common.NewAppError(err, "Return not found", http.StatusNotFound)
+	}
+	return ret, nil
+}
+
+// ListByOrder returns the returns of an order, to its owner or an admin
+func (s *ReturnService) ListByOrder(ctx context.Context, orderID uint, actor Actor) ([]domain.Return, *common.AppError) {
+	if _, aerr := s.orders.getOwned(ctx, orderID, actor); aerr != nil {
+		return nil, aerr
+	}
+	returns, err := s.repo.ListByOrder(ctx, orderID)
+	if err != nil {
+		return nil, common.NewAppError(err, "Failed to list returns", common.ErrInternalServer.Code)
+	}
+	return returns, nil
+}
+
+// List returns every return, of one status if status is set (admin
+// privilege)
+func (s *ReturnService) List(ctx context.Context, status domain.ReturnStatus) ([]domain.Return, *common.AppError) {
+	returns, err := s.repo.List(ctx, status)
+	if err != nil {
+		return nil, common.NewAppError(err, "Failed to list returns", common.ErrInternalServer.Code)
+	}
+	return returns, nil
+}
+
+// Approve accepts a requested return, the customer can send the items
+// back (admin privilege)
+func (s *ReturnService) Approve(ctx context.Context, id uint) (*domain.Return, *common.AppError) {
+	return s.update(ctx, id, func(ctx context.Context, ret *domain.Return) *common.AppError {
+		if ret.Status != domain.ReturnRequested {
+			return common.NewAppError(nil, "Only requested returns can be approved", http.StatusConflict)
+		}
+		ret.Status = domain.ReturnApproved
+		return s.record(ctx, ret, fmt.Sprintf("Return %d approved", ret.ID))
+	})
+}
+
+// Reject refuses a requested return (admin privilege)
+func (s *ReturnService) Reject(ctx context.Context, id uint, reason string) (*domain.Return, *common.AppError) {
+	return s.update(ctx, id, func(ctx context.Context, ret *domain.Return) *common.AppError {
+		if ret.Status != domain.ReturnRequested {
+			return common.NewAppError(nil, "Only requested returns can be rejected", http.StatusConflict)
+		}
+		ret.Status = domain.ReturnRejected
+		ret.RejectionReason = reason
+		return s.record(ctx, ret, fmt.Sprintf("Return %d rejected: %s", ret.ID, reason))
+	})
+}
+
+// Receive checks in the items of an approved return that arrived back,
+// putting the ones marked for restocking back into the warehouse they
+// shipped from (admin privilege). Items not listed are taken as not
+// received.
+func (s *ReturnService) Receive(ctx context.Context, id uint, req *domain.ReceiveReturnRequest) (*domain.Return, *common.AppError) {
+	return s.update(ctx, id, func(ctx context.Context, ret *domain.Return) *common.AppError {
+		if ret.Status != domain.ReturnApproved {
+			return common.NewAppError(nil, "Only approved returns can be received", http.StatusConflict)
+		}
+
+		items := make(map[uint]bool, len(ret.Items))
+		for _, item := range ret.Items {
+			items[item.ID] = true
+		}
+		lines := make(map[uint]domain.ReceiveReturnItemRequest, len(req.Items))
+		for _, line := range req.Items {
+			if !items[line.ReturnItemID] {
+				return common.NewAppError(nil, fmt.Sprintf("Return item %d is not part of the return", line.ReturnItemID), http.StatusBadRequest)
+			}
+			lines[line.ReturnItemID] = line
+		}
+
+		for i := range ret.Items {
+			item := &ret.Items[i]
+			line, ok := lines[item.ID]
+			if !ok {
+				continue
+			}
+			if line.Quantity > item.Quantity {
+				return common.NewAppError(nil, fmt.Sprintf("Return item %d has only %d units", item.ID, item.Quantity), http.StatusBadRequest)
+			}
+			item.ReceivedQuantity = line.Quantity
+			if line.Restock && line.Quantity > 0 {
+				_, aerr := s.inventory.Adjust(ctx, item.OrderItem.ProductID, line.Quantity, domain.StockChange{
+					WarehouseID: item.OrderItem.WarehouseID,
+					Type:        domain.MovementReturn,
+					UserID:      actorID(ctx),
+					Reason:      "return received",
+					Reference:   ReturnReference(ret.ID),
+				})
+				if aerr != nil {
+					return aerr
+				}
+				item.Restocked = line.Quantity
+			}
+			if err := s.repo.UpdateItem(ctx, item); err != nil {
+				return common.NewAppError(err, "Failed to update return item", common.ErrInternalServer.Code)
+			}
+		}
+		ret.Status = domain.ReturnReceived
+		return s.record(ctx, ret, fmt.Sprintf("Return %d received", ret.ID))
+	})
+}
+
+// Refund refunds a return through the order's payment (admin privilege).
+// Each line is refunded at the price paid for it: a received return for
+// the units that arrived, an approved one that was never sent back for all
+// of its units. The return that refunds the last units of the order also
+// refunds what was paid for shipping.
+//
+// The payment provider is not called with the return locked. The refund
+// carries the reference of the return, so a retry after a failure does not
+// refund it twice.
+func (s *ReturnService) Refund(ctx context.Context, id uint) (*domain.Return, *common.AppError) {
+	found, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, common.NewAppError(err, "Return not found", http.StatusNotFound)
+	}
+
+	var amount money.Money
+	var shipping bool
+	aerr := inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+		// Locking the order makes the returns of an order count each
+		// other's refunded units one at a time
+		order, err := s.orderRepo.GetForUpdate(ctx, found.OrderID)
+		if err != nil {
+			return common.NewAppError(err, "Order not found", http.StatusNotFound)
+		}
+		ret, err := s.repo.GetForUpdate(ctx, id)
+		if err != nil {
+			return common.NewAppError(err, "Return not found", http.StatusNotFound)
+		}
+		if ret.Status != domain.ReturnApproved && ret.Status != domain.ReturnReceived {
+			return common.NewAppError(nil, "Only approved or received returns can be refunded", http.StatusConflict)
+		}
+		refunded, err := s.repo.RefundedQuantities(ctx, order.ID, ret.ID)
+		if err != nil {
+			return common.NewAppError(err, "Failed to get refunded quantities", common.ErrInternalServer.Code)
+		}
+
+		var aerr *common.AppError
+		if amount, aerr = s.refundLines(ctx, ret, refunded); aerr != nil {
+			return aerr
+		}
+		ret.RefundsShipping = allRefunded(order, refunded)
+		if ret.RefundsShipping {
+			if amount, err = amount.Add(order.NetShipping()); err != nil {
+				return common.NewAppError(err, "Failed to compute refund", common.ErrInternalServer.Code)
+			}
+		}
+		shipping = ret.RefundsShipping
+		if err := s.repo.Update(ctx, ret); err != nil {
+			return common.NewAppError(err, "Failed to update return", common.ErrInternalServer.Code)
+		}
+		return nil
+	})
+	if aerr != nil {
+		return nil, aerr
+	}
+
+	var paymentID *uint
+	if amount.IsPositive() {
+		payment, aerr := s.payments.RefundOrder(ctx, found.OrderID, amount, ReturnReference(id))
+		if aerr != nil {
+			return nil, aerr
+		}
+		paymentID = &payment.ID
+	}
+
+	return s.update(ctx, id, func(ctx context.Context, ret *domain.Return) *common.AppError {
+		if ret.Status == domain.ReturnRefunded {
+			return nil
+		}
+		now := time.Now()
+		ret.Status = domain.ReturnRefunded
+		ret.PaymentID = paymentID
+		ret.RefundAmount = &amount
+		ret.RefundedAt = &now
+		message := fmt.Sprintf("Return %d refunded %s", ret.ID, amount)
+		if shipping {
+			message += " including shipping"
+		}
+		return s.record(ctx, ret, message)
+	})
+}
+
+// refundLines sets the units of each line of a return that are refunded
+// and returns their refund. refunded holds the units of each order item
+// refunded by other returns, it is updated with this one's.
+//
+// A line's refunds add up to the share of its net price, what was paid for
+// it, of the units refunded so far, rounded down. The return that refunds
+// the last units of a line so refunds all that is left of it.
+func (s *ReturnService) refundLines(ctx context.Context, ret *domain.Return, refunded map[uint]int) (money.Money, *common.AppError) {
+	lines := make([]money.Money, len(ret.Items))
+	for i := range ret.Items {
+		item := &ret.Items[i]
+		item.RefundedQuantity = item.Quantity
+		if ret.Status == domain.ReturnReceived {
+			item.RefundedQuantity = item.ReceivedQuantity
+		}
+		if err := s.repo.UpdateItem(ctx, item); err != nil {
+			return money.Money{}, common.NewAppError(err, "Failed to update return item", common.ErrInternalServer.Code)
+		}
+
+		before := refunded[item.OrderItemID]
+		refunded[item.OrderItemID] += item.RefundedQuantity
+		from, aerr := lineShare(item.OrderItem, before)
+		if aerr != nil {
+			return money.Money{}, aerr
+		}
+		to, aerr := lineShare(item.OrderItem, refunded[item.OrderItemID])
+		if aerr != nil {
+			return money.Money{}, aerr
+		}
+		lines[i] = money.New(to-from, ret.Currency)
+	}
+	total, err := money.Sum(ret.Currency, lines...)
+	if err != nil {
+		return money.Money{}, common.NewAppError(err, "Failed to compute refund", common.ErrInternalServer.Code)
+	}
+	return total, nil
+}
+
+// lineShare returns the share of the net price of an order item of
+// quantity of its units in minor units, rounded down
+func lineShare(item *domain.OrderItem, quantity int) (int64, *common.AppError) {
+	total, err := item.NetPrice().Mul(int64(quantity))
+	if err != nil {
+		return 0, common.NewAppError(err, "Refund is too large", http.StatusBadRequest)
+	}
+	return total.Amount / int64(item.Quantity), nil
+}
+
+// allRefunded reports whether every unit of an order has been refunded,
+// given the units refunded of each of its items
+func allRefunded(order *domain.Order, refunded map[uint]int) bool {
+	for _, item := range order.Items {
+		if refunded[item.ID] < item.Quantity {
+			return false
+		}
+	}
+	return true
+}
+
+// update locks return id, changes it with fn and saves it
+func (s *ReturnService) update(ctx context.Context, id uint, fn func(ctx context.Context, ret *domain.Return) *common.AppError) (*domain.Return, *common.AppError) {
+	var ret *domain.Return
+	aerr := inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+		var err error
+		ret, err = s.repo.GetForUpdate(ctx, id)
+		if err != nil {
+			return common.NewAppError(err, "Return not found", http.StatusNotFound)
+		}
+		if aerr := fn(ctx, ret); aerr != nil {
+			return aerr
+		}
+		if err := s.repo.Update(ctx, ret); err != nil {
+			return common.NewAppError(err, "Failed to update return", common.ErrInternalServer.Code)
+		}
+		return nil
+	})
+	if aerr != nil {
+		return nil, aerr
+	}
+	return ret, nil
+}
+
+// record adds a step of a return to the history of its order
+func (s *ReturnService) record(ctx context.Context, ret *domain.Return, message string) *common.AppError {
+	return s.orders.record(ctx, ret.OrderID, domain.EventReturn, "", string(ret.Status), message, false)
+}
+
+// ReturnReference is the ledger reference of stock restocked from a return
+func ReturnReference(returnID uint) string {
+	return fmt.Sprintf("return:%d", returnID)
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/internal/repository"
+	"github.com/Dubjay18/ecom-api/pkg/common"
+)
+
+type ReviewService struct {
+	tx          repository.TxManager
+	reviewRepo  repository.ReviewRepository
+	orderRepo   repository.OrderRepository
+	productRepo repository.ProductRepository
+}
+
+func NewReviewService(tx repository.TxManager, rr repository.ReviewRepository, or repository.OrderRepository, pr repository.ProductRepository) *ReviewService {
+	return &ReviewService{tx: tx, reviewRepo: rr, orderRepo: or, productRepo: pr}
+}
+
+// Create submits a review for moderation. Only users with a delivered order
+// containing the product may review it.
+func (s *ReviewService) Create(ctx context.Context, userID, productID uint, req *domain.CreateReviewRequest) (*domain.Review, *common.AppError) {
+	if _, err := s.productRepo.GetByIDIncludingArchived(ctx, productID); err != nil {
+		return nil, common.NewAppError(err, "Product not found", http.StatusNotFound)
+	}
+
+	delivered, err := s.orderRepo.HasDeliveredProduct(ctx, userID, productID)
+	if err != nil {
+		return nil, common.NewAppError(err, "Failed to check purchase history", common.ErrInternalServer.Code)
+	}
+	if !delivered {
+		return nil, common.NewAppError(nil, "Only customers who received this product can review it", http.StatusForbidden)
+	}
+
+	if existing, err := s.reviewRepo.GetByUserAndProduct(ctx, userID, productID); err == nil && existing != nil {
+		return nil, common.NewAppError(nil, "You have already reviewed this product", http.StatusConflict)
+	}
+
+	review := &domain.Review{
+		UserID:           userID,
+		ProductID:        productID,
+		Rating:           req.Rating,
+		Title:            req.Title,
+		Body:             req.Body,
+		Status:           domain.ReviewPending,
+		VerifiedPurchase: true,
+	}
+	if err := s.reviewRepo.Create(ctx, review); err != nil {
+		return nil, common.NewAppError(err, "Failed to create review", common.ErrInternalServer.Code)
+	}
+	return review, nil
+}
+
+// ListApproved returns the published reviews of a product
+func (s *ReviewService) ListApproved(ctx context.Context, productID uint) ([]domain.Review, *common.AppError) {
+	reviews, err := s.reviewRepo.ListByProduct(ctx, productID, domain.ReviewApproved)
+	if err != nil {
+		return nil, common.NewAppError(err, "Failed to list reviews", common.ErrInternalServer.Code)
+	}
+	return reviews, nil
+}
+
+// ListByStatus returns reviews in a moderation state (admin privilege)
+func (s *ReviewService) ListByStatus(ctx context.Context, status domain.ReviewStatus) ([]domain.Review, *common.AppError) {
+	reviews, err := s.reviewRepo.ListByStatus(ctx, status)
+	if err != nil {
+		return nil, common.NewAppError(err, "Failed to list reviews", common.ErrInternalServer.Code)
+	}
+	return reviews, nil
+}
+
+// Moderate changes a review's moderation state and refreshes the product's
+// rating aggregate (admin privilege). Both are saved in one transaction, so
+// the rating never disagrees with the reviews it is computed from.
+func (s *ReviewService) Moderate(ctx context.Context, id uint, status domain.ReviewStatus) (*domain.Review, *common.AppError) {
+	var review *domain.Review
+	aerr := inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+		var err error
+		review, err = s.reviewRepo.GetByID(ctx, id)
+		if err != nil {
+			return common.NewAppError(err, "Review not found", http.StatusNotFound)
+		}
+
+		review.Status = status
+		if err := s.reviewRepo.Update(ctx, review); err != nil {
+			return common.NewAppError(err, "Failed to update review", common.ErrInternalServer.Code)
+		}
+
+		if _, err := s.reviewRepo.RefreshProductRating(ctx, review.ProductID); err != nil {
+			return common.NewAppError(err, "Failed to update product rating", common.ErrInternalServer.Code)
+		}
+		return nil
+	})
+	if aerr != nil {
+		return nil, aerr
+	}
+	return review, nil
+}
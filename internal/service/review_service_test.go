@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/internal/repository"
+)
+
+type txKey struct{}
+
+// recordingTx runs work in a pretend transaction and records whether it
+// was rolled back
+type recordingTx struct {
+	rolledBack bool
+}
+
+func (tx *recordingTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	err := fn(context.WithValue(ctx, txKey{}, true))
+	if err != nil {
+		tx.rolledBack = true
+	}
+	return err
+}
+
+func (tx *recordingTx) AfterCommit(_ context.Context, fn func()) {
+	fn()
+}
+
+// failingRatings saves reviews but fails to refresh ratings
+type failingRatings struct {
+	repository.ReviewRepository
+	updatedInTx bool
+}
+
+func (r *failingRatings) GetByID(_ context.Context, id uint) (*domain.Review, error) {
+	return &domain.Review{Base: domain.Base{ID: id}, ProductID: 1, Status: domain.ReviewPending}, nil
+}
+
+func (r *failingRatings) Update(ctx context.Context, _ *domain.Review) error {
+	r.updatedInTx = ctx.Value(txKey{}) != nil
+	return nil
+}
+
+func (r *failingRatings) RefreshProductRating(context.Context, uint) (*domain.RatingSummary, error) {
+	return nil, errors.New("connection reset")
+}
+
+func TestModerateRollsBackWhenRatingFails(t *testing.T) {
+	tx := &recordingTx{}
+	reviews := &failingRatings{}
+	s := NewReviewService(tx, reviews, nil, nil)
+
+	if _, aerr := s.Moderate(context.Background(), 1, domain.ReviewApproved); aerr == nil {
+		t.Fatal("moderation succeeded without a rating refresh, want an error")
+	}
+	if !reviews.updatedInTx {
+		t.Error("review was saved outside the transaction")
+	}
+	if !tx.rolledBack {
+		t.Error("review update was not rolled back")
+	}
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/internal/repository"
+	"github.com/Dubjay18/ecom-api/pkg/common"
+)
+
+// ShipmentService ships the items of orders in one or more shipments. The
+// order moves to partially shipped or shipped as its items ship and to
+// delivered once every shipment has been delivered.
+type ShipmentService struct {
+	tx        repository.TxManager
+	repo      repository.ShipmentRepository
+	orderRepo repository.OrderRepository
+	orders    *OrderService
+}
+
+func NewShipmentService(tx repository.TxManager, repo repository.ShipmentRepository, orderRepo repository.OrderRepository, orders *OrderService) *ShipmentService {
+	s := &ShipmentService{
+		tx:        tx,
+		repo:      repo,
+		orderRepo: orderRepo,
+		orders:    orders,
+	}
+	orders.status.OnEnter(domain.StatusDelivered, s.onDelivered)
+	return s
+}
+
+// Create ships items of a confirmed or partially shipped order (admin
+// privilege). Only units in stock that have not shipped yet can ship.
+func (s *ShipmentService) Create(ctx context.Context, orderID uint, req *domain.CreateShipmentRequest) (*domain.Shipment, *common.AppError) {
+	var shipment *domain.Shipment
+	aerr := inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+		order, err := s.orderRepo.GetForUpdate(ctx, orderID)
+		if err != nil {
+			return common.NewAppError(err, "Order not found", http.StatusNotFound)
+		}
+		if order.Status != domain.StatusConfirmed && order.Status != domain.StatusPartiallyShipped {
+			return common.NewAppError(nil, "Only confirmed or partially shipped orders can ship", http.StatusConflict)
+		}
+
+		items := make(map[uint]*domain.OrderItem, len(order.Items))
+		for i := range order.Items {
+			items[order.Items[i].ID] = &order.Items[i]
+		}
+		shipment = &domain.Shipment{
+			OrderID:        order.ID,
+			Status:         domain.ShipmentShipped,
+			Carrier:        req.Carrier,
+			TrackingNumber: req.TrackingNumber,
+			TrackingURL:    req.TrackingURL,
+			Items:          make([]domain.ShipmentItem, len(req.Items)),
+			ShippedAt:      time.Now(),
+		}
+		for i, line := range req.Items {
+			item, ok := items[line.OrderItemID]
+			if !ok {
+				return common.NewAppError(nil, fmt.Sprintf("Order item %d is not part of the order", line.OrderItemID), http.StatusBadRequest)
+			}
+			if line.Quantity > item.ShippableQuantity() {
+				return common.NewAppError(nil, fmt.Sprintf("Order item %d has only %d units ready to ship", item.ID, item.ShippableQuantity()), http.StatusBadRequest)
+			}
+			item.ShippedQuantity += line.Quantity
+			if err := s.orderRepo.UpdateItem(ctx, item); err != nil {
+				return common.NewAppError(err, "Failed to update order item", common.ErrInternalServer.Code)
+			}
+			shipment.Items[i] = domain.ShipmentItem{
+				OrderItemID: item.ID,
+				Quantity:    line.Quantity,
+			}
+		}
+		if err := s.repo.Create(ctx, shipment); err != nil {
+			return common.NewAppError(err, "Failed to create shipment", common.ErrInternalServer.Code)
+		}
+		message := fmt.Sprintf("Shipment %d shipped with %s, tracking number %s", shipment.ID, shipment.Carrier, shipment.TrackingNumber)
+		if aerr := s.orders.record(ctx, order.ID, domain.EventFulfillment, "", "", message, false); aerr != nil {
+			return aerr
+		}
+
+		to := domain.StatusShipped
+		if _, all := order.ShippedItems(); !all {
+			to = domain.StatusPartiallyShipped
+		}
+		if order.Status == to {
+			return nil
+		}
+		if aerr := s.orders.status.Transition(ctx, order, to); aerr != nil {
+			return aerr
+		}
+		if err := s.orderRepo.Update(ctx, order); err != nil {
+			return common.NewAppError(err, "Failed to update order", common.ErrInternalServer.Code)
+		}
+		return nil
+	})
+	if aerr != nil {
+		return nil, aerr
+	}
+	return shipment, nil
+}
+
+// ListByOrder returns the shipments of an order, to its owner or an admin
+func (s *ShipmentService) ListByOrder(ctx context.Context, orderID uint, actor Actor) ([]domain.Shipment, *common.AppError) {
+	if _, aerr := s.orders.getOwned(ctx, orderID, actor); aerr != nil {
+		return nil, aerr
+	}
+	shipments, err := s.repo.ListByOrder(ctx, orderID)
+	if err != nil {
+		return nil, common.NewAppError(err, "Failed to list shipments", common.ErrInternalServer.Code)
+	}
+	return shipments, nil
+}
+
+// Update corrects the carrier and tracking details of a shipment (admin
+// privilege)
+func (s *ShipmentService) Update(ctx context.Context, id uint, req *domain.UpdateShipmentRequest) (*domain.Shipment, *common.AppError) {
+	var shipment *domain.Shipment
+	aerr := inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+		var err error
+		shipment, err = s.repo.GetForUpdate(ctx, id)
+		if err != nil {
+			return common.NewAppError(err, "Shipment not found", http.StatusNotFound)
+		}
+		if req.Carrier != "" {
+			shipment.Carrier = req.Carrier
+		}
+		if req.TrackingNumber != "" {
+			shipment.TrackingNumber = req.TrackingNumber
+		}
+		if req.TrackingURL != "" {
+			shipment.TrackingURL = req.TrackingURL
+		}
+		if err := s.repo.Update(ctx, shipment); err != nil {
+			return common.NewAppError(err, "Failed to update shipment", common.ErrInternalServer.Code)
+		}
+		message := fmt.Sprintf("Shipment %d tracking updated to %s, tracking number %s", shipment.ID, shipment.Carrier, shipment.TrackingNumber)
+		return s.orders.record(ctx, shipment.OrderID, domain.EventFulfillment, "", "", message, false)
+	})
+	if aerr != nil {
+		return nil, aerr
+	}
+	return shipment, nil
+}
+
+// Deliver marks a shipment as delivered (admin privilege). The order is
+// delivered once all of its items have shipped and every shipment has been
+// delivered.
+func (s *ShipmentService) Deliver(ctx context.Context, id uint) (*domain.Shipment, *common.AppError) {
+	shipment, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, common.NewAppError(err, "Shipment not found", http.StatusNotFound)
+	}
+
+	aerr := inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+		// The order is locked before the shipment, the same as when
+		// shipments are created
+		order, err := s.orderRepo.GetForUpdate(ctx, shipment.OrderID)
+		if err != nil {
+			return common.NewAppError(err, "Order not found", http.StatusNotFound)
+		}
+		shipment, err = s.repo.GetForUpdate(ctx, id)
+		if err != nil {
+			return common.NewAppError(err, "Shipment not found", http.StatusNotFound)
+		}
+		if shipment.IsDelivered() {
+			return common.NewAppError(nil, "Shipment has already been delivered", http.StatusConflict)
+		}
+
+		now := time.Now()
+		shipment.Status = domain.ShipmentDelivered
+		shipment.DeliveredAt = &now
+		if err := s.repo.Update(ctx, shipment); err != nil {
+			return common.NewAppError(err, "Failed to update shipment", common.ErrInternalServer.Code)
+		}
+		message := fmt.Sprintf("Shipment %d delivered", shipment.ID)
+		if aerr := s.orders.record(ctx, order.ID, domain.EventFulfillment, "", "", message, false); aerr != nil {
+			return aerr
+		}
+
+		if order.Status != domain.StatusShipped {
+			return nil
+		}
+		shipments, err := s.repo.ListByOrder(ctx, order.ID)
+		if err != nil {
+			return common.NewAppError(err, "Failed to list shipments", common.ErrInternalServer.Code)
+		}
+		for _, other := range shipments {
+			if !other.IsDelivered() {
+				return nil
+			}
+		}
+		if aerr := s.orders.status.Transition(ctx, order, domain.StatusDelivered); aerr != nil {
+			return aerr
+		}
+		if err := s.orderRepo.Update(ctx, order); err != nil {
+			return common.NewAppError(err, "Failed to update order", common.ErrInternalServer.Code)
+		}
+		return nil
+	})
+	if aerr != nil {
+		return nil, aerr
+	}
+	return shipment, nil
+}
+
+// onDelivered marks the shipments of an order delivered by hand as
+// delivered too
+func (s *ShipmentService) onDelivered(ctx context.Context, order *domain.Order, _ domain.OrderStatus) *common.AppError {
+	shipments, err := s.repo.ListByOrder(ctx, order.ID)
+	if err != nil {
+		return common.NewAppError(err, "Failed to list shipments", common.ErrInternalServer.Code)
+	}
+	now := time.Now()
+	for i := range shipments {
+		if shipments[i].IsDelivered() {
+			continue
+		}
+		shipments[i].Status = domain.ShipmentDelivered
+		shipments[i].DeliveredAt = &now
+		if err := s.repo.Update(ctx, &shipments[i]); err != nil {
+			return common.NewAppError(err, "Failed to update shipment", common.ErrInternalServer.Code)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/internal/repository"
+	"github.com/Dubjay18/ecom-api/pkg/common"
+	"github.com/Dubjay18/ecom-api/pkg/mailer"
+	"github.com/Dubjay18/ecom-api/pkg/webhook"
+)
+
+// StockLowEvent is the webhook event sent when products run low
+const StockLowEvent = "stock.low"
+
+type StockAlertService struct {
+	productRepo repository.ProductRepository
+	mailer      mailer.Mailer
+	webhook     *webhook.Client
+	recipients  []string
+	// evaluations are the evaluations started by stock changes
+	evaluations *background
+}
+
+// NewStockAlertService returns a service alerting recipients by email and,
+// when wh is not nil, through an outgoing webhook
+func NewStockAlertService(pr repository.ProductRepository, m mailer.Mailer, wh *webhook.Client, recipients []string) *StockAlertService {
+	return &StockAlertService{productRepo: pr, mailer: m, webhook: wh, recipients: recipients, evaluations: newBackground()}
+}
+
+// ListLowStock returns every product at or below its reorder threshold
+func (s *StockAlertService) ListLowStock(ctx context.Context) ([]domain.Product, *common.AppError) {
+	products, err := s.productRepo.ListLowStock(ctx, nil)
+	if err != nil {
+		return nil, common.NewAppError(err, "Failed to list low stock products", common.ErrInternalServer.Code)
+	}
+	return products, nil
+}
+
+// Evaluate alerts catalog managers about products that have fallen to their
+// reorder threshold since the last alert, and re-arms the alert of products
+// that have recovered. A nil ids evaluates every product. It returns the
+// number of products alerted about.
+//
+// The email and the webhook are delivered apart, each is recorded once sent
+// and only a failed one is tried again by the next evaluation.
+func (s *StockAlertService) Evaluate(ctx context.Context, ids []uint) (int, *common.AppError) {
+	if err := s.productRepo.ClearRecoveredLowStock(ctx, ids); err != nil {
+		return 0, common.NewAppError(err, "Failed to clear recovered stock alerts", common.ErrInternalServer.Code)
+	}
+
+	products, err := s.productRepo.ListLowStock(ctx, ids)
+	if err != nil {
+		return 0, common.NewAppError(err, "Failed to list low stock products", common.ErrInternalServer.Code)
+	}
+
+	// Claim each alert first so concurrent evaluations only send it once
+	now := time.Now()
+	var emailed, hooked []domain.Product
+	alerted := make(map[uint]bool)
+	for _, p := range products {
+		if p.LowStockAlertedAt == nil {
+			claimed, err := s.productRepo.MarkLowStockAlerted(ctx, p.ID, now)
+			if err != nil {
+				return 0, common.NewAppError(err, "Failed to record stock alert", common.ErrInternalServer.Code)
+			}
+			if claimed {
+				emailed = append(emailed, p)
+				alerted[p.ID] = true
+			}
+		}
+		if s.webhook != nil && p.LowStockWebhookAt == nil {
+			claimed, err := s.productRepo.MarkLowStockWebhookSent(ctx, p.ID, now)
+			if err != nil {
+				return 0, common.NewAppError(err, "Failed to record stock alert", common.ErrInternalServer.Code)
+			}
+			if claimed {
+				hooked = append(hooked, p)
+				alerted[p.ID] = true
+			}
+		}
+	}
+
+	// Leave the failed alerts armed so the next evaluation tries again
+	var failed error
+	if len(emailed) > 0 {
+		if err := s.sendEmail(ctx, emailed); err != nil {
+			failed = errors.Join(failed, err)
+			if rerr := s.productRepo.ResetLowStockAlerts(ctx, productIDsOf(emailed)); rerr != nil {
+				log.Printf("Failed to reset stock alerts: %v", rerr)
+			}
+		}
+	}
+	if len(hooked) > 0 {
+		if err := s.sendWebhook(ctx, hooked); err != nil {
+			failed = errors.Join(failed, err)
+			if rerr := s.productRepo.ResetLowStockWebhooks(ctx, productIDsOf(hooked)); rerr != nil {
+				log.Printf("Failed to reset stock alert webhooks: %v", rerr)
+			}
+		}
+	}
+	if failed != nil {
+		return 0, common.NewAppError(failed, "Failed to send stock alerts", common.ErrInternalServer.Code)
+	}
+	return len(alerted), nil
+}
+
+// StockChanged evaluates the given products in the background, it is called
+// after their stock changes
+func (s *StockAlertService) StockChanged(productIDs []uint) {
+	// The caller's request may finish before the evaluation does
+	s.evaluations.Go(func(ctx context.Context) {
+		if _, aerr := s.Evaluate(ctx, productIDs); aerr != nil {
+			log.Printf("Failed to evaluate stock alerts: %v", aerr)
+		}
+	})
+}
+
+// Shutdown waits for the evaluations started by stock changes to finish,
+// they are cancelled if ctx ends first
+func (s *StockAlertService) Shutdown(ctx context.Context) {
+	s.evaluations.Shutdown(ctx)
+}
+
+func (s *StockAlertService) sendEmail(ctx context.Context, products []domain.Product) error {
+	var body strings.Builder
+	body.WriteString("The following products are at or below their reorder threshold:\n\n")
+	for _, p := range products {
+		fmt.Fprintf(&body, "%s  %s: %d available (threshold %d)\n", p.SKU, p.Name, p.Available(), p.ReorderThreshold)
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      s.recipients,
+		Subject: fmt.Sprintf("Low stock: %d product(s) need reordering", len(products)),
+		Body:    body.String(),
+	})
+}
+
+func (s *StockAlertService) sendWebhook(ctx context.Context, products []domain.Product) error {
+	type lowStockProduct struct {
+		ID               uint   `json:"id"`
+		SKU              string `json:"sku"`
+		Name             string `json:"name"`
+		Stock            int    `json:"stock"`
+		Reserved         int    `json:"reserved"`
+		Available        int    `json:"available"`
+		ReorderThreshold int    `json:"reorder_threshold"`
+	}
+	data := make([]lowStockProduct, len(products))
+	for i, p := range products {
+		data[i] = lowStockProduct{
+			ID:               p.ID,
+			SKU:              p.SKU,
+			Name:             p.Name,
+			Stock:            p.Stock,
+			Reserved:         p.Reserved,
+			Available:        p.Available(),
+			ReorderThreshold: p.ReorderThreshold,
+		}
+	}
+	return s.webhook.Send(ctx, StockLowEvent, map[string]interface{}{"products": data})
+}
+
+func productIDsOf(products []domain.Product) []uint {
+	ids := make([]uint, len(products))
+	for i, p := range products {
+		ids[i] = p.ID
+	}
+	return ids
+}
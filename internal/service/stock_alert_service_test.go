@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/internal/repository"
+	"github.com/Dubjay18/ecom-api/pkg/mailer"
+	"github.com/Dubjay18/ecom-api/pkg/webhook"
+)
+
+// memLowStock holds products that are all low on stock
+type memLowStock struct {
+	repository.ProductRepository
+	mu       sync.Mutex
+	products map[uint]*domain.Product
+}
+
+func (r *memLowStock) ClearRecoveredLowStock(context.Context, []uint) error {
+	return nil
+}
+
+func (r *memLowStock) ListLowStock(context.Context, []uint) ([]domain.Product, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var products []domain.Product
+	for _, p := range r.products {
+		products = append(products, *p)
+	}
+	return products, nil
+}
+
+func (r *memLowStock) claim(field func(*domain.Product) **time.Time, id uint, at time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if *field(r.products[id]) != nil {
+		return false
+	}
+	*field(r.products[id]) = &at
+	return true
+}
+
+func (r *memLowStock) reset(field func(*domain.Product) **time.Time, ids []uint) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, id := range ids {
+		*field(r.products[id]) = nil
+	}
+}
+
+func alertedAt(p *domain.Product) **time.Time { return &p.LowStockAlertedAt }
+func webhookAt(p *domain.Product) **time.Time { return &p.LowStockWebhookAt }
+
+func (r *memLowStock) MarkLowStockAlerted(_ context.Context, id uint, at time.Time) (bool, error) {
+	return r.claim(alertedAt, id, at), nil
+}
+
+func (r *memLowStock) MarkLowStockWebhookSent(_ context.Context, id uint, at time.Time) (bool, error) {
+	return r.claim(webhookAt, id, at), nil
+}
+
+func (r *memLowStock) ResetLowStockAlerts(_ context.Context, ids []uint) error {
+	r.reset(alertedAt, ids)
+	return nil
+}
+
+func (r *memLowStock) ResetLowStockWebhooks(_ context.Context, ids []uint) error {
+	r.reset(webhookAt, ids)
+	return nil
+}
+
+type countingMailer struct {
+	mu   sync.Mutex
+	sent int
+}
+
+func (m *countingMailer) Send(context.Context, mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent++
+	return nil
+}
+
+func TestFailedWebhookDoesNotResendEmail(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		// The first delivery fails
+		if calls == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	products := &memLowStock{products: map[uint]*domain.Product{
+		1: {Base: domain.Base{ID: 1}, SKU: "LOW-1", Stock: 1, ReorderThreshold: 5},
+	}}
+	mail := &countingMailer{}
+	alerts := NewStockAlertService(products, mail, webhook.New(server.URL, "secret"), []string{"ops@example.com"})
+	ctx := context.Background()
+
+	if _, aerr := alerts.Evaluate(ctx, nil); aerr == nil {
+		t.Fatal("evaluation with the webhook down succeeded, want an error")
+	}
+	alerted, aerr := alerts.Evaluate(ctx, nil)
+	if aerr != nil {
+		t.Fatal(aerr)
+	}
+	if alerted != 1 {
+		t.Errorf("retry alerted about %d products, want 1", alerted)
+	}
+	if mail.sent != 1 {
+		t.Errorf("%d emails sent, want 1", mail.sent)
+	}
+	if calls != 2 {
+		t.Errorf("webhook called %d times, want 2", calls)
+	}
+
+	// Both are delivered, a third evaluation sends nothing
+	if alerted, aerr := alerts.Evaluate(ctx, nil); aerr != nil || alerted != 0 {
+		t.Errorf("third evaluation alerted about %d products with %v, want none", alerted, aerr)
+	}
+}
+
+func TestShutdownWaitsForStockChangeEvaluations(t *testing.T) {
+	products := &memLowStock{products: map[uint]*domain.Product{
+		1: {Base: domain.Base{ID: 1}, SKU: "LOW-1", Stock: 1, ReorderThreshold: 5},
+	}}
+	mail := &countingMailer{}
+	alerts := NewStockAlertService(products, mail, nil, []string{"ops@example.com"})
+
+	alerts.StockChanged([]uint{1})
+	alerts.Shutdown(context.Background())
+
+	mail.mu.Lock()
+	defer mail.mu.Unlock()
+	if mail.sent != 1 {
+		t.Errorf("%d emails sent by shutdown, want 1", mail.sent)
+	}
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Dubjay18/ecom-api/internal/repository"
+	"github.com/Dubjay18/ecom-api/pkg/common"
+)
+
+// inTx runs fn in a transaction, rolling back when it returns an AppError
+// and passing that AppError through unchanged
+func inTx(ctx context.Context, tm repository.TxManager, fn func(ctx context.Context) *common.AppError) *common.AppError {
+	err := tm.WithinTx(ctx, func(ctx context.Context) error {
+		if aerr := fn(ctx); aerr != nil {
+			return aerr
+		}
+		return nil
+	})
+	if err == nil {
+		return nil
+	}
+
+	var aerr *common.AppError
+	if errors.As(err, &aerr) {
+		return aerr
+	}
+	return common.NewAppError(err, "Failed to commit transaction", common.ErrInternalServer.Code)
+}
@@ -7,8 +7,10 @@ import (
 	"github.com/Dubjay18/ecom-api/internal/util"
 	"github.com/Dubjay18/ecom-api/pkg/common"
 	"github.com/Dubjay18/ecom-api/pkg/jwt"
+	"github.com/Dubjay18/ecom-api/pkg/money"
 	"log"
 	"net/http"
+	"strings"
 )
 
 var (
@@ -29,6 +31,8 @@ type UserService interface {
 	Update(ctx context.Context, user *domain.User) (*domain.User, *common.AppError)
 	// RegisterAdmin creates a new admin user
 	RegisterAdmin(ctx context.Context, req domain.RegisterRequest) (*domain.User, *common.AppError)
+	// UpdatePreferredCurrency sets the currency the user is priced in
+	UpdatePreferredCurrency(ctx context.Context, id uint, currency string) (*domain.User, *common.AppError)
 }
 
 type userService struct {
@@ -120,6 +124,24 @@ func (s *userService) Update(ctx context.Context, user *domain.User) (*domain.Us
 	return updated, nil
 }
 
+func (s *userService) UpdatePreferredCurrency(ctx context.Context, id uint, currency string) (*domain.User, *common.AppError) {
+	currency = strings.ToUpper(currency)
+	if currency != "" && !money.IsSupported(currency) {
+		return nil, &common.AppError{
+			Code:    http.StatusBadRequest,
+			Message: "Unsupported currency",
+		}
+	}
+	if err := s.repo.UpdatePreferredCurrency(ctx, id, currency); err != nil {
+		log.Printf("Failed to update preferred currency: %v", err)
+		return nil, &common.AppError{
+			Code:    http.StatusInternalServerError,
+			Message: "Failed to update user",
+		}
+	}
+	return s.GetByID(ctx, id)
+}
+
 func NewUserService(repo repository.UserRepository, jwt *jwt.JWTService) UserService {
 	return &userService{repo: repo,
 		jwt: jwt,
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Dubjay18/ecom-api/internal/domain"
+	"github.com/Dubjay18/ecom-api/internal/repository"
+	"github.com/Dubjay18/ecom-api/pkg/common"
+)
+
+type WarehouseService struct {
+	tx   repository.TxManager
+	repo repository.WarehouseRepository
+}
+
+func NewWarehouseService(tx repository.TxManager, repo repository.WarehouseRepository) *WarehouseService {
+	return &WarehouseService{tx: tx, repo: repo}
+}
+
+// Create adds a warehouse, making it the default one if requested
+func (s *WarehouseService) Create(ctx context.Context, req *domain.CreateWarehouseRequest) (*domain.Warehouse, *common.AppError) {
+	if existing, err := s.repo.GetByCode(ctx, req.Code); err == nil && existing != nil {
+		return nil, common.NewAppError(nil, "Warehouse code already exists", http.StatusConflict)
+	}
+
+	warehouse := &domain.Warehouse{
+		Code:      req.Code,
+		Name:      req.Name,
+		Street:    req.Street,
+		City:      req.City,
+		State:     req.State,
+		Country:   req.Country,
+		IsDefault: req.IsDefault,
+		IsActive:  true,
+	}
+	aerr := inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+		if warehouse.IsDefault {
+			if err := s.repo.ClearDefault(ctx); err != nil {
+				return common.NewAppError(err, "Failed to update default warehouse", common.ErrInternalServer.Code)
+			}
+		}
+		if err := s.repo.Create(ctx, warehouse); err != nil {
+			return common.NewAppError(err, "Failed to create warehouse", common.ErrInternalServer.Code)
+		}
+		return nil
+	})
+	if aerr != nil {
+		return nil, aerr
+	}
+	return warehouse, nil
+}
+
+func (s *WarehouseService) List(ctx context.Context) ([]domain.Warehouse, *common.AppError) {
+	warehouses, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, common.NewAppError(err, "Failed to list warehouses", common.ErrInternalServer.Code)
+	}
+	return warehouses, nil
+}
+
+// Update changes a warehouse. The default warehouse cannot be deactivated
+// and stays the default until another warehouse is made the default.
+func (s *WarehouseService) Update(ctx context.Context, id uint, req *domain.UpdateWarehouseRequest) (*domain.Warehouse, *common.AppError) {
+	warehouse, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, common.NewAppError(err, "Warehouse not found", http.StatusNotFound)
+	}
+
+	if req.Name != "" {
+		warehouse.Name = req.Name
+	}
+	if req.Street != "" {
+		warehouse.Street = req.Street
+	}
+	if req.City != "" {
+		warehouse.City = req.City
+	}
+	if req.State != "" {
+		warehouse.State = req.State
+	}
+	if req.Country != "" {
+		warehouse.Country = req.Country
+	}
+	if req.IsActive != nil {
+		warehouse.IsActive = *req.IsActive
+	}
+	makeDefault := req.IsDefault != nil && *req.IsDefault && !warehouse.IsDefault
+	if req.IsDefault != nil && !*req.IsDefault && warehouse.IsDefault {
+		return nil, common.NewAppError(nil, "Make another warehouse the default instead", http.StatusBadRequest)
+	}
+	if (warehouse.IsDefault || makeDefault) && !warehouse.IsActive {
+		return nil, common.NewAppError(nil, "The default warehouse cannot be deactivated", http.StatusBadRequest)
+	}
+
+	aerr := inTx(ctx, s.tx, func(ctx context.Context) *common.AppError {
+		if makeDefault {
+			if err := s.repo.ClearDefault(ctx); err != nil {
+				return common.NewAppError(err, "Failed to update default warehouse", common.ErrInternalServer.Code)
+			}
+			warehouse.IsDefault = true
+		}
+		if err := s.repo.Update(ctx, warehouse); err != nil {
+			return common.NewAppError(err, "Failed to update warehouse", common.ErrInternalServer.Code)
+		}
+		return nil
+	})
+	if aerr != nil {
+		return nil, aerr
+	}
+	return warehouse, nil
+}
@@ -0,0 +1,161 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/Dubjay18/ecom-api/internal/service"
+	"github.com/sirupsen/logrus"
+)
+
+// PurgeArchivedProductsJob permanently deletes products that have been
+// archived for longer than the retention window
+func PurgeArchivedProductsJob(s *service.ProductService, interval, retention time.Duration, logger *logrus.Logger) Job {
+	return Job{
+		Name:     "purge_archived_products",
+		Interval: interval,
+		Run: func(ctx context.Context) error {
+			purged, err := s.PurgeArchived(ctx, retention)
+			if err != nil {
+				return err
+			}
+			if purged > 0 {
+				logger.WithField("job", "purge_archived_products").Infof("purged %d archived products", purged)
+			}
+			return nil
+		},
+	}
+}
+
+// PublishScheduledProductsJob flips scheduled products live once their
+// publish time has passed
+func PublishScheduledProductsJob(s *service.ProductService, interval time.Duration, logger *logrus.Logger) Job {
+	return Job{
+		Name:     "publish_scheduled_products",
+		Interval: interval,
+		Run: func(ctx context.Context) error {
+			published, err := s.PublishScheduled(ctx)
+			if err != nil {
+				return err
+			}
+			if published > 0 {
+				logger.WithField("job", "publish_scheduled_products").Infof("published %d scheduled products", published)
+			}
+			return nil
+		},
+	}
+}
+
+// ExpireReservationsJob cancels unpaid orders whose stock reservations have
+// expired, returning the stock to the catalog
+func ExpireReservationsJob(s *service.OrderService, interval time.Duration, logger *logrus.Logger) Job {
+	return Job{
+		Name:     "expire_reservations",
+		Interval: interval,
+		Run: func(ctx context.Context) error {
+			expired, aerr := s.ExpireUnpaidOrders(ctx, 100)
+			if expired > 0 {
+				logger.WithField("job", "expire_reservations").Infof("cancelled %d orders with expired reservations", expired)
+			}
+			if aerr != nil {
+				return aerr
+			}
+			return nil
+		},
+	}
+}
+
+// EvaluateStockAlertsJob re-checks every product against its reorder
+// threshold, catching alerts that failed to send after a stock change
+func EvaluateStockAlertsJob(s *service.StockAlertService, interval time.Duration, logger *logrus.Logger) Job {
+	return Job{
+		Name:     "evaluate_stock_alerts",
+		Interval: interval,
+		Run: func(ctx context.Context) error {
+			alerted, aerr := s.Evaluate(ctx, nil)
+			if aerr != nil {
+				return aerr
+			}
+			if alerted > 0 {
+				logger.WithField("job", "evaluate_stock_alerts").Infof("sent low stock alerts for %d products", alerted)
+			}
+			return nil
+		},
+	}
+}
+
+// AllocateBackordersJob fills waiting backorders from available stock,
+// catching allocations missed after a stock change
+func AllocateBackordersJob(s *service.OrderService, interval time.Duration, logger *logrus.Logger) Job {
+	return Job{
+		Name:     "allocate_backorders",
+		Interval: interval,
+		Run: func(ctx context.Context) error {
+			allocated, aerr := s.AllocatePendingBackorders(ctx, 100)
+			if allocated > 0 {
+				logger.WithField("job", "allocate_backorders").Infof("allocated %d backordered units", allocated)
+			}
+			if aerr != nil {
+				return aerr
+			}
+			return nil
+		},
+	}
+}
+
+// ApplyScheduledPricesJob starts scheduled price changes and ends sales at
+// their scheduled times
+func ApplyScheduledPricesJob(s *service.PriceScheduleService, interval time.Duration, logger *logrus.Logger) Job {
+	return Job{
+		Name:     "apply_scheduled_prices",
+		Interval: interval,
+		Run: func(ctx context.Context) error {
+			applied, aerr := s.ApplyDue(ctx, 100)
+			if applied > 0 {
+				logger.WithField("job", "apply_scheduled_prices").Infof("applied %d scheduled price changes", applied)
+			}
+			if aerr != nil {
+				return aerr
+			}
+			return nil
+		},
+	}
+}
+
+// PurgeAbandonedCartsJob deletes anonymous carts that have not been used
+// within the retention window
+func PurgeAbandonedCartsJob(s *service.CartService, interval, retention time.Duration, logger *logrus.Logger) Job {
+	return Job{
+		Name:     "purge_abandoned_carts",
+		Interval: interval,
+		Run: func(ctx context.Context) error {
+			purged, err := s.PurgeAbandoned(ctx, retention)
+			if err != nil {
+				return err
+			}
+			if purged > 0 {
+				logger.WithField("job", "purge_abandoned_carts").Infof("purged %d abandoned carts", purged)
+			}
+			return nil
+		},
+	}
+}
+
+// PurgeIdempotencyKeysJob deletes idempotency keys whose responses are no
+// longer replayed
+func PurgeIdempotencyKeysJob(s *service.IdempotencyService, interval time.Duration, logger *logrus.Logger) Job {
+	return Job{
+		Name:     "purge_idempotency_keys",
+		Interval: interval,
+		Run: func(ctx context.Context) error {
+			purged, err := s.PurgeExpired(ctx)
+			if err != nil {
+				return err
+			}
+			if purged > 0 {
+				logger.WithField("job", "purge_idempotency_keys").Infof("purged %d expired idempotency keys", purged)
+			}
+			return nil
+		},
+	}
+}
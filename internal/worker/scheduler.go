@@ -0,0 +1,71 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Job is a unit of background work run on a fixed interval
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Scheduler runs registered jobs until its context is cancelled
+type Scheduler struct {
+	jobs   []Job
+	logger *logrus.Logger
+}
+
+func NewScheduler(logger *logrus.Logger) *Scheduler {
+	return &Scheduler{logger: logger}
+}
+
+// Add registers a job, it must be called before Start
+func (s *Scheduler) Add(job Job) {
+	s.jobs = append(s.jobs, job)
+}
+
+// Start runs every job in its own goroutine, once immediately and then on
+// every tick of its interval
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, job := range s.jobs {
+		go s.run(ctx, job)
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.runOnce(ctx, job)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) runOnce(ctx context.Context, job Job) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.WithField("job", job.Name).Errorf("job panicked: %v", r)
+		}
+	}()
+
+	start := time.Now()
+	if err := job.Run(ctx); err != nil {
+		s.logger.WithField("job", job.Name).Errorf("job failed: %v", err)
+		return
+	}
+	s.logger.WithFields(logrus.Fields{
+		"job":      job.Name,
+		"duration": time.Since(start),
+	}).Debug("job completed")
+}
@@ -0,0 +1,4 @@
+ALTER TABLE products
+    DROP COLUMN IF EXISTS thumbnail_url,
+    DROP COLUMN IF EXISTS medium_url,
+    DROP COLUMN IF EXISTS large_url;
@@ -0,0 +1,4 @@
+ALTER TABLE products
+    ADD COLUMN thumbnail_url VARCHAR(255),
+    ADD COLUMN medium_url VARCHAR(255),
+    ADD COLUMN large_url VARCHAR(255);
@@ -0,0 +1,13 @@
+CREATE TABLE import_jobs (
+    id SERIAL PRIMARY KEY,
+    user_id INT REFERENCES users(id),
+    status VARCHAR(20) DEFAULT 'pending',
+    dry_run BOOLEAN DEFAULT false,
+    total_rows INT NOT NULL DEFAULT 0,
+    created INT NOT NULL DEFAULT 0,
+    updated INT NOT NULL DEFAULT 0,
+    failed INT NOT NULL DEFAULT 0,
+    errors JSONB,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
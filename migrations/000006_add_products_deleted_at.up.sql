@@ -0,0 +1,3 @@
+ALTER TABLE products ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_products_deleted_at ON products(deleted_at);
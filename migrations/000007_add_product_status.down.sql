@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_products_status;
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS status,
+    DROP COLUMN IF EXISTS publish_at;
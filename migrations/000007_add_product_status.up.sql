@@ -0,0 +1,8 @@
+ALTER TABLE products
+    ADD COLUMN status VARCHAR(20) DEFAULT 'draft',
+    ADD COLUMN publish_at TIMESTAMP WITH TIME ZONE;
+
+-- Products created before lifecycle states existed were already live
+UPDATE products SET status = 'published', publish_at = created_at;
+
+CREATE INDEX idx_products_status ON products(status);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS reviews;
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS rating_average,
+    DROP COLUMN IF EXISTS rating_count;
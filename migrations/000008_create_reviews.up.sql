@@ -0,0 +1,20 @@
+ALTER TABLE products
+    ADD COLUMN rating_average DECIMAL(3,2) DEFAULT 0,
+    ADD COLUMN rating_count INT DEFAULT 0;
+
+CREATE TABLE reviews (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id),
+    product_id INT NOT NULL REFERENCES products(id),
+    rating INT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    title VARCHAR(255) NOT NULL,
+    body TEXT,
+    status VARCHAR(20) DEFAULT 'pending',
+    verified_purchase BOOLEAN DEFAULT false,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, product_id)
+);
+
+CREATE INDEX idx_reviews_product_id ON reviews(product_id);
+CREATE INDEX idx_reviews_status ON reviews(status);
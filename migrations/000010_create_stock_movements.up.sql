@@ -0,0 +1,22 @@
+CREATE TABLE stock_movements (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id),
+    quantity INT NOT NULL,
+    type VARCHAR(30) NOT NULL,
+    user_id INT REFERENCES users(id),
+    reason VARCHAR(255),
+    reference VARCHAR(100),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_stock_movements_product_id ON stock_movements(product_id);
+CREATE INDEX idx_stock_movements_type ON stock_movements(type);
+CREATE INDEX idx_stock_movements_user_id ON stock_movements(user_id);
+CREATE INDEX idx_stock_movements_reference ON stock_movements(reference);
+
+-- Seed the ledger with the current stock so existing products reconcile
+INSERT INTO stock_movements (product_id, quantity, type, reason)
+SELECT id, stock, 'adjustment', 'opening balance'
+FROM products
+WHERE stock <> 0;
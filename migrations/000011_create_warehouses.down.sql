@@ -0,0 +1,7 @@
+ALTER TABLE stock_movements DROP COLUMN IF EXISTS warehouse_id;
+ALTER TABLE stock_reservations DROP COLUMN IF EXISTS warehouse_id;
+ALTER TABLE order_items DROP COLUMN IF EXISTS warehouse_id;
+
+DROP TABLE IF EXISTS stock_transfers;
+DROP TABLE IF EXISTS warehouse_stocks;
+DROP TABLE IF EXISTS warehouses;
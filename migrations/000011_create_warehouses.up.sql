@@ -0,0 +1,68 @@
+CREATE TABLE warehouses (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(50) NOT NULL UNIQUE,
+    name VARCHAR(255) NOT NULL,
+    street VARCHAR(255),
+    city VARCHAR(100),
+    state VARCHAR(100),
+    country VARCHAR(100) NOT NULL,
+    is_default BOOLEAN DEFAULT false,
+    is_active BOOLEAN DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE warehouse_stocks (
+    id SERIAL PRIMARY KEY,
+    warehouse_id INT NOT NULL REFERENCES warehouses(id),
+    product_id INT NOT NULL REFERENCES products(id),
+    stock INT NOT NULL DEFAULT 0,
+    reserved INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_warehouse_stocks_warehouse_product ON warehouse_stocks(warehouse_id, product_id);
+CREATE INDEX idx_warehouse_stocks_product_id ON warehouse_stocks(product_id);
+
+CREATE TABLE stock_transfers (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id),
+    from_warehouse_id INT NOT NULL REFERENCES warehouses(id),
+    to_warehouse_id INT NOT NULL REFERENCES warehouses(id),
+    quantity INT NOT NULL,
+    user_id INT REFERENCES users(id),
+    reason VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_stock_transfers_product_id ON stock_transfers(product_id);
+CREATE INDEX idx_stock_transfers_from_warehouse_id ON stock_transfers(from_warehouse_id);
+CREATE INDEX idx_stock_transfers_to_warehouse_id ON stock_transfers(to_warehouse_id);
+CREATE INDEX idx_stock_transfers_user_id ON stock_transfers(user_id);
+
+-- Existing stock moves into a default warehouse
+INSERT INTO warehouses (code, name, country, is_default)
+VALUES ('MAIN', 'Main warehouse', 'US', true);
+
+INSERT INTO warehouse_stocks (warehouse_id, product_id, stock, reserved)
+SELECT w.id, p.id, p.stock, p.reserved
+FROM products p
+CROSS JOIN warehouses w
+WHERE w.code = 'MAIN';
+
+ALTER TABLE order_items ADD COLUMN warehouse_id INT REFERENCES warehouses(id);
+ALTER TABLE stock_reservations ADD COLUMN warehouse_id INT REFERENCES warehouses(id);
+ALTER TABLE stock_movements ADD COLUMN warehouse_id INT REFERENCES warehouses(id);
+
+UPDATE order_items SET warehouse_id = (SELECT id FROM warehouses WHERE code = 'MAIN');
+UPDATE stock_reservations SET warehouse_id = (SELECT id FROM warehouses WHERE code = 'MAIN');
+UPDATE stock_movements SET warehouse_id = (SELECT id FROM warehouses WHERE code = 'MAIN');
+
+ALTER TABLE stock_reservations ALTER COLUMN warehouse_id SET NOT NULL;
+ALTER TABLE stock_movements ALTER COLUMN warehouse_id SET NOT NULL;
+
+CREATE INDEX idx_order_items_warehouse_id ON order_items(warehouse_id);
+CREATE INDEX idx_stock_reservations_warehouse_id ON stock_reservations(warehouse_id);
+CREATE INDEX idx_stock_movements_warehouse_id ON stock_movements(warehouse_id);
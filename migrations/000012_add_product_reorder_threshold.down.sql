@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_products_low_stock;
+ALTER TABLE products DROP COLUMN IF EXISTS low_stock_alerted_at;
+ALTER TABLE products DROP COLUMN IF EXISTS reorder_threshold;
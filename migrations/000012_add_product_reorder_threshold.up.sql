@@ -0,0 +1,4 @@
+ALTER TABLE products ADD COLUMN reorder_threshold INT NOT NULL DEFAULT 0;
+ALTER TABLE products ADD COLUMN low_stock_alerted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_products_low_stock ON products(reorder_threshold) WHERE reorder_threshold > 0;
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_order_items_backordered;
+
+ALTER TABLE order_items DROP COLUMN IF EXISTS expected_at;
+ALTER TABLE order_items DROP COLUMN IF EXISTS backordered_quantity;
+
+ALTER TABLE products DROP COLUMN IF EXISTS available_at;
+ALTER TABLE products DROP COLUMN IF EXISTS pre_order;
+ALTER TABLE products DROP COLUMN IF EXISTS backordered;
+ALTER TABLE products DROP COLUMN IF EXISTS backorder_limit;
+ALTER TABLE products DROP COLUMN IF EXISTS backorder_policy;
@@ -0,0 +1,10 @@
+ALTER TABLE products ADD COLUMN backorder_policy VARCHAR(20) NOT NULL DEFAULT 'deny';
+ALTER TABLE products ADD COLUMN backorder_limit INT NOT NULL DEFAULT 0;
+ALTER TABLE products ADD COLUMN backordered INT NOT NULL DEFAULT 0;
+ALTER TABLE products ADD COLUMN pre_order BOOLEAN DEFAULT false;
+ALTER TABLE products ADD COLUMN available_at TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE order_items ADD COLUMN backordered_quantity INT NOT NULL DEFAULT 0;
+ALTER TABLE order_items ADD COLUMN expected_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_order_items_backordered ON order_items(product_id, id) WHERE backordered_quantity > 0;
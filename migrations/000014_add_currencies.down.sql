@@ -0,0 +1,11 @@
+ALTER TABLE order_items DROP COLUMN IF EXISTS currency;
+ALTER TABLE order_items ALTER COLUMN price TYPE DECIMAL(10,2);
+
+ALTER TABLE orders DROP COLUMN IF EXISTS exchange_rate;
+ALTER TABLE orders DROP COLUMN IF EXISTS currency;
+ALTER TABLE orders ALTER COLUMN total_amount TYPE DECIMAL(10,2);
+
+ALTER TABLE users DROP COLUMN IF EXISTS preferred_currency;
+
+DROP TABLE IF EXISTS product_prices;
+DROP TABLE IF EXISTS exchange_rates;
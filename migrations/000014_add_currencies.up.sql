@@ -0,0 +1,32 @@
+CREATE TABLE exchange_rates (
+    id SERIAL PRIMARY KEY,
+    currency CHAR(3) NOT NULL UNIQUE,
+    rate DECIMAL(18,8) NOT NULL,
+    updated_by INT REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE product_prices (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id),
+    currency CHAR(3) NOT NULL,
+    amount DECIMAL(15,3) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_product_prices_product_currency ON product_prices(product_id, currency);
+
+ALTER TABLE users ADD COLUMN preferred_currency CHAR(3);
+
+-- Orders placed so far were priced in the base currency, USD unless
+-- BASE_CURRENCY says otherwise
+ALTER TABLE orders ALTER COLUMN total_amount TYPE DECIMAL(15,3);
+ALTER TABLE orders ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE orders ALTER COLUMN currency DROP DEFAULT;
+ALTER TABLE orders ADD COLUMN exchange_rate DECIMAL(18,8) NOT NULL DEFAULT 1;
+
+ALTER TABLE order_items ALTER COLUMN price TYPE DECIMAL(15,3);
+ALTER TABLE order_items ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE order_items ALTER COLUMN currency DROP DEFAULT;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS price_changes;
+DROP TABLE IF EXISTS scheduled_prices;
+
+ALTER TABLE products DROP COLUMN IF EXISTS compare_at_price;
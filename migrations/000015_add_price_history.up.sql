@@ -0,0 +1,37 @@
+ALTER TABLE products ADD COLUMN compare_at_price DECIMAL(15,3);
+
+CREATE TABLE scheduled_prices (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id),
+    price DECIMAL(15,3) NOT NULL,
+    starts_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    ends_at TIMESTAMP WITH TIME ZONE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    original_price DECIMAL(15,3),
+    created_by INT REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_scheduled_prices_product_id ON scheduled_prices(product_id);
+CREATE INDEX idx_scheduled_prices_status ON scheduled_prices(status);
+
+CREATE TABLE price_changes (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id),
+    old_price DECIMAL(15,3),
+    new_price DECIMAL(15,3) NOT NULL,
+    compare_at_price DECIMAL(15,3),
+    source VARCHAR(20) NOT NULL,
+    schedule_id INT REFERENCES scheduled_prices(id),
+    changed_by INT REFERENCES users(id),
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_price_changes_product_changed_at ON price_changes(product_id, changed_at);
+
+-- Start the history from the current prices, earlier changes were not kept
+INSERT INTO price_changes (product_id, new_price, source, changed_at)
+SELECT id, price, 'manual', updated_at FROM products;
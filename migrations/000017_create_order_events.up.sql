@@ -0,0 +1,21 @@
+CREATE TABLE order_events (
+    id SERIAL PRIMARY KEY,
+    order_id INT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    type VARCHAR(30) NOT NULL,
+    from_status VARCHAR(20),
+    to_status VARCHAR(20),
+    message TEXT,
+    internal BOOLEAN DEFAULT FALSE,
+    actor_type VARCHAR(20) NOT NULL,
+    actor_id INT REFERENCES users(id),
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_order_events_order_id ON order_events(order_id);
+
+-- Existing orders start their history when they were placed
+INSERT INTO order_events (order_id, type, to_status, message, actor_type, actor_id, occurred_at)
+SELECT id, 'placed', 'pending', 'Order placed', 'customer', user_id, created_at
+FROM orders;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS payments;
+ALTER TABLE orders DROP COLUMN IF EXISTS payment_method;
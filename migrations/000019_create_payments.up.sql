@@ -0,0 +1,22 @@
+ALTER TABLE orders ADD COLUMN payment_method VARCHAR(100);
+
+CREATE TABLE payments (
+    id SERIAL PRIMARY KEY,
+    order_id INT NOT NULL REFERENCES orders(id),
+    provider VARCHAR(20) NOT NULL,
+    provider_ref VARCHAR(255),
+    method VARCHAR(100),
+    status VARCHAR(20) NOT NULL,
+    amount DECIMAL(15,3) NOT NULL,
+    refunded_amount DECIMAL(15,3) NOT NULL DEFAULT 0,
+    currency CHAR(3) NOT NULL,
+    failure_reason TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_payments_order_id ON payments(order_id);
+CREATE INDEX idx_payments_provider_ref ON payments(provider, provider_ref);
+
+-- An order has at most one payment in progress
+CREATE UNIQUE INDEX idx_payments_order_active ON payments(order_id) WHERE status IN ('pending', 'authorized');
@@ -0,0 +1,16 @@
+CREATE TABLE payment_webhook_events (
+    id SERIAL PRIMARY KEY,
+    provider VARCHAR(20) NOT NULL,
+    event_id VARCHAR(255) NOT NULL,
+    type VARCHAR(100),
+    payload BYTEA NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    error TEXT,
+    received_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    processed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_payment_webhook_events_provider_event ON payment_webhook_events(provider, event_id);
+CREATE INDEX idx_payment_webhook_events_status ON payment_webhook_events(status);
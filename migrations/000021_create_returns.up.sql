@@ -0,0 +1,33 @@
+CREATE TABLE returns (
+    id SERIAL PRIMARY KEY,
+    order_id INT NOT NULL REFERENCES orders(id),
+    user_id INT NOT NULL REFERENCES users(id),
+    status VARCHAR(20) NOT NULL,
+    reason TEXT NOT NULL,
+    rejection_reason TEXT,
+    refund_amount DECIMAL(15,3),
+    currency CHAR(3) NOT NULL,
+    payment_id INT REFERENCES payments(id),
+    refunded_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_returns_order_id ON returns(order_id);
+CREATE INDEX idx_returns_user_id ON returns(user_id);
+CREATE INDEX idx_returns_status ON returns(status);
+
+CREATE TABLE return_items (
+    id SERIAL PRIMARY KEY,
+    return_id INT NOT NULL REFERENCES returns(id) ON DELETE CASCADE,
+    order_item_id INT NOT NULL REFERENCES order_items(id),
+    quantity INT NOT NULL,
+    reason TEXT,
+    received_quantity INT NOT NULL DEFAULT 0,
+    restocked INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_return_items_return_id ON return_items(return_id);
+CREATE INDEX idx_return_items_order_item_id ON return_items(order_item_id);
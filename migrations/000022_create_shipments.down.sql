@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS shipment_items;
+DROP TABLE IF EXISTS shipments;
+
+ALTER TABLE order_items DROP COLUMN IF EXISTS shipped_quantity;
+
+-- Enum values cannot be dropped, partially shipped orders go back to
+-- confirmed and the value is left unused
+UPDATE orders SET status = 'confirmed' WHERE status = 'partially_shipped';
@@ -0,0 +1,36 @@
+-- Adding an enum value cannot run in the same transaction that uses it
+ALTER TYPE order_status ADD VALUE IF NOT EXISTS 'partially_shipped' AFTER 'confirmed';
+
+ALTER TABLE order_items ADD COLUMN shipped_quantity INT NOT NULL DEFAULT 0;
+
+CREATE TABLE shipments (
+    id SERIAL PRIMARY KEY,
+    order_id INT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL,
+    carrier VARCHAR(100) NOT NULL,
+    tracking_number VARCHAR(100) NOT NULL,
+    tracking_url VARCHAR(500),
+    shipped_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    delivered_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_shipments_order_id ON shipments(order_id);
+
+CREATE TABLE shipment_items (
+    id SERIAL PRIMARY KEY,
+    shipment_id INT NOT NULL REFERENCES shipments(id) ON DELETE CASCADE,
+    order_item_id INT NOT NULL REFERENCES order_items(id),
+    quantity INT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_shipment_items_shipment_id ON shipment_items(shipment_id);
+CREATE INDEX idx_shipment_items_order_item_id ON shipment_items(order_item_id);
+
+-- Orders shipped before shipments were tracked shipped all of their items
+UPDATE order_items SET shipped_quantity = quantity
+FROM orders
+WHERE orders.id = order_items.order_id AND orders.status IN ('shipped', 'delivered');
@@ -0,0 +1,8 @@
+ALTER TABLE order_items DROP COLUMN IF EXISTS discount;
+
+ALTER TABLE orders DROP COLUMN IF EXISTS free_shipping;
+ALTER TABLE orders DROP COLUMN IF EXISTS discount_total;
+ALTER TABLE orders DROP COLUMN IF EXISTS subtotal;
+
+DROP TABLE IF EXISTS order_discounts;
+DROP TABLE IF EXISTS promotions;
@@ -0,0 +1,49 @@
+CREATE TABLE promotions (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    code VARCHAR(50) UNIQUE,
+    type VARCHAR(20) NOT NULL,
+    percent_off INT NOT NULL DEFAULT 0,
+    amount_off DECIMAL(15,3),
+    buy_quantity INT NOT NULL DEFAULT 0,
+    get_quantity INT NOT NULL DEFAULT 0,
+    min_subtotal DECIMAL(15,3),
+    product_ids JSONB,
+    categories JSONB,
+    usage_limit INT NOT NULL DEFAULT 0,
+    per_user_limit INT NOT NULL DEFAULT 0,
+    usage_count INT NOT NULL DEFAULT 0,
+    starts_at TIMESTAMP WITH TIME ZONE,
+    ends_at TIMESTAMP WITH TIME ZONE,
+    active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_promotions_automatic ON promotions(id) WHERE code IS NULL AND active;
+
+CREATE TABLE order_discounts (
+    id SERIAL PRIMARY KEY,
+    order_id INT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    promotion_id INT NOT NULL REFERENCES promotions(id),
+    code VARCHAR(50),
+    name VARCHAR(255) NOT NULL,
+    type VARCHAR(20) NOT NULL,
+    amount DECIMAL(15,3) NOT NULL,
+    currency CHAR(3) NOT NULL,
+    released_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_order_discounts_order_id ON order_discounts(order_id);
+CREATE INDEX idx_order_discounts_promotion_id ON order_discounts(promotion_id);
+
+-- Orders placed before promotions were charged their subtotal
+ALTER TABLE orders ADD COLUMN subtotal DECIMAL(15,3);
+UPDATE orders SET subtotal = total_amount;
+ALTER TABLE orders ALTER COLUMN subtotal SET NOT NULL;
+ALTER TABLE orders ADD COLUMN discount_total DECIMAL(15,3) NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN free_shipping BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE order_items ADD COLUMN discount DECIMAL(15,3) NOT NULL DEFAULT 0;
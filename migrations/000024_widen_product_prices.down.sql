@@ -0,0 +1,12 @@
+-- Narrowing would round prices with a third decimal, refuse instead of
+-- changing them. The price history columns keep the width they are created
+-- with.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM products WHERE price <> ROUND(price, 2)) THEN
+        RAISE EXCEPTION 'products have prices with three decimals, DECIMAL(10,2) cannot hold them';
+    END IF;
+END
+$$;
+
+ALTER TABLE products ALTER COLUMN price TYPE DECIMAL(10,2);
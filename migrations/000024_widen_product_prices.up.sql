@@ -0,0 +1,13 @@
+-- Product prices are in the base currency, which may have three decimals
+ALTER TABLE products ALTER COLUMN price TYPE DECIMAL(15,3);
+
+-- The price history columns are created this wide, databases that added
+-- them narrower are widened too
+ALTER TABLE products ALTER COLUMN compare_at_price TYPE DECIMAL(15,3);
+
+ALTER TABLE scheduled_prices ALTER COLUMN price TYPE DECIMAL(15,3);
+ALTER TABLE scheduled_prices ALTER COLUMN original_price TYPE DECIMAL(15,3);
+
+ALTER TABLE price_changes ALTER COLUMN old_price TYPE DECIMAL(15,3);
+ALTER TABLE price_changes ALTER COLUMN new_price TYPE DECIMAL(15,3);
+ALTER TABLE price_changes ALTER COLUMN compare_at_price TYPE DECIMAL(15,3);
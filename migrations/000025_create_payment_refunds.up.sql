@@ -0,0 +1,17 @@
+CREATE TABLE payment_refunds (
+    id SERIAL PRIMARY KEY,
+    payment_id INT NOT NULL REFERENCES payments(id),
+    reference VARCHAR(100),
+    amount DECIMAL(15,3) NOT NULL,
+    currency CHAR(3) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    provider_ref VARCHAR(255),
+    failure_reason TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_payment_refunds_payment_id ON payment_refunds(payment_id);
+
+-- A payment is refunded at most once for the same reason
+CREATE UNIQUE INDEX idx_payment_refunds_payment_reference ON payment_refunds(payment_id, reference) WHERE reference <> '';
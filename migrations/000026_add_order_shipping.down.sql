@@ -0,0 +1,2 @@
+ALTER TABLE orders DROP COLUMN IF EXISTS shipping_discount;
+ALTER TABLE orders DROP COLUMN IF EXISTS shipping_amount;
@@ -0,0 +1,2 @@
+ALTER TABLE orders ADD COLUMN shipping_amount DECIMAL(15,3) NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN shipping_discount DECIMAL(15,3) NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+ALTER TABLE returns DROP COLUMN IF EXISTS refunds_shipping;
+ALTER TABLE return_items DROP COLUMN IF EXISTS refunded_quantity;
@@ -0,0 +1,13 @@
+ALTER TABLE return_items ADD COLUMN refunded_quantity INT NOT NULL DEFAULT 0;
+ALTER TABLE returns ADD COLUMN refunds_shipping BOOLEAN NOT NULL DEFAULT false;
+
+-- Returns refunded before this were refunded for the units received, or
+-- for all of them when none were sent back
+UPDATE return_items SET refunded_quantity = CASE
+    WHEN EXISTS (SELECT 1 FROM return_items received
+        WHERE received.return_id = return_items.return_id AND received.received_quantity > 0)
+    THEN return_items.received_quantity
+    ELSE return_items.quantity
+END
+FROM returns
+WHERE returns.id = return_items.return_id AND returns.status = 'refunded';
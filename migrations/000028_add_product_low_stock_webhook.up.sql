@@ -0,0 +1,4 @@
+ALTER TABLE products ADD COLUMN low_stock_webhook_at TIMESTAMP WITH TIME ZONE;
+
+-- Alerts sent so far went out by email and webhook together
+UPDATE products SET low_stock_webhook_at = low_stock_alerted_at WHERE low_stock_alerted_at IS NOT NULL;
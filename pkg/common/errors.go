@@ -8,20 +8,38 @@ import (
 type AppError struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
+	// Details carries structured context for the client, such as the
+	// statuses an order may move to after a refused transition
+	Details any `json:"details,omitempty"`
+	// Err is the cause of the error, it is not shown to the client
+	Err error `json:"-"`
 }
 
 func (e AppError) Error() string {
 	return e.Message
 }
 
+// Unwrap returns the cause of the error, so that errors.Is can tell
+// errors with the same code apart
+func (e AppError) Unwrap() error {
+	return e.Err
+}
+
 func NewAppError(err error, message string, code int) *AppError {
 	log.Println(err)
 	return &AppError{
 		Code:    code,
 		Message: message,
+		Err:     err,
 	}
 }
 
+// WithDetails attaches structured context to the error
+func (e *AppError) WithDetails(details any) *AppError {
+	e.Details = details
+	return e
+}
+
 var (
 	ErrInvalidInput       = AppError{Code: http.StatusBadRequest, Message: "invalid input"}
 	ErrUnauthorized       = AppError{Code: http.StatusUnauthorized, Message: "unauthorized"}
@@ -0,0 +1,437 @@
+// Package money represents amounts of money exactly, as an integer number
+// of the currency's minor units (cents for USD) plus its ISO 4217 code.
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidAmount    = errors.New("invalid money amount")
+	ErrUnknownCurrency  = errors.New("unknown currency")
+	ErrCurrencyMismatch = errors.New("currencies do not match")
+	ErrOverflow         = errors.New("money amount overflows")
+)
+
+// DefaultCurrency is used for amounts read without a currency
+var DefaultCurrency = "USD"
+
+// exponents lists the supported currencies and the number of minor unit
+// digits of each
+var exponents = map[string]int{
+	"USD": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"NGN": 2,
+	"CAD": 2,
+	"AUD": 2,
+	"JPY": 0,
+	"KRW": 0,
+	"KWD": 3,
+	"BHD": 3,
+}
+
+// Exponent returns the number of minor unit digits of a currency
+func Exponent(currency string) (int, bool) {
+	exp, ok := exponents[currency]
+	return exp, ok
+}
+
+// IsSupported reports whether currency is a known ISO 4217 code
+func IsSupported(currency string) bool {
+	_, ok := exponents[currency]
+	return ok
+}
+
+// Money is an exact amount in a currency's minor units
+type Money struct {
+	Amount   int64
+	Currency string
+	// exact is the decimal Scan read when it had more digits than the
+	// default currency keeps, AsCurrency reads it once the currency is known
+	exact string
+}
+
+// New returns amount minor units of currency
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// Parse reads a decimal amount such as "12.34" or "-5" in major units.
+// Digits beyond the currency's precision are rounded half away from zero,
+// the same as ROUND in Postgres.
+func Parse(s, currency string) (Money, error) {
+	exp, ok := Exponent(currency)
+	if !ok {
+		return Money{}, ErrUnknownCurrency
+	}
+
+	s = strings.TrimSpace(s)
+	negative := false
+	switch {
+	case strings.HasPrefix(s, "-"):
+		negative = true
+		s = s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" || !isDigits(whole) || !isDigits(frac) {
+		return Money{}, ErrInvalidAmount
+	}
+
+	// Keep exp fraction digits, the first dropped digit decides rounding
+	roundUp := false
+	if len(frac) > exp {
+		roundUp = frac[exp] >= '5'
+		frac = frac[:exp]
+	}
+	frac += strings.Repeat("0", exp-len(frac))
+
+	amount, ok := new(big.Int).SetString("0"+whole+frac, 10)
+	if !ok {
+		return Money{}, ErrInvalidAmount
+	}
+	if roundUp {
+		amount.Add(amount, big.NewInt(1))
+	}
+	if negative {
+		amount.Neg(amount)
+	}
+	if !amount.IsInt64() {
+		return Money{}, ErrOverflow
+	}
+	return Money{Amount: amount.Int64(), Currency: currency}, nil
+}
+
+// FromFloat converts a float amount in major units, rounding half away
+// from zero. It is meant for legacy inputs, prefer Parse.
+func FromFloat(f float64, currency string) (Money, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Money{}, ErrInvalidAmount
+	}
+	// The shortest decimal that round-trips avoids binary artefacts such
+	// as 1.005 being stored as 1.00499999...
+	return Parse(strconv.FormatFloat(f, 'f', -1, 64), currency)
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseRate reads an exchange rate such as "1550.25", it must be positive
+func ParseRate(rate string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(rate))
+	if !ok || r.Sign() <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	return r, nil
+}
+
+// Convert returns m in currency at rate units of currency per unit of m's
+// currency, rounded half away from zero to currency's minor units
+func (m Money) Convert(rate *big.Rat, currency string) (Money, error) {
+	fromExp, ok := Exponent(m.Currency)
+	if !ok {
+		return Money{}, ErrUnknownCurrency
+	}
+	toExp, ok := Exponent(currency)
+	if !ok {
+		return Money{}, ErrUnknownCurrency
+	}
+
+	// minor units in currency = amount / 10^fromExp * rate * 10^toExp
+	v := new(big.Rat).SetInt64(m.Amount)
+	v.Mul(v, rate)
+	v.Mul(v, new(big.Rat).SetFrac(pow10(toExp), pow10(fromExp)))
+
+	// Round half away from zero
+	num, den := new(big.Int).Abs(v.Num()), v.Denom()
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Lsh(r, 1).Cmp(den) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if v.Sign() < 0 {
+		q.Neg(q)
+	}
+	if !q.IsInt64() {
+		return Money{}, ErrOverflow
+	}
+	return Money{Amount: q.Int64(), Currency: currency}, nil
+}
+
+// AsCurrency reads the same decimal amount as being in currency. It fixes
+// up amounts scanned before their currency was known.
+func (m Money) AsCurrency(currency string) (Money, error) {
+	if m.exact != "" {
+		return Parse(m.exact, currency)
+	}
+	if m.Currency == currency {
+		return m, nil
+	}
+	return Parse(m.Decimal(), currency)
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// IsZero reports whether the amount is zero
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// IsPositive reports whether the amount is above zero
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+// IsNegative reports whether the amount is below zero
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// Add returns m + o, both must be in the same currency
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	sum := m.Amount + o.Amount
+	if (o.Amount > 0 && sum < m.Amount) || (o.Amount < 0 && sum > m.Amount) {
+		return Money{}, ErrOverflow
+	}
+	return Money{Amount: sum, Currency: m.Currency}, nil
+}
+
+// Sub returns m - o, both must be in the same currency
+func (m Money) Sub(o Money) (Money, error) {
+	if o.Amount == math.MinInt64 {
+		return Money{}, ErrOverflow
+	}
+	return m.Add(Money{Amount: -o.Amount, Currency: o.Currency})
+}
+
+// Mul returns m times n, such as a unit price times a quantity
+func (m Money) Mul(n int64) (Money, error) {
+	if n != 0 && m.Amount != 0 {
+		product := m.Amount * n
+		if product/n != m.Amount {
+			return Money{}, ErrOverflow
+		}
+		return Money{Amount: product, Currency: m.Currency}, nil
+	}
+	return Money{Amount: 0, Currency: m.Currency}, nil
+}
+
+// Cmp compares m and o, returning -1, 0 or 1. Both must be in the same
+// currency.
+func (m Money) Cmp(o Money) (int, error) {
+	if m.Currency != o.Currency {
+		return 0, ErrCurrencyMismatch
+	}
+	switch {
+	case m.Amount < o.Amount:
+		return -1, nil
+	case m.Amount > o.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Sum adds amounts in currency, the sum of nothing is zero
+func Sum(currency string, amounts ...Money) (Money, error) {
+	total := Money{Currency: currency}
+	for _, a := range amounts {
+		var err error
+		if total, err = total.Add(a); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// Allocate splits m into shares proportional to weights. Each share is
+// rounded towards zero and the minor units left over go one each to the
+// first shares with a weight, so the shares always add up to m. Weights
+// must not be negative and at least one must be positive.
+func (m Money) Allocate(weights ...int64) ([]Money, error) {
+	total := new(big.Int)
+	for _, w := range weights {
+		if w < 0 {
+			return nil, ErrInvalidAmount
+		}
+		total.Add(total, big.NewInt(w))
+	}
+	if total.Sign() == 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	shares := make([]Money, len(weights))
+	left := m.Amount
+	for i, w := range weights {
+		share := new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(w))
+		share.Quo(share, total)
+		shares[i] = Money{Amount: share.Int64(), Currency: m.Currency}
+		left -= shares[i].Amount
+	}
+
+	unit := int64(1)
+	if left < 0 {
+		unit = -1
+	}
+	for i := 0; left != 0; i++ {
+		if weights[i] > 0 {
+			shares[i].Amount += unit
+			left -= unit
+		}
+	}
+	return shares, nil
+}
+
+// Decimal formats the amount in major units, such as "12.34"
+func (m Money) Decimal() string {
+	exp, ok := Exponent(m.Currency)
+	if !ok {
+		exp = 2
+	}
+
+	sign := ""
+	amount := new(big.Int).SetInt64(m.Amount)
+	if amount.Sign() < 0 {
+		sign = "-"
+		amount.Neg(amount)
+	}
+	digits := amount.String()
+	if exp == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+}
+
+// String formats the amount with its currency, such as "12.34 USD"
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+type jsonMoney struct {
+	Amount    int64  `json:"amount"`
+	Currency  string `json:"currency"`
+	Formatted string `json:"formatted,omitempty"`
+}
+
+// MarshalJSON writes the minor units and currency along with the formatted
+// decimal amount for display
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonMoney{Amount: m.Amount, Currency: m.Currency, Formatted: m.Decimal()})
+}
+
+// UnmarshalJSON reads the object written by MarshalJSON, or a decimal string
+// in the default currency
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		parsed, err := Parse(s, DefaultCurrency)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	}
+
+	var v jsonMoney
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.Currency == "" {
+		v.Currency = DefaultCurrency
+	}
+	if !IsSupported(v.Currency) {
+		return ErrUnknownCurrency
+	}
+	*m = Money{Amount: v.Amount, Currency: v.Currency}
+	return nil
+}
+
+// UnmarshalParam reads a decimal amount in the default currency from a form
+// or query value, it is used by gin's form binding
+func (m *Money) UnmarshalParam(param string) error {
+	parsed, err := Parse(param, DefaultCurrency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Value stores the amount as a decimal in major units, so it fits the
+// DECIMAL price columns and compares correctly in SQL
+func (m Money) Value() (driver.Value, error) {
+	if m.exact != "" {
+		return m.exact, nil
+	}
+	return m.Decimal(), nil
+}
+
+// Scan reads a decimal column. The currency is not stored with the amount,
+// an unset currency becomes the default one. Digits the default currency
+// does not keep are not lost, AsCurrency applies them once the amount's
+// own currency is known.
+func (m *Money) Scan(src interface{}) error {
+	currency := m.Currency
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+
+	var s string
+	switch v := src.(type) {
+	case nil:
+		*m = Money{Currency: currency}
+		return nil
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return ErrInvalidAmount
+		}
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	default:
+		return fmt.Errorf("money: cannot scan %T", src)
+	}
+
+	parsed, err := Parse(s, currency)
+	if err != nil {
+		return err
+	}
+	if !fits(s, currency) {
+		parsed.exact = strings.TrimSpace(s)
+	}
+	*m = parsed
+	return nil
+}
+
+// fits reports whether currency keeps every non-zero digit of the decimal s
+func fits(s, currency string) bool {
+	exp, _ := Exponent(currency)
+	_, frac, _ := strings.Cut(strings.TrimSpace(s), ".")
+	return len(frac) <= exp || strings.Trim(frac[exp:], "0") == ""
+}
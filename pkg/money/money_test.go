@@ -0,0 +1,322 @@
+package money
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+var currencies = []string{"USD", "JPY", "KWD"}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in       string
+		currency string
+		want     int64
+		err      error
+	}{
+		{"12.34", "USD", 1234, nil},
+		{"12", "USD", 1200, nil},
+		{".5", "USD", 50, nil},
+		{"5.", "USD", 500, nil},
+		{" +1.1 ", "USD", 110, nil},
+		{"-0.01", "USD", -1, nil},
+		{"1.005", "USD", 101, nil},
+		{"1.004", "USD", 100, nil},
+		{"-1.005", "USD", -101, nil},
+		{"0.995", "USD", 100, nil},
+		{"1234.5", "JPY", 1235, nil},
+		{"-1234.5", "JPY", -1235, nil},
+		{"1.2345", "KWD", 1235, nil},
+		{"1.234", "KWD", 1234, nil},
+		{"92233720368547758.07", "USD", math.MaxInt64, nil},
+		{"92233720368547758.08", "USD", 0, ErrOverflow},
+		{"", "USD", 0, ErrInvalidAmount},
+		{".", "USD", 0, ErrInvalidAmount},
+		{"1.2.3", "USD", 0, ErrInvalidAmount},
+		{"1e3", "USD", 0, ErrInvalidAmount},
+		{"--1", "USD", 0, ErrInvalidAmount},
+		{"1", "XXX", 0, ErrUnknownCurrency},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in, tt.currency)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Parse(%q, %s) error = %v, want %v", tt.in, tt.currency, err, tt.err)
+			continue
+		}
+		if err == nil && (got.Amount != tt.want || got.Currency != tt.currency) {
+			t.Errorf("Parse(%q, %s) = %v, want %d", tt.in, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestDecimalRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	amounts := []int64{0, 1, -1, 9, 10, 99, 100, -100, 1000, math.MaxInt64, math.MinInt64 + 1}
+	for i := 0; i < 1000; i++ {
+		amounts = append(amounts, rng.Int63n(1_000_000_000)-500_000_000)
+	}
+	for _, currency := range currencies {
+		for _, amount := range amounts {
+			m := New(amount, currency)
+			got, err := Parse(m.Decimal(), currency)
+			if err != nil || got != m {
+				t.Fatalf("Parse(%q) = %v, %v, want %v", m.Decimal(), got, err, m)
+			}
+		}
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	tests := []struct {
+		m    Money
+		want string
+	}{
+		{New(1234, "USD"), "12.34"},
+		{New(5, "USD"), "0.05"},
+		{New(-5, "USD"), "-0.05"},
+		{New(0, "USD"), "0.00"},
+		{New(1234, "JPY"), "1234"},
+		{New(1234, "KWD"), "1.234"},
+		{New(-1, "KWD"), "-0.001"},
+	}
+	for _, tt := range tests {
+		if got := tt.m.Decimal(); got != tt.want {
+			t.Errorf("%d %s Decimal() = %q, want %q", tt.m.Amount, tt.m.Currency, got, tt.want)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		m        Money
+		rate     string
+		currency string
+		want     int64
+	}{
+		{New(100, "USD"), "1550.25", "NGN", 155025},
+		{New(1, "USD"), "0.5", "EUR", 1},
+		{New(-1, "USD"), "0.5", "EUR", -1},
+		{New(1, "USD"), "0.49", "EUR", 0},
+		{New(1000, "USD"), "151.456", "JPY", 1515},
+		{New(1000, "JPY"), "0.0066", "USD", 660},
+		{New(1000, "USD"), "0.30712", "KWD", 3071},
+		{New(1234, "KWD"), "1", "USD", 123},
+		{New(1235, "KWD"), "1", "USD", 124},
+	}
+	for _, tt := range tests {
+		rate, err := ParseRate(tt.rate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := tt.m.Convert(rate, tt.currency)
+		if err != nil || got != New(tt.want, tt.currency) {
+			t.Errorf("%v.Convert(%s, %s) = %v, %v, want %d", tt.m, tt.rate, tt.currency, got, err, tt.want)
+		}
+	}
+
+	if _, err := New(1, "USD").Convert(big.NewRat(1, 1), "XXX"); !errors.Is(err, ErrUnknownCurrency) {
+		t.Errorf("Convert to unknown currency error = %v", err)
+	}
+	if _, err := New(math.MaxInt64, "JPY").Convert(big.NewRat(1, 1), "KWD"); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Convert overflow error = %v", err)
+	}
+}
+
+// Converting at rate 1 into a currency with at least as many decimals loses
+// nothing, and converting back gives the amount again
+func TestConvertRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	one := big.NewRat(1, 1)
+	for i := 0; i < 1000; i++ {
+		m := New(rng.Int63n(1_000_000_000)-500_000_000, "JPY")
+		usd, err := m.Convert(one, "USD")
+		if err != nil {
+			t.Fatal(err)
+		}
+		kwd, err := usd.Convert(one, "KWD")
+		if err != nil {
+			t.Fatal(err)
+		}
+		back, err := kwd.Convert(one, "JPY")
+		if err != nil || back != m {
+			t.Fatalf("%v via USD and KWD = %v, %v", m, back, err)
+		}
+		if usd.Decimal() != m.Decimal()+".00" || kwd.Decimal() != m.Decimal()+".000" {
+			t.Fatalf("%v converted to %s and %s", m, usd.Decimal(), kwd.Decimal())
+		}
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	for _, rate := range []string{"", "0", "-1", "abc"} {
+		if _, err := ParseRate(rate); !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("ParseRate(%q) error = %v, want %v", rate, err, ErrInvalidAmount)
+		}
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		m       Money
+		weights []int64
+		want    []int64
+	}{
+		{New(100, "USD"), []int64{1, 1, 1}, []int64{34, 33, 33}},
+		{New(-100, "USD"), []int64{1, 1, 1}, []int64{-34, -33, -33}},
+		{New(5, "USD"), []int64{0, 1, 1}, []int64{0, 3, 2}},
+		{New(1000, "USD"), []int64{3000, 1000}, []int64{750, 250}},
+		{New(1, "USD"), []int64{1, 1, 1}, []int64{1, 0, 0}},
+		{New(0, "USD"), []int64{1, 2}, []int64{0, 0}},
+		{New(math.MaxInt64, "USD"), []int64{math.MaxInt64, math.MaxInt64}, []int64{math.MaxInt64/2 + 1, math.MaxInt64 / 2}},
+	}
+	for _, tt := range tests {
+		shares, err := tt.m.Allocate(tt.weights...)
+		if err != nil {
+			t.Errorf("%v.Allocate(%v) error = %v", tt.m, tt.weights, err)
+			continue
+		}
+		for i, share := range shares {
+			if share.Amount != tt.want[i] || share.Currency != tt.m.Currency {
+				t.Errorf("%v.Allocate(%v) = %v, want %v", tt.m, tt.weights, shares, tt.want)
+				break
+			}
+		}
+	}
+
+	for _, weights := range [][]int64{nil, {0, 0}, {1, -1}} {
+		if _, err := New(100, "USD").Allocate(weights...); !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("Allocate(%v) error = %v, want %v", weights, err, ErrInvalidAmount)
+		}
+	}
+}
+
+// Shares always add back up to the amount and stay within one minor unit of
+// their exact proportion
+func TestAllocatePreservesSum(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	for i := 0; i < 1000; i++ {
+		m := New(rng.Int63n(2_000_000)-1_000_000, currencies[i%len(currencies)])
+		weights := make([]int64, 1+rng.Intn(8))
+		var total int64
+		for j := range weights {
+			if rng.Intn(4) > 0 {
+				weights[j] = rng.Int63n(100_000)
+			}
+			total += weights[j]
+		}
+		if total == 0 {
+			weights[0], total = 1, 1
+		}
+
+		shares, err := m.Allocate(weights...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum, err := Sum(m.Currency, shares...)
+		if err != nil || sum != m {
+			t.Fatalf("%v.Allocate(%v) = %v, sums to %v, %v", m, weights, shares, sum, err)
+		}
+		for j, share := range shares {
+			exact := new(big.Rat).SetFrac64(m.Amount*weights[j], total)
+			diff := new(big.Rat).Sub(new(big.Rat).SetInt64(share.Amount), exact)
+			if diff.Abs(diff).Cmp(big.NewRat(1, 1)) >= 0 || weights[j] == 0 && share.Amount != 0 {
+				t.Fatalf("%v.Allocate(%v) share %d = %v, exact %s", m, weights, j, share, exact.FloatString(3))
+			}
+		}
+	}
+}
+
+func TestSum(t *testing.T) {
+	if got, err := Sum("USD"); err != nil || got != New(0, "USD") {
+		t.Errorf("Sum() = %v, %v, want 0 USD", got, err)
+	}
+	got, err := Sum("USD", New(150, "USD"), New(-50, "USD"), New(1, "USD"))
+	if err != nil || got != New(101, "USD") {
+		t.Errorf("Sum = %v, %v, want 1.01 USD", got, err)
+	}
+	if _, err := Sum("USD", New(1, "EUR")); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Sum of EUR in USD error = %v, want %v", err, ErrCurrencyMismatch)
+	}
+	if _, err := Sum("USD", New(math.MaxInt64, "USD"), New(1, "USD")); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Sum overflow error = %v, want %v", err, ErrOverflow)
+	}
+}
+
+func TestArithmeticOverflow(t *testing.T) {
+	if _, err := New(math.MaxInt64/2+1, "USD").Mul(2); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Mul overflow error = %v", err)
+	}
+	if _, err := New(0, "USD").Sub(New(math.MinInt64, "USD")); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Sub overflow error = %v", err)
+	}
+	if got, err := New(-250, "USD").Mul(3); err != nil || got != New(-750, "USD") {
+		t.Errorf("Mul = %v, %v", got, err)
+	}
+}
+
+func TestScanKeepsDigitsUntilCurrencyIsKnown(t *testing.T) {
+	tests := []struct {
+		src      interface{}
+		currency string
+		want     int64
+	}{
+		{[]byte("12.345"), "KWD", 12345},
+		{"12.345", "BHD", 12345},
+		{"-0.001", "KWD", -1},
+		{"12.340", "KWD", 12340},
+		{"12.340", "USD", 1234},
+		{"12.345", "USD", 1235},
+		{"1234.000", "JPY", 1234},
+		{float64(1.005), "KWD", 1005},
+		{int64(7), "KWD", 7000},
+	}
+	for _, tt := range tests {
+		var m Money
+		if err := m.Scan(tt.src); err != nil {
+			t.Fatalf("Scan(%v) error = %v", tt.src, err)
+		}
+		got, err := m.AsCurrency(tt.currency)
+		if err != nil || got != New(tt.want, tt.currency) {
+			t.Errorf("Scan(%v).AsCurrency(%s) = %v, %v, want %d", tt.src, tt.currency, got, err, tt.want)
+		}
+	}
+}
+
+func TestScanInDefaultCurrency(t *testing.T) {
+	var m Money
+	if err := m.Scan([]byte("12.340")); err != nil {
+		t.Fatal(err)
+	}
+	// Amounts that fit the default currency compare equal to ones built by
+	// hand
+	if m != New(1234, DefaultCurrency) {
+		t.Errorf("Scan = %#v, want 12.34 %s", m, DefaultCurrency)
+	}
+
+	if err := m.Scan(nil); err != nil || m != New(0, DefaultCurrency) {
+		t.Errorf("Scan(nil) = %v, %v", m, err)
+	}
+	if err := m.Scan("abc"); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("Scan(abc) error = %v, want %v", err, ErrInvalidAmount)
+	}
+	if err := m.Scan(true); err == nil {
+		t.Error("Scan(bool) succeeded")
+	}
+}
+
+func TestValueWritesBackWhatWasScanned(t *testing.T) {
+	var m Money
+	if err := m.Scan("12.345"); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := m.Value(); err != nil || v != "12.345" {
+		t.Errorf("Value() = %v, %v, want 12.345", v, err)
+	}
+
+	if v, err := New(12345, "KWD").Value(); err != nil || v != "12.345" {
+		t.Errorf("KWD Value() = %v, %v, want 12.345", v, err)
+	}
+}
@@ -0,0 +1,216 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/Dubjay18/ecom-api/pkg/money"
+	"github.com/Dubjay18/ecom-api/pkg/webhook"
+)
+
+// Payment methods the fake provider treats specially, any other method is
+// authorized straight away
+const (
+	FakeMethodDeclined       = "fake_declined"
+	FakeMethodRequiresAction = "fake_requires_action"
+)
+
+// Fake is an in-memory provider for tests and local development. It is
+// deterministic: intents are numbered in order and their outcome depends
+// only on the payment method. Its webhooks are signed like the ones this
+// application sends, see package webhook.
+type Fake struct {
+	mu            sync.Mutex
+	intents       map[string]*fakeIntent
+	keys          map[string]string
+	refunds       map[string]Refund
+	next          int
+	webhookSecret string
+}
+
+type fakeIntent struct {
+	Intent
+	refunded int64
+}
+
+// NewFake returns an empty fake provider verifying webhooks with
+// webhookSecret
+func NewFake(webhookSecret string) *Fake {
+	return &Fake{
+		intents:       make(map[string]*fakeIntent),
+		keys:          make(map[string]string),
+		refunds:       make(map[string]Refund),
+		webhookSecret: webhookSecret,
+	}
+}
+
+func (f *Fake) Name() string {
+	return "fake"
+}
+
+func (f *Fake) CreateIntent(ctx context.Context, req IntentRequest) (*Intent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if id, ok := f.keys[req.IdempotencyKey]; ok && req.IdempotencyKey != "" {
+		intent := f.intents[id].Intent
+		return &intent, nil
+	}
+
+	f.next++
+	intent := &fakeIntent{Intent: Intent{
+		ID:     fmt.Sprintf("fake_pi_%d", f.next),
+		Amount: req.Amount,
+	}}
+	switch req.Method {
+	case FakeMethodDeclined:
+		intent.Status = IntentFailed
+		intent.FailureReason = "Your card was declined."
+	case FakeMethodRequiresAction:
+		intent.Status = IntentRequiresAction
+		intent.ClientSecret = intent.ID + "_secret"
+	default:
+		intent.Status = IntentAuthorized
+	}
+	f.intents[intent.ID] = intent
+	if req.IdempotencyKey != "" {
+		f.keys[req.IdempotencyKey] = intent.ID
+	}
+
+	result := intent.Intent
+	return &result, nil
+}
+
+// Authorize completes the customer action of an intent, as the customer's
+// browser would
+func (f *Fake) Authorize(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	intent, ok := f.intents[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if intent.Status != IntentRequiresAction {
+		return ErrInvalidState
+	}
+	intent.Status = IntentAuthorized
+	return nil
+}
+
+func (f *Fake) GetIntent(ctx context.Context, id string) (*Intent, error) {
+	return f.update(id, "", "")
+}
+
+func (f *Fake) Capture(ctx context.Context, id string) (*Intent, error) {
+	return f.update(id, IntentAuthorized, IntentCaptured)
+}
+
+func (f *Fake) Void(ctx context.Context, id string) (*Intent, error) {
+	return f.update(id, IntentAuthorized, IntentCancelled)
+}
+
+// update moves intent id from status from to status to, it only reads the
+// intent if to is empty
+func (f *Fake) update(id string, from, to IntentStatus) (*Intent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	intent, ok := f.intents[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if to != "" {
+		if intent.Status != from {
+			return nil, ErrInvalidState
+		}
+		intent.Status = to
+	}
+	result := intent.Intent
+	return &result, nil
+}
+
+func (f *Fake) Refund(ctx context.Context, id string, amount money.Money, idempotencyKey string) (*Refund, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if refund, ok := f.refunds[idempotencyKey]; ok && idempotencyKey != "" {
+		return &refund, nil
+	}
+
+	intent, ok := f.intents[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if intent.Status != IntentCaptured {
+		return nil, ErrInvalidState
+	}
+	if amount.Currency != intent.Amount.Currency {
+		return nil, money.ErrCurrencyMismatch
+	}
+	if !amount.IsPositive() || intent.refunded+amount.Amount > intent.Amount.Amount {
+		return nil, ErrRefundTooHigh
+	}
+
+	intent.refunded += amount.Amount
+	f.next++
+	refund := Refund{ID: fmt.Sprintf("fake_re_%d", f.next), Amount: amount}
+	if idempotencyKey != "" {
+		f.refunds[idempotencyKey] = refund
+	}
+	return &refund, nil
+}
+
+// FakeEvent is the webhook payload of the fake provider. Type is
+// "intent.updated" with Intent set or "intent.refunded" with Refunded set
+// to the total refunded in minor units.
+type FakeEvent struct {
+	ID     string `json:"id"`
+	Type   string `json:"type"`
+	Intent struct {
+		ID            string       `json:"id"`
+		Status        IntentStatus `json:"status"`
+		Amount        int64        `json:"amount"`
+		Currency      string       `json:"currency"`
+		FailureReason string       `json:"failure_reason,omitempty"`
+	} `json:"intent"`
+	Refunded int64 `json:"refunded,omitempty"`
+}
+
+func (f *Fake) VerifyWebhook(payload []byte, header http.Header) error {
+	if !webhook.Verify(f.webhookSecret, payload, header.Get(webhook.SignatureHeader)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (f *Fake) ParseWebhook(payload []byte) (*Event, error) {
+	var fe FakeEvent
+	if err := json.Unmarshal(payload, &fe); err != nil {
+		return nil, err
+	}
+	currency := strings.ToUpper(fe.Intent.Currency)
+	if !money.IsSupported(currency) {
+		return nil, money.ErrUnknownCurrency
+	}
+
+	event := &Event{ID: fe.ID, Type: fe.Type, Kind: EventIgnored, IntentID: fe.Intent.ID}
+	switch fe.Type {
+	case "intent.updated":
+		event.Kind = EventIntentUpdated
+		event.Intent = &Intent{
+			ID:            fe.Intent.ID,
+			Status:        fe.Intent.Status,
+			Amount:        money.New(fe.Intent.Amount, currency),
+			FailureReason: fe.Intent.FailureReason,
+		}
+	case "intent.refunded":
+		event.Kind = EventRefunded
+		event.Refunded = money.New(fe.Refunded, currency)
+	}
+	return event, nil
+}
@@ -0,0 +1,83 @@
+// Package payment takes payments through a payment provider. Payments are
+// authorized first and captured separately, so an authorization can be
+// voided if the order no longer needs it.
+package payment
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/Dubjay18/ecom-api/pkg/money"
+)
+
+var (
+	ErrNotFound      = errors.New("payment intent not found")
+	ErrInvalidState  = errors.New("payment intent cannot do that in its current state")
+	ErrRefundTooHigh = errors.New("refund exceeds the captured amount")
+)
+
+// Provider is a payment provider such as Stripe
+type Provider interface {
+	// Name identifies the provider on stored payments
+	Name() string
+	// CreateIntent starts a payment and authorizes it if the method allows
+	// it without the customer. A declined payment is an intent with status
+	// IntentFailed, not an error.
+	CreateIntent(ctx context.Context, req IntentRequest) (*Intent, error)
+	// GetIntent returns the current state of an intent
+	GetIntent(ctx context.Context, id string) (*Intent, error)
+	// Capture collects an authorized intent
+	Capture(ctx context.Context, id string) (*Intent, error)
+	// Refund returns amount of a captured intent to the customer. Retries
+	// with the same idempotencyKey make one refund.
+	Refund(ctx context.Context, id string, amount money.Money, idempotencyKey string) (*Refund, error)
+	// Void releases an authorization that has not been captured
+	Void(ctx context.Context, id string) (*Intent, error)
+	// VerifyWebhook checks that a webhook request was signed by the
+	// provider, returning ErrInvalidSignature if not
+	VerifyWebhook(payload []byte, header http.Header) error
+	// ParseWebhook reads the event of a verified webhook payload
+	ParseWebhook(payload []byte) (*Event, error)
+}
+
+// IntentRequest describes a payment to start
+type IntentRequest struct {
+	Amount money.Money
+	// Method is a payment method of the provider, such as a Stripe
+	// PaymentMethod ID
+	Method string
+	// Reference identifies what is paid for, such as an order
+	Reference string
+	// IdempotencyKey makes retries of the same request start one payment
+	IdempotencyKey string
+}
+
+type IntentStatus string
+
+const (
+	// IntentRequiresAction waits for the customer, such as for 3-D Secure
+	IntentRequiresAction IntentStatus = "requires_action"
+	IntentAuthorized     IntentStatus = "authorized"
+	IntentCaptured       IntentStatus = "captured"
+	IntentFailed         IntentStatus = "failed"
+	IntentCancelled      IntentStatus = "cancelled"
+)
+
+// Intent is a payment at the provider
+type Intent struct {
+	ID     string
+	Status IntentStatus
+	Amount money.Money
+	// ClientSecret lets the customer's browser complete an intent that
+	// requires action
+	ClientSecret string
+	// FailureReason says why a failed intent was declined
+	FailureReason string
+}
+
+// Refund is money returned on a captured intent
+type Refund struct {
+	ID     string
+	Amount money.Money
+}
@@ -0,0 +1,294 @@
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Dubjay18/ecom-api/pkg/money"
+)
+
+const stripeAPI = "https://api.stripe.com/v1"
+
+// stripeSignatureTolerance is how old a signed webhook may be, older ones
+// are refused as possible replays
+const stripeSignatureTolerance = 5 * time.Minute
+
+// Stripe takes payments through the Stripe PaymentIntents API. Intents are
+// created with manual capture, so they are authorized until captured.
+type Stripe struct {
+	key           string
+	webhookSecret string
+	http          *http.Client
+}
+
+// NewStripe returns a Stripe provider using secret key key, webhooks are
+// verified with the endpoint's signing secret webhookSecret
+func NewStripe(key, webhookSecret string) *Stripe {
+	return &Stripe{key: key, webhookSecret: webhookSecret, http: &http.Client{Timeout: 30 * time.Second}}
+}
+
+func (s *Stripe) Name() string {
+	return "stripe"
+}
+
+// stripeIntent is the part of a Stripe PaymentIntent the provider reads
+type stripeIntent struct {
+	ID               string `json:"id"`
+	Status           string `json:"status"`
+	Amount           int64  `json:"amount"`
+	Currency         string `json:"currency"`
+	ClientSecret     string `json:"client_secret"`
+	LastPaymentError *struct {
+		Message string `json:"message"`
+	} `json:"last_payment_error"`
+}
+
+type stripeError struct {
+	Error struct {
+		Type          string        `json:"type"`
+		Code          string        `json:"code"`
+		Message       string        `json:"message"`
+		PaymentIntent *stripeIntent `json:"payment_intent"`
+	} `json:"error"`
+}
+
+func (s *Stripe) CreateIntent(ctx context.Context, req IntentRequest) (*Intent, error) {
+	form := url.Values{}
+	form.Set("amount", strconv.FormatInt(req.Amount.Amount, 10))
+	form.Set("currency", strings.ToLower(req.Amount.Currency))
+	form.Set("capture_method", "manual")
+	form.Set("metadata[reference]", req.Reference)
+	form.Set("automatic_payment_methods[enabled]", "true")
+	if strings.HasPrefix(req.Method, "pm_") {
+		// A saved payment method is confirmed now, others are confirmed by
+		// the customer's browser with the client secret
+		form.Set("payment_method", req.Method)
+		form.Set("confirm", "true")
+		form.Set("automatic_payment_methods[allow_redirects]", "never")
+	}
+	return s.intent(ctx, http.MethodPost, "/payment_intents", form, req.IdempotencyKey)
+}
+
+func (s *Stripe) GetIntent(ctx context.Context, id string) (*Intent, error) {
+	return s.intent(ctx, http.MethodGet, "/payment_intents/"+url.PathEscape(id), nil, "")
+}
+
+func (s *Stripe) Capture(ctx context.Context, id string) (*Intent, error) {
+	return s.intent(ctx, http.MethodPost, "/payment_intents/"+url.PathEscape(id)+"/capture", url.Values{}, "capture-"+id)
+}
+
+func (s *Stripe) Void(ctx context.Context, id string) (*Intent, error) {
+	return s.intent(ctx, http.MethodPost, "/payment_intents/"+url.PathEscape(id)+"/cancel", url.Values{}, "cancel-"+id)
+}
+
+func (s *Stripe) Refund(ctx context.Context, id string, amount money.Money, idempotencyKey string) (*Refund, error) {
+	form := url.Values{}
+	form.Set("payment_intent", id)
+	form.Set("amount", strconv.FormatInt(amount.Amount, 10))
+
+	var refund struct {
+		ID string `json:"id"`
+	}
+	if err := s.do(ctx, http.MethodPost, "/refunds", form, idempotencyKey, &refund); err != nil {
+		return nil, err
+	}
+	return &Refund{ID: refund.ID, Amount: amount}, nil
+}
+
+func (s *Stripe) intent(ctx context.Context, method, path string, form url.Values, idempotencyKey string) (*Intent, error) {
+	var pi stripeIntent
+	err := s.do(ctx, method, path, form, idempotencyKey, &pi)
+	if serr, ok := err.(*stripeAPIError); ok && serr.intent != nil {
+		// Declines are reported as errors carrying the failed intent
+		pi = *serr.intent
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pi.toIntent()
+}
+
+// stripeAPIError is an error response of the Stripe API
+type stripeAPIError struct {
+	status  int
+	code    string
+	message string
+	intent  *stripeIntent
+}
+
+func (e *stripeAPIError) Error() string {
+	return fmt.Sprintf("stripe: %s (status %d, code %s)", e.message, e.status, e.code)
+}
+
+func (s *Stripe) do(ctx context.Context, method, path string, form url.Values, idempotencyKey string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, stripeAPI+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(s.key, "")
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var serr stripeError
+		if err := json.NewDecoder(resp.Body).Decode(&serr); err != nil {
+			return fmt.Errorf("stripe: %s %s responded with status %d", method, path, resp.StatusCode)
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			return ErrNotFound
+		}
+		apiErr := &stripeAPIError{
+			status:  resp.StatusCode,
+			code:    serr.Error.Code,
+			message: serr.Error.Message,
+		}
+		if serr.Error.Type == "card_error" {
+			apiErr.intent = serr.Error.PaymentIntent
+		}
+		if serr.Error.Code == "payment_intent_unexpected_state" {
+			return fmt.Errorf("%w: %v", ErrInvalidState, apiErr)
+		}
+		return apiErr
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (pi *stripeIntent) toIntent() (*Intent, error) {
+	currency := strings.ToUpper(pi.Currency)
+	if !money.IsSupported(currency) {
+		return nil, money.ErrUnknownCurrency
+	}
+	intent := &Intent{
+		ID:           pi.ID,
+		Amount:       money.New(pi.Amount, currency),
+		ClientSecret: pi.ClientSecret,
+	}
+	switch pi.Status {
+	case "requires_capture":
+		intent.Status = IntentAuthorized
+	case "succeeded":
+		intent.Status = IntentCaptured
+	case "canceled":
+		intent.Status = IntentCancelled
+	case "requires_payment_method":
+		// A fresh intent waits for a method, a declined one for another
+		if pi.LastPaymentError != nil {
+			intent.Status = IntentFailed
+			intent.FailureReason = pi.LastPaymentError.Message
+		} else {
+			intent.Status = IntentRequiresAction
+		}
+	default:
+		// requires_confirmation, requires_action and processing
+		intent.Status = IntentRequiresAction
+	}
+	return intent, nil
+}
+
+// VerifyWebhook checks the Stripe-Signature header, which holds a timestamp
+// and one or more HMAC-SHA256 signatures of "timestamp.payload"
+func (s *Stripe) VerifyWebhook(payload []byte, header http.Header) error {
+	if s.webhookSecret == "" {
+		return ErrInvalidSignature
+	}
+
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header.Get("Stripe-Signature"), ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+	if age := time.Since(time.Unix(seconds, 0)); age > stripeSignatureTolerance || age < -stripeSignatureTolerance {
+		return ErrInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(s.webhookSecret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+type stripeEvent struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Data struct {
+		Object json.RawMessage `json:"object"`
+	} `json:"data"`
+}
+
+// ParseWebhook maps PaymentIntent events to intent updates and
+// charge.refunded to refunds, other events are ignored
+func (s *Stripe) ParseWebhook(payload []byte) (*Event, error) {
+	var se stripeEvent
+	if err := json.Unmarshal(payload, &se); err != nil {
+		return nil, err
+	}
+	event := &Event{ID: se.ID, Type: se.Type, Kind: EventIgnored}
+
+	switch {
+	case strings.HasPrefix(se.Type, "payment_intent."):
+		var pi stripeIntent
+		if err := json.Unmarshal(se.Data.Object, &pi); err != nil {
+			return nil, err
+		}
+		intent, err := pi.toIntent()
+		if err != nil {
+			return nil, err
+		}
+		event.Kind = EventIntentUpdated
+		event.IntentID = intent.ID
+		event.Intent = intent
+	case se.Type == "charge.refunded":
+		var charge struct {
+			PaymentIntent  string `json:"payment_intent"`
+			AmountRefunded int64  `json:"amount_refunded"`
+			Currency       string `json:"currency"`
+		}
+		if err := json.Unmarshal(se.Data.Object, &charge); err != nil {
+			return nil, err
+		}
+		currency := strings.ToUpper(charge.Currency)
+		if !money.IsSupported(currency) {
+			return nil, money.ErrUnknownCurrency
+		}
+		event.Kind = EventRefunded
+		event.IntentID = charge.PaymentIntent
+		event.Refunded = money.New(charge.AmountRefunded, currency)
+	}
+	return event, nil
+}
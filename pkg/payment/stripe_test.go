@@ -0,0 +1,184 @@
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dubjay18/ecom-api/pkg/money"
+)
+
+const testWebhookSecret = "whsec_test"
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	payload, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payload
+}
+
+// stripeSignature builds the Stripe-Signature header for payload signed at
+// ts with secret
+func stripeSignature(secret string, ts time.Time, payload []byte) http.Header {
+	timestamp := strconv.FormatInt(ts.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	header := http.Header{}
+	header.Set("Stripe-Signature", fmt.Sprintf("t=%s,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil))))
+	return header
+}
+
+func TestStripeVerifyWebhook(t *testing.T) {
+	payload := readFixture(t, "stripe_payment_intent_processing.json")
+	tampered := append([]byte{}, payload...)
+	tampered[len(tampered)-3] = ' '
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		secret  string
+		payload []byte
+		header  http.Header
+		valid   bool
+	}{
+		{"valid signature", testWebhookSecret, payload, stripeSignature(testWebhookSecret, now, payload), true},
+		{"tampered body", testWebhookSecret, tampered, stripeSignature(testWebhookSecret, now, payload), false},
+		{"other secret", testWebhookSecret, payload, stripeSignature("whsec_other", now, payload), false},
+		{"expired timestamp", testWebhookSecret, payload, stripeSignature(testWebhookSecret, now.Add(-stripeSignatureTolerance-time.Minute), payload), false},
+		{"timestamp in the future", testWebhookSecret, payload, stripeSignature(testWebhookSecret, now.Add(stripeSignatureTolerance+time.Minute), payload), false},
+		{"within tolerance", testWebhookSecret, payload, stripeSignature(testWebhookSecret, now.Add(-stripeSignatureTolerance+time.Minute), payload), true},
+		{"no header", testWebhookSecret, payload, http.Header{}, false},
+		{"no secret configured", "", payload, stripeSignature("", now, payload), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewStripe("sk_test", tt.secret).VerifyWebhook(tt.payload, tt.header)
+			if tt.valid && err != nil {
+				t.Fatalf("VerifyWebhook = %v, want nil", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidSignature) {
+				t.Fatalf("VerifyWebhook = %v, want %v", err, ErrInvalidSignature)
+			}
+		})
+	}
+}
+
+// A rolled secret is sent with a signature for each secret, any of them may
+// match
+func TestStripeVerifyWebhookAnySignature(t *testing.T) {
+	payload := readFixture(t, "stripe_payment_intent_processing.json")
+	ts := time.Now()
+	timestamp, valid, _ := strings.Cut(stripeSignature(testWebhookSecret, ts, payload).Get("Stripe-Signature"), ",")
+	header := http.Header{}
+	header.Set("Stripe-Signature", fmt.Sprintf("%s,v1=%064d,%s", timestamp, 0, valid))
+	if err := NewStripe("sk_test", testWebhookSecret).VerifyWebhook(payload, header); err != nil {
+		t.Fatalf("VerifyWebhook = %v, want nil", err)
+	}
+}
+
+func TestStripeParseWebhook(t *testing.T) {
+	stripe := NewStripe("sk_test", testWebhookSecret)
+	tests := []struct {
+		fixture string
+		want    Event
+	}{
+		{"stripe_payment_intent_processing.json", Event{
+			ID: "evt_1PmProcessing", Type: "payment_intent.processing", Kind: EventIntentUpdated, IntentID: "pi_3PmTest",
+			Intent: &Intent{ID: "pi_3PmTest", Status: IntentRequiresAction, Amount: money.New(5998, "USD"), ClientSecret: "pi_3PmTest_secret_abc"},
+		}},
+		{"stripe_payment_intent_failed.json", Event{
+			ID: "evt_1PmFailed", Type: "payment_intent.payment_failed", Kind: EventIntentUpdated, IntentID: "pi_3PmTest",
+			Intent: &Intent{ID: "pi_3PmTest", Status: IntentFailed, Amount: money.New(5998, "USD"), FailureReason: "Your card was declined."},
+		}},
+		{"stripe_charge_refunded.json", Event{
+			ID: "evt_1PmRefunded", Type: "charge.refunded", Kind: EventRefunded, IntentID: "pi_3PmTest", Refunded: money.New(1500, "USD"),
+		}},
+		{"stripe_customer_created.json", Event{ID: "evt_1PmCustomer", Type: "customer.created", Kind: EventIgnored}},
+	}
+	for _, tt := range tests {
+		event, err := stripe.ParseWebhook(readFixture(t, tt.fixture))
+		if err != nil {
+			t.Fatalf("%s: ParseWebhook = %v", tt.fixture, err)
+		}
+		if (event.Intent == nil) != (tt.want.Intent == nil) || event.Intent != nil && *event.Intent != *tt.want.Intent {
+			t.Errorf("%s: intent = %+v, want %+v", tt.fixture, event.Intent, tt.want.Intent)
+		}
+		event.Intent, tt.want.Intent = nil, nil
+		if *event != tt.want {
+			t.Errorf("%s: event = %+v, want %+v", tt.fixture, *event, tt.want)
+		}
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestStripeRefundSendsIdempotencyKey(t *testing.T) {
+	var keys []string
+	stripe := NewStripe("sk_test", testWebhookSecret)
+	stripe.http = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		keys = append(keys, req.Header.Get("Idempotency-Key"))
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"id": "re_3PmTest"}`)),
+			Header:     http.Header{},
+		}, nil
+	})}
+
+	for i := 0; i < 2; i++ {
+		refund, err := stripe.Refund(context.Background(), "pi_3PmTest", money.New(1500, "USD"), "refund-7")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if refund.ID != "re_3PmTest" {
+			t.Errorf("refund ID = %q, want re_3PmTest", refund.ID)
+		}
+	}
+	if len(keys) != 2 || keys[0] != "refund-7" || keys[1] != "refund-7" {
+		t.Errorf("idempotency keys = %q, want refund-7 on both attempts", keys)
+	}
+}
+
+func TestFakeRefundIsIdempotent(t *testing.T) {
+	fake := NewFake(testWebhookSecret)
+	ctx := context.Background()
+	intent, err := fake.CreateIntent(ctx, IntentRequest{Amount: money.New(5000, "USD")})
+	if err == nil {
+		_, err = fake.Capture(ctx, intent.ID)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := fake.Refund(ctx, intent.ID, money.New(3000, "USD"), "refund-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := fake.Refund(ctx, intent.ID, money.New(3000, "USD"), "refund-1")
+	if err != nil {
+		t.Fatalf("retry with the same key = %v, want the first refund", err)
+	}
+	if *again != *first {
+		t.Errorf("retry = %+v, want %+v", *again, *first)
+	}
+	if _, err := fake.Refund(ctx, intent.ID, money.New(3000, "USD"), "refund-2"); !errors.Is(err, ErrRefundTooHigh) {
+		t.Errorf("second refund of 30 out of 50 = %v, want ErrRefundTooHigh", err)
+	}
+}
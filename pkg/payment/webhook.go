@@ -0,0 +1,35 @@
+package payment
+
+import (
+	"errors"
+
+	"github.com/Dubjay18/ecom-api/pkg/money"
+)
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+type EventKind string
+
+const (
+	// EventIntentUpdated carries the new state of an intent
+	EventIntentUpdated EventKind = "intent_updated"
+	// EventRefunded carries the total refunded on an intent so far
+	EventRefunded EventKind = "refunded"
+	// EventIgnored is an event the application does not act on
+	EventIgnored EventKind = "ignored"
+)
+
+// Event is a webhook event sent by a provider
+type Event struct {
+	// ID is the provider's event ID, deliveries of the same event share it
+	ID string
+	// Type is the provider's name for the event
+	Type string
+	Kind EventKind
+	// IntentID is the intent the event is about
+	IntentID string
+	// Intent is set on EventIntentUpdated
+	Intent *Intent
+	// Refunded is set on EventRefunded
+	Refunded money.Money
+}
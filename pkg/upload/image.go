@@ -1,31 +1,75 @@
 package upload
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"mime/multipart"
 
 	"github.com/cloudinary/cloudinary-go"
 	"github.com/cloudinary/cloudinary-go/api/uploader"
 )
 
-func UploadImage(ctx context.Context, file *multipart.FileHeader, cloudName, apiKey, apiSecret string) (string, error) {
-	cld, err := cloudinary.NewFromParams(cloudName, apiKey, apiSecret)
+// ImageURLs holds the uploaded URL of each image rendition
+type ImageURLs struct {
+	Thumbnail string
+	Medium    string
+	Large     string
+}
+
+// assetStore uploads and deletes assets, such as Cloudinary's upload API
+type assetStore interface {
+	Upload(ctx context.Context, file interface{}, params uploader.UploadParams) (*uploader.UploadResult, error)
+	Destroy(ctx context.Context, params uploader.DestroyParams) (*uploader.DestroyResult, error)
+}
+
+// UploadImage validates and processes an image, then uploads each rendition
+func UploadImage(ctx context.Context, file *multipart.FileHeader, cloudName, apiKey, apiSecret string) (*ImageURLs, error) {
+	processed, err := ProcessImage(file)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// Open file
-	src, err := file.Open()
+	cld, err := cloudinary.NewFromParams(cloudName, apiKey, apiSecret)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	defer src.Close()
+	return uploadRenditions(ctx, &cld.Upload, processed)
+}
 
-	// Upload file to Cloudinary
-	uploadResult, err := cld.Upload.Upload(ctx, src, uploader.UploadParams{})
-	if err != nil {
-		return "", err
+// uploadRenditions uploads each rendition of an image. If one fails the
+// ones already uploaded are deleted, so a failed upload leaves none behind.
+func uploadRenditions(ctx context.Context, store assetStore, processed *ProcessedImage) (*ImageURLs, error) {
+	urls := make(map[string]string, len(Renditions))
+	var uploaded []string
+	for _, r := range Renditions {
+		// Upload file to Cloudinary
+		uploadResult, err := store.Upload(ctx, bytes.NewReader(processed.Renditions[r.Name]), uploader.UploadParams{})
+		if err == nil && uploadResult.Error.Message != "" {
+			err = errors.New(uploadResult.Error.Message)
+		}
+		if err != nil {
+			// Clean up even if the request that uploaded them has ended
+			cleanup := context.WithoutCancel(ctx)
+			for _, publicID := range uploaded {
+				if _, derr := store.Destroy(cleanup, uploader.DestroyParams{PublicID: publicID}); derr != nil {
+					err = errors.Join(err, derr)
+				}
+			}
+			return nil, err
+		}
+		uploaded = append(uploaded, uploadResult.PublicID)
+		urls[r.Name] = uploadResult.SecureURL
 	}
 
-	return uploadResult.SecureURL, nil
+	return &ImageURLs{
+		Thumbnail: urls[RenditionThumbnail.Name],
+		Medium:    urls[RenditionMedium.Name],
+		Large:     urls[RenditionLarge.Name],
+	}, nil
+}
+
+// IsValidationError reports whether err was caused by a rejected image
+func IsValidationError(err error) bool {
+	return err == ErrUnsupportedType || err == ErrFileTooLarge || err == ErrDimensionsTooLarge
 }
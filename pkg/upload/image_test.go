@@ -0,0 +1,77 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/cloudinary/cloudinary-go/api/uploader"
+)
+
+// memStore uploads into memory, failing the upload numbered failAt
+type memStore struct {
+	failAt    int
+	uploads   int
+	assets    map[string]bool
+	destroyed []string
+}
+
+func (s *memStore) Upload(_ context.Context, _ interface{}, _ uploader.UploadParams) (*uploader.UploadResult, error) {
+	s.uploads++
+	if s.uploads == s.failAt {
+		return nil, errors.New("upload failed")
+	}
+	id := fmt.Sprintf("asset-%d", s.uploads)
+	s.assets[id] = true
+	return &uploader.UploadResult{PublicID: id, SecureURL: "https://cdn.example.com/" + id}, nil
+}
+
+func (s *memStore) Destroy(_ context.Context, params uploader.DestroyParams) (*uploader.DestroyResult, error) {
+	s.destroyed = append(s.destroyed, params.PublicID)
+	delete(s.assets, params.PublicID)
+	return &uploader.DestroyResult{Result: "ok"}, nil
+}
+
+func TestUploadRenditions(t *testing.T) {
+	processed := &ProcessedImage{ContentType: "image/png", Renditions: map[string][]byte{}}
+	for _, r := range Renditions {
+		processed.Renditions[r.Name] = []byte(r.Name)
+	}
+
+	tests := []struct {
+		name   string
+		failAt int
+		// left is how many assets stay uploaded
+		left int
+	}{
+		{name: "all uploaded", left: 3},
+		{name: "first fails", failAt: 1},
+		{name: "second fails", failAt: 2},
+		{name: "last fails", failAt: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &memStore{failAt: tt.failAt, assets: map[string]bool{}}
+			urls, err := uploadRenditions(context.Background(), store, processed)
+			if tt.failAt != 0 {
+				if err == nil {
+					t.Fatal("upload succeeded, want an error")
+				}
+				if len(store.destroyed) != tt.failAt-1 {
+					t.Errorf("destroyed %v, want the %d uploaded before the failure", store.destroyed, tt.failAt-1)
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if urls.Thumbnail == "" || urls.Medium == "" || urls.Large == "" {
+					t.Errorf("urls = %+v, want one for each rendition", urls)
+				}
+			}
+			if len(store.assets) != tt.left {
+				t.Errorf("%d assets left, want %d", len(store.assets), tt.left)
+			}
+		})
+	}
+}
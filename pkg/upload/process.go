@@ -0,0 +1,201 @@
+package upload
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+)
+
+var (
+	ErrUnsupportedType    = errors.New("unsupported image type, allowed types are jpeg, png and gif")
+	ErrFileTooLarge       = errors.New("image file is too large")
+	ErrDimensionsTooLarge = errors.New("image dimensions are too large")
+)
+
+const (
+	// MaxFileSize is the largest image upload accepted, in bytes
+	MaxFileSize = 10 << 20
+	// MaxDimension is the largest width or height accepted, in pixels
+	MaxDimension = 6000
+)
+
+// allowedTypes maps sniffed content types to the format we re-encode to
+var allowedTypes = map[string]string{
+	"image/jpeg": "jpeg",
+	"image/png":  "png",
+	"image/gif":  "png",
+}
+
+// Rendition describes a resized copy of an uploaded image
+type Rendition struct {
+	Name    string
+	MaxSize int
+}
+
+var (
+	RenditionThumbnail = Rendition{Name: "thumbnail", MaxSize: 150}
+	RenditionMedium    = Rendition{Name: "medium", MaxSize: 600}
+	RenditionLarge     = Rendition{Name: "large", MaxSize: 1200}
+)
+
+// Renditions lists every rendition generated for an uploaded image
+var Renditions = []Rendition{RenditionThumbnail, RenditionMedium, RenditionLarge}
+
+// ProcessedImage holds the encoded bytes of each rendition keyed by name
+type ProcessedImage struct {
+	ContentType string
+	Renditions  map[string][]byte
+}
+
+// ProcessImage validates an uploaded image and generates its renditions.
+// Images are decoded and re-encoded, which drops EXIF and any other metadata.
+// JPEGs are turned as their EXIF orientation says first, so they are not
+// shown sideways once it is gone.
+func ProcessImage(file *multipart.FileHeader) (*ProcessedImage, error) {
+	if file.Size > MaxFileSize {
+		return nil, ErrFileTooLarge
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(io.LimitReader(src, MaxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxFileSize {
+		return nil, ErrFileTooLarge
+	}
+
+	// Sniff the content rather than trusting the client supplied header
+	format, ok := allowedTypes[http.DetectContentType(data)]
+	if !ok {
+		return nil, ErrUnsupportedType
+	}
+
+	// Check dimensions before decoding the full image
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+	if cfg.Width > MaxDimension || cfg.Height > MaxDimension {
+		return nil, ErrDimensionsTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+	if format == "jpeg" {
+		img = orient(img, exifOrientation(data))
+	}
+
+	processed := &ProcessedImage{
+		ContentType: "image/" + format,
+		Renditions:  make(map[string][]byte, len(Renditions)),
+	}
+	for _, r := range Renditions {
+		buf, err := encode(resize(img, r.MaxSize), format)
+		if err != nil {
+			return nil, err
+		}
+		processed.Renditions[r.Name] = buf
+	}
+
+	return processed, nil
+}
+
+func encode(img image.Image, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+	default:
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// resize scales img down so neither side exceeds maxSize, keeping the aspect
+// ratio. Images that already fit are copied as-is. Each destination pixel is
+// the average of the source pixels it covers.
+func resize(img image.Image, maxSize int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxSize && h <= maxSize {
+		return copyImage(img)
+	}
+
+	dw, dh := maxSize, maxSize
+	if w > h {
+		dh = h * maxSize / w
+	} else {
+		dw = w * maxSize / h
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		sy0 := b.Min.Y + y*h/dh
+		sy1 := b.Min.Y + (y+1)*h/dh
+		if sy1 <= sy0 {
+			sy1 = sy0 + 1
+		}
+		for x := 0; x < dw; x++ {
+			sx0 := b.Min.X + x*w/dw
+			sx1 := b.Min.X + (x+1)*w/dw
+			if sx1 <= sx0 {
+				sx1 = sx0 + 1
+			}
+
+			var r, g, bl, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					bl += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(bl / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
+
+func copyImage(img image.Image) image.Image {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			dst.Set(x, y, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
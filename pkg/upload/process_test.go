@@ -0,0 +1,308 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"mime/multipart"
+	"net/textproto"
+	"testing"
+)
+
+// fileHeader returns an uploaded file holding data, sent with contentType
+func fileHeader(t *testing.T, contentType string, data []byte) *multipart.FileHeader {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="file"; filename="upload"`)
+	header.Set("Content-Type", contentType)
+	part, err := w.CreatePart(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := part.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	form, err := multipart.NewReader(&body, w.Boundary()).ReadForm(64 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { form.RemoveAll() })
+	return form.File["file"][0]
+}
+
+// halves returns a w by h image, red on its left half and blue on its right
+func halves(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= w/2 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodeGIF(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := gif.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withExif inserts an EXIF segment with orientation and a camera make into
+// a JPEG, right after its start of image marker
+func withExif(jpg []byte, orientation uint16) []byte {
+	var tiff bytes.Buffer
+	tiff.WriteString("MM")
+	binary.Write(&tiff, binary.BigEndian, uint16(42))
+	binary.Write(&tiff, binary.BigEndian, uint32(8))
+	// Two entries: the orientation and the make, kept after the IFD
+	binary.Write(&tiff, binary.BigEndian, uint16(2))
+	binary.Write(&tiff, binary.BigEndian, []uint16{0x0112, 3})
+	binary.Write(&tiff, binary.BigEndian, uint32(1))
+	binary.Write(&tiff, binary.BigEndian, []uint16{orientation, 0})
+	binary.Write(&tiff, binary.BigEndian, []uint16{0x010F, 2})
+	binary.Write(&tiff, binary.BigEndian, uint32(11))
+	binary.Write(&tiff, binary.BigEndian, uint32(8+2+2*12+4))
+	binary.Write(&tiff, binary.BigEndian, uint32(0))
+	tiff.WriteString("SecretCam\x00\x00")
+
+	segment := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	var out bytes.Buffer
+	out.Write(jpg[:2])
+	out.Write([]byte{0xFF, 0xE1})
+	binary.Write(&out, binary.BigEndian, uint16(len(segment)+2))
+	out.Write(segment)
+	out.Write(jpg[2:])
+	return out.Bytes()
+}
+
+func TestProcessImageAllowlist(t *testing.T) {
+	img := halves(20, 10)
+	tests := []struct {
+		name        string
+		contentType string
+		data        []byte
+		want        string
+		err         error
+	}{
+		{name: "png", contentType: "image/png", data: encodePNG(t, img), want: "image/png"},
+		{name: "jpeg", contentType: "image/jpeg", data: encodeJPEG(t, img), want: "image/jpeg"},
+		{name: "gif is re-encoded as png", contentType: "image/gif", data: encodeGIF(t, img), want: "image/png"},
+		{name: "image sent as text", contentType: "text/plain", data: encodePNG(t, img), want: "image/png"},
+		{name: "text sent as png", contentType: "image/png", data: []byte("<html><script>alert(1)</script></html>"), err: ErrUnsupportedType},
+		{name: "pdf sent as jpeg", contentType: "image/jpeg", data: []byte("%PDF-1.4\n1 0 obj\n<<>>\nendobj\n"), err: ErrUnsupportedType},
+		{name: "bmp", contentType: "image/bmp", data: append([]byte("BM"), make([]byte, 64)...), err: ErrUnsupportedType},
+		{name: "truncated png", contentType: "image/png", data: encodePNG(t, img)[:40], err: ErrUnsupportedType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processed, err := ProcessImage(fileHeader(t, tt.contentType, tt.data))
+			if err != tt.err {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if err == nil && processed.ContentType != tt.want {
+				t.Errorf("content type = %q, want %q", processed.ContentType, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessImageFileSize(t *testing.T) {
+	small := encodePNG(t, halves(20, 10))
+	// PNG data is sniffed from its first bytes, the rest is padding
+	padded := func(size int) []byte {
+		return append(append([]byte{}, small...), make([]byte, size-len(small))...)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		// size is the size the upload claims, the data's own when zero
+		size int64
+		err  error
+	}{
+		{name: "under the cap", data: small},
+		{name: "over the cap", data: padded(MaxFileSize + 1), err: ErrFileTooLarge},
+		{name: "over the cap claiming to be small", data: padded(MaxFileSize + 1), size: 100, err: ErrFileTooLarge},
+		{name: "claiming to be over the cap", data: small, size: MaxFileSize + 1, err: ErrFileTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := fileHeader(t, "image/png", tt.data)
+			if tt.size != 0 {
+				file.Size = tt.size
+			}
+			if _, err := ProcessImage(file); err != tt.err {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestProcessImageDimensions(t *testing.T) {
+	tests := []struct {
+		name string
+		w, h int
+		err  error
+	}{
+		{name: "at the cap", w: MaxDimension, h: 1},
+		{name: "too wide", w: MaxDimension + 1, h: 1, err: ErrDimensionsTooLarge},
+		{name: "too tall", w: 1, h: MaxDimension + 1, err: ErrDimensionsTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := encodePNG(t, image.NewGray(image.Rect(0, 0, tt.w, tt.h)))
+			if _, err := ProcessImage(fileHeader(t, "image/png", data)); err != tt.err {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestProcessImageRenditions(t *testing.T) {
+	tests := []struct {
+		name string
+		w, h int
+		want map[string]image.Point
+	}{
+		{
+			name: "landscape",
+			w:    1600, h: 800,
+			want: map[string]image.Point{"thumbnail": {150, 75}, "medium": {600, 300}, "large": {1200, 600}},
+		},
+		{
+			name: "portrait",
+			w:    700, h: 1400,
+			want: map[string]image.Point{"thumbnail": {75, 150}, "medium": {300, 600}, "large": {600, 1200}},
+		},
+		{
+			name: "smaller than every rendition",
+			w:    100, h: 50,
+			want: map[string]image.Point{"thumbnail": {100, 50}, "medium": {100, 50}, "large": {100, 50}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processed, err := ProcessImage(fileHeader(t, "image/png", encodePNG(t, halves(tt.w, tt.h))))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(processed.Renditions) != len(Renditions) {
+				t.Fatalf("%d renditions, want %d", len(processed.Renditions), len(Renditions))
+			}
+			for name, want := range tt.want {
+				cfg, err := png.DecodeConfig(bytes.NewReader(processed.Renditions[name]))
+				if err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				if got := (image.Point{cfg.Width, cfg.Height}); got != want {
+					t.Errorf("%s is %v, want %v", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRenditionSizes(t *testing.T) {
+	want := map[string]int{"thumbnail": 150, "medium": 600, "large": 1200}
+	if len(Renditions) != len(want) {
+		t.Fatalf("%d renditions, want %d", len(Renditions), len(want))
+	}
+	for _, r := range Renditions {
+		if r.MaxSize != want[r.Name] {
+			t.Errorf("%s is %dpx, want %dpx", r.Name, r.MaxSize, want[r.Name])
+		}
+	}
+}
+
+func TestProcessImageStripsExif(t *testing.T) {
+	data := withExif(encodeJPEG(t, halves(40, 20)), 1)
+	if exifOrientation(data) != 1 || !bytes.Contains(data, []byte("SecretCam")) {
+		t.Fatal("test image has no EXIF")
+	}
+
+	processed, err := ProcessImage(fileHeader(t, "image/jpeg", data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, rendition := range processed.Renditions {
+		if bytes.Contains(rendition, []byte("Exif\x00\x00")) || bytes.Contains(rendition, []byte("SecretCam")) {
+			t.Errorf("%s kept the EXIF data", name)
+		}
+	}
+}
+
+func TestProcessImageAppliesExifOrientation(t *testing.T) {
+	// The image is stored 40 wide and 20 tall, red on the left
+	tests := []struct {
+		orientation uint16
+		size        image.Point
+		// red is a point of the shown image that is on the red half
+		red, blue image.Point
+	}{
+		{orientation: 1, size: image.Point{40, 20}, red: image.Point{5, 10}, blue: image.Point{35, 10}},
+		{orientation: 2, size: image.Point{40, 20}, red: image.Point{35, 10}, blue: image.Point{5, 10}},
+		{orientation: 3, size: image.Point{40, 20}, red: image.Point{35, 10}, blue: image.Point{5, 10}},
+		{orientation: 4, size: image.Point{40, 20}, red: image.Point{5, 10}, blue: image.Point{35, 10}},
+		{orientation: 5, size: image.Point{20, 40}, red: image.Point{10, 5}, blue: image.Point{10, 35}},
+		{orientation: 6, size: image.Point{20, 40}, red: image.Point{10, 5}, blue: image.Point{10, 35}},
+		{orientation: 7, size: image.Point{20, 40}, red: image.Point{10, 35}, blue: image.Point{10, 5}},
+		{orientation: 8, size: image.Point{20, 40}, red: image.Point{10, 35}, blue: image.Point{10, 5}},
+	}
+	for _, tt := range tests {
+		data := withExif(encodeJPEG(t, halves(40, 20)), tt.orientation)
+		processed, err := ProcessImage(fileHeader(t, "image/jpeg", data))
+		if err != nil {
+			t.Fatalf("orientation %d: %v", tt.orientation, err)
+		}
+		img, err := jpeg.Decode(bytes.NewReader(processed.Renditions[RenditionLarge.Name]))
+		if err != nil {
+			t.Fatalf("orientation %d: %v", tt.orientation, err)
+		}
+
+		if got := img.Bounds().Size(); got != tt.size {
+			t.Errorf("orientation %d: image is %v, want %v", tt.orientation, got, tt.size)
+			continue
+		}
+		if r, _, b, _ := img.At(tt.red.X, tt.red.Y).RGBA(); r < b {
+			t.Errorf("orientation %d: %v is not red", tt.orientation, tt.red)
+		}
+		if r, _, b, _ := img.At(tt.blue.X, tt.blue.Y).RGBA(); b < r {
+			t.Errorf("orientation %d: %v is not blue", tt.orientation, tt.blue)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the request body, keyed
+// with the shared secret, so receivers can verify the sender
+const SignatureHeader = "X-Webhook-Signature"
+
+// Event is the JSON body posted to a webhook endpoint
+type Event struct {
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// Client posts signed events to a single endpoint
+type Client struct {
+	url    string
+	secret string
+	http   *http.Client
+}
+
+// New returns a client for url, or nil if url is empty
+func New(url, secret string) *Client {
+	if url == "" {
+		return nil
+	}
+	return &Client{
+		url:    url,
+		secret: secret,
+		http:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Send posts an event of the given type, any non-2xx response is an error
+func (c *Client) Send(ctx context.Context, eventType string, data interface{}) error {
+	body, err := json.Marshal(Event{Type: eventType, CreatedAt: time.Now().UTC(), Data: data})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.secret != "" {
+		req.Header.Set(SignatureHeader, Sign(c.secret, body))
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s responded with status %d", c.url, resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the SignatureHeader value of body signed with secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the SignatureHeader value of body
+// signed with secret
+func Verify(secret string, body []byte, signature string) bool {
+	if secret == "" {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}